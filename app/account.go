@@ -0,0 +1,467 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/framework"
+	"github.com/michaelcosj/stms/models"
+)
+
+// ErrAccountNotEmpty is returned when restoring an archive into an account
+// that already has tasks, semesters, courses, exams or webhooks
+var ErrAccountNotEmpty = errors.New("account already has data, restore into a fresh account")
+
+// time blocks are fetched by the times they overlap, the export takes all
+// of them up to this
+var maxExportTime = time.Date(9999, 12, 31, 0, 0, 0, 0, time.UTC)
+
+// ExportAccount returns an archive of the user's profile, semesters,
+// courses, timetable, exams, availability, tasks, checklists, reminders and
+// recurring series, along with the trash, task history, time blocks, time
+// entries, webhooks, streak and badges.
+func (a *app) ExportAccount(userId int64) (models.AccountExport, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.AccountExport{}, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	semesters, err := a.repo.GetSemesters(userId)
+	if err != nil {
+		return models.AccountExport{}, fmt.Errorf("error getting semesters from database: %v", err)
+	}
+
+	courses, err := a.repo.GetCourses(userId)
+	if err != nil {
+		return models.AccountExport{}, fmt.Errorf("error getting courses from database: %v", err)
+	}
+
+	sessions, err := a.repo.GetTimetableSessions(userId)
+	if err != nil {
+		return models.AccountExport{}, fmt.Errorf("error getting timetable from database: %v", err)
+	}
+
+	exams, err := a.repo.GetExams(userId)
+	if err != nil {
+		return models.AccountExport{}, fmt.Errorf("error getting exams from database: %v", err)
+	}
+
+	availability, err := a.repo.GetAvailability(userId)
+	if err != nil {
+		return models.AccountExport{}, fmt.Errorf("error getting availability from database: %v", err)
+	}
+
+	series, err := a.repo.GetUserTaskSeries(userId)
+	if err != nil {
+		return models.AccountExport{}, fmt.Errorf("error getting task series from database: %v", err)
+	}
+
+	trash, err := a.repo.GetTrashedTasks(userId)
+	if err != nil {
+		return models.AccountExport{}, fmt.Errorf("error getting trashed tasks from database: %v", err)
+	}
+
+	blocks, err := a.repo.GetTimeBlocks(userId, time.Time{}, maxExportTime)
+	if err != nil {
+		return models.AccountExport{}, fmt.Errorf("error getting time blocks from database: %v", err)
+	}
+
+	webhooks, err := a.repo.GetWebhooks(userId)
+	if err != nil {
+		return models.AccountExport{}, fmt.Errorf("error getting webhooks from database: %v", err)
+	}
+
+	streak, err := a.repo.GetStreak(userId)
+	if err != nil {
+		return models.AccountExport{}, fmt.Errorf("error getting streak from database: %v", err)
+	}
+
+	badges, err := a.repo.GetBadges(userId)
+	if err != nil {
+		return models.AccountExport{}, fmt.Errorf("error getting badges from database: %v", err)
+	}
+
+	export := models.AccountExport{
+		Version:    models.AccountExportVersion,
+		ExportedAt: time.Now().UTC(),
+		Profile: models.AccountProfile{
+			Username:        user.Username,
+			Email:           user.Email,
+			Timezone:        user.Timezone,
+			QuietHoursStart: user.QuietHoursStart,
+			QuietHoursEnd:   user.QuietHoursEnd,
+
+			PomodoroWorkMinutes:  user.PomodoroWorkMinutes,
+			PomodoroBreakMinutes: user.PomodoroBreakMinutes,
+
+			AgendaEmails: user.AgendaEmails,
+			DigestEmails: user.DigestEmails,
+			AgendaTime:   user.AgendaTime,
+		},
+		Semesters:    []models.Semester{},
+		Courses:      []models.Course{},
+		Timetable:    []models.TimetableSession{},
+		Exams:        []models.Exam{},
+		Availability: []models.AvailabilityWindow{},
+		Series:       []models.TaskSeries{},
+		Tasks:        []models.ExportedTask{},
+		Trash:        []models.ExportedTask{},
+		Revisions:    []models.TaskRevision{},
+		TimeBlocks:   []models.TimeBlock{},
+		TimeEntries:  []models.ExportedTimeEntry{},
+		Webhooks:     []models.Webhook{},
+		Streak:       streak,
+		Badges:       []models.Badge{},
+	}
+
+	export.Semesters = append(export.Semesters, semesters...)
+	export.Courses = append(export.Courses, courses...)
+	export.Timetable = append(export.Timetable, sessions...)
+	export.Exams = append(export.Exams, exams...)
+	export.Availability = append(export.Availability, availability...)
+	export.Series = append(export.Series, series...)
+	export.TimeBlocks = append(export.TimeBlocks, blocks...)
+	export.Webhooks = append(export.Webhooks, webhooks...)
+	export.Badges = append(export.Badges, badges...)
+	for _, t := range user.Tasks {
+		t.UpdateProgress()
+		export.Tasks = append(export.Tasks, models.ExportedTask{Task: t, RecurrenceTime: t.RecurrenceTime})
+	}
+	for _, t := range trash {
+		t.UpdateProgress()
+		export.Trash = append(export.Trash, models.ExportedTask{Task: t, RecurrenceTime: t.RecurrenceTime})
+	}
+
+	for _, t := range append(user.Tasks, trash...) {
+		revs, err := a.repo.GetTaskRevisions(t.ID)
+		if err != nil {
+			return models.AccountExport{}, fmt.Errorf("error getting task history from database: %v", err)
+		}
+		// oldest first, so they're restored in order
+		for i := len(revs) - 1; i >= 0; i-- {
+			export.Revisions = append(export.Revisions, revs[i])
+		}
+
+		entries, err := a.repo.GetTaskTimeEntries(t.ID)
+		if err != nil {
+			return models.AccountExport{}, fmt.Errorf("error getting time entries from database: %v", err)
+		}
+		for i := len(entries) - 1; i >= 0; i-- {
+			export.TimeEntries = append(export.TimeEntries, models.ExportedTimeEntry{
+				TimeEntry: entries[i], BreakMinutes: entries[i].BreakMinutes,
+			})
+		}
+	}
+
+	return export, nil
+}
+
+// ImportAccount restores an archive made by ExportAccount into the user's
+// account, which must not have any tasks yet, trashed ones included. The
+// user keeps their own username, email and password, the settings are taken
+// from the archive.
+// Nothing is restored unless the whole archive is valid.
+func (a *app) ImportAccount(userId int64, export models.AccountExport) (models.User, error) {
+	if export.Version < 1 || export.Version > models.AccountExportVersion {
+		return models.User{}, fmt.Errorf("unsupported export version %d, expected 1 to %d", export.Version, models.AccountExportVersion)
+	}
+
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.User{}, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	trash, err := a.repo.GetTrashedTasks(userId)
+	if err != nil {
+		return models.User{}, fmt.Errorf("error getting trashed tasks from database: %v", err)
+	}
+
+	semesters, err := a.repo.GetSemesters(userId)
+	if err != nil {
+		return models.User{}, fmt.Errorf("error getting semesters from database: %v", err)
+	}
+
+	courses, err := a.repo.GetCourses(userId)
+	if err != nil {
+		return models.User{}, fmt.Errorf("error getting courses from database: %v", err)
+	}
+
+	exams, err := a.repo.GetExams(userId)
+	if err != nil {
+		return models.User{}, fmt.Errorf("error getting exams from database: %v", err)
+	}
+
+	webhooks, err := a.repo.GetWebhooks(userId)
+	if err != nil {
+		return models.User{}, fmt.Errorf("error getting webhooks from database: %v", err)
+	}
+
+	if len(user.Tasks) > 0 || len(trash) > 0 || len(semesters) > 0 ||
+		len(courses) > 0 || len(exams) > 0 || len(webhooks) > 0 {
+		return models.User{}, ErrAccountNotEmpty
+	}
+
+	user.Timezone = export.Profile.Timezone
+	user.QuietHoursStart = export.Profile.QuietHoursStart
+	user.QuietHoursEnd = export.Profile.QuietHoursEnd
+
+	// archives made before pomodoros keep the user's lengths
+	if export.Profile.PomodoroWorkMinutes != 0 {
+		user.PomodoroWorkMinutes = export.Profile.PomodoroWorkMinutes
+	}
+	if export.Profile.PomodoroBreakMinutes != 0 {
+		user.PomodoroBreakMinutes = export.Profile.PomodoroBreakMinutes
+	}
+
+	// and ones made before scheduled emails keep the user's agenda time
+	user.AgendaEmails = export.Profile.AgendaEmails
+	user.DigestEmails = export.Profile.DigestEmails
+	if export.Profile.AgendaTime != "" {
+		user.AgendaTime = export.Profile.AgendaTime
+	}
+	if err := validateSettings(user); err != nil {
+		return models.User{}, err
+	}
+
+	semesterIds := make(map[int64]bool)
+	for _, s := range export.Semesters {
+		if err := validateSemester(s); err != nil {
+			return models.User{}, fmt.Errorf("invalid semester %d: %v", s.ID, err)
+		}
+		if semesterIds[s.ID] {
+			return models.User{}, fmt.Errorf("duplicate semester %d", s.ID)
+		}
+		semesterIds[s.ID] = true
+	}
+
+	courseIds := make(map[int64]bool)
+	for _, c := range export.Courses {
+		if err := validateCourse(c); err != nil {
+			return models.User{}, fmt.Errorf("invalid course %d: %v", c.ID, err)
+		}
+		if c.SemesterID != 0 && !semesterIds[c.SemesterID] {
+			return models.User{}, fmt.Errorf("course %q refers to unknown semester %d", c.Name, c.SemesterID)
+		}
+		if courseIds[c.ID] {
+			return models.User{}, fmt.Errorf("duplicate course %d", c.ID)
+		}
+		courseIds[c.ID] = true
+	}
+
+	for i := range export.Timetable {
+		s := &export.Timetable[i]
+		if err := validateSession(s); err != nil {
+			return models.User{}, fmt.Errorf("invalid timetable session %d: %v", s.ID, err)
+		}
+		if !courseIds[s.CourseID] {
+			return models.User{}, fmt.Errorf("timetable session %d refers to unknown course %d", s.ID, s.CourseID)
+		}
+	}
+
+	examIds := make(map[int64]bool)
+	for i := range export.Exams {
+		e := &export.Exams[i]
+		if err := validateExam(e); err != nil {
+			return models.User{}, fmt.Errorf("invalid exam %d: %v", e.ID, err)
+		}
+		if !courseIds[e.CourseID] {
+			return models.User{}, fmt.Errorf("exam %q refers to unknown course %d", e.Title, e.CourseID)
+		}
+		if examIds[e.ID] {
+			return models.User{}, fmt.Errorf("duplicate exam %d", e.ID)
+		}
+		examIds[e.ID] = true
+	}
+
+	if err := validateAvailability(export.Availability); err != nil {
+		return models.User{}, fmt.Errorf("invalid availability: %v", err)
+	}
+
+	seriesIds := make(map[int64]bool)
+	for _, s := range export.Series {
+		// series that were ended are left with an empty rule
+		if s.RRule != "" {
+			if _, err := framework.ParseRRule(s.RRule); err != nil {
+				return models.User{}, fmt.Errorf("invalid rrule of series %d: %v", s.ID, err)
+			}
+		}
+		if seriesIds[s.ID] {
+			return models.User{}, fmt.Errorf("duplicate series %d", s.ID)
+		}
+		seriesIds[s.ID] = true
+	}
+
+	taskIds := make(map[int64]bool)
+	occurrences := make(map[[2]int64]bool)
+	importTask := func(e models.ExportedTask) (models.ExportedTask, error) {
+		t := e.Task
+		t.RecurrenceTime = e.RecurrenceTime
+
+		if err := validateTask(&t); err != nil {
+			return e, fmt.Errorf("invalid task %q: %v", t.Name, err)
+		}
+		if err := validateAssessment(&t); err != nil {
+			return e, fmt.Errorf("invalid assessment of task %q: %v", t.Name, err)
+		}
+		if t.SeriesID != 0 && !seriesIds[t.SeriesID] {
+			return e, fmt.Errorf("task %q refers to unknown series %d", t.Name, t.SeriesID)
+		}
+		if t.CourseID != 0 && !courseIds[t.CourseID] {
+			return e, fmt.Errorf("task %q refers to unknown course %d", t.Name, t.CourseID)
+		}
+		if t.ExamID != 0 && !examIds[t.ExamID] {
+			return e, fmt.Errorf("task %q refers to unknown exam %d", t.Name, t.ExamID)
+		}
+		for _, offset := range t.Reminders {
+			if offset < 1 || offset > maxReminderOffset {
+				return e, fmt.Errorf("invalid reminder offset %d of task %q", offset, t.Name)
+			}
+		}
+		for _, item := range t.Items {
+			if len(item.Name) < 1 {
+				return e, fmt.Errorf("invalid checklist item name of task %q", t.Name)
+			}
+		}
+		if taskIds[t.ID] {
+			return e, fmt.Errorf("duplicate task %d", t.ID)
+		}
+		taskIds[t.ID] = true
+
+		if t.SeriesID != 0 {
+			occurrence := [2]int64{t.SeriesID, int64(t.Occurrence)}
+			if occurrences[occurrence] {
+				return e, fmt.Errorf("duplicate occurrence %d of series %d", t.Occurrence, t.SeriesID)
+			}
+			occurrences[occurrence] = true
+		}
+
+		if t.TimeCreated.IsZero() {
+			t.TimeCreated = time.Now()
+		}
+		if !t.IsCompleted || t.TimeCompleted.IsZero() {
+			t.TimeCompleted = time.Unix(0, 0).UTC()
+		}
+
+		return models.ExportedTask{Task: t, RecurrenceTime: t.RecurrenceTime}, nil
+	}
+
+	for i := range export.Tasks {
+		export.Tasks[i].TimeDeleted = nil
+		if export.Tasks[i], err = importTask(export.Tasks[i]); err != nil {
+			return models.User{}, err
+		}
+	}
+	for i := range export.Trash {
+		if export.Trash[i].TimeDeleted == nil {
+			return models.User{}, fmt.Errorf("trashed task %q has no time_deleted", export.Trash[i].Name)
+		}
+		if export.Trash[i], err = importTask(export.Trash[i]); err != nil {
+			return models.User{}, err
+		}
+	}
+
+	for _, rev := range export.Revisions {
+		if !taskIds[rev.TaskID] {
+			return models.User{}, fmt.Errorf("revision %d refers to unknown task %d", rev.ID, rev.TaskID)
+		}
+		switch rev.Action {
+		case "create", "update", "complete", "delete", "restore", "revert":
+		default:
+			return models.User{}, fmt.Errorf("invalid action %q of revision %d", rev.Action, rev.ID)
+		}
+	}
+
+	for _, b := range export.TimeBlocks {
+		if !taskIds[b.TaskID] {
+			return models.User{}, fmt.Errorf("time block %d refers to unknown task %d", b.ID, b.TaskID)
+		}
+		if !b.End.After(b.Start) {
+			return models.User{}, fmt.Errorf("time block %d must end after it starts", b.ID)
+		}
+	}
+
+	running := false
+	for _, e := range export.TimeEntries {
+		if !taskIds[e.TaskID] {
+			return models.User{}, fmt.Errorf("time entry %d refers to unknown task %d", e.ID, e.TaskID)
+		}
+		switch e.Kind {
+		case "timer", "pomodoro", "manual":
+		default:
+			return models.User{}, fmt.Errorf("invalid kind %q of time entry %d", e.Kind, e.ID)
+		}
+		if e.End == nil {
+			if running {
+				return models.User{}, fmt.Errorf("time entry %d is running along with another one", e.ID)
+			}
+			running = true
+		} else if !e.End.After(e.Start) {
+			return models.User{}, fmt.Errorf("time entry %d must end after it starts", e.ID)
+		}
+	}
+
+	if len(export.Webhooks) > maxWebhooksPerUser {
+		return models.User{}, fmt.Errorf("a user can't have more than %d webhooks", maxWebhooksPerUser)
+	}
+	for i := range export.Webhooks {
+		w := &export.Webhooks[i]
+		if w.EventTypes == nil {
+			w.EventTypes = []string{}
+		}
+		if err := validateWebhook(*w); err != nil {
+			return models.User{}, fmt.Errorf("invalid webhook %d: %v", w.ID, err)
+		}
+		if len(w.Secret) < 16 {
+			return models.User{}, fmt.Errorf("invalid webhook %d: secret must be at least 16 characters", w.ID)
+		}
+	}
+
+	if err := validateStreak(export.Streak); err != nil {
+		return models.User{}, fmt.Errorf("invalid streak: %v", err)
+	}
+
+	badges := make(map[string]bool)
+	for _, b := range export.Badges {
+		if _, ok := badgeDetails[b.Name]; !ok {
+			return models.User{}, fmt.Errorf("unknown badge %q", b.Name)
+		}
+		if badges[b.Name] {
+			return models.User{}, fmt.Errorf("duplicate badge %q", b.Name)
+		}
+		badges[b.Name] = true
+	}
+	if badges[BadgeStreak7] && export.Streak.Longest < 7 {
+		return models.User{}, fmt.Errorf("badge %q needs a longest streak of at least 7 days", BadgeStreak7)
+	}
+
+	if err := a.repo.ImportAccount(userId, user, export); err != nil {
+		return models.User{}, fmt.Errorf("error importing account to database: %v", err)
+	}
+
+	return a.GetProfile(userId)
+}
+
+func validateStreak(s models.Streak) error {
+	if s.Current < 0 || s.Longest < 0 || s.Freezes < 0 {
+		return fmt.Errorf("invalid streak lengths or freezes")
+	}
+	if s.Current > s.Longest {
+		return fmt.Errorf("current streak can't be longer than the longest")
+	}
+	if s.Freezes > maxStreakFreezes {
+		return fmt.Errorf("a user can't hold more than %d freezes", maxStreakFreezes)
+	}
+
+	days := s.FrozenDays
+	if s.LastDay != "" {
+		days = append([]string{s.LastDay}, days...)
+	}
+	for _, day := range days {
+		if _, err := time.Parse(dateLayout, day); err != nil {
+			return fmt.Errorf("invalid day %q, expected YYYY-MM-DD", day)
+		}
+	}
+
+	return nil
+}
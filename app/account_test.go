@@ -0,0 +1,68 @@
+package app
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func TestValidateStreak(t *testing.T) {
+	tests := []struct {
+		name    string
+		streak  models.Streak
+		wantErr bool
+	}{
+		{"no streak", models.Streak{}, false},
+		{"streak", models.Streak{Current: 3, Longest: 8, Freezes: 1, LastDay: "2026-10-18", FrozenDays: []string{"2026-10-12"}}, false},
+		{"most freezes", models.Streak{Current: 14, Longest: 14, Freezes: maxStreakFreezes}, false},
+		{"too many freezes", models.Streak{Current: 21, Longest: 21, Freezes: maxStreakFreezes + 1}, true},
+		{"current above longest", models.Streak{Current: 5, Longest: 4}, true},
+		{"negative length", models.Streak{Current: -1}, true},
+		{"invalid last day", models.Streak{Current: 1, Longest: 1, LastDay: "18/10/2026"}, true},
+		{"invalid frozen day", models.Streak{Current: 1, Longest: 1, FrozenDays: []string{"yesterday"}}, true},
+	}
+
+	for _, tt := range tests {
+		if err := validateStreak(tt.streak); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestImportAccountIntoAccountWithData(t *testing.T) {
+	a, userId := newTestApp(t)
+	addTestWebhook(t, a, userId, "https://example.com/hook")
+
+	export := models.AccountExport{Version: models.AccountExportVersion}
+	if _, err := a.ImportAccount(userId, export); !errors.Is(err, ErrAccountNotEmpty) {
+		t.Errorf("got error %v, want ErrAccountNotEmpty", err)
+	}
+}
+
+func TestImportAccountBadges(t *testing.T) {
+	tests := []struct {
+		name    string
+		streak  models.Streak
+		badges  []models.Badge
+		wantErr bool
+	}{
+		{"earned", models.Streak{Current: 2, Longest: 9}, []models.Badge{{Name: BadgeFirstTask}, {Name: BadgeStreak7}}, false},
+		{"unknown", models.Streak{}, []models.Badge{{Name: "streak_1000"}}, true},
+		{"duplicate", models.Streak{}, []models.Badge{{Name: BadgeFirstTask}, {Name: BadgeFirstTask}}, true},
+		{"streak never reached", models.Streak{Current: 3, Longest: 3}, []models.Badge{{Name: BadgeStreak7}}, true},
+	}
+
+	for _, tt := range tests {
+		a, userId := newTestApp(t)
+		export := models.AccountExport{
+			Version: models.AccountExportVersion,
+			Profile: models.AccountProfile{Timezone: "UTC"},
+			Streak:  tt.streak,
+			Badges:  tt.badges,
+		}
+		if _, err := a.ImportAccount(userId, export); (err != nil) != tt.wantErr {
+			t.Errorf("%s: got error %v, want error %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
@@ -2,7 +2,9 @@ package app
 
 import (
 	"context"
+	"io"
 
+	"github.com/michaelcosj/stms/framework/events"
 	"github.com/michaelcosj/stms/models"
 	"github.com/michaelcosj/stms/repository"
 	"github.com/redis/go-redis/v9"
@@ -10,9 +12,31 @@ import (
 
 var ctx = context.Background()
 
+var (
+	ErrTaskNotFound = repository.ErrTaskNotFound
+	ErrItemNotFound = repository.ErrItemNotFound
+	ErrUserNotFound = repository.ErrUserNotFound
+
+	ErrEmailNotFound    = repository.ErrEmailNotFound
+	ErrRevisionNotFound = repository.ErrRevisionNotFound
+	ErrVersionMismatch  = repository.ErrVersionMismatch
+	ErrWebhookNotFound  = repository.ErrWebhookNotFound
+	ErrSemesterNotFound = repository.ErrSemesterNotFound
+	ErrCourseNotFound   = repository.ErrCourseNotFound
+	ErrSessionNotFound  = repository.ErrSessionNotFound
+	ErrExamNotFound     = repository.ErrExamNotFound
+	ErrBlockNotFound    = repository.ErrBlockNotFound
+	ErrEntryNotFound    = repository.ErrEntryNotFound
+	ErrTimerRunning     = repository.ErrTimerRunning
+)
+
 type app struct {
-	repo  repository.Repo
-	cache *redis.Client
+	repo   repository.Repo
+	cache  *redis.Client
+	events events.Bus
+
+	// events held back until a batch is committed, nil outside of one
+	held *[]heldEvent
 }
 
 type App interface {
@@ -20,13 +44,105 @@ type App interface {
 	SendVerificationCode(email string) error
 	VerifyUser(code string) (models.User, error)
 	GetUser(email, password string) (models.User, string, error)
+	GetProfile(userId int64) (models.User, error)
+	UpdateSettings(userId int64, s models.UserSettings) (models.User, error)
+	ExportAccount(userId int64) (models.AccountExport, error)
+	ImportAccount(userId int64, export models.AccountExport) (models.User, error)
 
 	AddTask(userId int64, t *models.Task) error
 	GetTaskByFilters(userId int64, filter map[string][]string) ([]models.Task, error)
-	UpdateTask(taskId int64, t *models.Task) error
-	DeleteTask(taskId int64) error
+	GetTask(userId, taskId int64) (models.Task, error)
+	UpdateTask(userId, taskId, version int64, t *models.Task) error
+	DeleteTask(userId, taskId, version int64) error
+	BatchTasks(userId int64, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, bool, error)
+	GetTrash(userId int64) ([]models.Task, error)
+	RestoreTask(userId, taskId int64) (models.Task, error)
+	GetTaskHistory(userId, taskId int64) ([]models.TaskRevision, error)
+	RevertTask(userId, taskId, revisionId int64) (models.Task, error)
+
+	AddSemester(userId int64, s *models.Semester) error
+	GetSemesters(userId int64) ([]models.Semester, error)
+	GetSemester(userId, semesterId int64) (models.Semester, error)
+	UpdateSemester(userId, semesterId int64, update models.SemesterUpdate) (models.Semester, error)
+	ArchiveSemester(userId, semesterId int64, archived bool) (models.Semester, error)
+	DeleteSemester(userId, semesterId int64) error
+	AddCourse(userId int64, c *models.Course) error
+	GetCourses(userId, semesterId int64) ([]models.Course, error)
+	GetCourse(userId, courseId int64) (models.Course, error)
+	UpdateCourse(userId, courseId int64, update models.CourseUpdate) (models.Course, error)
+	DeleteCourse(userId, courseId int64) error
+	GetCourseGrades(userId, courseId int64, target *float64) (models.CourseGrades, error)
+	AddTimetableSession(userId int64, s *models.TimetableSession) error
+	GetTimetable(userId, courseId int64) ([]models.TimetableSession, error)
+	UpdateTimetableSession(userId, sessionId int64, update models.TimetableSessionUpdate) (models.TimetableSession, error)
+	DeleteTimetableSession(userId, sessionId int64) error
+	GetSchedule(userId int64, from, to string) ([]models.AgendaDay, error)
+	AddExam(userId int64, e *models.Exam) error
+	GetExams(userId, courseId int64) ([]models.Exam, error)
+	GetExam(userId, examId int64) (models.Exam, error)
+	UpdateExam(userId, examId int64, update models.ExamUpdate) (models.Exam, error)
+	DeleteExam(userId, examId int64) error
+	PlanExam(userId, examId int64, studyHours float64, sessionMinutes int) (models.Exam, error)
+
+	GetAvailability(userId int64) ([]models.AvailabilityWindow, error)
+	SetAvailability(userId int64, windows []models.AvailabilityWindow) ([]models.AvailabilityWindow, error)
+	PlanTasks(userId int64, from string, days int, taskIds []int64, accept bool) (models.Plan, error)
+	GetTimeBlocks(userId int64, from, to string) ([]models.TimeBlock, error)
+	DeleteTimeBlock(userId, blockId int64) error
+
+	StartTimer(userId, taskId int64, kind, note string) (models.TimeEntry, error)
+	GetTimer(userId int64) (*models.TimeEntry, error)
+	StopTimer(userId int64) (models.TimeEntry, error)
+	AddTimeEntry(userId, taskId int64, e *models.TimeEntry) error
+	GetTaskTimeEntries(userId, taskId int64) ([]models.TimeEntry, error)
+	DeleteTimeEntry(userId, taskId, entryId int64) error
+	GetTimeTotals(userId int64, from, to string) (models.TimeTotals, error)
+	GetStats(userId int64, from, to string) (models.TaskStats, error)
+	GetStreak(userId int64) (models.Streak, error)
+	FreezeStreak(userId int64, date string) (models.Streak, error)
+	GetBadges(userId int64) ([]models.Badge, error)
+
+	UpdateTaskSeries(userId, taskId, version int64, t *models.Task) error
+	SkipTaskOccurrence(userId, taskId, version int64) (*models.Task, error)
+
+	ImportTasks(userId int64, r io.Reader, opts models.ImportOptions) (models.ImportResult, error)
+	ExportCalendar(userId int64, component string) ([]byte, error)
+	CalendarFeed(token, component string) ([]byte, error)
+	CreateCalendarFeedToken(userId int64) (string, error)
+	RevokeCalendarFeedToken(userId int64) error
+	CheckUnsubscribeLink(userId int64, list, token string) error
+	Unsubscribe(userId int64, list, token string) error
+
+	AddChecklistItem(userId, taskId int64, item *models.ChecklistItem) error
+	UpdateChecklistItem(userId, taskId, itemId int64, name *string, isCompleted *bool) (models.ChecklistItem, error)
+	ReorderChecklist(userId, taskId int64, itemIds []int64) ([]models.ChecklistItem, error)
+	DeleteChecklistItem(userId, taskId, itemId int64) error
+
+	StartIdempotentRequest(userId int64, key, requestHash string) (*models.IdempotentResponse, error)
+	SaveIdempotentResponse(userId int64, key string, resp models.IdempotentResponse) error
+	ReleaseIdempotencyKey(userId int64, key string) error
+
+	SubscribeEvents(userId int64, lastEventId string) (*events.Subscription, error)
+
+	AddWebhook(userId int64, w *models.Webhook) error
+	GetWebhooks(userId int64) ([]models.Webhook, error)
+	UpdateWebhook(userId, webhookId int64, url *string, eventTypes *[]string, isActive *bool) (models.Webhook, error)
+	DeleteWebhook(userId, webhookId int64) error
+	GetWebhookDeliveries(userId, webhookId int64, status string) ([]models.WebhookDelivery, error)
+	PingWebhook(userId, webhookId int64) (models.WebhookDelivery, error)
+
+	// background jobs
+	SendDueReminders() error
+	SendScheduledEmails() error
+	DeliverOutbox() error
+	DeliverWebhooks() error
+	PurgeTrash() error
+
+	// admin
+	GetOutboxEmails(status string) ([]models.OutboxEmail, error)
+	ReplayEmail(emailId int64) error
 }
 
-func InitAppService(repo repository.Repo, cache *redis.Client) App {
-	return &app{repo, cache}
+func InitAppService(repo repository.Repo, cache *redis.Client, bus events.Bus) App {
+	return &app{repo: repo, cache: cache, events: bus}
 }
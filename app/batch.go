@@ -0,0 +1,167 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/michaelcosj/stms/models"
+	"github.com/michaelcosj/stms/repository"
+)
+
+// largest number of operations in a batch
+const maxBatchSize = 100
+
+var errBatchFailed = errors.New("batch failed")
+
+// BatchTasks runs task operations in a single transaction. An atomic batch
+// stops at the first operation that fails and rolls back the others,
+// otherwise only the failed operations are rolled back. It returns the
+// result of each operation and whether the batch was committed.
+func (a *app) BatchTasks(userId int64, ops []models.BatchOperation, atomic bool) ([]models.BatchResult, bool, error) {
+	if len(ops) == 0 || len(ops) > maxBatchSize {
+		return nil, false, fmt.Errorf("a batch needs 1 to %d operations", maxBatchSize)
+	}
+
+	results := make([]models.BatchResult, len(ops))
+	for i, op := range ops {
+		results[i] = models.BatchResult{Index: i, Op: op.Op, TaskID: op.TaskID, Status: "skipped"}
+	}
+
+	// events are only sent once the batch is committed
+	var held []heldEvent
+	err := a.repo.Transaction(func(tx repository.Repo) error {
+		failed := false
+		for i, op := range ops {
+			// each operation is a savepoint, so a failed one leaves
+			// nothing behind
+			var task *models.Task
+			var opHeld []heldEvent
+			err := tx.Transaction(func(tx repository.Repo) error {
+				opApp := a.withRepo(tx)
+				opApp.held = &opHeld
+
+				var err error
+				task, err = opApp.runBatchOperation(userId, op)
+				return err
+			})
+
+			if err != nil {
+				results[i].Status = "failed"
+				results[i].Error = err.Error()
+				failed = true
+				if atomic {
+					break
+				}
+				continue
+			}
+
+			held = append(held, opHeld...)
+			results[i].Status = "ok"
+			results[i].Task = task
+			if task != nil {
+				results[i].TaskID = task.ID
+			}
+		}
+
+		if atomic && failed {
+			return errBatchFailed
+		}
+		return nil
+	})
+
+	if errors.Is(err, errBatchFailed) {
+		for i := range results {
+			if results[i].Status == "ok" {
+				results[i].Status = "rolled_back"
+				results[i].TaskID = ops[i].TaskID
+				results[i].Task = nil
+			}
+		}
+		return results, false, nil
+	}
+	if err != nil {
+		return nil, false, fmt.Errorf("error running batch: %v", err)
+	}
+
+	for _, e := range held {
+		a.publish(e.userId, e.eventType, e.data)
+	}
+	return results, true, nil
+}
+
+// withRepo returns a copy of the app that uses the given repo, for running
+// app methods in a transaction
+func (a *app) withRepo(repo repository.Repo) *app {
+	return &app{repo: repo, cache: a.cache, events: a.events, held: a.held}
+}
+
+// inTransaction runs fn with a copy of the app that uses a transaction,
+// which is committed if fn returns nil. The events fn publishes are only
+// sent once it's committed.
+func (a *app) inTransaction(fn func(a *app) error) error {
+	var held []heldEvent
+	err := a.repo.Transaction(func(tx repository.Repo) error {
+		txApp := a.withRepo(tx)
+		txApp.held = &held
+		return fn(txApp)
+	})
+	if err != nil {
+		return err
+	}
+
+	for _, e := range held {
+		a.publish(e.userId, e.eventType, e.data)
+	}
+	return nil
+}
+
+// runBatchOperation runs an operation and returns the task it created or
+// changed, nil for a delete
+func (a *app) runBatchOperation(userId int64, op models.BatchOperation) (*models.Task, error) {
+	// like If-Match on single changes, an operation on a task must say
+	// which version of it the change was made against
+	switch op.Op {
+	case "update", "complete", "delete":
+		if op.Version < 1 {
+			return nil, fmt.Errorf("%s needs the version of the task", op.Op)
+		}
+	}
+
+	switch op.Op {
+	case "create":
+		if op.Task == nil {
+			return nil, fmt.Errorf("create needs a task")
+		}
+		t := *op.Task
+		if err := a.AddTask(userId, &t); err != nil {
+			return nil, err
+		}
+		return &t, nil
+
+	case "update":
+		if op.Task == nil {
+			return nil, fmt.Errorf("update needs a task")
+		}
+		t := *op.Task
+		if err := a.UpdateTask(userId, op.TaskID, op.Version, &t); err != nil {
+			return nil, err
+		}
+		return &t, nil
+
+	case "complete":
+		t, err := a.getTaskVersion(userId, op.TaskID, op.Version)
+		if err != nil {
+			return nil, err
+		}
+		t.IsCompleted = true
+		if err := a.UpdateTask(userId, op.TaskID, t.Version, &t); err != nil {
+			return nil, err
+		}
+		return &t, nil
+
+	case "delete":
+		return nil, a.DeleteTask(userId, op.TaskID, op.Version)
+	}
+
+	return nil, fmt.Errorf("invalid operation %q, expected create, update, complete or delete", op.Op)
+}
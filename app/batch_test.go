@@ -0,0 +1,20 @@
+package app
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func TestBatchOperationNeedsVersion(t *testing.T) {
+	a := &app{}
+	task := &models.Task{Name: "Essay", Tag: "study", Description: "Two pages"}
+
+	for _, op := range []string{"update", "complete", "delete"} {
+		_, err := a.runBatchOperation(1, models.BatchOperation{Op: op, TaskID: 1, Task: task})
+		if err == nil || !strings.Contains(err.Error(), "needs the version") {
+			t.Errorf("%s without a version: got error %v", op, err)
+		}
+	}
+}
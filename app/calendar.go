@@ -0,0 +1,158 @@
+package app
+
+import (
+	"bytes"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/framework"
+	"github.com/michaelcosj/stms/models"
+)
+
+// ical priorities go from 1 (highest) to 9 (lowest), 0 is undefined
+var icalPriorities = map[models.Priority]int{
+	models.PriorityNone:   0,
+	models.PriorityLow:    7,
+	models.PriorityMedium: 5,
+	models.PriorityHigh:   3,
+	models.PriorityUrgent: 1,
+}
+
+// hasDueTime reports whether the task has a real due time, tasks created
+// without one have the zero time or the unix epoch
+func hasDueTime(t models.Task) bool {
+	return t.TimeDue.After(time.Unix(0, 0))
+}
+
+// taskUID is the uid of an imported task or one made from its id
+func taskUID(t models.Task) string {
+	if t.UID != "" {
+		return t.UID
+	}
+	return fmt.Sprintf("task-%d@stms", t.ID)
+}
+
+// taskICalComponent renders a task as a VTODO or a VEVENT. Uncompleted
+// occurrences of recurring tasks carry the rule, with COUNT reduced by the
+// occurrences already done, so calendars show the ones to come.
+func taskICalComponent(t models.Task, kind string) framework.ICalComponent {
+	c := framework.ICalComponent{
+		Kind:        kind,
+		UID:         taskUID(t),
+		Summary:     t.Name,
+		Description: t.Description,
+		Priority:    icalPriorities[t.Priority],
+		Due:         t.TimeDue,
+		Stamp:       t.TimeCreated,
+	}
+
+	if t.Tag != "" {
+		c.Categories = []string{t.Tag}
+	}
+
+	switch {
+	case t.IsCompleted && kind == framework.ICalTodo:
+		c.Status = "COMPLETED"
+		c.Completed = t.TimeCompleted
+	case t.IsCompleted:
+		c.Status = "CONFIRMED"
+	case kind == framework.ICalTodo:
+		c.Status = "NEEDS-ACTION"
+	}
+
+	if t.RRule != "" && !t.IsCompleted {
+		if rule, err := framework.ParseRRule(t.RRule); err == nil {
+			if rule.Count > 0 {
+				rule.Count -= t.Occurrence - 1
+			}
+			c.RRule = rule.String()
+		}
+	}
+
+	return c
+}
+
+// ExportCalendar renders the user's tasks that have a due time as an
+// iCalendar file, as VEVENTs ("event") or VTODOs ("todo"). Events also
+// include the blocks planned to work on tasks.
+func (a *app) ExportCalendar(userId int64, component string) ([]byte, error) {
+	kind := framework.ICalEvent
+	switch component {
+	case "", "event":
+	case "todo":
+		kind = framework.ICalTodo
+	default:
+		return nil, fmt.Errorf("invalid component %s, expected event or todo", component)
+	}
+
+	tasks, err := a.repo.GetTasks(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting tasks from database: %v", err)
+	}
+
+	var components []framework.ICalComponent
+	for _, t := range tasks {
+		if hasDueTime(t) {
+			components = append(components, taskICalComponent(t, kind))
+		}
+	}
+
+	if kind == framework.ICalEvent {
+		blocks, err := a.repo.GetTimeBlocks(userId, time.Unix(0, 0), time.Now().AddDate(1, 0, 0))
+		if err != nil {
+			return nil, fmt.Errorf("error getting time blocks from database: %v", err)
+		}
+
+		for _, b := range blocks {
+			components = append(components, framework.ICalComponent{
+				Kind:    framework.ICalEvent,
+				UID:     fmt.Sprintf("block-%d@stms", b.ID),
+				Summary: b.TaskName,
+				Start:   b.Start,
+				End:     b.End,
+				Status:  "CONFIRMED",
+				Stamp:   b.Start,
+			})
+		}
+	}
+
+	var buf bytes.Buffer
+	if err := framework.WriteICal(&buf, "STMS tasks", components); err != nil {
+		return nil, fmt.Errorf("error writing calendar: %v", err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+// CalendarFeed renders the calendar of the user the feed token belongs to.
+func (a *app) CalendarFeed(token, component string) ([]byte, error) {
+	userId, err := a.repo.GetUserIDByCalendarToken(framework.HashToken(token))
+	if err != nil {
+		return nil, fmt.Errorf("error getting calendar feed: %w", err)
+	}
+
+	return a.ExportCalendar(userId, component)
+}
+
+// CreateCalendarFeedToken creates a new secret token for the user's
+// calendar feed, replacing any previous one.
+func (a *app) CreateCalendarFeedToken(userId int64) (string, error) {
+	token, err := framework.CreateToken(24)
+	if err != nil {
+		return "", err
+	}
+
+	if err := a.repo.SetCalendarToken(userId, framework.HashToken(token)); err != nil {
+		return "", fmt.Errorf("error saving calendar token: %v", err)
+	}
+
+	return token, nil
+}
+
+func (a *app) RevokeCalendarFeedToken(userId int64) error {
+	if err := a.repo.SetCalendarToken(userId, ""); err != nil {
+		return fmt.Errorf("error revoking calendar token: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,152 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func validateChecklistItem(item *models.ChecklistItem) error {
+	item.Name = strings.TrimSpace(item.Name)
+	if len(item.Name) < 1 {
+		return fmt.Errorf("invalid checklist item name")
+	}
+	return nil
+}
+
+func (a *app) addChecklistItem(taskId int64, item *models.ChecklistItem) error {
+	if err := validateChecklistItem(item); err != nil {
+		return err
+	}
+
+	itemId, err := a.repo.AddChecklistItem(taskId, *item)
+	if err != nil {
+		return fmt.Errorf("error adding checklist item to database: %v", err)
+	}
+
+	added, err := a.repo.GetChecklistItem(taskId, itemId)
+	if err != nil {
+		return fmt.Errorf("error getting checklist item from database: %v", err)
+	}
+
+	*item = added
+	return nil
+}
+
+func (a *app) AddChecklistItem(userId, taskId int64, item *models.ChecklistItem) error {
+	if _, err := a.repo.GetTask(userId, taskId); err != nil {
+		return fmt.Errorf("error getting task from database: %w", err)
+	}
+
+	return a.changeChecklist(userId, taskId, func(a *app) error {
+		return a.addChecklistItem(taskId, item)
+	})
+}
+
+// changeChecklist runs a change to the task's checklist, bumps the task's
+// version and sends the task to the user's live streams, all or nothing
+func (a *app) changeChecklist(userId, taskId int64, change func(a *app) error) error {
+	return a.inTransaction(func(a *app) error {
+		if err := change(a); err != nil {
+			return err
+		}
+
+		if err := a.repo.BumpTaskVersion(taskId); err != nil {
+			return err
+		}
+
+		a.publishTaskChange(userId, taskId)
+		return nil
+	})
+}
+
+// UpdateChecklistItem renames or ticks an item, nil fields are left as is.
+func (a *app) UpdateChecklistItem(userId, taskId, itemId int64, name *string, isCompleted *bool) (models.ChecklistItem, error) {
+	if _, err := a.repo.GetTask(userId, taskId); err != nil {
+		return models.ChecklistItem{}, fmt.Errorf("error getting task from database: %w", err)
+	}
+
+	item, err := a.repo.GetChecklistItem(taskId, itemId)
+	if err != nil {
+		return models.ChecklistItem{}, fmt.Errorf("error getting checklist item from database: %w", err)
+	}
+
+	if name != nil {
+		item.Name = strings.TrimSpace(*name)
+		if len(item.Name) < 1 {
+			return models.ChecklistItem{}, fmt.Errorf("invalid checklist item name")
+		}
+	}
+
+	if isCompleted != nil {
+		item.IsCompleted = *isCompleted
+	}
+
+	err = a.changeChecklist(userId, taskId, func(a *app) error {
+		if err := a.repo.UpdateChecklistItem(taskId, itemId, item); err != nil {
+			return fmt.Errorf("error updating checklist item in database: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.ChecklistItem{}, err
+	}
+
+	return item, nil
+}
+
+// ReorderChecklist orders the task's items as given. itemIds must contain
+// every item of the task exactly once.
+func (a *app) ReorderChecklist(userId, taskId int64, itemIds []int64) ([]models.ChecklistItem, error) {
+	task, err := a.repo.GetTask(userId, taskId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting task from database: %w", err)
+	}
+
+	if len(itemIds) != len(task.Items) {
+		return nil, fmt.Errorf("expected %d checklist items, got %d", len(task.Items), len(itemIds))
+	}
+
+	byId := make(map[int64]models.ChecklistItem, len(task.Items))
+	for _, item := range task.Items {
+		byId[item.ID] = item
+	}
+
+	items := make([]models.ChecklistItem, 0, len(itemIds))
+	for pos, id := range itemIds {
+		item, ok := byId[id]
+		if !ok {
+			return nil, fmt.Errorf("checklist item %d not in task or listed twice", id)
+		}
+		delete(byId, id)
+
+		item.Position = pos
+		items = append(items, item)
+	}
+
+	err = a.changeChecklist(userId, taskId, func(a *app) error {
+		if err := a.repo.ReorderChecklistItems(taskId, itemIds); err != nil {
+			return fmt.Errorf("error reordering checklist in database: %v", err)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return items, nil
+}
+
+func (a *app) DeleteChecklistItem(userId, taskId, itemId int64) error {
+	if _, err := a.repo.GetTask(userId, taskId); err != nil {
+		return fmt.Errorf("error getting task from database: %w", err)
+	}
+
+	return a.changeChecklist(userId, taskId, func(a *app) error {
+		if err := a.repo.DeleteChecklistItem(taskId, itemId); err != nil {
+			return fmt.Errorf("error removing checklist item from database: %w", err)
+		}
+		return nil
+	})
+}
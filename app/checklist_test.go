@@ -0,0 +1,66 @@
+package app
+
+import (
+	"testing"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func TestChecklistChangesBumpTaskVersion(t *testing.T) {
+	a, userId := newTestApp(t)
+
+	task := models.Task{Name: "Lab report", Tag: "study", Description: "Chemistry", TimeDue: time.Now().Add(48 * time.Hour)}
+	if err := a.AddTask(userId, &task); err != nil {
+		t.Fatal(err)
+	}
+
+	version := task.Version
+	check := func(change string) {
+		t.Helper()
+		got, err := a.repo.GetTask(userId, task.ID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != version+1 {
+			t.Errorf("%s: got version %d, want %d", change, got.Version, version+1)
+		}
+		version = got.Version
+	}
+
+	first := models.ChecklistItem{Name: "Method"}
+	if err := a.AddChecklistItem(userId, task.ID, &first); err != nil {
+		t.Fatal(err)
+	}
+	check("add")
+
+	second := models.ChecklistItem{Name: "Results"}
+	if err := a.AddChecklistItem(userId, task.ID, &second); err != nil {
+		t.Fatal(err)
+	}
+	check("add")
+
+	done := true
+	if _, err := a.UpdateChecklistItem(userId, task.ID, first.ID, nil, &done); err != nil {
+		t.Fatal(err)
+	}
+	check("update")
+
+	if _, err := a.ReorderChecklist(userId, task.ID, []int64{second.ID, first.ID}); err != nil {
+		t.Fatal(err)
+	}
+	check("reorder")
+
+	if err := a.DeleteChecklistItem(userId, task.ID, first.ID); err != nil {
+		t.Fatal(err)
+	}
+	check("delete")
+
+	// a failed change leaves the version as it was
+	if err := a.DeleteChecklistItem(userId, task.ID, first.ID); err == nil {
+		t.Fatal("deleted a checklist item twice")
+	}
+	if got, _ := a.repo.GetTask(userId, task.ID); got.Version != version {
+		t.Errorf("failed delete: got version %d, want %d", got.Version, version)
+	}
+}
@@ -0,0 +1,328 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// layout of semester and course dates
+const dateLayout = "2006-01-02"
+
+// validateDates checks a "2006-01-02" date range, both dates are needed
+// unless optional is set and both are empty
+func validateDates(start, end string, optional bool) error {
+	if optional && start == "" && end == "" {
+		return nil
+	}
+
+	startDate, err := time.Parse(dateLayout, start)
+	if err != nil {
+		return fmt.Errorf("invalid start date %q, expected YYYY-MM-DD", start)
+	}
+
+	endDate, err := time.Parse(dateLayout, end)
+	if err != nil {
+		return fmt.Errorf("invalid end date %q, expected YYYY-MM-DD", end)
+	}
+
+	if endDate.Before(startDate) {
+		return fmt.Errorf("end date %s is before start date %s", end, start)
+	}
+
+	return nil
+}
+
+// isValidColour reports whether s is a "#rrggbb" colour, or empty
+func isValidColour(s string) bool {
+	if s == "" {
+		return true
+	}
+	if len(s) != 7 || s[0] != '#' {
+		return false
+	}
+	for _, c := range strings.ToLower(s[1:]) {
+		if !(c >= '0' && c <= '9' || c >= 'a' && c <= 'f') {
+			return false
+		}
+	}
+	return true
+}
+
+func validateSemester(s models.Semester) error {
+	if len(strings.TrimSpace(s.Name)) < 1 {
+		return fmt.Errorf("invalid semester name")
+	}
+
+	return validateDates(s.StartDate, s.EndDate, false)
+}
+
+func validateCourse(c models.Course) error {
+	if len(strings.TrimSpace(c.Name)) < 1 {
+		return fmt.Errorf("invalid course name")
+	}
+
+	if !isValidColour(c.Colour) {
+		return fmt.Errorf("invalid colour %q, expected #rrggbb", c.Colour)
+	}
+
+	return validateDates(c.StartDate, c.EndDate, true)
+}
+
+func (a *app) AddSemester(userId int64, s *models.Semester) error {
+	if err := validateSemester(*s); err != nil {
+		return err
+	}
+
+	s.IsArchived = false
+	s.TimeCreated = time.Now()
+	s.Courses = []models.Course{}
+
+	semesterId, err := a.repo.AddSemester(userId, *s)
+	if err != nil {
+		return fmt.Errorf("error adding semester to database: %v", err)
+	}
+
+	s.ID = semesterId
+	return nil
+}
+
+// GetSemesters returns the user's semesters with their courses, latest
+// first.
+func (a *app) GetSemesters(userId int64) ([]models.Semester, error) {
+	semesters, err := a.repo.GetSemesters(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting semesters from database: %v", err)
+	}
+
+	courses, err := a.repo.GetCourses(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting courses from database: %v", err)
+	}
+
+	for i := range semesters {
+		semesters[i].Courses = []models.Course{}
+		for _, c := range courses {
+			if c.SemesterID == semesters[i].ID {
+				semesters[i].Courses = append(semesters[i].Courses, c)
+			}
+		}
+	}
+
+	return semesters, nil
+}
+
+func (a *app) GetSemester(userId, semesterId int64) (models.Semester, error) {
+	s, err := a.repo.GetSemester(userId, semesterId)
+	if err != nil {
+		return models.Semester{}, fmt.Errorf("error getting semester from database: %w", err)
+	}
+
+	courses, err := a.repo.GetCourses(userId)
+	if err != nil {
+		return models.Semester{}, fmt.Errorf("error getting courses from database: %v", err)
+	}
+
+	s.Courses = []models.Course{}
+	for _, c := range courses {
+		if c.SemesterID == s.ID {
+			s.Courses = append(s.Courses, c)
+		}
+	}
+
+	return s, nil
+}
+
+func (a *app) UpdateSemester(userId, semesterId int64, update models.SemesterUpdate) (models.Semester, error) {
+	s, err := a.repo.GetSemester(userId, semesterId)
+	if err != nil {
+		return models.Semester{}, fmt.Errorf("error getting semester from database: %w", err)
+	}
+
+	if update.Name != nil {
+		s.Name = *update.Name
+	}
+	if update.StartDate != nil {
+		s.StartDate = *update.StartDate
+	}
+	if update.EndDate != nil {
+		s.EndDate = *update.EndDate
+	}
+
+	if err := validateSemester(s); err != nil {
+		return models.Semester{}, err
+	}
+
+	if err := a.repo.UpdateSemester(semesterId, s); err != nil {
+		return models.Semester{}, fmt.Errorf("error updating semester: %v", err)
+	}
+
+	return a.GetSemester(userId, semesterId)
+}
+
+// ArchiveSemester archives or unarchives a semester, along with all of its
+// courses and their tasks.
+func (a *app) ArchiveSemester(userId, semesterId int64, archived bool) (models.Semester, error) {
+	s, err := a.repo.GetSemester(userId, semesterId)
+	if err != nil {
+		return models.Semester{}, fmt.Errorf("error getting semester from database: %w", err)
+	}
+
+	s.IsArchived = archived
+	if err := a.repo.UpdateSemester(semesterId, s); err != nil {
+		return models.Semester{}, fmt.Errorf("error updating semester: %v", err)
+	}
+
+	return a.GetSemester(userId, semesterId)
+}
+
+// DeleteSemester deletes a semester, its courses are kept without one.
+func (a *app) DeleteSemester(userId, semesterId int64) error {
+	if _, err := a.repo.GetSemester(userId, semesterId); err != nil {
+		return fmt.Errorf("error getting semester from database: %w", err)
+	}
+
+	if err := a.repo.DeleteSemester(semesterId); err != nil {
+		return fmt.Errorf("error deleting semester: %v", err)
+	}
+
+	return nil
+}
+
+// checkSemester makes sure a semester a course is put in is the user's
+func (a *app) checkSemester(userId, semesterId int64) error {
+	if semesterId == 0 {
+		return nil
+	}
+
+	if _, err := a.repo.GetSemester(userId, semesterId); err != nil {
+		return fmt.Errorf("error getting semester %d: %v", semesterId, err)
+	}
+
+	return nil
+}
+
+func (a *app) AddCourse(userId int64, c *models.Course) error {
+	if err := validateCourse(*c); err != nil {
+		return err
+	}
+
+	if err := a.checkSemester(userId, c.SemesterID); err != nil {
+		return err
+	}
+
+	c.TimeCreated = time.Now()
+	courseId, err := a.repo.AddCourse(userId, *c)
+	if err != nil {
+		return fmt.Errorf("error adding course to database: %v", err)
+	}
+
+	*c, err = a.repo.GetCourse(userId, courseId)
+	if err != nil {
+		return fmt.Errorf("error getting course from database: %v", err)
+	}
+
+	return nil
+}
+
+// GetCourses returns the user's courses, only the ones of a semester when
+// semesterId isn't 0.
+func (a *app) GetCourses(userId, semesterId int64) ([]models.Course, error) {
+	courses, err := a.repo.GetCourses(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting courses from database: %v", err)
+	}
+
+	if semesterId == 0 {
+		return courses, nil
+	}
+
+	var filtered []models.Course
+	for _, c := range courses {
+		if c.SemesterID == semesterId {
+			filtered = append(filtered, c)
+		}
+	}
+
+	return filtered, nil
+}
+
+func (a *app) GetCourse(userId, courseId int64) (models.Course, error) {
+	c, err := a.repo.GetCourse(userId, courseId)
+	if err != nil {
+		return models.Course{}, fmt.Errorf("error getting course from database: %w", err)
+	}
+
+	return c, nil
+}
+
+func (a *app) UpdateCourse(userId, courseId int64, update models.CourseUpdate) (models.Course, error) {
+	c, err := a.repo.GetCourse(userId, courseId)
+	if err != nil {
+		return models.Course{}, fmt.Errorf("error getting course from database: %w", err)
+	}
+
+	if update.SemesterID != nil {
+		c.SemesterID = *update.SemesterID
+	}
+	if update.Name != nil {
+		c.Name = *update.Name
+	}
+	if update.Code != nil {
+		c.Code = *update.Code
+	}
+	if update.Instructor != nil {
+		c.Instructor = *update.Instructor
+	}
+	if update.Colour != nil {
+		c.Colour = *update.Colour
+	}
+	if update.StartDate != nil {
+		c.StartDate = *update.StartDate
+	}
+	if update.EndDate != nil {
+		c.EndDate = *update.EndDate
+	}
+
+	if err := validateCourse(c); err != nil {
+		return models.Course{}, err
+	}
+
+	if err := a.checkSemester(userId, c.SemesterID); err != nil {
+		return models.Course{}, err
+	}
+
+	if err := a.repo.UpdateCourse(courseId, c); err != nil {
+		return models.Course{}, fmt.Errorf("error updating course: %v", err)
+	}
+
+	return a.GetCourse(userId, courseId)
+}
+
+// DeleteCourse deletes a course, its tasks are kept without one.
+func (a *app) DeleteCourse(userId, courseId int64) error {
+	if _, err := a.repo.GetCourse(userId, courseId); err != nil {
+		return fmt.Errorf("error getting course from database: %w", err)
+	}
+
+	if err := a.repo.DeleteCourse(courseId); err != nil {
+		return fmt.Errorf("error deleting course: %v", err)
+	}
+
+	return nil
+}
+
+// checkCourse makes sure the course a task is linked to is the user's
+func (a *app) checkCourse(userId, courseId int64) error {
+	if courseId == 0 {
+		return nil
+	}
+
+	if _, err := a.repo.GetCourse(userId, courseId); err != nil {
+		return fmt.Errorf("error getting course %d: %v", courseId, err)
+	}
+
+	return nil
+}
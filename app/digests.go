@@ -0,0 +1,295 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaelcosj/stms/framework"
+	"github.com/michaelcosj/stms/models"
+)
+
+const (
+	// scheduled emails are only sent this long after their time, a server
+	// that was down all morning doesn't send the agenda in the evening
+	scheduledEmailWindow = 3 * time.Hour
+	// tasks listed under each heading of the digest
+	digestListLimit = 10
+
+	// email lists users can unsubscribe from, "all" is both
+	listAgenda = "agenda"
+	listDigest = "digest"
+	listAll    = "all"
+)
+
+// ErrInvalidUnsubscribeLink is returned for unsubscribe links with an
+// unknown list or a token that wasn't signed for them
+var ErrInvalidUnsubscribeLink = errors.New("invalid unsubscribe link")
+
+// SendScheduledEmails queues the morning agenda and the weekly digest of the
+// users who opted in to them, once their agenda time has come in their
+// timezone. The digest goes out on Mondays. Each is sent at most once a day.
+func (a *app) SendScheduledEmails() error {
+	subscribers, err := a.repo.GetEmailSubscribers()
+	if err != nil {
+		return fmt.Errorf("error getting email subscribers: %v", err)
+	}
+
+	now := time.Now()
+
+	var errs []error
+	for _, s := range subscribers {
+		loc, err := time.LoadLocation(s.Timezone)
+		if err != nil {
+			loc = time.UTC
+		}
+
+		local := now.In(loc)
+		day := local.Format(dateLayout)
+
+		sendAt, err := parseClock(s.AgendaTime)
+		if err != nil {
+			continue
+		}
+		start := atClock(local, sendAt)
+		if local.Before(start) || !local.Before(start.Add(scheduledEmailWindow)) {
+			continue
+		}
+
+		if s.AgendaEmails && s.AgendaSentOn != day {
+			if err := a.sendAgenda(s, local); err != nil {
+				errs = append(errs, fmt.Errorf("error queueing agenda for user %d: %v", s.UserID, err))
+			}
+		}
+
+		if s.DigestEmails && s.DigestSentOn != day && local.Weekday() == time.Monday {
+			if err := a.sendDigest(s, local); err != nil {
+				errs = append(errs, fmt.Errorf("error queueing digest for user %d: %v", s.UserID, err))
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// sendAgenda queues the user's agenda for the day. Days with nothing on
+// aren't sent but are still claimed, so they aren't looked at again.
+func (a *app) sendAgenda(s models.EmailSubscriber, local time.Time) error {
+	day := local.Format(dateLayout)
+	days, err := a.GetSchedule(s.UserID, day, day)
+	if err != nil {
+		return err
+	}
+
+	var lines []string
+	for _, item := range days[0].Items {
+		if item.Type == "task" && item.Task.IsCompleted {
+			continue
+		}
+		lines = append(lines, agendaLine(item))
+	}
+
+	if len(lines) == 0 {
+		if _, err := a.repo.ClaimAgendaEmail(s.UserID, day, nil); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	email := newOutboxEmail(s.Email, framework.EmailData{
+		Template: framework.TemplateAgenda,
+		Subject:  fmt.Sprintf("Your agenda for %s", local.Format("Monday, 2 January")),
+		Message:  fmt.Sprintf("Here's what's on for %s.", local.Format("Monday, 2 January")),
+		Fields: map[string]string{
+			"items":           strings.Join(lines, "\n"),
+			"unsubscribe_url": unsubscribeURL(s.UserID, listAgenda),
+		},
+	})
+
+	if _, err := a.repo.ClaimAgendaEmail(s.UserID, day, &email); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+func agendaLine(item models.AgendaItem) string {
+	title := item.Title
+	if item.CourseCode != "" && item.Type != "session" {
+		title = item.CourseCode + ": " + title
+	}
+
+	var line string
+	switch item.Type {
+	case "session":
+		line = fmt.Sprintf("%s-%s %s", item.Start.Format("15:04"), item.End.Format("15:04"), title)
+		if item.Kind != "" {
+			line += " (" + item.Kind + ")"
+		}
+	case "exam":
+		line = fmt.Sprintf("%s Exam: %s", item.Start.Format("15:04"), title)
+	case "block":
+		line = fmt.Sprintf("%s-%s Work on %s", item.Start.Format("15:04"), item.End.Format("15:04"), title)
+	default:
+		line = fmt.Sprintf("%s Due: %s", item.Start.Format("15:04"), title)
+	}
+
+	if item.Location != "" {
+		line += ", " + item.Location
+	}
+	return line
+}
+
+// sendDigest queues the user's weekly digest, the tasks they completed in
+// the week before, the ones overdue and the ones due in the week ahead.
+// Weeks with none of them aren't sent but are still claimed.
+func (a *app) sendDigest(s models.EmailSubscriber, local time.Time) error {
+	user, err := a.repo.GetUserByID(s.UserID)
+	if err != nil {
+		return fmt.Errorf("error getting user from database: %v", err)
+	}
+
+	thisWeek := weekStart(local)
+	lastWeek := thisWeek.AddDate(0, 0, -7)
+	nextWeek := thisWeek.AddDate(0, 0, 7)
+
+	var completed, overdue, upcoming []models.Task
+	for _, t := range user.Tasks {
+		switch {
+		case t.IsCompleted:
+			if !t.TimeCompleted.Before(lastWeek) && t.TimeCompleted.Before(thisWeek) {
+				completed = append(completed, t)
+			}
+		case !hasDueTime(t):
+			// tasks without a due time are never overdue or upcoming
+		case t.TimeDue.Before(local):
+			overdue = append(overdue, t)
+		case t.TimeDue.Before(nextWeek):
+			upcoming = append(upcoming, t)
+		}
+	}
+
+	day := local.Format(dateLayout)
+	if len(completed) == 0 && len(overdue) == 0 && len(upcoming) == 0 {
+		if _, err := a.repo.ClaimDigestEmail(s.UserID, day, nil); err != nil {
+			return err
+		}
+		return nil
+	}
+
+	sort.SliceStable(completed, func(i, j int) bool { return completed[i].TimeCompleted.Before(completed[j].TimeCompleted) })
+	sort.SliceStable(overdue, func(i, j int) bool { return overdue[i].TimeDue.Before(overdue[j].TimeDue) })
+	sort.SliceStable(upcoming, func(i, j int) bool { return upcoming[i].TimeDue.Before(upcoming[j].TimeDue) })
+
+	loc := local.Location()
+	email := newOutboxEmail(s.Email, framework.EmailData{
+		Template: framework.TemplateDigest,
+		Subject:  fmt.Sprintf("Your week of %s", thisWeek.Format("2 January")),
+		Message: fmt.Sprintf(
+			"Last week you completed %s. You have %s overdue and %s due this week.",
+			plural(len(completed), "task"), plural(len(overdue), "task"), plural(len(upcoming), "task"),
+		),
+		Fields: map[string]string{
+			"completed": digestList(completed, func(t models.Task) string {
+				return t.Name + ", " + t.TimeCompleted.In(loc).Format("Mon 2 Jan")
+			}),
+			"overdue": digestList(overdue, func(t models.Task) string {
+				return t.Name + ", due " + t.TimeDue.In(loc).Format("Mon 2 Jan 15:04")
+			}),
+			"upcoming": digestList(upcoming, func(t models.Task) string {
+				return t.Name + ", due " + t.TimeDue.In(loc).Format("Mon 2 Jan 15:04")
+			}),
+			"unsubscribe_url": unsubscribeURL(s.UserID, listDigest),
+		},
+	})
+
+	if _, err := a.repo.ClaimDigestEmail(s.UserID, day, &email); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// digestList lists the first few tasks a line each, the rest are counted
+func digestList(tasks []models.Task, line func(models.Task) string) string {
+	var lines []string
+	for i, t := range tasks {
+		if i == digestListLimit {
+			lines = append(lines, fmt.Sprintf("and %d more", len(tasks)-i))
+			break
+		}
+		lines = append(lines, line(t))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// unsubscribeURL returns the link in scheduled emails that turns them off
+// without logging in, it's signed so it can't be made for other users.
+func unsubscribeURL(userId int64, list string) string {
+	baseUrl := os.Getenv("PUBLIC_URL")
+	if baseUrl == "" {
+		port := os.Getenv("SERVER_PORT")
+		if port == "" {
+			port = "6969"
+		}
+		baseUrl = "http://localhost:" + port
+	}
+
+	query := url.Values{}
+	query.Set("user", fmt.Sprint(userId))
+	query.Set("list", list)
+	query.Set("token", framework.UnsubscribeToken(os.Getenv("ACCESS_TOKEN_SECRET"), userId, list))
+
+	return strings.TrimSuffix(baseUrl, "/") + "/unsubscribe?" + query.Encode()
+}
+
+// CheckUnsubscribeLink checks an unsubscribe link without using it, so it
+// can be confirmed before anything changes.
+func (a *app) CheckUnsubscribeLink(userId int64, list, token string) error {
+	if list != listAgenda && list != listDigest && list != listAll {
+		return ErrInvalidUnsubscribeLink
+	}
+
+	if !framework.ValidUnsubscribeToken(os.Getenv("ACCESS_TOKEN_SECRET"), userId, list, token) {
+		return ErrInvalidUnsubscribeLink
+	}
+
+	return nil
+}
+
+// Unsubscribe turns off the user's agenda or digest emails, or both for
+// the "all" list, from a signed unsubscribe link.
+func (a *app) Unsubscribe(userId int64, list, token string) error {
+	if err := a.CheckUnsubscribeLink(userId, list, token); err != nil {
+		return err
+	}
+
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	if list == listAgenda || list == listAll {
+		user.AgendaEmails = false
+	}
+	if list == listDigest || list == listAll {
+		user.DigestEmails = false
+	}
+
+	if err := a.repo.UpdateUser(userId, user); err != nil {
+		return fmt.Errorf("error updating user in database: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,159 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/michaelcosj/stms/framework"
+	"github.com/michaelcosj/stms/models"
+)
+
+// subscribe turns on the user's scheduled emails and returns them as a
+// subscriber
+func subscribe(t *testing.T, a *app, userId int64) models.EmailSubscriber {
+	t.Helper()
+
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	user.IsVerified = true
+	user.AgendaEmails = true
+	user.DigestEmails = true
+	if err := a.repo.UpdateUser(userId, user); err != nil {
+		t.Fatal(err)
+	}
+
+	return subscriber(t, a, userId)
+}
+
+func subscriber(t *testing.T, a *app, userId int64) models.EmailSubscriber {
+	t.Helper()
+
+	subscribers, err := a.repo.GetEmailSubscribers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range subscribers {
+		if s.UserID == userId {
+			return s
+		}
+	}
+
+	t.Fatalf("user %d isn't subscribed", userId)
+	return models.EmailSubscriber{}
+}
+
+func TestScheduledEmailsClaimEmptyDays(t *testing.T) {
+	a, userId := newTestApp(t)
+	s := subscribe(t, a, userId)
+
+	// a monday with nothing on and no tasks for the digest
+	local := time.Date(2026, 10, 19, 7, 30, 0, 0, time.UTC)
+	if err := a.sendAgenda(s, local); err != nil {
+		t.Fatal(err)
+	}
+	if err := a.sendDigest(s, local); err != nil {
+		t.Fatal(err)
+	}
+
+	s = subscriber(t, a, userId)
+	if s.AgendaSentOn != "2026-10-19" || s.DigestSentOn != "2026-10-19" {
+		t.Errorf("got agenda sent on %q and digest on %q, want both claimed", s.AgendaSentOn, s.DigestSentOn)
+	}
+
+	emails, err := a.repo.GetOutboxEmails("pending", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emails) != 0 {
+		t.Errorf("got %d emails queued for an empty day", len(emails))
+	}
+}
+
+func TestScheduledEmailsSentOnce(t *testing.T) {
+	a, userId := newTestApp(t)
+	s := subscribe(t, a, userId)
+
+	local := time.Date(2026, 10, 19, 7, 30, 0, 0, time.UTC)
+	task := models.Task{Name: "Essay", Tag: "study", Description: "Two pages", TimeDue: local.Add(8 * time.Hour)}
+	if err := a.AddTask(userId, &task); err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := a.sendAgenda(s, local); err != nil {
+			t.Fatal(err)
+		}
+		if err := a.sendDigest(s, local); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	emails, err := a.repo.GetOutboxEmails("pending", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emails) != 2 {
+		t.Fatalf("got %d emails queued, want the agenda and digest once", len(emails))
+	}
+	for _, e := range emails {
+		if e.Fields["unsubscribe_url"] == "" {
+			t.Errorf("%s email has no unsubscribe link", e.Template)
+		}
+	}
+}
+
+func TestUnsubscribe(t *testing.T) {
+	t.Setenv("ACCESS_TOKEN_SECRET", "access-token-secret")
+
+	a, userId := newTestApp(t)
+	subscribe(t, a, userId)
+
+	token := framework.UnsubscribeToken("access-token-secret", userId, listAgenda)
+
+	tests := []struct {
+		name   string
+		userId int64
+		list   string
+		token  string
+	}{
+		{"unknown list", userId, "reminders", token},
+		{"token of another list", userId, listDigest, token},
+		{"token of another user", userId + 1, listAgenda, token},
+		{"no token", userId, listAgenda, ""},
+	}
+	for _, tt := range tests {
+		if err := a.Unsubscribe(tt.userId, tt.list, tt.token); !errors.Is(err, ErrInvalidUnsubscribeLink) {
+			t.Errorf("%s: got error %v, want ErrInvalidUnsubscribeLink", tt.name, err)
+		}
+	}
+
+	// checking the link changes nothing
+	if err := a.CheckUnsubscribeLink(userId, listAgenda, token); err != nil {
+		t.Fatal(err)
+	}
+	if s := subscriber(t, a, userId); !s.AgendaEmails {
+		t.Fatal("checking the link unsubscribed the user")
+	}
+
+	if err := a.Unsubscribe(userId, listAgenda, token); err != nil {
+		t.Fatal(err)
+	}
+	if s := subscriber(t, a, userId); s.AgendaEmails || !s.DigestEmails {
+		t.Errorf("got agenda emails %v and digest emails %v, want only the agenda off", s.AgendaEmails, s.DigestEmails)
+	}
+
+	all := framework.UnsubscribeToken("access-token-secret", userId, listAll)
+	if err := a.Unsubscribe(userId, listAll, all); err != nil {
+		t.Fatal(err)
+	}
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.AgendaEmails || user.DigestEmails {
+		t.Error("the all list didn't turn off both emails")
+	}
+}
@@ -0,0 +1,68 @@
+package app
+
+import (
+	"log"
+
+	"github.com/michaelcosj/stms/framework/events"
+	"github.com/michaelcosj/stms/models"
+)
+
+// task event types sent to the user's live streams
+const (
+	EventTaskCreated = "task.created"
+	EventTaskUpdated = "task.updated"
+	EventTaskDeleted = "task.deleted"
+)
+
+type heldEvent struct {
+	userId    int64
+	eventType string
+	data      interface{}
+}
+
+// publish sends an event to the user's live streams and webhooks. A
+// failure to publish doesn't fail the change that caused it, clients catch
+// up on their next full sync.
+func (a *app) publish(userId int64, eventType string, data interface{}) {
+	if a.held != nil {
+		*a.held = append(*a.held, heldEvent{userId, eventType, data})
+		return
+	}
+
+	a.queueWebhooks(userId, eventType, data)
+
+	if a.events == nil {
+		return
+	}
+
+	if err := a.events.Publish(userId, eventType, data); err != nil {
+		log.Printf("error publishing %s event: %v", eventType, err)
+	}
+}
+
+func (a *app) publishTask(userId int64, eventType string, t models.Task) {
+	if eventType == EventTaskDeleted {
+		a.publish(userId, eventType, map[string]int64{"id": t.ID})
+		return
+	}
+
+	a.publish(userId, eventType, t)
+}
+
+// publishTaskChange sends the current state of a task whose checklist
+// changed
+func (a *app) publishTaskChange(userId, taskId int64) {
+	t, err := a.repo.GetTask(userId, taskId)
+	if err != nil {
+		return
+	}
+
+	t.UpdateProgress()
+	a.publishTask(userId, EventTaskUpdated, t)
+}
+
+// SubscribeEvents streams the events of the user's tasks, starting after
+// lastEventId when it's set.
+func (a *app) SubscribeEvents(userId int64, lastEventId string) (*events.Subscription, error) {
+	return a.events.Subscribe(userId, lastEventId)
+}
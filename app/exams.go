@@ -0,0 +1,511 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+const (
+	// length of a study session when none is given and the lengths it can
+	// be set to, in minutes
+	defaultStudySession = 60
+	minStudySession     = 15
+	maxStudySession     = 240
+	maxStudyHours       = 200
+
+	// study sessions are planned between these times of the day, in
+	// minutes since midnight in the user's timezone
+	studyDayStart = 9 * 60
+	studyDayEnd   = 21 * 60
+
+	// time kept free to finish a task before it's due
+	dueTaskBuffer = time.Hour
+	// time kept free for sitting another exam
+	examLength = 3 * time.Hour
+	// break kept between a study session and anything else
+	studyBreak = 15 * time.Minute
+	// study sessions start on the quarter hour
+	studySlotStep = 15 * time.Minute
+)
+
+func validateExam(e *models.Exam) error {
+	e.Title = strings.TrimSpace(e.Title)
+	if len(e.Title) < 1 {
+		return fmt.Errorf("invalid exam title")
+	}
+
+	if e.Time.IsZero() {
+		return fmt.Errorf("exam needs a time")
+	}
+
+	if e.Weight < 0 || e.Weight > 100 {
+		return fmt.Errorf("invalid exam weight %v, expected 0 to 100 percent", e.Weight)
+	}
+
+	topics := []string{}
+	for _, topic := range e.Topics {
+		topic = strings.TrimSpace(topic)
+		if topic == "" {
+			return fmt.Errorf("exam topics can't be empty")
+		}
+		topics = append(topics, topic)
+	}
+	e.Topics = topics
+
+	return nil
+}
+
+// daysLeft counts the days until the exam in the user's timezone, 0 on the
+// day of the exam and negative once it's past
+func daysLeft(e models.Exam, loc *time.Location) int {
+	return daysBetween(time.Now().In(loc), e.Time.In(loc))
+}
+
+// AddExam adds an exam to one of the user's courses. It's named after the
+// course when it has no title.
+func (a *app) AddExam(userId int64, e *models.Exam) error {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	c, err := a.repo.GetCourse(userId, e.CourseID)
+	if err != nil {
+		return fmt.Errorf("error getting course %d: %v", e.CourseID, err)
+	}
+
+	if strings.TrimSpace(e.Title) == "" {
+		e.Title = c.Name + " exam"
+	}
+
+	if err := validateExam(e); err != nil {
+		return err
+	}
+
+	e.StudyHours, e.SessionMinutes = 0, 0
+	e.TimeCreated = time.Now()
+
+	e.ID, err = a.repo.AddExam(userId, *e)
+	if err != nil {
+		return fmt.Errorf("error adding exam to database: %v", err)
+	}
+
+	e.DaysLeft = daysLeft(*e, location(user))
+	return nil
+}
+
+// GetExams returns the user's exams, soonest first, only the ones of a
+// course when courseId isn't 0.
+func (a *app) GetExams(userId, courseId int64) ([]models.Exam, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	exams, err := a.repo.GetExams(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting exams from database: %v", err)
+	}
+
+	loc := location(user)
+	var filtered []models.Exam
+	for _, e := range exams {
+		if courseId == 0 || e.CourseID == courseId {
+			e.DaysLeft = daysLeft(e, loc)
+			filtered = append(filtered, e)
+		}
+	}
+
+	return filtered, nil
+}
+
+// GetExam returns an exam along with the tasks planned to study for it.
+func (a *app) GetExam(userId, examId int64) (models.Exam, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.Exam{}, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	e, err := a.repo.GetExam(userId, examId)
+	if err != nil {
+		return models.Exam{}, fmt.Errorf("error getting exam from database: %w", err)
+	}
+
+	e.DaysLeft = daysLeft(e, location(user))
+	for _, t := range user.Tasks {
+		if t.ExamID == e.ID {
+			t.UpdateProgress()
+			e.StudyTasks = append(e.StudyTasks, t)
+		}
+	}
+
+	sort.SliceStable(e.StudyTasks, func(i, j int) bool {
+		return e.StudyTasks[i].TimeDue.Before(e.StudyTasks[j].TimeDue)
+	})
+
+	return e, nil
+}
+
+// UpdateExam changes the fields that are set. When an exam with a study
+// plan moves, the study tasks that are left are planned again for the new
+// date, as many as still fit before it.
+func (a *app) UpdateExam(userId, examId int64, update models.ExamUpdate) (models.Exam, error) {
+	e, err := a.repo.GetExam(userId, examId)
+	if err != nil {
+		return models.Exam{}, fmt.Errorf("error getting exam from database: %w", err)
+	}
+	old := e
+
+	if update.CourseID != nil {
+		e.CourseID = *update.CourseID
+	}
+	if update.Title != nil {
+		e.Title = *update.Title
+	}
+	if update.Time != nil {
+		e.Time = *update.Time
+	}
+	if update.Location != nil {
+		e.Location = *update.Location
+	}
+	if update.Weight != nil {
+		e.Weight = *update.Weight
+	}
+	if update.Topics != nil {
+		e.Topics = *update.Topics
+	}
+
+	if err := validateExam(&e); err != nil {
+		return models.Exam{}, err
+	}
+
+	if _, err := a.repo.GetCourse(userId, e.CourseID); err != nil {
+		return models.Exam{}, fmt.Errorf("error getting course %d: %v", e.CourseID, err)
+	}
+
+	replan := e.StudyHours > 0 &&
+		(!e.Time.Equal(old.Time) || e.CourseID != old.CourseID)
+
+	err = a.inTransaction(func(a *app) error {
+		if err := a.repo.UpdateExam(examId, e); err != nil {
+			return fmt.Errorf("error updating exam: %v", err)
+		}
+
+		if replan {
+			if _, _, err := a.planStudy(userId, e); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Exam{}, err
+	}
+
+	return a.GetExam(userId, examId)
+}
+
+// DeleteExam deletes an exam and moves the study tasks that are left for it
+// to the trash, the completed ones and the ones with tracked time are kept
+// without an exam.
+func (a *app) DeleteExam(userId, examId int64) error {
+	e, err := a.repo.GetExam(userId, examId)
+	if err != nil {
+		return fmt.Errorf("error getting exam from database: %w", err)
+	}
+
+	return a.inTransaction(func(a *app) error {
+		e.StudyHours = 0
+		if _, _, err := a.planStudy(userId, e); err != nil {
+			return err
+		}
+
+		if err := a.repo.DeleteExam(examId); err != nil {
+			return fmt.Errorf("error deleting exam: %v", err)
+		}
+		return nil
+	})
+}
+
+// PlanExam plans study tasks for an exam, studyHours in all split into
+// sessions of sessionMinutes, spread over the days until the exam. Study
+// tasks already completed count towards the hours, as does the time tracked
+// on the ones left, the others are replaced. Planning 0 hours removes the plan.
+func (a *app) PlanExam(userId, examId int64, studyHours float64, sessionMinutes int) (models.Exam, error) {
+	if studyHours < 0 || studyHours > maxStudyHours {
+		return models.Exam{}, fmt.Errorf("invalid study hours %v, expected 0 to %d", studyHours, maxStudyHours)
+	}
+
+	if sessionMinutes == 0 {
+		sessionMinutes = defaultStudySession
+	}
+	if sessionMinutes < minStudySession || sessionMinutes > maxStudySession {
+		return models.Exam{}, fmt.Errorf("invalid session length %d, expected %d to %d minutes", sessionMinutes, minStudySession, maxStudySession)
+	}
+
+	e, err := a.repo.GetExam(userId, examId)
+	if err != nil {
+		return models.Exam{}, fmt.Errorf("error getting exam from database: %w", err)
+	}
+
+	if studyHours > 0 && !e.Time.After(time.Now()) {
+		return models.Exam{}, fmt.Errorf("exam is already over")
+	}
+
+	e.StudyHours = studyHours
+	e.SessionMinutes = sessionMinutes
+	if studyHours == 0 {
+		e.SessionMinutes = 0
+	}
+
+	err = a.inTransaction(func(a *app) error {
+		if err := a.repo.UpdateExam(examId, e); err != nil {
+			return fmt.Errorf("error updating exam: %v", err)
+		}
+
+		planned, wanted, err := a.planStudy(userId, e)
+		if err != nil {
+			return err
+		}
+		if planned < wanted {
+			return fmt.Errorf("only %d of %d study sessions fit before the exam, plan fewer hours or shorter sessions", planned, wanted)
+		}
+		return nil
+	})
+	if err != nil {
+		return models.Exam{}, err
+	}
+
+	return a.GetExam(userId, examId)
+}
+
+// interval is a span of time that's taken
+type interval struct {
+	start, end time.Time
+}
+
+// planStudy moves the study tasks left for an exam to the trash and plans
+// new ones for the hours its plan has left, the ones with tracked time are
+// kept. It returns how many sessions were planned and
+// how many were wanted, fewer are planned when they don't all fit.
+func (a *app) planStudy(userId int64, e models.Exam) (int, int, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	var busy []interval
+	completed, tracked := 0, 0
+	removed := time.Now()
+	for _, t := range user.Tasks {
+		if t.ExamID == e.ID {
+			if t.IsCompleted {
+				completed++
+				continue
+			}
+
+			// a study task that time was tracked on is kept and the time
+			// counts towards the plan
+			entries, err := a.repo.GetTaskTimeEntries(t.ID)
+			if err != nil {
+				return 0, 0, fmt.Errorf("error getting time entries from database: %v", err)
+			}
+			if len(entries) > 0 {
+				for _, entry := range entries {
+					fillEntry(&entry, removed)
+					tracked += entry.Minutes
+				}
+				busy = append(busy, interval{t.TimeDue.Add(-dueTaskBuffer), t.TimeDue})
+				continue
+			}
+
+			if err := a.repo.TrashTask(t.ID, t.Version, removed); err != nil {
+				return 0, 0, fmt.Errorf("error removing study task from database: %w", err)
+			}
+
+			t.Version++
+			t.TimeDeleted = &removed
+			t.UpdateProgress()
+			if err := a.recordRevision(userId, "delete", nil, t); err != nil {
+				return 0, 0, err
+			}
+			continue
+		}
+
+		if !t.IsCompleted && !t.TimeDue.IsZero() {
+			busy = append(busy, interval{t.TimeDue.Add(-dueTaskBuffer), t.TimeDue})
+		}
+	}
+
+	if e.StudyHours == 0 {
+		return 0, 0, nil
+	}
+
+	left := e.StudyHours*60 - float64(tracked)
+	wanted := int(math.Ceil(left/float64(e.SessionMinutes))) - completed
+	if wanted <= 0 {
+		return 0, 0, nil
+	}
+
+	exams, err := a.repo.GetExams(userId)
+	if err != nil {
+		return 0, 0, fmt.Errorf("error getting exams from database: %v", err)
+	}
+	for _, other := range exams {
+		if other.ID != e.ID {
+			busy = append(busy, interval{other.Time.Add(-dueTaskBuffer), other.Time.Add(examLength)})
+		}
+	}
+
+	tt, err := a.getTimetable(userId)
+	if err != nil {
+		return 0, 0, err
+	}
+
+	// the days from today until the one before the exam
+	loc := location(user)
+	now := time.Now().In(loc)
+	var days []time.Time
+	examDay := e.Time.In(loc)
+	for day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc); daysBetween(day, examDay) > 0; day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+		for _, s := range tt.on(day) {
+			start, end := sessionTimes(s, day)
+			busy = append(busy, interval{start, end})
+		}
+	}
+
+	slots := planStudySessions(now, days, wanted, time.Duration(e.SessionMinutes)*time.Minute, busy)
+	topics := sessionTopics(e.Topics, len(slots))
+
+	for i, slot := range slots {
+		description := fmt.Sprintf("Revise for %s", e.Title)
+		if len(topics[i]) > 0 {
+			description = fmt.Sprintf("Revise %s", strings.Join(topics[i], ", "))
+		}
+
+		t := models.Task{
+			Name: fmt.Sprintf("Study for %s (%d/%d)", e.Title, i+1, len(slots)),
+			Tag:  "study",
+			Description: fmt.Sprintf("%s from %s to %s", description,
+				slot.start.Format("15:04"), slot.end.Format("15:04")),
+			Priority:      models.PriorityMedium,
+			TimeDue:       slot.end,
+			TimeCreated:   time.Now(),
+			TimeCompleted: time.Unix(0, 0).UTC(),
+			CourseID:      e.CourseID,
+			ExamID:        e.ID,
+			Reminders:     []int{},
+			Version:       1,
+		}
+
+		if t.ID, err = a.repo.AddTask(userId, t); err != nil {
+			return 0, 0, fmt.Errorf("error adding study task to database: %v", err)
+		}
+
+		if err := a.recordRevision(userId, "create", nil, t); err != nil {
+			return 0, 0, err
+		}
+	}
+
+	return len(slots), wanted, nil
+}
+
+// planStudySessions finds times for count sessions of a length over the
+// given days, in the study hours of each day and clear of what's busy. The
+// sessions are spread evenly with the last one on the last day, a session
+// that doesn't fit its day goes on the closest later day with room, or
+// else the closest earlier one. Fewer sessions are returned when they don't
+// all fit.
+func planStudySessions(now time.Time, days []time.Time, count int, length time.Duration, busy []interval) []interval {
+	var planned []interval
+	for i := 0; i < count && len(days) > 0; i++ {
+		target := (i+1)*len(days)/count - 1
+		if target < 0 {
+			target = 0
+		}
+
+		order := make([]int, 0, len(days))
+		for d := target; d < len(days); d++ {
+			order = append(order, d)
+		}
+		for d := target - 1; d >= 0; d-- {
+			order = append(order, d)
+		}
+
+		found := false
+		for _, d := range order {
+			slot, ok := freeSlot(now, days[d], length, busy)
+			if ok {
+				busy = append(busy, slot)
+				planned = append(planned, slot)
+				found = true
+				break
+			}
+		}
+
+		if !found {
+			break
+		}
+	}
+
+	sort.Slice(planned, func(i, j int) bool {
+		return planned[i].start.Before(planned[j].start)
+	})
+	return planned
+}
+
+// freeSlot returns the earliest time of a day, after now, a session of a
+// length fits in without running into anything busy
+func freeSlot(now, day time.Time, length time.Duration, busy []interval) (interval, bool) {
+	from := atClock(day, studyDayStart)
+	to := atClock(day, studyDayEnd)
+	if earliest := now.Truncate(studySlotStep).Add(studySlotStep); from.Before(earliest) {
+		from = earliest
+	}
+
+	for start := from; !start.Add(length).After(to); start = start.Add(studySlotStep) {
+		slot := interval{start, start.Add(length)}
+
+		free := true
+		for _, b := range busy {
+			if slot.start.Before(b.end.Add(studyBreak)) && b.start.Before(slot.end.Add(studyBreak)) {
+				free = false
+				break
+			}
+		}
+
+		if free {
+			return slot, true
+		}
+	}
+
+	return interval{}, false
+}
+
+// sessionTopics shares the exam's topics out between its study sessions in
+// order. With fewer topics than sessions they're gone over again.
+func sessionTopics(topics []string, sessions int) [][]string {
+	shared := make([][]string, sessions)
+	if len(topics) == 0 || sessions == 0 {
+		return shared
+	}
+
+	if len(topics) < sessions {
+		for i := range shared {
+			shared[i] = []string{topics[i%len(topics)]}
+		}
+		return shared
+	}
+
+	for j, topic := range topics {
+		i := j * sessions / len(topics)
+		shared[i] = append(shared[i], topic)
+	}
+	return shared
+}
@@ -0,0 +1,193 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// addTestExam adds a course and an exam of it days from now
+func addTestExam(t *testing.T, a *app, userId int64, days int) models.Exam {
+	t.Helper()
+
+	e := models.Exam{CourseID: addTestCourse(t, a, userId), Title: "Chemistry final", Time: time.Now().AddDate(0, 0, days)}
+	if err := a.AddExam(userId, &e); err != nil {
+		t.Fatal(err)
+	}
+	return e
+}
+
+// studyTasks returns the user's tasks for an exam
+func studyTasks(t *testing.T, a *app, userId, examId int64) []models.Task {
+	t.Helper()
+
+	tasks, err := a.repo.GetTasks(userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var study []models.Task
+	for _, task := range tasks {
+		if task.ExamID == examId {
+			study = append(study, task)
+		}
+	}
+	return study
+}
+
+func TestPlanExamKeepsTrackedStudyTasks(t *testing.T) {
+	a, userId := newTestApp(t)
+	e := addTestExam(t, a, userId, 5)
+
+	if _, err := a.PlanExam(userId, e.ID, 2, 60); err != nil {
+		t.Fatal(err)
+	}
+	planned := studyTasks(t, a, userId, e.ID)
+	if len(planned) != 2 {
+		t.Fatalf("got %d study tasks, want 2", len(planned))
+	}
+
+	// half an hour was studied on the first session
+	end := time.Now().Add(-time.Hour)
+	entry := models.TimeEntry{Start: end.Add(-30 * time.Minute), End: &end}
+	if err := a.AddTimeEntry(userId, planned[0].ID, &entry); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := a.PlanExam(userId, e.ID, 2, 60); err != nil {
+		t.Fatal(err)
+	}
+
+	// the tracked one is kept and the 90 minutes left need two sessions
+	study := studyTasks(t, a, userId, e.ID)
+	if len(study) != 3 {
+		t.Fatalf("got %d study tasks, want the tracked one and 2 new ones", len(study))
+	}
+	kept := false
+	for _, task := range study {
+		kept = kept || task.ID == planned[0].ID
+	}
+	if !kept {
+		t.Error("the study task with tracked time was replaced")
+	}
+
+	// the other one went to the trash with its history
+	if _, err := a.repo.GetTrashedTask(userId, planned[1].ID); err != nil {
+		t.Fatalf("replaced study task isn't in the trash: %v", err)
+	}
+	revisions, err := a.repo.GetTaskRevisions(planned[1].ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) != 2 || revisions[0].Action != "delete" {
+		t.Errorf("got %d revisions, want the create and delete", len(revisions))
+	}
+}
+
+func TestFreeSlot(t *testing.T) {
+	day := time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC)
+	at := func(hour, min int) time.Time {
+		return time.Date(2026, 10, 20, hour, min, 0, 0, time.UTC)
+	}
+	yesterday := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		now    time.Time
+		length time.Duration
+		busy   []interval
+		want   interval
+		wantOk bool
+	}{
+		{"free day", yesterday, time.Hour, nil, interval{at(9, 0), at(10, 0)}, true},
+		{"later today", at(10, 5), time.Hour, nil, interval{at(10, 15), at(11, 15)}, true},
+		{"break after busy", yesterday, time.Hour, []interval{{at(9, 0), at(10, 0)}}, interval{at(10, 15), at(11, 15)}, true},
+		{"fits before busy", yesterday, time.Hour, []interval{{at(10, 15), at(12, 0)}}, interval{at(9, 0), at(10, 0)}, true},
+		{"no break before busy", yesterday, time.Hour, []interval{{at(10, 0), at(12, 0)}}, interval{at(12, 15), at(13, 15)}, true},
+		{"busy from another day", yesterday, time.Hour, []interval{{at(0, 0).Add(-time.Hour), at(9, 30)}}, interval{at(9, 45), at(10, 45)}, true},
+		{"ends at the end of the day", at(19, 50), time.Hour, nil, interval{at(20, 0), at(21, 0)}, true},
+		{"too long", yesterday, 4 * time.Hour, []interval{{at(10, 0), at(20, 0)}}, interval{}, false},
+		{"day is over", at(21, 30), 15 * time.Minute, nil, interval{}, false},
+	}
+
+	for _, tt := range tests {
+		got, ok := freeSlot(tt.now, day, tt.length, tt.busy)
+		if ok != tt.wantOk || !got.start.Equal(tt.want.start) || !got.end.Equal(tt.want.end) {
+			t.Errorf("%s: got %v to %v, %v, want %v to %v, %v", tt.name,
+				got.start.Format("15:04"), got.end.Format("15:04"), ok,
+				tt.want.start.Format("15:04"), tt.want.end.Format("15:04"), tt.wantOk)
+		}
+	}
+}
+
+func TestPlanStudySessions(t *testing.T) {
+	now := time.Date(2026, 10, 19, 8, 0, 0, 0, time.UTC)
+	day := func(d int) time.Time {
+		return time.Date(2026, 10, 20+d, 0, 0, 0, 0, time.UTC)
+	}
+	at := func(d, hour, min int) time.Time {
+		return time.Date(2026, 10, 20+d, hour, min, 0, 0, time.UTC)
+	}
+	wholeDay := func(d int) interval {
+		return interval{day(d), day(d + 1)}
+	}
+
+	tests := []struct {
+		name   string
+		days   int
+		count  int
+		length time.Duration
+		busy   []interval
+		want   []time.Time
+	}{
+		{"spread with the last on the last day", 4, 2, time.Hour, nil, []time.Time{at(1, 9, 0), at(3, 9, 0)}},
+		{"more sessions than days", 2, 4, time.Hour, nil, []time.Time{at(0, 9, 0), at(0, 10, 15), at(0, 11, 30), at(1, 9, 0)}},
+		{"busy day moves to a later one", 3, 2, time.Hour, []interval{wholeDay(0)}, []time.Time{at(1, 9, 0), at(2, 9, 0)}},
+		{"busy last day moves to an earlier one", 3, 1, time.Hour, []interval{wholeDay(2)}, []time.Time{at(1, 9, 0)}},
+		{"not all fit", 1, 3, 4 * time.Hour, nil, []time.Time{at(0, 9, 0), at(0, 13, 15)}},
+		{"no days", 0, 2, time.Hour, nil, nil},
+	}
+
+	for _, tt := range tests {
+		var days []time.Time
+		for d := 0; d < tt.days; d++ {
+			days = append(days, day(d))
+		}
+
+		var got []time.Time
+		for _, slot := range planStudySessions(now, days, tt.count, tt.length, tt.busy) {
+			if slot.end.Sub(slot.start) != tt.length {
+				t.Errorf("%s: got a session of %v, want %v", tt.name, slot.end.Sub(slot.start), tt.length)
+			}
+			got = append(got, slot.start)
+		}
+
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got sessions at %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestSessionTopics(t *testing.T) {
+	tests := []struct {
+		topics   []string
+		sessions int
+		want     [][]string
+	}{
+		{nil, 2, [][]string{nil, nil}},
+		{[]string{"acids", "bases"}, 0, [][]string{}},
+		{[]string{"acids"}, 3, [][]string{{"acids"}, {"acids"}, {"acids"}}},
+		{[]string{"acids", "bases"}, 3, [][]string{{"acids"}, {"bases"}, {"acids"}}},
+		{[]string{"acids", "bases", "salts"}, 3, [][]string{{"acids"}, {"bases"}, {"salts"}}},
+		{[]string{"acids", "bases", "salts"}, 2, [][]string{{"acids", "bases"}, {"salts"}}},
+		{[]string{"acids", "bases", "salts", "redox"}, 2, [][]string{{"acids", "bases"}, {"salts", "redox"}}},
+	}
+
+	for _, tt := range tests {
+		if got := sessionTopics(tt.topics, tt.sessions); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%v over %d sessions: got %v, want %v", tt.topics, tt.sessions, got, tt.want)
+		}
+	}
+}
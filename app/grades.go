@@ -0,0 +1,132 @@
+package app
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// validateAssessment checks the assessment of a task, only tasks of a
+// course can be assessed. An empty assessment is dropped.
+func validateAssessment(t *models.Task) error {
+	a := t.Assessment
+	if a == nil {
+		return nil
+	}
+
+	if a.Weight == 0 && a.MaxScore == 0 && a.Score == nil {
+		t.Assessment = nil
+		return nil
+	}
+
+	if t.CourseID == 0 {
+		return fmt.Errorf("only tasks of a course can be assessed")
+	}
+
+	if a.Weight < 0 || a.Weight > 100 {
+		return fmt.Errorf("invalid assessment weight %v, expected 0 to 100 percent", a.Weight)
+	}
+
+	if a.MaxScore <= 0 {
+		return fmt.Errorf("invalid max score %v, expected more than 0", a.MaxScore)
+	}
+
+	if a.Score != nil && (*a.Score < 0 || *a.Score > a.MaxScore) {
+		return fmt.Errorf("invalid score %v, expected 0 to %v", *a.Score, a.MaxScore)
+	}
+
+	return nil
+}
+
+// round2 rounds a grade to 2 decimal places
+func round2(f float64) *float64 {
+	r := math.Round(f*100) / 100
+	return &r
+}
+
+// GetCourseGrades works out the grades of a course from the assessed tasks
+// of it. The projected final grade takes the ungraded assessments to score
+// the current average. target is the final grade to aim for, nil for none.
+func (a *app) GetCourseGrades(userId, courseId int64, target *float64) (models.CourseGrades, error) {
+	if target != nil && (*target < 0 || *target > 100) {
+		return models.CourseGrades{}, fmt.Errorf("invalid target %v, expected 0 to 100 percent", *target)
+	}
+
+	if _, err := a.repo.GetCourse(userId, courseId); err != nil {
+		return models.CourseGrades{}, fmt.Errorf("error getting course from database: %w", err)
+	}
+
+	tasks, err := a.repo.GetTasks(userId)
+	if err != nil {
+		return models.CourseGrades{}, fmt.Errorf("error getting tasks from database: %v", err)
+	}
+
+	grades := models.CourseGrades{
+		CourseID: courseId,
+		Target:   target,
+		Items:    []models.GradeItem{},
+	}
+
+	// percentage points of the graded assessments, by weight
+	var earned float64
+	for _, t := range tasks {
+		if t.CourseID != courseId || t.Assessment == nil {
+			continue
+		}
+
+		item := models.GradeItem{
+			TaskID:   t.ID,
+			Name:     t.Name,
+			TimeDue:  t.TimeDue,
+			Weight:   t.Assessment.Weight,
+			MaxScore: t.Assessment.MaxScore,
+			Score:    t.Assessment.Score,
+		}
+
+		grades.TotalWeight += item.Weight
+		if item.Score != nil {
+			percentage := *item.Score / item.MaxScore * 100
+			item.Percentage = round2(percentage)
+			grades.GradedWeight += item.Weight
+			earned += item.Weight * percentage
+		}
+
+		grades.Items = append(grades.Items, item)
+	}
+
+	sort.SliceStable(grades.Items, func(i, j int) bool {
+		return grades.Items[i].TimeDue.Before(grades.Items[j].TimeDue)
+	})
+
+	ungradedWeight := grades.TotalWeight - grades.GradedWeight
+	if grades.GradedWeight > 0 {
+		average := earned / grades.GradedWeight
+		grades.CurrentAverage = round2(average)
+		grades.ProjectedFinal = round2((earned + ungradedWeight*average) / grades.TotalWeight)
+	}
+
+	if target == nil || grades.TotalWeight == 0 {
+		return grades, nil
+	}
+
+	if ungradedWeight == 0 {
+		achievable := earned/grades.TotalWeight >= *target
+		grades.Achievable = &achievable
+		return grades, nil
+	}
+
+	needed := math.Max((*target*grades.TotalWeight-earned)/ungradedWeight, 0)
+	achievable := needed <= 100
+	grades.Needed = round2(needed)
+	grades.Achievable = &achievable
+
+	for i, item := range grades.Items {
+		if item.Score == nil && item.Weight > 0 {
+			grades.Items[i].NeededScore = round2(needed / 100 * item.MaxScore)
+		}
+	}
+
+	return grades, nil
+}
@@ -0,0 +1,122 @@
+package app
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func addTestCourse(t *testing.T, a *app, userId int64) int64 {
+	t.Helper()
+
+	c := models.Course{Name: "Chemistry", Colour: "#336699"}
+	if err := a.AddCourse(userId, &c); err != nil {
+		t.Fatal(err)
+	}
+	return c.ID
+}
+
+func float(f float64) *float64 {
+	return &f
+}
+
+func formatFloat(f *float64) string {
+	if f == nil {
+		return "nil"
+	}
+	return fmt.Sprint(*f)
+}
+
+func TestGetCourseGrades(t *testing.T) {
+	// a quiz at 80%, a lab at 60% and an ungraded final out of 20
+	graded := []models.Assessment{
+		{Weight: 20, MaxScore: 50, Score: float(40)},
+		{Weight: 30, MaxScore: 100, Score: float(60)},
+		{Weight: 50, MaxScore: 20},
+	}
+	yes, no := true, false
+
+	tests := []struct {
+		name        string
+		assessments []models.Assessment
+		target      *float64
+		average     *float64
+		projected   *float64
+		needed      *float64
+		achievable  *bool
+		finalScore  *float64
+	}{
+		{"no target", graded, nil, float(68), float(68), nil, nil, nil},
+		{"target", graded, float(70), float(68), float(68), float(72), &yes, float(14.4)},
+		{"target out of reach", graded, float(95), float(68), float(68), float(122), &no, float(24.4)},
+		{"target already met", graded, float(30), float(68), float(68), float(0), &yes, float(0)},
+		{
+			"nothing graded", []models.Assessment{{Weight: 40, MaxScore: 10}, {Weight: 60, MaxScore: 20}},
+			float(70), nil, nil, float(70), &yes, float(14),
+		},
+		{
+			"everything graded", []models.Assessment{{Weight: 50, MaxScore: 3, Score: float(1)}, {Weight: 50, MaxScore: 3, Score: float(3)}},
+			float(70), float(66.67), float(66.67), nil, &no, nil,
+		},
+		{
+			"weights under 100", []models.Assessment{{Weight: 10, MaxScore: 10, Score: float(9)}, {Weight: 10, MaxScore: 10}},
+			float(50), float(90), float(90), float(10), &yes, float(1),
+		},
+	}
+
+	for _, tt := range tests {
+		a, userId := newTestApp(t)
+		courseId := addTestCourse(t, a, userId)
+
+		for i := range tt.assessments {
+			task := models.Task{
+				Name:        fmt.Sprintf("Assessment %d", i+1),
+				Tag:         "study",
+				Description: "Graded",
+				TimeDue:     time.Now().AddDate(0, 0, i+1),
+				CourseID:    courseId,
+				Assessment:  &tt.assessments[i],
+			}
+			if err := a.AddTask(userId, &task); err != nil {
+				t.Fatal(err)
+			}
+		}
+
+		grades, err := a.GetCourseGrades(userId, courseId, tt.target)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for _, f := range []struct {
+			field     string
+			got, want *float64
+		}{
+			{"current average", grades.CurrentAverage, tt.average},
+			{"projected final", grades.ProjectedFinal, tt.projected},
+			{"needed", grades.Needed, tt.needed},
+			{"needed score of the last", grades.Items[len(grades.Items)-1].NeededScore, tt.finalScore},
+		} {
+			if formatFloat(f.got) != formatFloat(f.want) {
+				t.Errorf("%s: got %s %s, want %s", tt.name, f.field, formatFloat(f.got), formatFloat(f.want))
+			}
+		}
+
+		if (grades.Achievable == nil) != (tt.achievable == nil) ||
+			(grades.Achievable != nil && *grades.Achievable != *tt.achievable) {
+			t.Errorf("%s: got achievable %v, want %v", tt.name, grades.Achievable, tt.achievable)
+		}
+	}
+}
+
+func TestGetCourseGradesInvalidTarget(t *testing.T) {
+	a, userId := newTestApp(t)
+	courseId := addTestCourse(t, a, userId)
+
+	for _, target := range []float64{-1, 100.5} {
+		if _, err := a.GetCourseGrades(userId, courseId, &target); err == nil {
+			t.Errorf("target %v: got no error", target)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// recordRevision adds a revision to the task's history and sends the
+// change to the user's live streams. old is the task before the change,
+// it's nil for actions that don't change its fields (create, delete and
+// restore). Updates that leave every field as it was aren't recorded.
+func (a *app) recordRevision(userId int64, action string, old *models.Task, t models.Task) error {
+	changes := make(map[string]models.FieldChange)
+	if old != nil {
+		changes = taskChanges(*old, t)
+		if action == "update" && len(changes) == 0 {
+			return nil
+		}
+		if action == "update" && t.IsCompleted && !old.IsCompleted {
+			action = "complete"
+		}
+	}
+
+	rev := models.TaskRevision{
+		TaskID:      t.ID,
+		UserID:      userId,
+		Action:      action,
+		Changes:     changes,
+		Task:        t,
+		TimeCreated: time.Now(),
+	}
+
+	if _, err := a.repo.AddTaskRevision(rev); err != nil {
+		return fmt.Errorf("error adding task revision to database: %v", err)
+	}
+
+	if action == "complete" {
+		a.recordCompletion(userId, t)
+	}
+
+	switch action {
+	case "create", "restore":
+		a.publishTask(userId, EventTaskCreated, t)
+	case "delete":
+		a.publishTask(userId, EventTaskDeleted, t)
+	default:
+		a.publishTask(userId, EventTaskUpdated, t)
+	}
+	return nil
+}
+
+// taskChanges lists the fields that differ between two versions of a task
+func taskChanges(old, t models.Task) map[string]models.FieldChange {
+	changes := make(map[string]models.FieldChange)
+	diff := func(field string, changed bool, from, to interface{}) {
+		if changed {
+			changes[field] = models.FieldChange{Old: from, New: to}
+		}
+	}
+
+	diff("name", old.Name != t.Name, old.Name, t.Name)
+	diff("description", old.Description != t.Description, old.Description, t.Description)
+	diff("tag", old.Tag != t.Tag, old.Tag, t.Tag)
+	diff("course_id", old.CourseID != t.CourseID, old.CourseID, t.CourseID)
+	diff("estimate_minutes", old.EstimateMinutes != t.EstimateMinutes, old.EstimateMinutes, t.EstimateMinutes)
+	diff("assessment", !equalAssessments(old.Assessment, t.Assessment), old.Assessment, t.Assessment)
+	diff("priority", old.Priority != t.Priority, old.Priority, t.Priority)
+	diff("is_completed", old.IsCompleted != t.IsCompleted, old.IsCompleted, t.IsCompleted)
+	diff("time_due", !old.TimeDue.Equal(t.TimeDue), old.TimeDue, t.TimeDue)
+	diff("rrule", old.RRule != t.RRule, old.RRule, t.RRule)
+	diff("reminders", !equalOffsets(old.Reminders, t.Reminders), old.Reminders, t.Reminders)
+
+	return changes
+}
+
+func equalAssessments(a, b *models.Assessment) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	sameScore := a.Score == b.Score ||
+		(a.Score != nil && b.Score != nil && *a.Score == *b.Score)
+	return a.Weight == b.Weight && a.MaxScore == b.MaxScore && sameScore
+}
+
+func equalOffsets(a, b []int) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// GetTaskHistory returns the revisions of a task, newest first. The history
+// of a task in the trash can be read too.
+func (a *app) GetTaskHistory(userId, taskId int64) ([]models.TaskRevision, error) {
+	_, err := a.repo.GetTask(userId, taskId)
+	if errors.Is(err, ErrTaskNotFound) {
+		_, err = a.repo.GetTrashedTask(userId, taskId)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting task from database: %w", err)
+	}
+
+	revs, err := a.repo.GetTaskRevisions(taskId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting task history from database: %v", err)
+	}
+
+	return revs, nil
+}
+
+// RevertTask sets the task's fields back to how they were after the given
+// revision. The checklist isn't reverted, its items are edited on their own.
+func (a *app) RevertTask(userId, taskId, revisionId int64) (models.Task, error) {
+	old, err := a.repo.GetTask(userId, taskId)
+	if err != nil {
+		return models.Task{}, fmt.Errorf("error getting task from database: %w", err)
+	}
+
+	rev, err := a.repo.GetTaskRevision(taskId, revisionId)
+	if err != nil {
+		return models.Task{}, fmt.Errorf("error getting task revision from database: %w", err)
+	}
+
+	t := old
+	t.Name = rev.Task.Name
+	t.Description = rev.Task.Description
+	t.Tag = rev.Task.Tag
+	t.Priority = rev.Task.Priority
+	t.IsCompleted = rev.Task.IsCompleted
+	t.TimeDue = rev.Task.TimeDue
+	t.EstimateMinutes = rev.Task.EstimateMinutes
+	t.Reminders = rev.Task.Reminders
+	if t.Reminders == nil {
+		t.Reminders = []int{}
+	}
+
+	// the course is left as it is if it was deleted since
+	if a.checkCourse(userId, rev.Task.CourseID) == nil {
+		t.CourseID = rev.Task.CourseID
+	}
+
+	// and so is the assessment, which goes with the course
+	if t.CourseID == rev.Task.CourseID {
+		t.Assessment = rev.Task.Assessment
+	}
+
+	if err := validateTask(&t); err != nil {
+		return models.Task{}, err
+	}
+
+	if err := validateAssessment(&t); err != nil {
+		return models.Task{}, err
+	}
+
+	err = a.inTransaction(func(a *app) error {
+		if err := a.updateTask(userId, old, &t); err != nil {
+			return err
+		}
+		return a.recordRevision(userId, "revert", &old, t)
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+	return t, nil
+}
@@ -0,0 +1,102 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+	"github.com/redis/go-redis/v9"
+)
+
+var (
+	ErrIdempotencyKeyReused = errors.New("idempotency key was already used with a different request")
+	ErrRequestInProgress    = errors.New("a request with this idempotency key is still in progress")
+)
+
+// how long a key is held while its first request is handled, so a request
+// that never finishes doesn't block retries for good
+const idempotencyLockExpiry = time.Minute
+
+func idempotencyCacheKey(userId int64, key string) string {
+	return fmt.Sprintf("idempotency:%d:%s", userId, key)
+}
+
+// StartIdempotentRequest claims an idempotency key for a request. It
+// returns nil when the request should be handled, or the stored response
+// when the key was already used for the same request. Keys are per user.
+func (a *app) StartIdempotentRequest(userId int64, key, requestHash string) (*models.IdempotentResponse, error) {
+	cacheKey := idempotencyCacheKey(userId, key)
+
+	lock, err := json.Marshal(models.IdempotentResponse{RequestHash: requestHash})
+	if err != nil {
+		return nil, fmt.Errorf("error encoding idempotency key: %v", err)
+	}
+
+	// the key can expire between the two calls, try claiming it once more
+	for i := 0; i < 2; i++ {
+		claimed, err := a.cache.SetNX(ctx, cacheKey, lock, idempotencyLockExpiry).Result()
+		if err != nil {
+			return nil, fmt.Errorf("error claiming idempotency key: %v", err)
+		}
+		if claimed {
+			return nil, nil
+		}
+
+		stored, err := a.cache.Get(ctx, cacheKey).Bytes()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error getting idempotency key from cache: %v", err)
+		}
+
+		var resp models.IdempotentResponse
+		if err := json.Unmarshal(stored, &resp); err != nil {
+			return nil, fmt.Errorf("error decoding idempotency key: %v", err)
+		}
+
+		switch {
+		case resp.RequestHash != requestHash:
+			return nil, ErrIdempotencyKeyReused
+		case resp.Status == 0:
+			return nil, ErrRequestInProgress
+		}
+		return &resp, nil
+	}
+
+	return nil, ErrRequestInProgress
+}
+
+// SaveIdempotentResponse stores the response of a request claimed with
+// StartIdempotentRequest for replaying on retries.
+func (a *app) SaveIdempotentResponse(userId int64, key string, resp models.IdempotentResponse) error {
+	ttl, err := strconv.Atoi(os.Getenv("IDEMPOTENCY_KEY_TTL_HOURS"))
+	if err != nil {
+		ttl = 24 // default ttl if env isn't set
+	}
+
+	stored, err := json.Marshal(resp)
+	if err != nil {
+		return fmt.Errorf("error encoding idempotent response: %v", err)
+	}
+
+	if err := a.cache.Set(ctx, idempotencyCacheKey(userId, key), stored, time.Duration(ttl)*time.Hour).Err(); err != nil {
+		return fmt.Errorf("error caching idempotent response: %v", err)
+	}
+
+	return nil
+}
+
+// ReleaseIdempotencyKey frees a key whose request failed, so a retry is
+// handled again instead of replaying the failure.
+func (a *app) ReleaseIdempotencyKey(userId int64, key string) error {
+	if err := a.cache.Del(ctx, idempotencyCacheKey(userId, key)).Err(); err != nil {
+		return fmt.Errorf("error releasing idempotency key: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,309 @@
+package app
+
+import (
+	"crypto/sha256"
+	"encoding/csv"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/michaelcosj/stms/framework"
+	"github.com/michaelcosj/stms/models"
+)
+
+var importFields = []string{"name", "description", "tag", "priority", "time_due", "rrule", "uid"}
+
+// layouts tried for csv times when no time format is given
+var importTimeLayouts = []string{
+	time.RFC3339,
+	"2006-01-02T15:04:05",
+	"2006-01-02 15:04:05",
+	"2006-01-02T15:04",
+	"2006-01-02 15:04",
+	"2006-01-02",
+}
+
+// importEntry is a task read from an import file, or the reason it
+// couldn't be read
+type importEntry struct {
+	task models.Task
+	err  error
+	skip string
+}
+
+// ImportTasks creates tasks from an ics or csv file. Entries are checked
+// with the same rules as AddTask and ones whose uid was already imported
+// are skipped. On a dry run nothing is created.
+func (a *app) ImportTasks(userId int64, r io.Reader, opts models.ImportOptions) (models.ImportResult, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.ImportResult{}, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	var entries []importEntry
+	switch strings.ToLower(opts.Format) {
+	case "ics":
+		entries, err = readICalImport(r, loc)
+	case "csv":
+		entries, err = readCSVImport(r, loc, opts)
+	default:
+		return models.ImportResult{}, fmt.Errorf("invalid import format %q, expected ics or csv", opts.Format)
+	}
+	if err != nil {
+		return models.ImportResult{}, err
+	}
+
+	result := models.ImportResult{
+		DryRun:  opts.DryRun,
+		Created: []models.Task{},
+		Skipped: []models.ImportIssue{},
+		Failed:  []models.ImportIssue{},
+	}
+
+	seen := make(map[string]bool)
+	for i, e := range entries {
+		t := e.task
+		issue := models.ImportIssue{Entry: i + 1, UID: t.UID, Name: t.Name}
+
+		if e.skip != "" {
+			issue.Reason = e.skip
+			result.Skipped = append(result.Skipped, issue)
+			continue
+		}
+
+		if e.err == nil {
+			e.err = validateImport(&t)
+		}
+		if e.err != nil {
+			issue.Reason = e.err.Error()
+			result.Failed = append(result.Failed, issue)
+			continue
+		}
+
+		exists, err := a.repo.TaskUIDExists(userId, t.UID)
+		if err != nil {
+			return models.ImportResult{}, err
+		}
+		if exists || seen[t.UID] {
+			issue.Reason = "already imported"
+			result.Skipped = append(result.Skipped, issue)
+			continue
+		}
+		seen[t.UID] = true
+
+		if !opts.DryRun {
+			if err := a.AddTask(userId, &t); err != nil {
+				issue.Reason = err.Error()
+				result.Failed = append(result.Failed, issue)
+				continue
+			}
+		}
+
+		result.Created = append(result.Created, t)
+	}
+
+	return result, nil
+}
+
+// validateImport runs the checks AddTask would, so a dry run reports the
+// same failures as a real import
+func validateImport(t *models.Task) error {
+	if err := validateTask(t); err != nil {
+		return err
+	}
+
+	if t.RRule != "" {
+		if _, err := framework.ParseRRule(t.RRule); err != nil {
+			return fmt.Errorf("invalid recurrence rule: %v", err)
+		}
+		if t.TimeDue.IsZero() {
+			return fmt.Errorf("recurring task needs a due time")
+		}
+	}
+
+	return nil
+}
+
+// importTag keeps a valid tag and files anything else under "others"
+func importTag(tags ...string) string {
+	for _, tag := range tags {
+		switch tag = strings.ToLower(strings.TrimSpace(tag)); tag {
+		case "study", "work", "others":
+			return tag
+		}
+	}
+	return "others"
+}
+
+func readICalImport(r io.Reader, loc *time.Location) ([]importEntry, error) {
+	components, err := framework.ParseICal(r, loc)
+	if err != nil {
+		return nil, fmt.Errorf("error reading calendar: %v", err)
+	}
+
+	entries := make([]importEntry, 0, len(components))
+	for _, c := range components {
+		t := models.Task{
+			UID:         c.UID,
+			Name:        strings.TrimSpace(c.Summary),
+			Description: strings.TrimSpace(c.Description),
+			Tag:         importTag(c.Categories...),
+			Priority:    priorityFromICal(c.Priority),
+			TimeDue:     c.Due,
+			RRule:       c.RRule,
+		}
+
+		// events are deadlines at their start
+		if c.Kind == framework.ICalEvent || t.TimeDue.IsZero() {
+			t.TimeDue = c.Start
+		}
+
+		// calendar entries rarely have a description, the summary will do
+		if t.Description == "" {
+			t.Description = t.Name
+		}
+
+		if t.UID == "" {
+			t.UID = importUID(t)
+		}
+
+		e := importEntry{task: t}
+		switch c.Status {
+		case "COMPLETED", "CANCELLED":
+			e.skip = strings.ToLower(c.Status)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func priorityFromICal(p int) models.Priority {
+	switch {
+	case p <= 0:
+		return models.PriorityNone
+	case p <= 2:
+		return models.PriorityUrgent
+	case p <= 4:
+		return models.PriorityHigh
+	case p == 5:
+		return models.PriorityMedium
+	default:
+		return models.PriorityLow
+	}
+}
+
+func readCSVImport(r io.Reader, loc *time.Location, opts models.ImportOptions) ([]importEntry, error) {
+	cr := csv.NewReader(r)
+	cr.FieldsPerRecord = -1
+	cr.TrimLeadingSpace = true
+
+	header, err := cr.Read()
+	if err != nil {
+		return nil, fmt.Errorf("error reading csv header: %v", err)
+	}
+
+	columns := make(map[string]int)
+	for i, h := range header {
+		columns[strings.ToLower(strings.TrimSpace(h))] = i
+	}
+
+	// index of the column each task field is read from
+	fieldColumns := make(map[string]int)
+	for field, col := range opts.Mapping {
+		i, ok := columns[strings.ToLower(strings.TrimSpace(col))]
+		if !ok {
+			return nil, fmt.Errorf("mapped column %q for %s not in csv header", col, field)
+		}
+		fieldColumns[field] = i
+	}
+	for _, field := range importFields {
+		if _, ok := fieldColumns[field]; !ok {
+			if i, ok := columns[field]; ok {
+				fieldColumns[field] = i
+			}
+		}
+	}
+
+	if _, ok := fieldColumns["name"]; !ok {
+		return nil, fmt.Errorf("csv has no name column")
+	}
+
+	var entries []importEntry
+	for {
+		record, err := cr.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error reading csv: %v", err)
+		}
+
+		get := func(field string) string {
+			if i, ok := fieldColumns[field]; ok && i < len(record) {
+				return strings.TrimSpace(record[i])
+			}
+			return ""
+		}
+
+		e := importEntry{task: models.Task{
+			UID:         get("uid"),
+			Name:        get("name"),
+			Description: get("description"),
+			Tag:         get("tag"),
+			RRule:       get("rrule"),
+		}}
+
+		if e.task.Tag == "" {
+			e.task.Tag = "others"
+		}
+
+		if e.task.Description == "" {
+			e.task.Description = e.task.Name
+		}
+
+		if p := get("priority"); p != "" {
+			e.task.Priority, e.err = models.ParsePriority(p)
+		}
+
+		if due := get("time_due"); due != "" && e.err == nil {
+			e.task.TimeDue, e.err = parseImportTime(due, opts.TimeFormat, loc)
+		}
+
+		if e.task.UID == "" {
+			e.task.UID = importUID(e.task)
+		}
+
+		entries = append(entries, e)
+	}
+
+	return entries, nil
+}
+
+func parseImportTime(value, layout string, loc *time.Location) (time.Time, error) {
+	if layout != "" {
+		return time.ParseInLocation(layout, value, loc)
+	}
+
+	for _, layout := range importTimeLayouts {
+		if t, err := time.ParseInLocation(layout, value, loc); err == nil {
+			return t, nil
+		}
+	}
+
+	return time.Time{}, fmt.Errorf("invalid time %q, set a time format for the import", value)
+}
+
+// importUID makes a uid for entries that don't have one, from their name
+// and due time, so importing the same file again skips them
+func importUID(t models.Task) string {
+	sum := sha256.Sum256([]byte(t.Name + "|" + t.TimeDue.UTC().Format(time.RFC3339)))
+	return "import-" + hex.EncodeToString(sum[:8]) + "@stms"
+}
@@ -0,0 +1,134 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/michaelcosj/stms/framework"
+	"github.com/michaelcosj/stms/models"
+)
+
+const (
+	// emails delivered per run of the outbox worker
+	outboxBatchSize = 50
+	// how long an email is held by the worker sending it
+	outboxLease = 5 * time.Minute
+	// retry delays double from outboxBaseDelay up to outboxMaxDelay
+	outboxBaseDelay = 30 * time.Second
+	outboxMaxDelay  = 6 * time.Hour
+)
+
+func newOutboxEmail(to string, data framework.EmailData) models.OutboxEmail {
+	now := time.Now()
+	return models.OutboxEmail{
+		Recipient:   to,
+		Template:    data.Template,
+		Subject:     data.Subject,
+		Code:        data.Code,
+		Message:     data.Message,
+		Fields:      data.Fields,
+		NextAttempt: now,
+		TimeCreated: now,
+	}
+}
+
+// queueEmail adds an email to the outbox for the worker to deliver.
+func (a *app) queueEmail(to string, data framework.EmailData) error {
+	if _, err := a.repo.AddOutboxEmail(newOutboxEmail(to, data)); err != nil {
+		return fmt.Errorf("error queueing email: %v", err)
+	}
+	return nil
+}
+
+// outboxBackoff returns the delay before the next delivery attempt
+func outboxBackoff(attempts int) time.Duration {
+	delay := outboxBaseDelay
+	for i := 1; i < attempts && delay < outboxMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > outboxMaxDelay {
+		delay = outboxMaxDelay
+	}
+	return delay
+}
+
+// DeliverOutbox sends the queued emails that are due. Failed emails are
+// retried with exponential backoff and marked dead after
+// OUTBOX_MAX_ATTEMPTS tries.
+func (a *app) DeliverOutbox() error {
+	maxAttempts, err := strconv.Atoi(os.Getenv("OUTBOX_MAX_ATTEMPTS"))
+	if err != nil {
+		maxAttempts = 8 // default attempts if env isn't set
+	}
+
+	now := time.Now()
+	emails, err := a.repo.GetDueOutboxEmails(now, outboxBatchSize)
+	if err != nil {
+		return fmt.Errorf("error getting due emails: %v", err)
+	}
+
+	var errs []error
+	for _, e := range emails {
+		leased, err := a.repo.LeaseOutboxEmail(e.ID, now, now.Add(outboxLease))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !leased {
+			continue
+		}
+
+		sendErr := framework.SendEmail(e.Recipient, framework.EmailData{
+			Template: e.Template,
+			Subject:  e.Subject,
+			Code:     e.Code,
+			Message:  e.Message,
+			Fields:   e.Fields,
+		})
+
+		if sendErr == nil {
+			if err := a.repo.MarkOutboxEmailSent(e.ID, time.Now()); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		attempts := e.Attempts + 1
+		dead := attempts >= maxAttempts
+		next := time.Now().Add(outboxBackoff(attempts))
+		if err := a.repo.MarkOutboxEmailFailed(e.ID, attempts, sendErr.Error(), next, dead); err != nil {
+			errs = append(errs, err)
+		}
+		errs = append(errs, fmt.Errorf("error delivering email %d: %v", e.ID, sendErr))
+	}
+
+	return errors.Join(errs...)
+}
+
+func (a *app) GetOutboxEmails(status string) ([]models.OutboxEmail, error) {
+	switch status {
+	case "":
+		status = "dead"
+	case "pending", "sent", "dead":
+	default:
+		return nil, fmt.Errorf("invalid status %s, expected pending, sent or dead", status)
+	}
+
+	emails, err := a.repo.GetOutboxEmails(status, 100)
+	if err != nil {
+		return nil, fmt.Errorf("error getting emails from database: %v", err)
+	}
+
+	return emails, nil
+}
+
+// ReplayEmail queues a dead email for delivery again.
+func (a *app) ReplayEmail(emailId int64) error {
+	if err := a.repo.ReplayOutboxEmail(emailId, time.Now()); err != nil {
+		return fmt.Errorf("error replaying email: %w", err)
+	}
+	return nil
+}
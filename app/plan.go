@@ -0,0 +1,416 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+const (
+	// longest estimate a task can have, a week of work
+	maxEstimateMinutes = 7 * 24 * 60
+
+	// days a plan covers when none are given and the most it can cover
+	defaultPlanDays = 7
+	maxPlanDays     = 28
+
+	// shortest and longest block of work on a task
+	minPlanBlock = 15 * time.Minute
+	maxPlanBlock = 2 * time.Hour
+	// break kept after a block
+	planBreak = 15 * time.Minute
+	// a task is planned as if it were due this much earlier for each
+	// priority level it has above none
+	priorityLead = 12 * time.Hour
+)
+
+func validateEstimate(minutes int) error {
+	if minutes < 0 || minutes > maxEstimateMinutes {
+		return fmt.Errorf("invalid estimate %d, expected 0 to %d minutes", minutes, maxEstimateMinutes)
+	}
+	return nil
+}
+
+// validateAvailability checks the user's windows, windows on the same day
+// can't overlap.
+func validateAvailability(windows []models.AvailabilityWindow) error {
+	type span struct{ start, end int }
+	byDay := make(map[string][]span)
+
+	for i := range windows {
+		w := &windows[i]
+		w.Day = strings.ToLower(w.Day)
+		if _, ok := weekdays[w.Day]; !ok {
+			return fmt.Errorf("invalid day %q, expected a weekday like monday", w.Day)
+		}
+
+		start, err := parseClock(w.StartTime)
+		if err != nil {
+			return fmt.Errorf("invalid start time: %v", err)
+		}
+
+		end, err := parseClock(w.EndTime)
+		if err != nil {
+			return fmt.Errorf("invalid end time: %v", err)
+		}
+
+		if end <= start {
+			return fmt.Errorf("window must end after it starts")
+		}
+
+		for _, s := range byDay[w.Day] {
+			if start < s.end && s.start < end {
+				return fmt.Errorf("windows on %s overlap", w.Day)
+			}
+		}
+		byDay[w.Day] = append(byDay[w.Day], span{start, end})
+	}
+
+	return nil
+}
+
+// GetAvailability returns the weekly windows the user is available to work
+// in. Without any, plans use the study hours of every day.
+func (a *app) GetAvailability(userId int64) ([]models.AvailabilityWindow, error) {
+	windows, err := a.repo.GetAvailability(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting availability from database: %v", err)
+	}
+
+	if windows == nil {
+		windows = []models.AvailabilityWindow{}
+	}
+	return windows, nil
+}
+
+// SetAvailability replaces the user's availability windows.
+func (a *app) SetAvailability(userId int64, windows []models.AvailabilityWindow) ([]models.AvailabilityWindow, error) {
+	if err := validateAvailability(windows); err != nil {
+		return nil, err
+	}
+
+	if err := a.repo.SetAvailability(userId, windows); err != nil {
+		return nil, fmt.Errorf("error setting availability: %v", err)
+	}
+
+	return a.GetAvailability(userId)
+}
+
+// freeTime returns the times between from and to the user is available and
+// has nothing else on, in order
+func freeTime(days []time.Time, windows []models.AvailabilityWindow, from time.Time, busy []interval) []interval {
+	var free []interval
+	for _, day := range days {
+		var open []interval
+		for _, w := range windows {
+			if weekdays[w.Day] != day.Weekday() {
+				continue
+			}
+
+			start, _ := parseClock(w.StartTime)
+			end, _ := parseClock(w.EndTime)
+			open = append(open, interval{atClock(day, start), atClock(day, end)})
+		}
+
+		if len(windows) == 0 {
+			open = append(open, interval{atClock(day, studyDayStart), atClock(day, studyDayEnd)})
+		}
+
+		sort.Slice(open, func(i, j int) bool {
+			return open[i].start.Before(open[j].start)
+		})
+
+		for _, o := range open {
+			if o.start.Before(from) {
+				o.start = from
+			}
+			if o.start.Before(o.end) {
+				free = append(free, o)
+			}
+		}
+	}
+
+	for _, b := range busy {
+		var left []interval
+		for _, f := range free {
+			if !f.start.Before(b.end) || !b.start.Before(f.end) {
+				left = append(left, f)
+				continue
+			}
+			if f.start.Before(b.start) {
+				left = append(left, interval{f.start, b.start})
+			}
+			if b.end.Before(f.end) {
+				left = append(left, interval{b.end, f.end})
+			}
+		}
+		free = left
+	}
+
+	return free
+}
+
+// planTask is a task being planned, due is its deadline with its priority
+// taken into account
+type planTask struct {
+	task models.Task
+	left time.Duration
+	due  time.Time
+}
+
+// PlanTasks plans blocks of work on the user's uncompleted tasks that have
+// an estimate, over days days from the from date. Tasks are planned
+// earliest deadline first, a higher priority counting as an earlier
+// deadline, in the free time of the user's availability windows around
+// their timetable, exams and the blocks of other tasks. Blocks already
+// worked count towards a task's estimate. Only the tasks of taskIds are
+// planned when it isn't empty. Accepting the plan replaces the blocks to
+// come of the planned tasks with its own.
+func (a *app) PlanTasks(userId int64, from string, days int, taskIds []int64, accept bool) (models.Plan, error) {
+	if days == 0 {
+		days = defaultPlanDays
+	}
+	if days < 1 || days > maxPlanDays {
+		return models.Plan{}, fmt.Errorf("invalid days %d, expected 1 to %d", days, maxPlanDays)
+	}
+
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.Plan{}, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	loc := location(user)
+	start, _, err := parseScheduleRange(from, "", loc)
+	if err != nil {
+		return models.Plan{}, err
+	}
+	end := start.AddDate(0, 0, days)
+
+	// blocks start on the quarter hour from now
+	now := time.Now().In(loc)
+	earliest := now.Truncate(studySlotStep).Add(studySlotStep)
+
+	windows, err := a.repo.GetAvailability(userId)
+	if err != nil {
+		return models.Plan{}, fmt.Errorf("error getting availability from database: %v", err)
+	}
+
+	tt, err := a.getTimetable(userId)
+	if err != nil {
+		return models.Plan{}, err
+	}
+
+	exams, err := a.repo.GetExams(userId)
+	if err != nil {
+		return models.Plan{}, fmt.Errorf("error getting exams from database: %v", err)
+	}
+
+	worked, err := a.repo.GetPastTimeBlockMinutes(userId, now)
+	if err != nil {
+		return models.Plan{}, fmt.Errorf("error getting time blocks from database: %v", err)
+	}
+
+	blocks, err := a.repo.GetTimeBlocks(userId, now, end)
+	if err != nil {
+		return models.Plan{}, fmt.Errorf("error getting time blocks from database: %v", err)
+	}
+
+	wanted := make(map[int64]bool)
+	for _, id := range taskIds {
+		wanted[id] = true
+	}
+
+	var tasks []*planTask
+	var plannedIds []int64
+	planned := make(map[int64]bool)
+	for _, t := range user.Tasks {
+		if t.IsCompleted || t.EstimateMinutes == 0 || tt.courses[t.CourseID].IsArchived {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[t.ID] {
+			continue
+		}
+
+		plannedIds = append(plannedIds, t.ID)
+		planned[t.ID] = true
+
+		left := time.Duration(t.EstimateMinutes-worked[t.ID]) * time.Minute
+		if left <= 0 {
+			continue
+		}
+
+		pt := &planTask{task: t, left: left}
+		if hasDueTime(t) {
+			pt.due = t.TimeDue.Add(-time.Duration(t.Priority) * priorityLead)
+		}
+		tasks = append(tasks, pt)
+	}
+
+	// earliest deadline first, tasks without one last by priority
+	sort.SliceStable(tasks, func(i, j int) bool {
+		ti, tj := tasks[i], tasks[j]
+		if ti.due.IsZero() != tj.due.IsZero() {
+			return !ti.due.IsZero()
+		}
+		if !ti.due.Equal(tj.due) {
+			return ti.due.Before(tj.due)
+		}
+		if ti.task.Priority != tj.task.Priority {
+			return ti.task.Priority > tj.task.Priority
+		}
+		return ti.task.ID < tj.task.ID
+	})
+
+	var planDays []time.Time
+	var busy []interval
+	for day := start; day.Before(end); day = day.AddDate(0, 0, 1) {
+		planDays = append(planDays, day)
+		for _, s := range tt.on(day) {
+			sessionStart, sessionEnd := sessionTimes(s, day)
+			busy = append(busy, interval{sessionStart, sessionEnd})
+		}
+	}
+	for _, e := range exams {
+		busy = append(busy, interval{e.Time.Add(-dueTaskBuffer), e.Time.Add(examLength)})
+	}
+	for _, b := range blocks {
+		if !planned[b.TaskID] || b.Start.Before(now) {
+			busy = append(busy, interval{b.Start, b.End.Add(planBreak)})
+		}
+	}
+
+	free := freeTime(planDays, windows, earliest, busy)
+
+	plan := models.Plan{
+		From:      start.Format(dateLayout),
+		To:        end.AddDate(0, 0, -1).Format(dateLayout),
+		Unplanned: []models.UnplannedTask{},
+	}
+
+	var newBlocks []models.TimeBlock
+	for _, pt := range tasks {
+		// overdue tasks are planned as soon as they can be
+		deadline := time.Time{}
+		if hasDueTime(pt.task) && pt.task.TimeDue.After(earliest) {
+			deadline = pt.task.TimeDue
+		}
+
+		for i := 0; i < len(free) && pt.left > 0; {
+			f := &free[i]
+			blockEnd := f.end
+			if !deadline.IsZero() && deadline.Before(blockEnd) {
+				blockEnd = deadline
+			}
+
+			length := blockEnd.Sub(f.start)
+			if length > pt.left {
+				length = pt.left
+			}
+			if length > maxPlanBlock {
+				length = maxPlanBlock
+			}
+
+			if length <= 0 || (length < minPlanBlock && length < pt.left) {
+				i++
+				continue
+			}
+
+			newBlocks = append(newBlocks, models.TimeBlock{
+				TaskID:   pt.task.ID,
+				TaskName: pt.task.Name,
+				Start:    f.start,
+				End:      f.start.Add(length),
+			})
+			pt.left -= length
+			f.start = f.start.Add(length + planBreak)
+			if !f.start.Before(f.end) {
+				i++
+			}
+		}
+
+		if pt.left > 0 {
+			reason := "not enough free time in the plan"
+			if !deadline.IsZero() && deadline.Before(end) {
+				reason = "not enough free time before it's due"
+			}
+
+			plan.Unplanned = append(plan.Unplanned, models.UnplannedTask{
+				TaskID:      pt.task.ID,
+				Name:        pt.task.Name,
+				MinutesLeft: int(pt.left.Round(time.Minute) / time.Minute),
+				Reason:      reason,
+			})
+		}
+	}
+
+	sort.SliceStable(newBlocks, func(i, j int) bool {
+		return newBlocks[i].Start.Before(newBlocks[j].Start)
+	})
+
+	if accept {
+		ids, err := a.repo.ReplaceTimeBlocks(userId, plannedIds, now, newBlocks)
+		if err != nil {
+			return models.Plan{}, fmt.Errorf("error accepting plan: %v", err)
+		}
+		for i := range newBlocks {
+			newBlocks[i].ID = ids[i]
+		}
+		plan.Accepted = true
+	}
+
+	dayIndex := make(map[string]int)
+	for _, day := range planDays {
+		dayIndex[day.Format(dateLayout)] = len(plan.Days)
+		plan.Days = append(plan.Days, models.PlanDay{
+			Date:   day.Format(dateLayout),
+			Blocks: []models.TimeBlock{},
+		})
+	}
+
+	for _, b := range newBlocks {
+		d := &plan.Days[dayIndex[b.Start.Format(dateLayout)]]
+		d.Blocks = append(d.Blocks, b)
+		d.Minutes += int(b.End.Sub(b.Start) / time.Minute)
+	}
+
+	return plan, nil
+}
+
+// GetTimeBlocks returns the accepted blocks overlapping the days from the
+// from date to the to date, a week from today by default.
+func (a *app) GetTimeBlocks(userId int64, from, to string) ([]models.TimeBlock, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	start, end, err := parseScheduleRange(from, to, location(user))
+	if err != nil {
+		return nil, err
+	}
+
+	blocks, err := a.repo.GetTimeBlocks(userId, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("error getting time blocks from database: %v", err)
+	}
+
+	if blocks == nil {
+		blocks = []models.TimeBlock{}
+	}
+	return blocks, nil
+}
+
+func (a *app) DeleteTimeBlock(userId, blockId int64) error {
+	if _, err := a.repo.GetTimeBlock(userId, blockId); err != nil {
+		return fmt.Errorf("error getting time block from database: %w", err)
+	}
+
+	if err := a.repo.DeleteTimeBlock(blockId); err != nil {
+		return fmt.Errorf("error deleting time block: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,119 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func TestFreeTime(t *testing.T) {
+	// a tuesday and a wednesday
+	days := []time.Time{
+		time.Date(2026, 10, 20, 0, 0, 0, 0, time.UTC),
+		time.Date(2026, 10, 21, 0, 0, 0, 0, time.UTC),
+	}
+	at := func(day, hour, min int) time.Time {
+		return time.Date(2026, 10, day, hour, min, 0, 0, time.UTC)
+	}
+	before := at(19, 0, 0)
+	tuesday := []models.AvailabilityWindow{
+		{Day: "tuesday", StartTime: "10:00", EndTime: "12:00"},
+		{Day: "tuesday", StartTime: "08:00", EndTime: "09:00"},
+	}
+
+	tests := []struct {
+		name    string
+		windows []models.AvailabilityWindow
+		from    time.Time
+		busy    []interval
+		want    []interval
+	}{
+		{"study hours without windows", nil, before, nil, []interval{
+			{at(20, 9, 0), at(20, 21, 0)}, {at(21, 9, 0), at(21, 21, 0)},
+		}},
+		{"windows in order", tuesday, before, nil, []interval{
+			{at(20, 8, 0), at(20, 9, 0)}, {at(20, 10, 0), at(20, 12, 0)},
+		}},
+		{"from cuts the windows", tuesday, at(20, 10, 30), nil, []interval{
+			{at(20, 10, 30), at(20, 12, 0)},
+		}},
+		{"busy splits a window", tuesday, before, []interval{{at(20, 10, 30), at(20, 11, 0)}}, []interval{
+			{at(20, 8, 0), at(20, 9, 0)}, {at(20, 10, 0), at(20, 10, 30)}, {at(20, 11, 0), at(20, 12, 0)},
+		}},
+		{"busy over the start and end", tuesday, before, []interval{{at(20, 7, 0), at(20, 8, 30)}, {at(20, 11, 0), at(20, 13, 0)}}, []interval{
+			{at(20, 8, 30), at(20, 9, 0)}, {at(20, 10, 0), at(20, 11, 0)},
+		}},
+		{"busy over a whole window", tuesday, before, []interval{{at(20, 7, 0), at(20, 9, 30)}}, []interval{
+			{at(20, 10, 0), at(20, 12, 0)},
+		}},
+		{"busy across days", nil, before, []interval{{at(20, 20, 0), at(21, 10, 0)}}, []interval{
+			{at(20, 9, 0), at(20, 20, 0)}, {at(21, 10, 0), at(21, 21, 0)},
+		}},
+	}
+
+	for _, tt := range tests {
+		if got := freeTime(days, tt.windows, tt.from, tt.busy); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestPlanTasks(t *testing.T) {
+	a, userId := newTestApp(t)
+
+	// a day far enough ahead that now doesn't cut into it
+	day := time.Now().UTC().AddDate(0, 0, 2)
+	at := func(hour, min int) time.Time {
+		return time.Date(day.Year(), day.Month(), day.Day(), hour, min, 0, 0, time.UTC)
+	}
+
+	add := func(name string, estimate int, due time.Time) models.Task {
+		task := models.Task{Name: name, Tag: "study", Description: name, EstimateMinutes: estimate, TimeDue: due}
+		if err := a.AddTask(userId, &task); err != nil {
+			t.Fatal(err)
+		}
+		return task
+	}
+	essay := add("Essay", 180, at(15, 0))
+	reading := add("Reading", 60, time.Time{})
+	quiz := add("Quiz prep", 120, at(10, 0))
+	add("No estimate", 0, at(12, 0))
+
+	plan, err := a.PlanTasks(userId, day.Format(dateLayout), 1, nil, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	type block struct {
+		taskId     int64
+		start, end time.Time
+	}
+	var got []block
+	for _, b := range plan.Days[0].Blocks {
+		if b.ID == 0 {
+			t.Error("accepted block has no id")
+		}
+		got = append(got, block{b.TaskID, b.Start.UTC(), b.End.UTC()})
+	}
+
+	// earliest deadline first, in blocks of at most 2 hours with breaks
+	want := []block{
+		{quiz.ID, at(9, 0), at(10, 0)},
+		{essay.ID, at(10, 15), at(12, 15)},
+		{essay.ID, at(12, 30), at(13, 30)},
+		{reading.ID, at(13, 45), at(14, 45)},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("got blocks %v, want %v", got, want)
+	}
+	if plan.Days[0].Minutes != 300 {
+		t.Errorf("got %d minutes planned, want 300", plan.Days[0].Minutes)
+	}
+
+	if len(plan.Unplanned) != 1 || plan.Unplanned[0].TaskID != quiz.ID || plan.Unplanned[0].MinutesLeft != 60 ||
+		plan.Unplanned[0].Reason != "not enough free time before it's due" {
+		t.Errorf("got unplanned %+v, want an hour of the quiz prep", plan.Unplanned)
+	}
+}
@@ -0,0 +1,268 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/framework"
+	"github.com/michaelcosj/stms/models"
+	"github.com/michaelcosj/stms/repository"
+)
+
+func seriesFromTask(t models.Task) models.TaskSeries {
+	return models.TaskSeries{
+		RRule:       t.RRule,
+		Name:        t.Name,
+		Tag:         t.Tag,
+		Priority:    t.Priority,
+		Description: t.Description,
+	}
+}
+
+// validateSeries checks that t can start a recurring series and normalises
+// its rule
+func validateSeries(t *models.Task) error {
+	rule, err := framework.ParseRRule(t.RRule)
+	if err != nil {
+		return fmt.Errorf("invalid recurrence rule: %v", err)
+	}
+
+	if t.TimeDue.IsZero() {
+		return fmt.Errorf("recurring task needs a due time")
+	}
+
+	t.RRule = rule.String()
+	return nil
+}
+
+// startSeries makes t the first occurrence of a new recurring series
+func (a *app) startSeries(userId int64, t *models.Task) error {
+	if err := validateSeries(t); err != nil {
+		return err
+	}
+
+	seriesId, err := a.repo.AddTaskSeries(userId, seriesFromTask(*t))
+	if err != nil {
+		return fmt.Errorf("error adding task series to database: %v", err)
+	}
+
+	t.SeriesID = seriesId
+	t.Occurrence = 1
+	t.RecurrenceTime = t.TimeDue
+	return nil
+}
+
+// createNextOccurrence adds the occurrence following t to its series. ok is
+// false when the series has ended. If the next occurrence already exists,
+// say because t was completed before, it is returned instead. Occurrences
+// in the trash count as skipped, the one after them is used.
+func (a *app) createNextOccurrence(userId int64, t models.Task) (next models.Task, ok bool, err error) {
+	prev := t
+	for {
+		next, err = a.repo.GetSeriesOccurrence(t.SeriesID, prev.Occurrence+1)
+		if err == repository.ErrTaskNotFound {
+			break
+		} else if err != nil {
+			return models.Task{}, false, fmt.Errorf("error getting next occurrence from database: %v", err)
+		}
+
+		if next.TimeDeleted == nil {
+			return next, true, nil
+		}
+		prev = next
+	}
+
+	series, err := a.repo.GetTaskSeries(t.SeriesID)
+	if err != nil {
+		return models.Task{}, false, fmt.Errorf("error getting task series from database: %v", err)
+	}
+
+	if series.RRule == "" {
+		return models.Task{}, false, nil
+	}
+
+	rule, err := framework.ParseRRule(series.RRule)
+	if err != nil {
+		return models.Task{}, false, fmt.Errorf("invalid recurrence rule: %v", err)
+	}
+
+	if rule.Count > 0 && prev.Occurrence >= rule.Count {
+		return models.Task{}, false, nil
+	}
+
+	due, ok := rule.Next(prev.RecurrenceTime)
+	if !ok {
+		return models.Task{}, false, nil
+	}
+
+	next = models.Task{
+		Name:            series.Name,
+		Tag:             series.Tag,
+		Priority:        series.Priority,
+		Description:     series.Description,
+		TimeDue:         due,
+		TimeCreated:     time.Now(),
+		TimeCompleted:   time.Unix(0, 0).UTC(),
+		RRule:           series.RRule,
+		SeriesID:        t.SeriesID,
+		Occurrence:      prev.Occurrence + 1,
+		RecurrenceTime:  due,
+		Reminders:       t.Reminders,
+		CourseID:        t.CourseID,
+		EstimateMinutes: t.EstimateMinutes,
+		Version:         1,
+	}
+
+	next.ID, err = a.repo.AddTask(userId, next)
+	if err != nil {
+		return models.Task{}, false, fmt.Errorf("error adding next occurrence to database: %v", err)
+	}
+
+	if err := a.setTaskReminders(&next); err != nil {
+		return models.Task{}, false, err
+	}
+
+	// the checklist carries over, unticked
+	for _, item := range t.Items {
+		added := models.ChecklistItem{Name: item.Name}
+		if err := a.addChecklistItem(next.ID, &added); err != nil {
+			return models.Task{}, false, err
+		}
+		next.Items = append(next.Items, added)
+	}
+
+	next.UpdateProgress()
+	a.publishTask(userId, EventTaskCreated, next)
+	return next, true, nil
+}
+
+// SkipTaskOccurrence moves an uncompleted occurrence of a recurring task to
+// the trash if it's still at the given version and returns the occurrence
+// that replaces it, nil if the series has ended.
+func (a *app) SkipTaskOccurrence(userId, taskId, version int64) (*models.Task, error) {
+	t, err := a.getTaskVersion(userId, taskId, version)
+	if err != nil {
+		return nil, err
+	}
+
+	if t.SeriesID == 0 {
+		return nil, fmt.Errorf("task is not recurring")
+	}
+
+	if t.IsCompleted {
+		return nil, fmt.Errorf("task is already completed")
+	}
+
+	var next *models.Task
+	now := time.Now()
+	err = a.inTransaction(func(a *app) error {
+		n, ok, err := a.createNextOccurrence(userId, t)
+		if err != nil {
+			return err
+		}
+		if ok {
+			next = &n
+		}
+
+		if err := a.repo.TrashTask(taskId, t.Version, now); err != nil {
+			return fmt.Errorf("error removing task from database: %w", err)
+		}
+
+		t.Version++
+		t.TimeDeleted = &now
+		t.UpdateProgress()
+		return a.recordRevision(userId, "delete", nil, t)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return next, nil
+}
+
+// UpdateTaskSeries applies the changes to this occurrence of a recurring
+// task and all the ones after it. An empty rule ends the series after this
+// occurrence. Moving the due time moves the uncompleted occurrences that
+// were already created by the same amount, later ones follow the new time.
+func (a *app) UpdateTaskSeries(userId, taskId, version int64, t *models.Task) error {
+	old, err := a.getTaskVersion(userId, taskId, version)
+	if err != nil {
+		return err
+	}
+
+	if old.SeriesID == 0 {
+		return fmt.Errorf("task is not recurring")
+	}
+
+	if err := validateTask(t); err != nil {
+		return err
+	}
+
+	if err := a.checkCourse(userId, t.CourseID); err != nil {
+		return err
+	}
+
+	if t.Assessment == nil {
+		t.Assessment = old.Assessment
+	}
+	if err := validateAssessment(t); err != nil {
+		return err
+	}
+
+	if t.RRule != "" {
+		rule, err := framework.ParseRRule(t.RRule)
+		if err != nil {
+			return fmt.Errorf("invalid recurrence rule: %v", err)
+		}
+		t.RRule = rule.String()
+	}
+
+	t.SeriesID = old.SeriesID
+	t.Occurrence = old.Occurrence
+	t.RecurrenceTime = old.RecurrenceTime
+	if !t.TimeDue.Equal(old.TimeDue) {
+		t.RecurrenceTime = t.TimeDue
+	}
+
+	// the series, its occurrences and this one change together or not at all
+	series := seriesFromTask(*t)
+	return a.inTransaction(func(a *app) error {
+		if err := a.repo.UpdateTaskSeries(old.SeriesID, series); err != nil {
+			return fmt.Errorf("error updating task series in database: %v", err)
+		}
+
+		// read before this one is updated, completing it creates the next
+		// occurrence at the new time already
+		future, err := a.repo.GetFutureOccurrences(old.SeriesID, old.Occurrence)
+		if err != nil {
+			return err
+		}
+
+		if err := a.updateTask(userId, old, t); err != nil {
+			return err
+		}
+		if err := a.recordRevision(userId, "update", &old, *t); err != nil {
+			return err
+		}
+
+		shift := t.TimeDue.Sub(old.TimeDue)
+		for _, o := range future {
+			next := o
+			next.Name = series.Name
+			next.Tag = series.Tag
+			next.Priority = series.Priority
+			next.Description = series.Description
+			next.TimeDue = o.TimeDue.Add(shift)
+			next.RecurrenceTime = o.RecurrenceTime.Add(shift)
+			next.Reminders = nil
+
+			if err := a.updateTask(userId, o, &next); err != nil {
+				return err
+			}
+			if err := a.recordRevision(userId, "update", &o, next); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
@@ -0,0 +1,153 @@
+package app
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// addTestSeries adds a daily recurring task to the user's tasks
+func addTestSeries(t *testing.T, a *app, userId int64) models.Task {
+	t.Helper()
+
+	task := models.Task{
+		Name:        "Revise",
+		Tag:         "study",
+		Description: "Flashcards",
+		TimeDue:     time.Date(2026, 10, 19, 18, 0, 0, 0, time.UTC),
+		RRule:       "FREQ=DAILY",
+	}
+	if err := a.AddTask(userId, &task); err != nil {
+		t.Fatal(err)
+	}
+	return task
+}
+
+func TestSkipTaskOccurrence(t *testing.T) {
+	a, userId := newTestApp(t)
+	first := addTestSeries(t, a, userId)
+
+	if _, err := a.SkipTaskOccurrence(userId, first.ID, first.Version+1); !errors.Is(err, ErrVersionMismatch) {
+		t.Fatalf("got error %v for a stale version, want ErrVersionMismatch", err)
+	}
+
+	next, err := a.SkipTaskOccurrence(userId, first.ID, first.Version)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if next == nil || next.Occurrence != 2 || !next.TimeDue.Equal(first.TimeDue.AddDate(0, 0, 1)) {
+		t.Fatalf("got next occurrence %+v", next)
+	}
+
+	// the skipped occurrence is in the trash with its history
+	trashed, err := a.repo.GetTrashedTask(userId, first.ID)
+	if err != nil {
+		t.Fatalf("skipped occurrence isn't in the trash: %v", err)
+	}
+	if trashed.Version != first.Version+1 {
+		t.Errorf("got version %d, want %d", trashed.Version, first.Version+1)
+	}
+
+	revisions, err := a.repo.GetTaskRevisions(first.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) == 0 || revisions[0].Action != "delete" {
+		t.Errorf("got revisions %+v, want the skip recorded as a delete", revisions)
+	}
+}
+
+func TestUpdateTaskSeriesMovesFutureOccurrences(t *testing.T) {
+	a, userId := newTestApp(t)
+	first := addTestSeries(t, a, userId)
+
+	// completing and reopening the first occurrence leaves the second one
+	task := first
+	task.IsCompleted = true
+	if err := a.UpdateTask(userId, first.ID, task.Version, &task); err != nil {
+		t.Fatal(err)
+	}
+	task.IsCompleted = false
+	if err := a.UpdateTask(userId, first.ID, task.Version, &task); err != nil {
+		t.Fatal(err)
+	}
+	second, err := a.repo.GetSeriesOccurrence(first.SeriesID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	task.Name = "Revise chapter"
+	task.TimeDue = first.TimeDue.Add(-2 * time.Hour)
+	if err := a.UpdateTaskSeries(userId, first.ID, task.Version, &task); err != nil {
+		t.Fatal(err)
+	}
+
+	moved, err := a.repo.GetSeriesOccurrence(first.SeriesID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := second.TimeDue.Add(-2 * time.Hour); !moved.TimeDue.Equal(want) || moved.Name != "Revise chapter" {
+		t.Errorf("got second occurrence %q due %v, want %q due %v", moved.Name, moved.TimeDue, "Revise chapter", want)
+	}
+	if moved.Version != second.Version+1 {
+		t.Errorf("got version %d, want %d", moved.Version, second.Version+1)
+	}
+
+	revisions, err := a.repo.GetTaskRevisions(second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(revisions) == 0 || revisions[0].Action != "update" || revisions[0].Changes["time_due"].New == nil {
+		t.Errorf("got revisions %+v, want the move recorded", revisions)
+	}
+}
+
+func TestTrashedOccurrenceIsSkipped(t *testing.T) {
+	a, userId := newTestApp(t)
+	first := addTestSeries(t, a, userId)
+
+	task := first
+	task.IsCompleted = true
+	if err := a.UpdateTask(userId, first.ID, task.Version, &task); err != nil {
+		t.Fatal(err)
+	}
+	second, err := a.repo.GetSeriesOccurrence(first.SeriesID, 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := a.DeleteTask(userId, second.ID, second.Version); err != nil {
+		t.Fatal(err)
+	}
+
+	// completing the first one again goes past the trashed second one
+	task.IsCompleted = false
+	if err := a.UpdateTask(userId, first.ID, task.Version, &task); err != nil {
+		t.Fatal(err)
+	}
+	task.IsCompleted = true
+	if err := a.UpdateTask(userId, first.ID, task.Version, &task); err != nil {
+		t.Fatal(err)
+	}
+
+	third, err := a.repo.GetSeriesOccurrence(first.SeriesID, 3)
+	if err != nil {
+		t.Fatalf("third occurrence wasn't created: %v", err)
+	}
+	if want := first.TimeDue.AddDate(0, 0, 2); !third.TimeDue.Equal(want) {
+		t.Errorf("got third occurrence due %v, want %v", third.TimeDue, want)
+	}
+
+	// and restoring the second one leaves one of each
+	if _, err := a.RestoreTask(userId, second.ID); err != nil {
+		t.Fatal(err)
+	}
+	tasks, err := a.repo.GetTasks(userId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(tasks) != 3 {
+		t.Errorf("got %d tasks, want the three occurrences", len(tasks))
+	}
+}
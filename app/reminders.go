@@ -0,0 +1,139 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/michaelcosj/stms/framework"
+	"github.com/michaelcosj/stms/models"
+)
+
+// reminders can be set up to four weeks before the due time
+const maxReminderOffset = 4 * 7 * 24 * 60
+
+// validateReminders checks the reminder offsets and returns them sorted
+// without duplicates
+func validateReminders(reminders []int) ([]int, error) {
+	seen := make(map[int]bool)
+	offsets := []int{}
+	for _, offset := range reminders {
+		if offset < 1 || offset > maxReminderOffset {
+			return nil, fmt.Errorf("invalid reminder offset %d, expected 1 to %d minutes", offset, maxReminderOffset)
+		}
+		if !seen[offset] {
+			seen[offset] = true
+			offsets = append(offsets, offset)
+		}
+	}
+	sort.Ints(offsets)
+
+	return offsets, nil
+}
+
+func (a *app) setTaskReminders(t *models.Task) error {
+	offsets, err := validateReminders(t.Reminders)
+	if err != nil {
+		return err
+	}
+
+	if err := a.repo.SetTaskReminders(t.ID, offsets); err != nil {
+		return fmt.Errorf("error setting task reminders in database: %v", err)
+	}
+
+	t.Reminders = offsets
+	return nil
+}
+
+// SendDueReminders queues emails for the reminders that are due and haven't
+// been sent yet. Reminders falling in the user's quiet hours are held back
+// until they're over.
+func (a *app) SendDueReminders() error {
+	grace, err := strconv.Atoi(os.Getenv("OVERDUE_REMINDER_GRACE_HOURS"))
+	if err != nil {
+		grace = 24 // default grace period if env isn't set
+	}
+
+	now := time.Now()
+	reminders, err := a.repo.GetPendingReminders(now, time.Duration(grace)*time.Hour)
+	if err != nil {
+		return fmt.Errorf("error getting pending reminders: %v", err)
+	}
+
+	var errs []error
+	for _, r := range reminders {
+		if inQuietHours(now, r.Timezone, r.QuietHoursStart, r.QuietHoursEnd) {
+			continue
+		}
+
+		email := newOutboxEmail(r.Email, reminderEmail(r))
+		if _, err := a.repo.ClaimReminder(r, now, email); err != nil {
+			errs = append(errs, fmt.Errorf("error queueing reminder for task %d: %v", r.TaskID, err))
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+func reminderEmail(r models.Reminder) framework.EmailData {
+	due := r.TimeDue
+	if loc, err := time.LoadLocation(r.Timezone); err == nil {
+		due = due.In(loc)
+	}
+
+	if r.Kind == "overdue" {
+		return framework.EmailData{
+			Template: framework.TemplateReminder,
+			Subject:  fmt.Sprintf("Overdue: %s", r.TaskName),
+			Message:  fmt.Sprintf("%s was due %s.", r.TaskName, due.Format(time.RFC1123)),
+		}
+	}
+
+	return framework.EmailData{
+		Template: framework.TemplateReminder,
+		Subject:  fmt.Sprintf("Reminder: %s", r.TaskName),
+		Message:  fmt.Sprintf("%s is due %s.", r.TaskName, due.Format(time.RFC1123)),
+	}
+}
+
+// inQuietHours reports whether now falls between the "15:04" start and end
+// times in the given timezone. The range may wrap around midnight.
+func inQuietHours(now time.Time, timezone, start, end string) bool {
+	if start == "" || end == "" {
+		return false
+	}
+
+	loc, err := time.LoadLocation(timezone)
+	if err != nil {
+		loc = time.UTC
+	}
+
+	startMin, err := parseClock(start)
+	if err != nil {
+		return false
+	}
+	endMin, err := parseClock(end)
+	if err != nil {
+		return false
+	}
+
+	local := now.In(loc)
+	min := local.Hour()*60 + local.Minute()
+
+	if startMin <= endMin {
+		return min >= startMin && min < endMin
+	}
+	return min >= startMin || min < endMin
+}
+
+// parseClock returns the minutes since midnight of a "15:04" time
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -0,0 +1,461 @@
+package app
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// longest range of days a schedule can be asked for
+const maxScheduleDays = 92
+
+var weekdays = map[string]time.Weekday{
+	"sunday":    time.Sunday,
+	"monday":    time.Monday,
+	"tuesday":   time.Tuesday,
+	"wednesday": time.Wednesday,
+	"thursday":  time.Thursday,
+	"friday":    time.Friday,
+	"saturday":  time.Saturday,
+}
+
+func validateSession(s *models.TimetableSession) error {
+	s.Day = strings.ToLower(s.Day)
+	if _, ok := weekdays[s.Day]; !ok {
+		return fmt.Errorf("invalid day %q, expected a weekday like monday", s.Day)
+	}
+
+	start, err := parseClock(s.StartTime)
+	if err != nil {
+		return fmt.Errorf("invalid start time: %v", err)
+	}
+
+	end, err := parseClock(s.EndTime)
+	if err != nil {
+		return fmt.Errorf("invalid end time: %v", err)
+	}
+
+	if end <= start {
+		return fmt.Errorf("session must end after it starts")
+	}
+
+	if s.Weeks == "" {
+		s.Weeks = "all"
+	}
+
+	switch s.Weeks {
+	case "all", "odd", "even":
+	default:
+		return fmt.Errorf("invalid weeks %q, expected all, odd or even", s.Weeks)
+	}
+
+	return nil
+}
+
+func (a *app) AddTimetableSession(userId int64, s *models.TimetableSession) error {
+	if err := validateSession(s); err != nil {
+		return err
+	}
+
+	if _, err := a.repo.GetCourse(userId, s.CourseID); err != nil {
+		return fmt.Errorf("error getting course %d: %v", s.CourseID, err)
+	}
+
+	s.TimeCreated = time.Now()
+	sessionId, err := a.repo.AddTimetableSession(userId, *s)
+	if err != nil {
+		return fmt.Errorf("error adding timetable session to database: %v", err)
+	}
+
+	s.ID = sessionId
+	return nil
+}
+
+// GetTimetable returns the user's weekly sessions, only the ones of a
+// course when courseId isn't 0.
+func (a *app) GetTimetable(userId, courseId int64) ([]models.TimetableSession, error) {
+	sessions, err := a.repo.GetTimetableSessions(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting timetable from database: %v", err)
+	}
+
+	if courseId == 0 {
+		return sessions, nil
+	}
+
+	var filtered []models.TimetableSession
+	for _, s := range sessions {
+		if s.CourseID == courseId {
+			filtered = append(filtered, s)
+		}
+	}
+
+	return filtered, nil
+}
+
+func (a *app) UpdateTimetableSession(userId, sessionId int64, update models.TimetableSessionUpdate) (models.TimetableSession, error) {
+	s, err := a.repo.GetTimetableSession(userId, sessionId)
+	if err != nil {
+		return models.TimetableSession{}, fmt.Errorf("error getting timetable session from database: %w", err)
+	}
+
+	if update.CourseID != nil {
+		s.CourseID = *update.CourseID
+	}
+	if update.Day != nil {
+		s.Day = *update.Day
+	}
+	if update.StartTime != nil {
+		s.StartTime = *update.StartTime
+	}
+	if update.EndTime != nil {
+		s.EndTime = *update.EndTime
+	}
+	if update.Location != nil {
+		s.Location = *update.Location
+	}
+	if update.Kind != nil {
+		s.Kind = *update.Kind
+	}
+	if update.Weeks != nil {
+		s.Weeks = *update.Weeks
+	}
+
+	if err := validateSession(&s); err != nil {
+		return models.TimetableSession{}, err
+	}
+
+	if _, err := a.repo.GetCourse(userId, s.CourseID); err != nil {
+		return models.TimetableSession{}, fmt.Errorf("error getting course %d: %v", s.CourseID, err)
+	}
+
+	if err := a.repo.UpdateTimetableSession(sessionId, s); err != nil {
+		return models.TimetableSession{}, fmt.Errorf("error updating timetable session: %v", err)
+	}
+
+	return s, nil
+}
+
+func (a *app) DeleteTimetableSession(userId, sessionId int64) error {
+	if _, err := a.repo.GetTimetableSession(userId, sessionId); err != nil {
+		return fmt.Errorf("error getting timetable session from database: %w", err)
+	}
+
+	if err := a.repo.DeleteTimetableSession(sessionId); err != nil {
+		return fmt.Errorf("error deleting timetable session: %v", err)
+	}
+
+	return nil
+}
+
+// courseTerm returns the dates a course runs between, its own or else its
+// semester's. Either can be empty when they aren't known.
+func courseTerm(c models.Course, semesters map[int64]models.Semester) (string, string) {
+	if c.StartDate != "" {
+		return c.StartDate, c.EndDate
+	}
+
+	s := semesters[c.SemesterID]
+	return s.StartDate, s.EndDate
+}
+
+// daysBetween counts the calendar days from a to b
+func daysBetween(a, b time.Time) int {
+	a = time.Date(a.Year(), a.Month(), a.Day(), 0, 0, 0, 0, time.UTC)
+	b = time.Date(b.Year(), b.Month(), b.Day(), 0, 0, 0, 0, time.UTC)
+	return int(b.Sub(a).Hours() / 24)
+}
+
+// termWeek returns the week of the term a day falls in, starting at 1 for
+// the week, from monday, the term starts in. Without a term start it's the
+// iso week.
+func termWeek(day time.Time, termStart string) int {
+	start, err := time.Parse(dateLayout, termStart)
+	if err != nil {
+		_, week := day.ISOWeek()
+		return week
+	}
+
+	// monday of the week the term starts in
+	offset := (int(start.Weekday()) + 6) % 7
+	monday := start.AddDate(0, 0, -offset)
+	return daysBetween(monday, day)/7 + 1
+}
+
+// sessionOn reports whether a session is held on a day of its course's term
+func sessionOn(s models.TimetableSession, day time.Time, termStart, termEnd string) bool {
+	if weekdays[s.Day] != day.Weekday() {
+		return false
+	}
+
+	date := day.Format(dateLayout)
+	if (termStart != "" && date < termStart) || (termEnd != "" && date > termEnd) {
+		return false
+	}
+
+	switch s.Weeks {
+	case "odd":
+		return termWeek(day, termStart)%2 == 1
+	case "even":
+		return termWeek(day, termStart)%2 == 0
+	}
+	return true
+}
+
+// atClock returns the time on a day some minutes after midnight, as the
+// clock reads, so a change to or from daylight saving doesn't shift it
+func atClock(day time.Time, minutes int) time.Time {
+	return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, day.Location())
+}
+
+// parseScheduleRange reads the "2006-01-02" days a schedule covers in the
+// user's timezone, from today for a week when they're not given.
+func parseScheduleRange(from, to string, loc *time.Location) (time.Time, time.Time, error) {
+	now := time.Now().In(loc)
+	start := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, loc)
+	if from != "" {
+		t, err := time.ParseInLocation(dateLayout, from, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid from date %q, expected YYYY-MM-DD", from)
+		}
+		start = t
+	}
+
+	end := start.AddDate(0, 0, 6)
+	if to != "" {
+		t, err := time.ParseInLocation(dateLayout, to, loc)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", to)
+		}
+		end = t
+	}
+
+	if end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("to date is before from date")
+	}
+
+	if daysBetween(start, end) >= maxScheduleDays {
+		return time.Time{}, time.Time{}, fmt.Errorf("range can't cover more than %d days", maxScheduleDays)
+	}
+
+	return start, end, nil
+}
+
+// location returns the user's timezone, utc when it isn't a valid one
+func location(user models.User) *time.Location {
+	loc, err := time.LoadLocation(user.Timezone)
+	if err != nil {
+		return time.UTC
+	}
+	return loc
+}
+
+// timetable is the user's timetable along with the courses and semesters
+// that tell which days its sessions are held on
+type timetable struct {
+	sessions  []models.TimetableSession
+	courses   map[int64]models.Course
+	semesters map[int64]models.Semester
+}
+
+func (a *app) getTimetable(userId int64) (timetable, error) {
+	semesters, err := a.repo.GetSemesters(userId)
+	if err != nil {
+		return timetable{}, fmt.Errorf("error getting semesters from database: %v", err)
+	}
+
+	courses, err := a.repo.GetCourses(userId)
+	if err != nil {
+		return timetable{}, fmt.Errorf("error getting courses from database: %v", err)
+	}
+
+	sessions, err := a.repo.GetTimetableSessions(userId)
+	if err != nil {
+		return timetable{}, fmt.Errorf("error getting timetable from database: %v", err)
+	}
+
+	tt := timetable{
+		sessions:  sessions,
+		courses:   make(map[int64]models.Course),
+		semesters: make(map[int64]models.Semester),
+	}
+	for _, s := range semesters {
+		tt.semesters[s.ID] = s
+	}
+	for _, c := range courses {
+		tt.courses[c.ID] = c
+	}
+
+	return tt, nil
+}
+
+// on returns the sessions held on a day, leaving out the ones of courses in
+// archived semesters
+func (tt timetable) on(day time.Time) []models.TimetableSession {
+	var sessions []models.TimetableSession
+	for _, s := range tt.sessions {
+		c := tt.courses[s.CourseID]
+		if c.IsArchived {
+			continue
+		}
+
+		termStart, termEnd := courseTerm(c, tt.semesters)
+		if sessionOn(s, day, termStart, termEnd) {
+			sessions = append(sessions, s)
+		}
+	}
+
+	return sessions
+}
+
+// sessionTimes returns when a session starts and ends on a day
+func sessionTimes(s models.TimetableSession, day time.Time) (time.Time, time.Time) {
+	startMin, _ := parseClock(s.StartTime)
+	endMin, _ := parseClock(s.EndTime)
+	return atClock(day, startMin), atClock(day, endMin)
+}
+
+// GetSchedule returns the user's agenda for each day from the from date to
+// the to date, their timetable sessions merged with their exams, the
+// blocks planned to work on tasks and the tasks due on the day. Courses of
+// archived semesters are left out.
+func (a *app) GetSchedule(userId int64, from, to string) ([]models.AgendaDay, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	loc := location(user)
+	start, end, err := parseScheduleRange(from, to, loc)
+	if err != nil {
+		return nil, err
+	}
+
+	tt, err := a.getTimetable(userId)
+	if err != nil {
+		return nil, err
+	}
+
+	exams, err := a.repo.GetExams(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting exams from database: %v", err)
+	}
+
+	blocks, err := a.repo.GetTimeBlocks(userId, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return nil, fmt.Errorf("error getting time blocks from database: %v", err)
+	}
+
+	var days []models.AgendaDay
+	dayIndex := make(map[string]int)
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		date := day.Format(dateLayout)
+		dayIndex[date] = len(days)
+		agenda := models.AgendaDay{Date: date, Items: []models.AgendaItem{}}
+
+		for _, s := range tt.on(day) {
+			c := tt.courses[s.CourseID]
+			sessionStart, sessionEnd := sessionTimes(s, day)
+			agenda.Items = append(agenda.Items, models.AgendaItem{
+				Type:       "session",
+				Start:      sessionStart,
+				End:        &sessionEnd,
+				Title:      c.Name,
+				CourseID:   c.ID,
+				CourseCode: c.Code,
+				Colour:     c.Colour,
+				Location:   s.Location,
+				Kind:       s.Kind,
+				SessionID:  s.ID,
+			})
+		}
+
+		days = append(days, agenda)
+	}
+
+	for _, e := range exams {
+		i, ok := dayIndex[e.Time.In(loc).Format(dateLayout)]
+		c := tt.courses[e.CourseID]
+		if !ok || c.IsArchived {
+			continue
+		}
+
+		days[i].Items = append(days[i].Items, models.AgendaItem{
+			Type:       "exam",
+			Start:      e.Time.In(loc),
+			Title:      e.Title,
+			CourseID:   c.ID,
+			CourseCode: c.Code,
+			Colour:     c.Colour,
+			Location:   e.Location,
+			ExamID:     e.ID,
+		})
+	}
+
+	tasks := make(map[int64]models.Task)
+	for _, t := range user.Tasks {
+		tasks[t.ID] = t
+	}
+
+	for _, b := range blocks {
+		i, ok := dayIndex[b.Start.In(loc).Format(dateLayout)]
+		t, found := tasks[b.TaskID]
+		c := tt.courses[t.CourseID]
+		if !ok || !found || c.IsArchived {
+			continue
+		}
+
+		blockEnd := b.End.In(loc)
+		t.UpdateProgress()
+		days[i].Items = append(days[i].Items, models.AgendaItem{
+			Type:       "block",
+			Start:      b.Start.In(loc),
+			End:        &blockEnd,
+			Title:      t.Name,
+			CourseID:   t.CourseID,
+			CourseCode: c.Code,
+			Colour:     c.Colour,
+			BlockID:    b.ID,
+			Task:       &t,
+		})
+	}
+
+	for _, t := range user.Tasks {
+		if t.TimeDue.IsZero() {
+			continue
+		}
+
+		i, ok := dayIndex[t.TimeDue.In(loc).Format(dateLayout)]
+		if !ok {
+			continue
+		}
+
+		c := tt.courses[t.CourseID]
+		if c.IsArchived {
+			continue
+		}
+
+		t := t
+		t.UpdateProgress()
+		days[i].Items = append(days[i].Items, models.AgendaItem{
+			Type:       "task",
+			Start:      t.TimeDue.In(loc),
+			Title:      t.Name,
+			CourseID:   t.CourseID,
+			CourseCode: c.Code,
+			Colour:     c.Colour,
+			Task:       &t,
+		})
+	}
+
+	for _, d := range days {
+		sort.SliceStable(d.Items, func(i, j int) bool {
+			return d.Items[i].Start.Before(d.Items[j].Start)
+		})
+	}
+
+	return days, nil
+}
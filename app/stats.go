@@ -0,0 +1,65 @@
+package app
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// days the completion figures of stats cover when no range is given
+const defaultStatsDays = 30
+
+// GetStats returns statistics of the user's tasks, with the completions of
+// the days from the from date to the to date in the user's timezone, the
+// last 30 days by default. They're counted by the database rather than
+// from the user's tasks.
+func (a *app) GetStats(userId int64, from, to string) (models.TaskStats, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.TaskStats{}, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	loc := location(user)
+	now := time.Now().In(loc)
+	if to == "" {
+		to = now.Format(dateLayout)
+	}
+	if from == "" {
+		end, err := time.ParseInLocation(dateLayout, to, loc)
+		if err != nil {
+			return models.TaskStats{}, fmt.Errorf("invalid to date %q, expected YYYY-MM-DD", to)
+		}
+		from = end.AddDate(0, 0, 1-defaultStatsDays).Format(dateLayout)
+	}
+
+	start, end, err := parseScheduleRange(from, to, loc)
+	if err != nil {
+		return models.TaskStats{}, err
+	}
+
+	var days []time.Time
+	for day := start; !day.After(end); day = day.AddDate(0, 0, 1) {
+		days = append(days, day)
+	}
+
+	stats, err := a.repo.GetTaskStats(userId, now, days)
+	if err != nil {
+		return models.TaskStats{}, fmt.Errorf("error getting stats from database: %v", err)
+	}
+
+	stats.From = start.Format(dateLayout)
+	stats.To = end.Format(dateLayout)
+
+	if stats.Total > 0 {
+		stats.CompletionRate = *round2(float64(stats.Completed) / float64(stats.Total) * 100)
+	}
+	if stats.OnTime+stats.Late > 0 {
+		stats.OnTimeRate = round2(float64(stats.OnTime) / float64(stats.OnTime+stats.Late) * 100)
+	}
+	if stats.AverageLeadHours != nil {
+		stats.AverageLeadHours = round2(*stats.AverageLeadHours)
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,255 @@
+package app
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// streak and badge event types sent to the user's live streams
+const (
+	EventStreakUpdated = "streak.updated"
+	EventBadgeEarned   = "badge.earned"
+)
+
+const (
+	// most freezes a user can hold
+	maxStreakFreezes = 2
+	// a freeze is earned for every this many days of a streak
+	streakFreezeEvery = 7
+)
+
+// badges users can earn
+const (
+	BadgeFirstTask = "first_task"
+	BadgeStreak7   = "streak_7"
+	BadgeAllOnTime = "all_on_time"
+)
+
+var badgeDetails = map[string]models.Badge{
+	BadgeFirstTask: {Title: "First task", Description: "Completed a first task"},
+	BadgeStreak7:   {Title: "7-day streak", Description: "Completed a task 7 days in a row"},
+	BadgeAllOnTime: {Title: "Always on time", Description: "Completed every task with a due time before it was due"},
+}
+
+// nextDay returns the "2006-01-02" day after day
+func nextDay(day string) string {
+	t, _ := time.Parse(dateLayout, day)
+	return t.AddDate(0, 0, 1).Format(dateLayout)
+}
+
+// streakReaches reports whether the streak carries on to day, which it
+// does when every day between its last day and day is frozen
+func streakReaches(s models.Streak, day string) bool {
+	if s.LastDay == "" || s.Current == 0 {
+		return false
+	}
+
+	frozen := make(map[string]bool)
+	for _, d := range s.FrozenDays {
+		frozen[d] = true
+	}
+
+	for d := nextDay(s.LastDay); d < day; d = nextDay(d) {
+		if !frozen[d] {
+			return false
+		}
+	}
+	return true
+}
+
+// today returns the current "2006-01-02" day of the user
+func today(user models.User) string {
+	return time.Now().In(location(user)).Format(dateLayout)
+}
+
+// getStreak returns the user's streak, with Current at 0 if it's broken
+func (a *app) getStreak(user models.User) (models.Streak, error) {
+	s, err := a.repo.GetStreak(user.ID)
+	if err != nil {
+		return models.Streak{}, fmt.Errorf("error getting streak from database: %v", err)
+	}
+
+	if !streakReaches(s, today(user)) {
+		s.Current = 0
+	}
+	return s, nil
+}
+
+func (a *app) GetStreak(userId int64) (models.Streak, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.Streak{}, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	return a.getStreak(user)
+}
+
+// FreezeStreak uses one of the user's freezes to keep their streak going
+// over a day they don't complete a task on, today when date is empty. Only
+// the days after the streak's last day can be frozen, up to today, and
+// only in order.
+func (a *app) FreezeStreak(userId int64, date string) (models.Streak, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.Streak{}, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	day := today(user)
+	if date != "" {
+		if _, err := time.Parse(dateLayout, date); err != nil {
+			return models.Streak{}, fmt.Errorf("invalid date %q, expected YYYY-MM-DD", date)
+		}
+		if date > day {
+			return models.Streak{}, fmt.Errorf("can't freeze a day that hasn't started yet")
+		}
+		day = date
+	}
+
+	s, err := a.repo.GetStreak(userId)
+	if err != nil {
+		return models.Streak{}, fmt.Errorf("error getting streak from database: %v", err)
+	}
+
+	if s.Freezes < 1 {
+		return models.Streak{}, fmt.Errorf("no streak freezes left")
+	}
+
+	if day <= s.LastDay {
+		return models.Streak{}, fmt.Errorf("only the days after %s can be frozen", s.LastDay)
+	}
+
+	for _, d := range s.FrozenDays {
+		if d == day {
+			return models.Streak{}, fmt.Errorf("%s is already frozen", day)
+		}
+	}
+
+	if !streakReaches(s, day) {
+		return models.Streak{}, fmt.Errorf("no streak to keep going on %s", day)
+	}
+
+	s.Freezes--
+	s.FrozenDays = append(s.FrozenDays, day)
+	err = a.inTransaction(func(a *app) error {
+		if err := a.repo.FreezeStreakDay(userId, day); err != nil {
+			return fmt.Errorf("error freezing streak: %v", err)
+		}
+
+		if err := a.repo.UpdateStreak(userId, s); err != nil {
+			return fmt.Errorf("error updating streak: %v", err)
+		}
+
+		a.publish(userId, EventStreakUpdated, s)
+		return nil
+	})
+	if err != nil {
+		return models.Streak{}, err
+	}
+
+	return a.getStreak(user)
+}
+
+// GetBadges returns the badges the user earned, earliest first.
+func (a *app) GetBadges(userId int64) ([]models.Badge, error) {
+	badges, err := a.repo.GetBadges(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting badges from database: %v", err)
+	}
+
+	for i := range badges {
+		details := badgeDetails[badges[i].Name]
+		badges[i].Title = details.Title
+		badges[i].Description = details.Description
+	}
+
+	if badges == nil {
+		badges = []models.Badge{}
+	}
+	return badges, nil
+}
+
+// awardBadge gives the user a badge they don't have yet
+func (a *app) awardBadge(userId int64, name string, earned time.Time) error {
+	added, err := a.repo.AddBadge(userId, name, earned)
+	if err != nil {
+		return fmt.Errorf("error adding badge: %v", err)
+	}
+
+	if added {
+		b := badgeDetails[name]
+		b.Name = name
+		b.TimeEarned = earned
+		a.publish(userId, EventBadgeEarned, b)
+	}
+	return nil
+}
+
+// recordCompletion carries the user's streak on to the day a task was
+// completed on and awards the badges it earned. Like publishing events, a
+// failure here doesn't fail the completion itself.
+func (a *app) recordCompletion(userId int64, t models.Task) {
+	if err := a.updateStreak(userId, t); err != nil {
+		log.Printf("error recording completion of task %d: %v", t.ID, err)
+	}
+}
+
+func (a *app) updateStreak(userId int64, t models.Task) error {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return fmt.Errorf("error getting user from database: %v", err)
+	}
+
+	s, err := a.repo.GetStreak(userId)
+	if err != nil {
+		return fmt.Errorf("error getting streak from database: %v", err)
+	}
+
+	day := t.TimeCompleted.In(location(user)).Format(dateLayout)
+	if day > s.LastDay {
+		if streakReaches(s, day) {
+			s.Current++
+		} else {
+			s.Current = 1
+		}
+		s.LastDay = day
+		s.FrozenDays = []string{}
+
+		if s.Current > s.Longest {
+			s.Longest = s.Current
+		}
+		if s.Current%streakFreezeEvery == 0 && s.Freezes < maxStreakFreezes {
+			s.Freezes++
+		}
+
+		if err := a.repo.UpdateStreak(userId, s); err != nil {
+			return fmt.Errorf("error updating streak: %v", err)
+		}
+		a.publish(userId, EventStreakUpdated, s)
+	}
+
+	now := time.Now()
+	if err := a.awardBadge(userId, BadgeFirstTask, now); err != nil {
+		return err
+	}
+
+	if s.Current >= 7 {
+		if err := a.awardBadge(userId, BadgeStreak7, now); err != nil {
+			return err
+		}
+	}
+
+	open, late, onTime, err := a.repo.GetDueTaskCounts(userId)
+	if err != nil {
+		return fmt.Errorf("error counting due tasks: %v", err)
+	}
+	if open == 0 && late == 0 && onTime > 0 {
+		if err := a.awardBadge(userId, BadgeAllOnTime, now); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,53 @@
+package app
+
+import (
+	"testing"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func TestStreakReaches(t *testing.T) {
+	tests := []struct {
+		name   string
+		streak models.Streak
+		day    string
+		want   bool
+	}{
+		{"no streak", models.Streak{}, "2026-10-19", false},
+		{"broken streak", models.Streak{Current: 0, LastDay: "2026-10-18"}, "2026-10-19", false},
+		{"same day", models.Streak{Current: 3, LastDay: "2026-10-19"}, "2026-10-19", true},
+		{"next day", models.Streak{Current: 3, LastDay: "2026-10-18"}, "2026-10-19", true},
+		{"missed a day", models.Streak{Current: 3, LastDay: "2026-10-17"}, "2026-10-19", false},
+		{
+			"missed day frozen",
+			models.Streak{Current: 3, LastDay: "2026-10-17", FrozenDays: []string{"2026-10-18"}},
+			"2026-10-19", true,
+		},
+		{
+			"one of two missed days frozen",
+			models.Streak{Current: 3, LastDay: "2026-10-16", FrozenDays: []string{"2026-10-18"}},
+			"2026-10-19", false,
+		},
+		{
+			"frozen across a month",
+			models.Streak{Current: 5, LastDay: "2026-09-29", FrozenDays: []string{"2026-09-30", "2026-10-01"}},
+			"2026-10-02", true,
+		},
+		{
+			"frozen across a year",
+			models.Streak{Current: 5, LastDay: "2026-12-30", FrozenDays: []string{"2026-12-31"}},
+			"2027-01-01", true,
+		},
+		{
+			"frozen day after the gap doesn't count",
+			models.Streak{Current: 3, LastDay: "2026-10-17", FrozenDays: []string{"2026-10-19"}},
+			"2026-10-19", false,
+		},
+	}
+
+	for _, tt := range tests {
+		if got := streakReaches(tt.streak, tt.day); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
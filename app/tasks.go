@@ -6,26 +6,88 @@ import (
 	"strings"
 	"time"
 
+	"github.com/michaelcosj/stms/framework"
 	"github.com/michaelcosj/stms/models"
 )
 
-func (a *app) AddTask(userId int64, t *models.Task) error {
+func validateTask(t *models.Task) error {
 	tag := strings.ToLower(t.Tag)
 	if len(t.Name) < 3 || len(t.Description) < 3 ||
 		!(tag == "study" || tag == "work" || tag == "others") {
 		return fmt.Errorf("invalid task name, description or tag")
 	}
 
+	if !t.Priority.IsValid() {
+		return fmt.Errorf("invalid task priority")
+	}
+
+	return validateEstimate(t.EstimateMinutes)
+}
+
+func (a *app) AddTask(userId int64, t *models.Task) error {
+	if err := validateTask(t); err != nil {
+		return err
+	}
+
+	if err := a.checkCourse(userId, t.CourseID); err != nil {
+		return err
+	}
+
+	if err := validateAssessment(t); err != nil {
+		return err
+	}
+
 	t.IsCompleted = false
+	t.ExamID = 0
+	t.Version = 1
 	t.TimeCreated = time.Now()
+	t.TimeCompleted = time.Unix(0, 0).UTC()
 
-	taskId, err := a.repo.AddTask(userId, *t)
-	if err != nil {
-		return fmt.Errorf("error adding task to database: %v", err)
+	t.SeriesID, t.Occurrence = 0, 0
+	if t.RRule != "" {
+		if err := validateSeries(t); err != nil {
+			return err
+		}
+	}
+
+	if _, err := validateReminders(t.Reminders); err != nil {
+		return err
 	}
 
-	t.ID = taskId
-	return err
+	for i := range t.Items {
+		if err := validateChecklistItem(&t.Items[i]); err != nil {
+			return err
+		}
+	}
+
+	// nothing is left behind if any part of the task fails to save
+	return a.inTransaction(func(a *app) error {
+		if t.RRule != "" {
+			if err := a.startSeries(userId, t); err != nil {
+				return err
+			}
+		}
+
+		taskId, err := a.repo.AddTask(userId, *t)
+		if err != nil {
+			return fmt.Errorf("error adding task to database: %v", err)
+		}
+
+		t.ID = taskId
+
+		if err := a.setTaskReminders(t); err != nil {
+			return err
+		}
+
+		for i := range t.Items {
+			if err := a.addChecklistItem(taskId, &t.Items[i]); err != nil {
+				return err
+			}
+		}
+
+		t.UpdateProgress()
+		return a.recordRevision(userId, "create", nil, *t)
+	})
 }
 
 func (a *app) GetTaskByFilters(userId int64, filter map[string][]string) ([]models.Task, error) {
@@ -34,6 +96,35 @@ func (a *app) GetTaskByFilters(userId int64, filter map[string][]string) ([]mode
 		return nil, fmt.Errorf("error getting tasks from database: %v", err)
 	}
 
+	priorityFilters, err := parsePriorityFilters(filter)
+	if err != nil {
+		return nil, err
+	}
+
+	courseIds, err := parseIdFilter(filter, "course_id")
+	if err != nil {
+		return nil, err
+	}
+
+	semesterIds, err := parseIdFilter(filter, "semester_id")
+	if err != nil {
+		return nil, err
+	}
+
+	courses, err := a.repo.GetCourses(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting courses from database: %v", err)
+	}
+
+	courseById := make(map[int64]models.Course)
+	for _, c := range courses {
+		courseById[c.ID] = c
+	}
+
+	// tasks of archived semesters are only listed when they're asked for
+	includeArchived := len(courseIds) > 0 || len(semesterIds) > 0 ||
+		(len(filter["include_archived"]) > 0 && filter["include_archived"][0] == "true")
+
 	var filtered_tasks []models.Task
 	for _, task := range tasks {
 		isCompletedFilter := true
@@ -42,8 +133,11 @@ func (a *app) GetTaskByFilters(userId int64, filter map[string][]string) ([]mode
 		}
 
 		priorityFilter := true
-		if len(filter["priority"]) > 0 {
-			priorityFilter = strconv.FormatBool(task.Priority) == filter["priority"][0]
+		for _, f := range priorityFilters {
+			if !f.match(task.Priority) {
+				priorityFilter = false
+				break
+			}
 		}
 
 		idFilter := true
@@ -71,7 +165,14 @@ func (a *app) GetTaskByFilters(userId int64, filter map[string][]string) ([]mode
 			}
 		}
 
-		if isCompletedFilter && priorityFilter && idFilter && tagFilter {
+		course := courseById[task.CourseID]
+		courseFilter := len(courseIds) == 0 || courseIds[task.CourseID]
+		semesterFilter := len(semesterIds) == 0 || semesterIds[course.SemesterID]
+		archivedFilter := includeArchived || !course.IsArchived
+
+		if isCompletedFilter && priorityFilter && idFilter && tagFilter &&
+			courseFilter && semesterFilter && archivedFilter {
+			task.UpdateProgress()
 			filtered_tasks = append(filtered_tasks, task)
 		}
 	}
@@ -79,16 +180,244 @@ func (a *app) GetTaskByFilters(userId int64, filter map[string][]string) ([]mode
 	return filtered_tasks, nil
 }
 
-func (a *app) UpdateTask(taskId int64, t *models.Task) error {
-	if err := a.repo.UpdateTask(taskId, *t); err != nil {
-		return fmt.Errorf("error updating task in database: %v", err)
+// parseIdFilter reads the ids a query key may match, "none" matches an id
+// of 0
+func parseIdFilter(filter map[string][]string, key string) (map[int64]bool, error) {
+	ids := make(map[int64]bool)
+	for _, idStr := range filter[key] {
+		if idStr == "none" {
+			ids[0] = true
+			continue
+		}
+
+		id, err := strconv.ParseInt(idStr, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("error parsing %s %s", key, idStr)
+		}
+		ids[id] = true
 	}
-	return nil
+
+	return ids, nil
+}
+
+type priorityFilter struct {
+	op    string
+	level models.Priority
+}
+
+func (f priorityFilter) match(p models.Priority) bool {
+	switch f.op {
+	case ">=":
+		return p >= f.level
+	case "<=":
+		return p <= f.level
+	case ">":
+		return p > f.level
+	case "<":
+		return p < f.level
+	case "!=":
+		return p != f.level
+	}
+	return p == f.level
+}
+
+// parsePriorityFilters reads priority comparisons from the query. Url parsing
+// splits "priority>=high" into the key "priority>" and the value "high", so
+// the operator is put back together from the key and the value. Only the
+// "priority" key and the ones made by splitting an operator are filters,
+// other keys starting with "priority" are left alone. The legacy boolean
+// values map the same way they do in json, "true" to high and "false" to
+// none.
+func parsePriorityFilters(filter map[string][]string) ([]priorityFilter, error) {
+	var filters []priorityFilter
+
+	for key, values := range filter {
+		rest, found := strings.CutPrefix(key, "priority")
+		if !found || (rest != "" && !strings.ContainsAny(rest[:1], "<>!=")) {
+			continue
+		}
+
+		for _, value := range values {
+			expr := strings.TrimPrefix(key, "priority")
+			if expr != "" && !strings.HasSuffix(expr, "=") && value != "" {
+				expr += "="
+			}
+			expr += value
+
+			op := "="
+			for _, o := range []string{">=", "<=", "!=", ">", "<", "="} {
+				if strings.HasPrefix(expr, o) {
+					op = o
+					expr = strings.TrimPrefix(expr, o)
+					break
+				}
+			}
+
+			level, err := models.ParsePriority(expr)
+			if err != nil {
+				return nil, fmt.Errorf("error parsing priority filter: %v", err)
+			}
+
+			filters = append(filters, priorityFilter{op, level})
+		}
+	}
+
+	return filters, nil
+}
+
+func (a *app) GetTask(userId, taskId int64) (models.Task, error) {
+	t, err := a.repo.GetTask(userId, taskId)
+	if err != nil {
+		return models.Task{}, fmt.Errorf("error getting task from database: %w", err)
+	}
+
+	t.UpdateProgress()
+	return t, nil
+}
+
+// getTaskVersion gets a task that's expected to be at the given version,
+// a version of 0 matches any.
+func (a *app) getTaskVersion(userId, taskId, version int64) (models.Task, error) {
+	t, err := a.repo.GetTask(userId, taskId)
+	if err != nil {
+		return models.Task{}, fmt.Errorf("error getting task from database: %w", err)
+	}
+
+	if version != 0 && version != t.Version {
+		return models.Task{}, ErrVersionMismatch
+	}
+
+	return t, nil
 }
 
-func (a *app) DeleteTask(taskId int64) error {
-	if err := a.repo.DeleteTask(taskId); err != nil {
-		return fmt.Errorf("error removing task from database: %v", err)
+// UpdateTask replaces the task's fields if it's still at the given version.
+// Completing a task ticks off all of its checklist items and creates the
+// next occurrence of a recurring task. Changes to a recurring task only
+// apply to this occurrence, see UpdateTaskSeries for changing the
+// occurrences that follow.
+func (a *app) UpdateTask(userId, taskId, version int64, t *models.Task) error {
+	old, err := a.getTaskVersion(userId, taskId, version)
+	if err != nil {
+		return err
+	}
+
+	if !t.Priority.IsValid() {
+		return fmt.Errorf("invalid task priority")
+	}
+
+	if err := validateEstimate(t.EstimateMinutes); err != nil {
+		return err
+	}
+
+	if err := a.checkCourse(userId, t.CourseID); err != nil {
+		return err
+	}
+
+	// the assessment is kept unless the update sets it
+	if t.Assessment == nil {
+		t.Assessment = old.Assessment
+	}
+	if err := validateAssessment(t); err != nil {
+		return err
 	}
+
+	if t.RRule != "" {
+		rule, err := framework.ParseRRule(t.RRule)
+		if err != nil {
+			return fmt.Errorf("invalid recurrence rule: %v", err)
+		}
+		t.RRule = rule.String()
+	}
+
+	switch {
+	case old.SeriesID == 0 && t.RRule != "":
+		if err := a.startSeries(userId, t); err != nil {
+			return err
+		}
+	case old.SeriesID != 0 && t.RRule != "" && t.RRule != old.RRule:
+		return fmt.Errorf("changing the recurrence rule of a recurring task needs scope=future")
+	default:
+		t.RRule = old.RRule
+		t.SeriesID = old.SeriesID
+		t.Occurrence = old.Occurrence
+		t.RecurrenceTime = old.RecurrenceTime
+	}
+
+	// the change and its revision are saved together
+	return a.inTransaction(func(a *app) error {
+		if err := a.updateTask(userId, old, t); err != nil {
+			return err
+		}
+		return a.recordRevision(userId, "update", &old, *t)
+	})
+}
+
+func (a *app) updateTask(userId int64, old models.Task, t *models.Task) error {
+	completed := t.IsCompleted && !old.IsCompleted
+
+	t.ID = old.ID
+	t.UID = old.UID
+	t.ExamID = old.ExamID
+	t.Version = old.Version
+	t.TimeCreated = old.TimeCreated
+	switch {
+	case completed:
+		t.TimeCompleted = time.Now()
+	case t.IsCompleted:
+		t.TimeCompleted = old.TimeCompleted
+	default:
+		t.TimeCompleted = time.Unix(0, 0).UTC()
+	}
+
+	if err := a.repo.UpdateTask(old.ID, *t); err != nil {
+		return fmt.Errorf("error updating task in database: %w", err)
+	}
+	t.Version++
+
+	// reminders are kept unless the update sets them
+	if t.Reminders == nil {
+		t.Reminders = old.Reminders
+	} else if err := a.setTaskReminders(t); err != nil {
+		return err
+	}
+
+	t.Items = old.Items
+	if completed {
+		if err := a.repo.CompleteChecklistItems(old.ID); err != nil {
+			return fmt.Errorf("error completing task checklist: %v", err)
+		}
+		for i := range t.Items {
+			t.Items[i].IsCompleted = true
+		}
+
+		if t.SeriesID != 0 {
+			if _, _, err := a.createNextOccurrence(userId, *t); err != nil {
+				return err
+			}
+		}
+	}
+
+	t.UpdateProgress()
 	return nil
 }
+
+// DeleteTask moves the task to the trash if it's still at the given
+// version, it can be restored until it's purged after the retention period.
+func (a *app) DeleteTask(userId, taskId, version int64) error {
+	t, err := a.getTaskVersion(userId, taskId, version)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	return a.inTransaction(func(a *app) error {
+		if err := a.repo.TrashTask(taskId, t.Version, now); err != nil {
+			return fmt.Errorf("error removing task from database: %w", err)
+		}
+
+		t.Version++
+		t.TimeDeleted = &now
+		t.UpdateProgress()
+		return a.recordRevision(userId, "delete", nil, t)
+	})
+}
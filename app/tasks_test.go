@@ -0,0 +1,60 @@
+package app
+
+import (
+	"net/url"
+	"reflect"
+	"testing"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func TestParsePriorityFilters(t *testing.T) {
+	tests := []struct {
+		query   string
+		want    []priorityFilter
+		wantErr bool
+	}{
+		{"", nil, false},
+		{"priority=high", []priorityFilter{{"=", models.PriorityHigh}}, false},
+		{"priority>=medium", []priorityFilter{{">=", models.PriorityMedium}}, false},
+		{"priority<=low", []priorityFilter{{"<=", models.PriorityLow}}, false},
+		{"priority!=none", []priorityFilter{{"!=", models.PriorityNone}}, false},
+		{"priority>low", []priorityFilter{{">", models.PriorityLow}}, false},
+		{"priority<urgent", []priorityFilter{{"<", models.PriorityUrgent}}, false},
+		{"priority=true", []priorityFilter{{"=", models.PriorityHigh}}, false},
+		{"priority=false", []priorityFilter{{"=", models.PriorityNone}}, false},
+		{"priority_sort=desc", nil, false},
+		{"prioritylevel=high", nil, false},
+		{"tag=work", nil, false},
+		{"priority=critical", nil, true},
+	}
+
+	for _, tt := range tests {
+		query, err := url.ParseQuery(tt.query)
+		if err != nil {
+			t.Fatalf("parse query %q: %v", tt.query, err)
+		}
+
+		got, err := parsePriorityFilters(query)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%q: got error %v, want error %v", tt.query, err, tt.wantErr)
+			continue
+		}
+		if !tt.wantErr && !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("%q: got %v, want %v", tt.query, got, tt.want)
+		}
+	}
+}
+
+func TestPriorityFilterMatch(t *testing.T) {
+	f := priorityFilter{">=", models.PriorityHigh}
+	for p, want := range map[models.Priority]bool{
+		models.PriorityMedium: false,
+		models.PriorityHigh:   true,
+		models.PriorityUrgent: true,
+	} {
+		if got := f.match(p); got != want {
+			t.Errorf("%v >= high: got %v, want %v", p, got, want)
+		}
+	}
+}
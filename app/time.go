@@ -0,0 +1,372 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// timer event types sent to the user's live streams
+const (
+	EventTimerStarted = "timer.started"
+	EventTimerStopped = "timer.stopped"
+)
+
+const (
+	// pomodoro lengths of users who haven't set theirs
+	defaultPomodoroWork  = 25
+	defaultPomodoroBreak = 5
+
+	maxPomodoroWork  = 120
+	maxPomodoroBreak = 60
+
+	// longest time entry that can be entered by hand
+	maxTimeEntry = 24 * time.Hour
+)
+
+func validatePomodoro(work, breakLength int) error {
+	if work < 1 || work > maxPomodoroWork {
+		return fmt.Errorf("invalid pomodoro work length %d, expected 1 to %d minutes", work, maxPomodoroWork)
+	}
+
+	if breakLength < 1 || breakLength > maxPomodoroBreak {
+		return fmt.Errorf("invalid pomodoro break length %d, expected 1 to %d minutes", breakLength, maxPomodoroBreak)
+	}
+
+	return nil
+}
+
+// entryEnd returns when an entry ended, or when it would if it was stopped
+// now
+func entryEnd(e models.TimeEntry, now time.Time) time.Time {
+	if e.End != nil {
+		return *e.End
+	}
+	if e.PlannedEnd != nil && e.PlannedEnd.Before(now) {
+		return *e.PlannedEnd
+	}
+	return now
+}
+
+// fillEntry sets the minutes of an entry and the end of a pomodoro's break
+func fillEntry(e *models.TimeEntry, now time.Time) {
+	e.Minutes = int(entryEnd(*e, now).Sub(e.Start).Round(time.Minute) / time.Minute)
+
+	if e.Kind == "pomodoro" && e.End != nil {
+		breakUntil := e.End.Add(time.Duration(e.BreakMinutes) * time.Minute)
+		e.BreakUntil = &breakUntil
+	}
+}
+
+// runningTimer returns the user's running timer, nil when there's none. A
+// pomodoro whose work length is up is stopped on the way.
+func (a *app) runningTimer(userId int64) (*models.TimeEntry, error) {
+	e, err := a.repo.GetRunningTimeEntry(userId)
+	if errors.Is(err, ErrEntryNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error getting running timer from database: %v", err)
+	}
+
+	now := time.Now()
+	if e.PlannedEnd != nil && !e.PlannedEnd.After(now) {
+		if err := a.stopTimer(userId, &e, now); err != nil {
+			return nil, err
+		}
+		return nil, nil
+	}
+
+	fillEntry(&e, now)
+	return &e, nil
+}
+
+func (a *app) stopTimer(userId int64, e *models.TimeEntry, now time.Time) error {
+	end := entryEnd(*e, now)
+	if err := a.repo.StopTimeEntry(e.ID, end); err != nil {
+		return fmt.Errorf("error stopping timer: %v", err)
+	}
+
+	e.End = &end
+	fillEntry(e, now)
+	a.publish(userId, EventTimerStopped, e)
+	return nil
+}
+
+// StartTimer starts tracking time on a task, kind is "timer" or "pomodoro".
+// A pomodoro runs for the user's pomodoro work length. A user has one
+// running timer at most, starting another fails until it's stopped.
+func (a *app) StartTimer(userId, taskId int64, kind, note string) (models.TimeEntry, error) {
+	switch kind {
+	case "":
+		kind = "timer"
+	case "timer", "pomodoro":
+	default:
+		return models.TimeEntry{}, fmt.Errorf("invalid timer kind %s, expected timer or pomodoro", kind)
+	}
+
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.TimeEntry{}, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	if _, err := a.repo.GetTask(userId, taskId); err != nil {
+		return models.TimeEntry{}, fmt.Errorf("error getting task from database: %w", err)
+	}
+
+	running, err := a.runningTimer(userId)
+	if err != nil {
+		return models.TimeEntry{}, err
+	}
+	if running != nil {
+		return models.TimeEntry{}, fmt.Errorf("%w on task %q, stop it first", ErrTimerRunning, running.TaskName)
+	}
+
+	now := time.Now()
+	e := models.TimeEntry{
+		TaskID:      taskId,
+		Kind:        kind,
+		Start:       now,
+		Note:        strings.TrimSpace(note),
+		TimeCreated: now,
+	}
+
+	if kind == "pomodoro" {
+		plannedEnd := now.Add(time.Duration(user.PomodoroWorkMinutes) * time.Minute)
+		e.PlannedEnd = &plannedEnd
+		e.BreakMinutes = user.PomodoroBreakMinutes
+	}
+
+	if e.ID, err = a.repo.AddTimeEntry(userId, e); err != nil {
+		if errors.Is(err, ErrTimerRunning) {
+			return models.TimeEntry{}, fmt.Errorf("error starting timer: %w", err)
+		}
+		return models.TimeEntry{}, fmt.Errorf("error starting timer: %v", err)
+	}
+
+	e, err = a.repo.GetTimeEntry(userId, e.ID)
+	if err != nil {
+		return models.TimeEntry{}, fmt.Errorf("error getting time entry from database: %v", err)
+	}
+
+	fillEntry(&e, now)
+	a.publish(userId, EventTimerStarted, e)
+	return e, nil
+}
+
+// GetTimer returns the user's running timer, nil when none is running.
+func (a *app) GetTimer(userId int64) (*models.TimeEntry, error) {
+	return a.runningTimer(userId)
+}
+
+// StopTimer stops the user's running timer. A pomodoro stopped early keeps
+// the time worked so far.
+func (a *app) StopTimer(userId int64) (models.TimeEntry, error) {
+	e, err := a.repo.GetRunningTimeEntry(userId)
+	if err != nil {
+		return models.TimeEntry{}, fmt.Errorf("error getting running timer from database: %w", err)
+	}
+
+	if err := a.stopTimer(userId, &e, time.Now()); err != nil {
+		return models.TimeEntry{}, err
+	}
+
+	return e, nil
+}
+
+// AddTimeEntry adds time spent on a task that wasn't tracked with a timer.
+func (a *app) AddTimeEntry(userId, taskId int64, e *models.TimeEntry) error {
+	if _, err := a.repo.GetTask(userId, taskId); err != nil {
+		return fmt.Errorf("error getting task from database: %w", err)
+	}
+
+	now := time.Now()
+	if e.Start.IsZero() || e.End == nil || e.End.IsZero() {
+		return fmt.Errorf("time entry needs a start and an end")
+	}
+
+	if !e.End.After(e.Start) {
+		return fmt.Errorf("time entry must end after it starts")
+	}
+
+	if e.End.After(now) {
+		return fmt.Errorf("time entry can't end in the future")
+	}
+
+	if e.End.Sub(e.Start) > maxTimeEntry {
+		return fmt.Errorf("time entry can't be longer than %v", maxTimeEntry)
+	}
+
+	e.TaskID = taskId
+	e.Kind = "manual"
+	e.Note = strings.TrimSpace(e.Note)
+	e.PlannedEnd = nil
+	e.BreakMinutes = 0
+	e.TimeCreated = now
+
+	entryId, err := a.repo.AddTimeEntry(userId, *e)
+	if err != nil {
+		return fmt.Errorf("error adding time entry to database: %v", err)
+	}
+
+	*e, err = a.repo.GetTimeEntry(userId, entryId)
+	if err != nil {
+		return fmt.Errorf("error getting time entry from database: %v", err)
+	}
+
+	fillEntry(e, now)
+	return nil
+}
+
+// GetTaskTimeEntries returns the time entries of a task, latest first.
+func (a *app) GetTaskTimeEntries(userId, taskId int64) ([]models.TimeEntry, error) {
+	if _, err := a.repo.GetTask(userId, taskId); err != nil {
+		return nil, fmt.Errorf("error getting task from database: %w", err)
+	}
+
+	entries, err := a.repo.GetTaskTimeEntries(taskId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting time entries from database: %v", err)
+	}
+
+	now := time.Now()
+	for i := range entries {
+		fillEntry(&entries[i], now)
+	}
+
+	if entries == nil {
+		entries = []models.TimeEntry{}
+	}
+	return entries, nil
+}
+
+// DeleteTimeEntry deletes a time entry of a task, a running timer is
+// discarded.
+func (a *app) DeleteTimeEntry(userId, taskId, entryId int64) error {
+	e, err := a.repo.GetTimeEntry(userId, entryId)
+	if err != nil {
+		return fmt.Errorf("error getting time entry from database: %w", err)
+	}
+
+	if e.TaskID != taskId {
+		return fmt.Errorf("error getting time entry from database: %w", ErrEntryNotFound)
+	}
+
+	if err := a.repo.DeleteTimeEntry(entryId); err != nil {
+		return fmt.Errorf("error deleting time entry: %v", err)
+	}
+
+	return nil
+}
+
+// weekStart returns the monday of the week of a day
+func weekStart(day time.Time) time.Time {
+	day = time.Date(day.Year(), day.Month(), day.Day(), 0, 0, 0, 0, day.Location())
+	return day.AddDate(0, 0, -((int(day.Weekday()) + 6) % 7))
+}
+
+// GetTimeTotals sums the time tracked on the days from the from date to the
+// to date, by task, course and week. The range defaults to the last four
+// weeks, the current one included. A running timer counts up to now.
+func (a *app) GetTimeTotals(userId int64, from, to string) (models.TimeTotals, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.TimeTotals{}, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	loc := location(user)
+	now := time.Now().In(loc)
+	if from == "" {
+		from = weekStart(now).AddDate(0, 0, -21).Format(dateLayout)
+	}
+	if to == "" {
+		to = now.Format(dateLayout)
+	}
+
+	start, end, err := parseScheduleRange(from, to, loc)
+	if err != nil {
+		return models.TimeTotals{}, err
+	}
+
+	entries, err := a.repo.GetTimeEntries(userId, start, end.AddDate(0, 0, 1))
+	if err != nil {
+		return models.TimeTotals{}, fmt.Errorf("error getting time entries from database: %v", err)
+	}
+
+	courses, err := a.repo.GetCourses(userId)
+	if err != nil {
+		return models.TimeTotals{}, fmt.Errorf("error getting courses from database: %v", err)
+	}
+
+	tasks := make(map[int64]models.Task)
+	for _, t := range user.Tasks {
+		tasks[t.ID] = t
+	}
+
+	courseNames := make(map[int64]string)
+	for _, c := range courses {
+		courseNames[c.ID] = c.Name
+	}
+
+	totals := models.TimeTotals{
+		From:    start.Format(dateLayout),
+		To:      end.Format(dateLayout),
+		Tasks:   []models.TaskTime{},
+		Courses: []models.CourseTime{},
+		Weeks:   []models.WeekTime{},
+	}
+
+	taskIndex := make(map[int64]int)
+	courseIndex := make(map[int64]int)
+	weekIndex := make(map[string]int)
+	for week := weekStart(start); !week.After(end); week = week.AddDate(0, 0, 7) {
+		weekIndex[week.Format(dateLayout)] = len(totals.Weeks)
+		totals.Weeks = append(totals.Weeks, models.WeekTime{Week: week.Format(dateLayout)})
+	}
+
+	for _, e := range entries {
+		fillEntry(&e, now)
+		totals.Minutes += e.Minutes
+
+		t := tasks[e.TaskID]
+		i, ok := taskIndex[e.TaskID]
+		if !ok {
+			i = len(totals.Tasks)
+			taskIndex[e.TaskID] = i
+			totals.Tasks = append(totals.Tasks, models.TaskTime{
+				TaskID:          e.TaskID,
+				Name:            e.TaskName,
+				CourseID:        t.CourseID,
+				EstimateMinutes: t.EstimateMinutes,
+			})
+		}
+		totals.Tasks[i].Minutes += e.Minutes
+
+		i, ok = courseIndex[t.CourseID]
+		if !ok {
+			i = len(totals.Courses)
+			courseIndex[t.CourseID] = i
+			totals.Courses = append(totals.Courses, models.CourseTime{
+				CourseID: t.CourseID,
+				Name:     courseNames[t.CourseID],
+			})
+		}
+		totals.Courses[i].Minutes += e.Minutes
+
+		week := weekStart(e.Start.In(loc)).Format(dateLayout)
+		totals.Weeks[weekIndex[week]].Minutes += e.Minutes
+	}
+
+	sort.SliceStable(totals.Tasks, func(i, j int) bool {
+		return totals.Tasks[i].Minutes > totals.Tasks[j].Minutes
+	})
+	sort.SliceStable(totals.Courses, func(i, j int) bool {
+		return totals.Courses[i].Minutes > totals.Courses[j].Minutes
+	})
+
+	return totals, nil
+}
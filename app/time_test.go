@@ -0,0 +1,113 @@
+package app
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func TestWeekStart(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	tests := []struct {
+		day  time.Time
+		want string
+	}{
+		{time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC), "2026-10-19"},
+		{time.Date(2026, 10, 25, 23, 59, 0, 0, time.UTC), "2026-10-19"},
+		{time.Date(2026, 10, 1, 12, 0, 0, 0, time.UTC), "2026-09-28"},
+		{time.Date(2027, 1, 2, 9, 0, 0, 0, time.UTC), "2026-12-28"},
+		// the week clocks go back in
+		{time.Date(2026, 11, 4, 9, 0, 0, 0, ny), "2026-11-02"},
+		{time.Date(2026, 11, 1, 23, 0, 0, 0, ny), "2026-10-26"},
+	}
+
+	for _, tt := range tests {
+		got := weekStart(tt.day)
+		if got.Format(dateLayout) != tt.want || got.Hour() != 0 || got.Location() != tt.day.Location() {
+			t.Errorf("week of %v: got %v, want midnight on %s", tt.day, got, tt.want)
+		}
+	}
+}
+
+func TestGetTimeTotals(t *testing.T) {
+	a, userId := newTestApp(t)
+
+	timezone := "America/New_York"
+	if _, err := a.UpdateSettings(userId, models.UserSettings{Timezone: &timezone}); err != nil {
+		t.Skip(err)
+	}
+
+	courseId := addTestCourse(t, a, userId)
+	lab := models.Task{Name: "Lab report", Tag: "study", Description: "Titration", CourseID: courseId}
+	chores := models.Task{Name: "Chores", Tag: "others", Description: "Laundry"}
+	for _, task := range []*models.Task{&lab, &chores} {
+		if err := a.AddTask(userId, task); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	track := func(taskId int64, start string, minutes int) {
+		t.Helper()
+		s, err := time.Parse(time.RFC3339, start)
+		if err != nil {
+			t.Fatal(err)
+		}
+		end := s.Add(time.Duration(minutes) * time.Minute)
+		if err := a.AddTimeEntry(userId, taskId, &models.TimeEntry{Start: s, End: &end}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// sunday evening in new york, monday in utc
+	track(lab.ID, "2026-10-05T02:00:00Z", 60)
+	track(lab.ID, "2026-10-05T14:00:00Z", 30)
+	// sunday evening at the end of the range
+	track(chores.ID, "2026-10-11T23:00:00Z", 45)
+	// monday after the range in new york
+	track(chores.ID, "2026-10-12T05:00:00Z", 20)
+
+	totals, err := a.GetTimeTotals(userId, "2026-09-28", "2026-10-11")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if totals.Minutes != 135 {
+		t.Errorf("got %d minutes, want 135", totals.Minutes)
+	}
+
+	wantWeeks := []models.WeekTime{{Week: "2026-09-28", Minutes: 60}, {Week: "2026-10-05", Minutes: 75}}
+	if !reflect.DeepEqual(totals.Weeks, wantWeeks) {
+		t.Errorf("got weeks %+v, want %+v", totals.Weeks, wantWeeks)
+	}
+
+	wantTasks := []models.TaskTime{
+		{TaskID: lab.ID, Name: "Lab report", CourseID: courseId, Minutes: 90},
+		{TaskID: chores.ID, Name: "Chores", Minutes: 45},
+	}
+	if !reflect.DeepEqual(totals.Tasks, wantTasks) {
+		t.Errorf("got tasks %+v, want %+v", totals.Tasks, wantTasks)
+	}
+
+	wantCourses := []models.CourseTime{
+		{CourseID: courseId, Name: "Chemistry", Minutes: 90},
+		{CourseID: 0, Name: "", Minutes: 45},
+	}
+	if !reflect.DeepEqual(totals.Courses, wantCourses) {
+		t.Errorf("got courses %+v, want %+v", totals.Courses, wantCourses)
+	}
+
+	// a range starting mid week still buckets by the weeks' mondays
+	totals, err = a.GetTimeTotals(userId, "2026-09-30", "2026-10-05")
+	if err != nil {
+		t.Fatal(err)
+	}
+	wantWeeks = []models.WeekTime{{Week: "2026-09-28", Minutes: 60}, {Week: "2026-10-05", Minutes: 30}}
+	if !reflect.DeepEqual(totals.Weeks, wantWeeks) {
+		t.Errorf("mid week: got weeks %+v, want %+v", totals.Weeks, wantWeeks)
+	}
+}
@@ -0,0 +1,62 @@
+package app
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func (a *app) GetTrash(userId int64) ([]models.Task, error) {
+	tasks, err := a.repo.GetTrashedTasks(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting trash from database: %v", err)
+	}
+
+	for i := range tasks {
+		tasks[i].UpdateProgress()
+	}
+
+	return tasks, nil
+}
+
+// RestoreTask takes a task out of the trash, with its checklist and
+// reminders as they were.
+func (a *app) RestoreTask(userId, taskId int64) (models.Task, error) {
+	t, err := a.repo.GetTrashedTask(userId, taskId)
+	if err != nil {
+		return models.Task{}, fmt.Errorf("error getting task from trash: %w", err)
+	}
+
+	err = a.inTransaction(func(a *app) error {
+		if err := a.repo.RestoreTask(taskId); err != nil {
+			return fmt.Errorf("error restoring task in database: %v", err)
+		}
+
+		t.TimeDeleted = nil
+		t.Version++
+		t.UpdateProgress()
+		return a.recordRevision(userId, "restore", nil, t)
+	})
+	if err != nil {
+		return models.Task{}, err
+	}
+	return t, nil
+}
+
+// PurgeTrash permanently deletes tasks that have been in the trash for
+// longer than the retention period.
+func (a *app) PurgeTrash() error {
+	days, err := strconv.Atoi(os.Getenv("TRASH_RETENTION_DAYS"))
+	if err != nil {
+		days = 30 // default retention if env isn't set
+	}
+
+	if _, err := a.repo.PurgeTrashedTasks(time.Now().AddDate(0, 0, -days)); err != nil {
+		return fmt.Errorf("error purging trash: %v", err)
+	}
+
+	return nil
+}
@@ -8,7 +8,7 @@ import (
 
 	"github.com/michaelcosj/stms/framework"
 	"github.com/michaelcosj/stms/models"
-	"github.com/redis/go-redis/v9"
+	"github.com/michaelcosj/stms/repository"
 	"golang.org/x/crypto/bcrypt"
 )
 
@@ -60,29 +60,31 @@ func (a *app) SendVerificationCode(email string) error {
 	}
 	expiry := time.Duration(exp_hrs) * time.Hour
 
-	if err := a.cache.Set(ctx, code, email, expiry).Err(); err != nil {
-		return fmt.Errorf("error caching code: %v", err)
-	}
-
 	emailData := framework.EmailData{
-		Code:    code,
-		Subject: "Email Verification",
+		Template: framework.TemplateVerification,
+		Code:     code,
+		Subject:  "Email Verification",
+		Fields: map[string]string{
+			"expiry": time.Now().Add(expiry).UTC().Format(time.RFC1123),
+		},
 	}
 
-	if err := framework.SendEmail(email, emailData); err != nil {
-		return fmt.Errorf("error sending email: %v", err)
+	// the code is only kept if the email sending it is queued too
+	mail := newOutboxEmail(email, emailData)
+	if err := a.repo.AddVerificationCode(code, email, time.Now().Add(expiry), mail); err != nil {
+		return fmt.Errorf("error saving code: %v", err)
 	}
 
 	return nil
 }
 
 func (a *app) VerifyUser(code string) (models.User, error) {
-	email, err := a.cache.Get(ctx, code).Result()
+	email, err := a.repo.GetVerificationCode(code, time.Now())
 	if err != nil {
-		if err == redis.Nil {
+		if err == repository.ErrCodeNotFound {
 			return models.User{}, fmt.Errorf("code expired or invalid")
 		}
-		return models.User{}, fmt.Errorf("error getting user from cache: %v", err)
+		return models.User{}, fmt.Errorf("error getting code from database: %v", err)
 	}
 
 	user, err := a.repo.GetUserByEmail(email)
@@ -95,8 +97,14 @@ func (a *app) VerifyUser(code string) (models.User, error) {
 	}
 
 	user.IsVerified = true
-	if err := a.repo.UpdateUser(user.ID, user); err != nil {
-		return models.User{}, fmt.Errorf("error updating user from database: %v", err)
+	err = a.inTransaction(func(a *app) error {
+		if err := a.repo.UpdateUser(user.ID, user); err != nil {
+			return fmt.Errorf("error updating user from database: %v", err)
+		}
+		return a.repo.DeleteVerificationCode(code)
+	})
+	if err != nil {
+		return models.User{}, err
 	}
 
 	return user, nil
@@ -125,3 +133,94 @@ func (a *app) GetUser(email, password string) (models.User, string, error) {
 
 	return user, token, nil
 }
+
+// GetProfile returns the user along with their streak and badges.
+func (a *app) GetProfile(userId int64) (models.User, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.User{}, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	streak, err := a.getStreak(user)
+	if err != nil {
+		return models.User{}, err
+	}
+	user.Streak = &streak
+
+	if user.Badges, err = a.GetBadges(userId); err != nil {
+		return models.User{}, err
+	}
+
+	return user, nil
+}
+
+func (a *app) UpdateSettings(userId int64, s models.UserSettings) (models.User, error) {
+	user, err := a.repo.GetUserByID(userId)
+	if err != nil {
+		return models.User{}, fmt.Errorf("error getting user from database: %w", err)
+	}
+
+	if s.Timezone != nil {
+		user.Timezone = *s.Timezone
+	}
+
+	if s.QuietHoursStart != nil {
+		user.QuietHoursStart = *s.QuietHoursStart
+	}
+
+	if s.QuietHoursEnd != nil {
+		user.QuietHoursEnd = *s.QuietHoursEnd
+	}
+
+	if s.PomodoroWorkMinutes != nil {
+		user.PomodoroWorkMinutes = *s.PomodoroWorkMinutes
+	}
+
+	if s.PomodoroBreakMinutes != nil {
+		user.PomodoroBreakMinutes = *s.PomodoroBreakMinutes
+	}
+
+	if s.AgendaEmails != nil {
+		user.AgendaEmails = *s.AgendaEmails
+	}
+
+	if s.DigestEmails != nil {
+		user.DigestEmails = *s.DigestEmails
+	}
+
+	if s.AgendaTime != nil {
+		user.AgendaTime = *s.AgendaTime
+	}
+
+	if err := validateSettings(user); err != nil {
+		return models.User{}, err
+	}
+
+	if err := a.repo.UpdateUser(userId, user); err != nil {
+		return models.User{}, fmt.Errorf("error updating user in database: %v", err)
+	}
+
+	return user, nil
+}
+
+func validateSettings(user models.User) error {
+	if _, err := time.LoadLocation(user.Timezone); err != nil || user.Timezone == "" {
+		return fmt.Errorf("invalid timezone %q", user.Timezone)
+	}
+
+	for _, clock := range []string{user.QuietHoursStart, user.QuietHoursEnd} {
+		if _, err := parseClock(clock); clock != "" && err != nil {
+			return fmt.Errorf("invalid quiet hours: %v", err)
+		}
+	}
+
+	if (user.QuietHoursStart == "") != (user.QuietHoursEnd == "") {
+		return fmt.Errorf("quiet hours need both a start and an end")
+	}
+
+	if _, err := parseClock(user.AgendaTime); err != nil {
+		return fmt.Errorf("invalid agenda time: %v", err)
+	}
+
+	return validatePomodoro(user.PomodoroWorkMinutes, user.PomodoroBreakMinutes)
+}
@@ -0,0 +1,55 @@
+package app
+
+import "testing"
+
+func TestVerifyUser(t *testing.T) {
+	a, userId := newTestApp(t)
+
+	if err := a.SendVerificationCode("student@stms.test"); err != nil {
+		t.Fatal(err)
+	}
+
+	// the code goes out with the email it was saved with
+	emails, err := a.repo.GetOutboxEmails("pending", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(emails) != 1 || emails[0].Code == "" {
+		t.Fatalf("got %d emails queued, want the code sent once", len(emails))
+	}
+	code := emails[0].Code
+
+	if _, err := a.VerifyUser("not-" + code); err == nil {
+		t.Error("verified the user with a wrong code")
+	}
+
+	user, err := a.VerifyUser(code)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if user.ID != userId || !user.IsVerified {
+		t.Errorf("got user %d verified %v", user.ID, user.IsVerified)
+	}
+
+	// a code is only used once
+	if _, err := a.VerifyUser(code); err == nil {
+		t.Error("verified the user again with a used code")
+	}
+}
+
+func TestVerifyUserExpiredCode(t *testing.T) {
+	t.Setenv("OTP_EXPIRY_HOURS", "0")
+
+	a, _ := newTestApp(t)
+	if err := a.SendVerificationCode("student@stms.test"); err != nil {
+		t.Fatal(err)
+	}
+
+	emails, err := a.repo.GetOutboxEmails("pending", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := a.VerifyUser(emails[0].Code); err == nil {
+		t.Error("verified the user with an expired code")
+	}
+}
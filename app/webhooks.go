@@ -0,0 +1,315 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"time"
+
+	"github.com/michaelcosj/stms/framework"
+	"github.com/michaelcosj/stms/models"
+)
+
+const (
+	// sent to a single webhook to check it's set up right
+	EventWebhookPing = "ping"
+	// sent to the user's live streams when a webhook is disabled
+	EventWebhookDisabled = "webhook.disabled"
+)
+
+// the events a webhook can subscribe to, no event types means all of them
+var webhookEventTypes = []string{EventTaskCreated, EventTaskUpdated, EventTaskDeleted}
+
+const (
+	maxWebhooksPerUser = 10
+	// deliveries sent per run of the webhook worker
+	webhookBatchSize = 50
+	// how long a delivery is held by the worker sending it
+	webhookLease = 5 * time.Minute
+)
+
+// webhookPayload is the body POSTed to a webhook
+type webhookPayload struct {
+	ID        int64           `json:"id"`
+	Type      string          `json:"type"`
+	CreatedAt time.Time       `json:"created_at"`
+	Data      json.RawMessage `json:"data"`
+}
+
+func isWebhookEventType(eventType string) bool {
+	for _, t := range webhookEventTypes {
+		if t == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+func validateWebhook(w models.Webhook) error {
+	if !framework.IsValidWebhookURL(w.URL) {
+		return fmt.Errorf("invalid webhook url %q, expected an http or https url", w.URL)
+	}
+
+	seen := make(map[string]bool)
+	for _, t := range w.EventTypes {
+		if !isWebhookEventType(t) {
+			return fmt.Errorf("invalid event type %s, expected one of %v", t, webhookEventTypes)
+		}
+		if seen[t] {
+			return fmt.Errorf("event type %s is listed twice", t)
+		}
+		seen[t] = true
+	}
+
+	return nil
+}
+
+// AddWebhook subscribes a url to the user's task events. A secret is
+// generated when none is given, it's only returned here.
+func (a *app) AddWebhook(userId int64, w *models.Webhook) error {
+	webhooks, err := a.repo.GetWebhooks(userId)
+	if err != nil {
+		return fmt.Errorf("error getting webhooks from database: %v", err)
+	}
+
+	if len(webhooks) >= maxWebhooksPerUser {
+		return fmt.Errorf("a user can't have more than %d webhooks", maxWebhooksPerUser)
+	}
+
+	if w.EventTypes == nil {
+		w.EventTypes = []string{}
+	}
+
+	if err := validateWebhook(*w); err != nil {
+		return err
+	}
+
+	if w.Secret == "" {
+		if w.Secret, err = framework.CreateToken(32); err != nil {
+			return err
+		}
+	} else if len(w.Secret) < 16 {
+		return fmt.Errorf("webhook secret must be at least 16 characters")
+	}
+
+	w.UserID = userId
+	w.IsActive = true
+	w.Failures = 0
+	w.TimeCreated = time.Now()
+
+	w.ID, err = a.repo.AddWebhook(userId, *w)
+	if err != nil {
+		return fmt.Errorf("error adding webhook to database: %v", err)
+	}
+
+	return nil
+}
+
+func (a *app) GetWebhooks(userId int64) ([]models.Webhook, error) {
+	webhooks, err := a.repo.GetWebhooks(userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhooks from database: %v", err)
+	}
+
+	for i := range webhooks {
+		webhooks[i].Secret = ""
+	}
+
+	return webhooks, nil
+}
+
+// UpdateWebhook changes the fields that are set. Enabling a webhook clears
+// its failures and lets its pending deliveries go out again.
+func (a *app) UpdateWebhook(userId, webhookId int64, url *string, eventTypes *[]string, isActive *bool) (models.Webhook, error) {
+	w, err := a.repo.GetWebhook(userId, webhookId)
+	if err != nil {
+		return models.Webhook{}, fmt.Errorf("error getting webhook from database: %w", err)
+	}
+
+	if url != nil {
+		w.URL = *url
+	}
+
+	if eventTypes != nil {
+		w.EventTypes = *eventTypes
+		if w.EventTypes == nil {
+			w.EventTypes = []string{}
+		}
+	}
+
+	if isActive != nil {
+		if *isActive && !w.IsActive {
+			w.Failures = 0
+		}
+		w.IsActive = *isActive
+	}
+
+	if err := validateWebhook(w); err != nil {
+		return models.Webhook{}, err
+	}
+
+	if err := a.repo.UpdateWebhook(webhookId, w); err != nil {
+		return models.Webhook{}, fmt.Errorf("error updating webhook: %v", err)
+	}
+
+	w.Secret = ""
+	return w, nil
+}
+
+func (a *app) DeleteWebhook(userId, webhookId int64) error {
+	if _, err := a.repo.GetWebhook(userId, webhookId); err != nil {
+		return fmt.Errorf("error getting webhook from database: %w", err)
+	}
+
+	if err := a.repo.DeleteWebhook(webhookId); err != nil {
+		return fmt.Errorf("error deleting webhook: %v", err)
+	}
+
+	return nil
+}
+
+// GetWebhookDeliveries returns the latest deliveries of a webhook, of any
+// status when status is empty.
+func (a *app) GetWebhookDeliveries(userId, webhookId int64, status string) ([]models.WebhookDelivery, error) {
+	switch status {
+	case "", "pending", "delivered", "dead":
+	default:
+		return nil, fmt.Errorf("invalid status %s, expected pending, delivered or dead", status)
+	}
+
+	if _, err := a.repo.GetWebhook(userId, webhookId); err != nil {
+		return nil, fmt.Errorf("error getting webhook from database: %w", err)
+	}
+
+	deliveries, err := a.repo.GetWebhookDeliveries(webhookId, status, 100)
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhook deliveries from database: %v", err)
+	}
+
+	return deliveries, nil
+}
+
+// PingWebhook queues a ping event for a webhook, the worker delivers it
+// like any other event.
+func (a *app) PingWebhook(userId, webhookId int64) (models.WebhookDelivery, error) {
+	w, err := a.repo.GetWebhook(userId, webhookId)
+	if err != nil {
+		return models.WebhookDelivery{}, fmt.Errorf("error getting webhook from database: %w", err)
+	}
+
+	if !w.IsActive {
+		return models.WebhookDelivery{}, fmt.Errorf("webhook is disabled, enable it before pinging it")
+	}
+
+	payload, err := json.Marshal(map[string]int64{"webhook_id": w.ID})
+	if err != nil {
+		return models.WebhookDelivery{}, fmt.Errorf("error encoding ping: %v", err)
+	}
+
+	now := time.Now()
+	d := models.WebhookDelivery{
+		WebhookID:   w.ID,
+		EventType:   EventWebhookPing,
+		Payload:     payload,
+		Status:      "pending",
+		NextAttempt: now,
+		TimeCreated: now,
+	}
+
+	if d.ID, err = a.repo.AddWebhookDelivery(d); err != nil {
+		return models.WebhookDelivery{}, fmt.Errorf("error queueing ping: %v", err)
+	}
+
+	return d, nil
+}
+
+// queueWebhooks queues an event for the user's webhooks. Like publishing
+// to the live streams, a failure here doesn't fail the change itself.
+func (a *app) queueWebhooks(userId int64, eventType string, data interface{}) {
+	if !isWebhookEventType(eventType) {
+		return
+	}
+
+	payload, err := json.Marshal(data)
+	if err != nil {
+		log.Printf("error encoding %s webhook payload: %v", eventType, err)
+		return
+	}
+
+	if _, err := a.repo.QueueWebhookDeliveries(userId, eventType, payload, time.Now()); err != nil {
+		log.Printf("error queueing %s webhooks: %v", eventType, err)
+	}
+}
+
+// DeliverWebhooks sends the queued webhook deliveries that are due. Failed
+// deliveries are retried with the same backoff as the email outbox and
+// marked dead after WEBHOOK_MAX_ATTEMPTS tries, a webhook is disabled after
+// WEBHOOK_MAX_FAILURES failed attempts in a row.
+func (a *app) DeliverWebhooks() error {
+	maxAttempts, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_ATTEMPTS"))
+	if err != nil {
+		maxAttempts = 8 // default attempts if env isn't set
+	}
+
+	maxFailures, err := strconv.Atoi(os.Getenv("WEBHOOK_MAX_FAILURES"))
+	if err != nil {
+		maxFailures = 20 // default failures if env isn't set
+	}
+
+	now := time.Now()
+	deliveries, err := a.repo.GetDueWebhookDeliveries(now, webhookBatchSize)
+	if err != nil {
+		return fmt.Errorf("error getting due webhook deliveries: %v", err)
+	}
+
+	var errs []error
+	for _, d := range deliveries {
+		leased, err := a.repo.LeaseWebhookDelivery(d.ID, now, now.Add(webhookLease))
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		if !leased {
+			continue
+		}
+
+		body, err := json.Marshal(webhookPayload{
+			ID:        d.ID,
+			Type:      d.EventType,
+			CreatedAt: d.TimeCreated,
+			Data:      d.Payload,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("error encoding webhook delivery %d: %v", d.ID, err))
+			continue
+		}
+
+		status, sendErr := framework.PostWebhook(d.URL, d.Secret, d.EventType, d.ID, body)
+		if sendErr == nil {
+			if err := a.repo.MarkWebhookDelivered(d, status, time.Now()); err != nil {
+				errs = append(errs, err)
+			}
+			continue
+		}
+
+		attempts := d.Attempts + 1
+		dead := attempts >= maxAttempts
+		next := time.Now().Add(outboxBackoff(attempts))
+		disabled, err := a.repo.MarkWebhookDeliveryFailed(
+			d, attempts, status, sendErr.Error(), next, dead, maxFailures,
+		)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+		if disabled {
+			a.publish(d.UserID, EventWebhookDisabled, map[string]int64{"id": d.WebhookID})
+		}
+		errs = append(errs, fmt.Errorf("error sending webhook delivery %d: %v", d.ID, sendErr))
+	}
+
+	return errors.Join(errs...)
+}
@@ -0,0 +1,214 @@
+package app
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/michaelcosj/stms/framework/database"
+	"github.com/michaelcosj/stms/migrations"
+	"github.com/michaelcosj/stms/models"
+	"github.com/michaelcosj/stms/repository"
+)
+
+// newTestApp returns an app on a fresh sqlite database, without a cache or
+// event bus, along with a user of it
+func newTestApp(t *testing.T) (*app, int64) {
+	t.Helper()
+
+	db, err := database.InitDb(filepath.Join(t.TempDir(), "stms.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := migrations.RunMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+
+	repo := repository.InitRepo(db)
+	userId, err := repo.NewUser(models.User{Email: "student@stms.test", Username: "student", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &app{repo: repo}, userId
+}
+
+// webhookReceiver is a local webhook endpoint that responds with the given
+// statuses in turn, the last one for every request after them
+func webhookReceiver(t *testing.T, statuses ...int) (*httptest.Server, *atomic.Int64) {
+	t.Helper()
+
+	// the receiver listens on a loopback address
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE", "1")
+
+	var requests atomic.Int64
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := int(requests.Add(1))
+		if n > len(statuses) {
+			n = len(statuses)
+		}
+		w.WriteHeader(statuses[n-1])
+	}))
+	t.Cleanup(srv.Close)
+
+	return srv, &requests
+}
+
+func addTestWebhook(t *testing.T, a *app, userId int64, url string) int64 {
+	t.Helper()
+
+	webhookId, err := a.repo.AddWebhook(userId, models.Webhook{
+		URL:         url,
+		EventTypes:  []string{},
+		Secret:      "whsec_0123456789abcdef",
+		TimeCreated: time.Now(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return webhookId
+}
+
+// retryDue makes the webhook's pending deliveries due now
+func retryDue(t *testing.T, a *app, webhookId int64) {
+	t.Helper()
+
+	deliveries, err := a.repo.GetWebhookDeliveries(webhookId, "pending", 100)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, d := range deliveries {
+		if _, err := a.repo.LeaseWebhookDelivery(d.ID, d.NextAttempt, time.Now().Add(-time.Second)); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
+
+func TestDeliverWebhooksRetriesWithBackoff(t *testing.T) {
+	a, userId := newTestApp(t)
+	srv, requests := webhookReceiver(t, http.StatusInternalServerError, http.StatusBadGateway, http.StatusOK)
+	webhookId := addTestWebhook(t, a, userId, srv.URL)
+
+	a.publish(userId, EventTaskCreated, map[string]int64{"id": 1})
+
+	for attempt := 1; attempt <= 2; attempt++ {
+		before := time.Now()
+		if err := a.DeliverWebhooks(); err == nil {
+			t.Fatalf("attempt %d: got no error for a failed delivery", attempt)
+		}
+
+		deliveries, err := a.repo.GetWebhookDeliveries(webhookId, "", 10)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d := deliveries[0]
+		if d.Status != "pending" || d.Attempts != attempt {
+			t.Fatalf("attempt %d: got status %s after %d attempts", attempt, d.Status, d.Attempts)
+		}
+
+		// each retry waits twice as long as the one before
+		wait := d.NextAttempt.Sub(before)
+		if want := outboxBackoff(attempt); wait < want || wait > want+time.Minute {
+			t.Errorf("attempt %d: retried after %v, want %v", attempt, wait, want)
+		}
+
+		// nothing is sent again before then
+		if err := a.DeliverWebhooks(); err != nil {
+			t.Fatal(err)
+		}
+		if got := requests.Load(); got != int64(attempt) {
+			t.Fatalf("attempt %d: got %d requests", attempt, got)
+		}
+
+		retryDue(t, a, webhookId)
+	}
+
+	if err := a.DeliverWebhooks(); err != nil {
+		t.Fatalf("third attempt: %v", err)
+	}
+
+	deliveries, err := a.repo.GetWebhookDeliveries(webhookId, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := deliveries[0]; d.Status != "delivered" || d.Attempts != 3 || d.ResponseStatus != http.StatusOK {
+		t.Errorf("got status %s, %d attempts and response %d", d.Status, d.Attempts, d.ResponseStatus)
+	}
+
+	// a delivery resets the failures in a row
+	w, err := a.repo.GetWebhook(userId, webhookId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.Failures != 0 || !w.IsActive {
+		t.Errorf("got %d failures and active %v", w.Failures, w.IsActive)
+	}
+}
+
+func TestDeliverWebhooksGivesUpAfterMaxAttempts(t *testing.T) {
+	t.Setenv("WEBHOOK_MAX_ATTEMPTS", "2")
+
+	a, userId := newTestApp(t)
+	srv, _ := webhookReceiver(t, http.StatusServiceUnavailable)
+	webhookId := addTestWebhook(t, a, userId, srv.URL)
+
+	a.publish(userId, EventTaskCreated, map[string]int64{"id": 1})
+
+	a.DeliverWebhooks()
+	retryDue(t, a, webhookId)
+	a.DeliverWebhooks()
+
+	deliveries, err := a.repo.GetWebhookDeliveries(webhookId, "", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if d := deliveries[0]; d.Status != "dead" || d.Attempts != 2 || d.ResponseStatus != http.StatusServiceUnavailable {
+		t.Errorf("got status %s, %d attempts and response %d", d.Status, d.Attempts, d.ResponseStatus)
+	}
+}
+
+func TestDeliverWebhooksDisablesFailingWebhook(t *testing.T) {
+	t.Setenv("WEBHOOK_MAX_FAILURES", "3")
+
+	a, userId := newTestApp(t)
+	srv, requests := webhookReceiver(t, http.StatusInternalServerError)
+	webhookId := addTestWebhook(t, a, userId, srv.URL)
+
+	// failing deliveries of different events add up
+	for i := 0; i < 3; i++ {
+		a.publish(userId, EventTaskCreated, map[string]int64{"id": int64(i + 1)})
+	}
+
+	a.DeliverWebhooks()
+
+	w, err := a.repo.GetWebhook(userId, webhookId)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w.IsActive || w.Failures != 3 {
+		t.Fatalf("got active %v after %d failures, want it disabled after 3", w.IsActive, w.Failures)
+	}
+
+	// a disabled webhook isn't sent its pending deliveries or new events
+	retryDue(t, a, webhookId)
+	a.publish(userId, EventTaskCreated, map[string]int64{"id": 4})
+	if err := a.DeliverWebhooks(); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("got %d requests, want 3", got)
+	}
+
+	pending, err := a.repo.GetWebhookDeliveries(webhookId, "pending", 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pending) != 3 {
+		t.Errorf("got %d pending deliveries, want the 3 failed ones kept", len(pending))
+	}
+}
@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/michaelcosj/stms/framework"
+)
+
+// initMailer sets up the mailer picked by MAIL_DRIVER: "smtp", "file" or
+// "console" (the default) which prints emails to stdout.
+func initMailer() (framework.Mailer, error) {
+	from := os.Getenv("MAIL_FROM")
+	if from == "" {
+		from = "STMS <stms@localhost>"
+	}
+
+	switch driver := os.Getenv("MAIL_DRIVER"); driver {
+	case "smtp":
+		return framework.NewSMTPMailer(framework.SMTPConfig{
+			Host:               os.Getenv("SMTP_HOST"),
+			Port:               os.Getenv("SMTP_PORT"),
+			Username:           os.Getenv("SMTP_USERNAME"),
+			Password:           os.Getenv("SMTP_PASSWORD"),
+			From:               from,
+			StartTLS:           os.Getenv("SMTP_STARTTLS") != "false",
+			InsecureSkipVerify: os.Getenv("SMTP_INSECURE_SKIP_VERIFY") == "true",
+		}), nil
+	case "file":
+		dir := os.Getenv("MAIL_DIR")
+		if dir == "" {
+			dir = "./mail"
+		}
+		return framework.NewFileMailer(from, dir)
+	case "", "console":
+		return framework.NewConsoleMailer(from, nil), nil
+	default:
+		return nil, fmt.Errorf("unknown mail driver %s", driver)
+	}
+}
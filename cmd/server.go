@@ -3,10 +3,15 @@ package cmd
 import (
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/michaelcosj/stms/app"
+	"github.com/michaelcosj/stms/framework"
 	"github.com/michaelcosj/stms/framework/cache"
 	"github.com/michaelcosj/stms/framework/database"
+	"github.com/michaelcosj/stms/framework/events"
+	"github.com/michaelcosj/stms/framework/scheduler"
 	"github.com/michaelcosj/stms/handlers"
 	"github.com/michaelcosj/stms/migrations"
 	"github.com/michaelcosj/stms/repository"
@@ -26,14 +31,56 @@ func Run() error {
 		return fmt.Errorf("error migrating database: %v", err)
 	}
 
+	// setup mailer
+	mailer, err := initMailer()
+	if err != nil {
+		return fmt.Errorf("error initialising mailer: %v", err)
+	}
+	framework.SetMailer(mailer)
+
 	// setup cache
 	cache := cache.InitCache(os.Getenv("REDIS_PORT"))
 
 	// Initialise repository, service and handler
 	repo := repository.InitRepo(db)
-	service := app.InitAppService(repo, cache)
+	bus := events.InitBus(cache)
+	defer bus.Close()
+
+	service := app.InitAppService(repo, cache, bus)
 	handler := handlers.InitHandler(service)
 
+	// Start background jobs, they're stopped once the router shuts down
+	reminderSecs, err := strconv.Atoi(os.Getenv("REMINDER_INTERVAL_SECONDS"))
+	if err != nil || reminderSecs <= 0 {
+		reminderSecs = 60 // default interval if env isn't set
+	}
+
+	jobs := scheduler.InitScheduler()
+	jobs.Every("due reminders", time.Duration(reminderSecs)*time.Second, service.SendDueReminders)
+
+	scheduledSecs, err := strconv.Atoi(os.Getenv("SCHEDULED_EMAIL_INTERVAL_SECONDS"))
+	if err != nil || scheduledSecs <= 0 {
+		scheduledSecs = 300 // default interval if env isn't set
+	}
+	jobs.Every("scheduled emails", time.Duration(scheduledSecs)*time.Second, service.SendScheduledEmails)
+
+	outboxSecs, err := strconv.Atoi(os.Getenv("OUTBOX_INTERVAL_SECONDS"))
+	if err != nil || outboxSecs <= 0 {
+		outboxSecs = 15 // default interval if env isn't set
+	}
+	jobs.Every("email outbox", time.Duration(outboxSecs)*time.Second, service.DeliverOutbox)
+
+	webhookSecs, err := strconv.Atoi(os.Getenv("WEBHOOK_INTERVAL_SECONDS"))
+	if err != nil || webhookSecs <= 0 {
+		webhookSecs = 10 // default interval if env isn't set
+	}
+	jobs.Every("webhooks", time.Duration(webhookSecs)*time.Second, service.DeliverWebhooks)
+
+	// the retention period is in days, checking hourly is plenty
+	jobs.Every("trash purge", time.Hour, service.PurgeTrash)
+	jobs.Start()
+	defer jobs.Stop()
+
 	// Run the router
 	port := os.Getenv("SERVER_PORT")
 	if port == "" {
@@ -3,12 +3,24 @@ package database
 import (
 	"database/sql"
 	"fmt"
+	"strings"
 
 	_ "github.com/mattn/go-sqlite3"
 )
 
+// sqlite settings for the background jobs writing alongside requests. WAL
+// lets reads go on during a write, transactions take the write lock when
+// they begin so they can't deadlock upgrading a read lock, and writers wait
+// for the lock instead of failing with "database is locked".
+const dbOptions = "_journal_mode=WAL&_busy_timeout=10000&_txlock=immediate"
+
 func InitDb(dbFilePath string) (*sql.DB, error) {
-	db, err := sql.Open("sqlite3", dbFilePath)
+	sep := "?"
+	if strings.Contains(dbFilePath, "?") {
+		sep = "&"
+	}
+
+	db, err := sql.Open("sqlite3", dbFilePath+sep+dbOptions)
 	if err != nil {
 		return nil, fmt.Errorf("error initialising database: %v", err)
 	}
@@ -1,14 +1,107 @@
 package framework
 
-import "fmt"
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"embed"
+	"encoding/hex"
+	"fmt"
+	htmltemplate "html/template"
+	"sync"
+	texttemplate "text/template"
+)
+
+// email templates, each has a .txt and a .html version
+const (
+	TemplateVerification  = "verification"
+	TemplatePasswordReset = "password_reset"
+	TemplateReminder      = "reminder"
+	TemplateAgenda        = "agenda"
+	TemplateDigest        = "digest"
+)
+
+//go:embed templates
+var templateFS embed.FS
+
+var (
+	textTemplates = texttemplate.Must(texttemplate.ParseFS(templateFS, "templates/*.txt"))
+	htmlTemplates = htmltemplate.Must(htmltemplate.ParseFS(templateFS, "templates/*.html"))
+)
 
 type EmailData struct {
-	Code    string
-	Subject string
+	Template string
+	Subject  string
+	Code     string
+	Message  string
+
+	// extra values used by the template
+	Fields map[string]string
 }
 
+var (
+	mailerMu sync.RWMutex
+	mailer   Mailer = NewConsoleMailer("stms@localhost", nil)
+)
+
+// SetMailer sets the mailer used by SendEmail, emails are printed to stdout
+// until one is set.
+func SetMailer(m Mailer) {
+	mailerMu.Lock()
+	defer mailerMu.Unlock()
+	mailer = m
+}
+
+// SendEmail renders the email's template and sends it with the configured
+// mailer.
 func SendEmail(userEmail string, emailData EmailData) error {
-	// TODO: integrate a mailing service
-	fmt.Println(emailData)
-	return nil
+	msg, err := RenderEmail(userEmail, emailData)
+	if err != nil {
+		return err
+	}
+
+	mailerMu.RLock()
+	m := mailer
+	mailerMu.RUnlock()
+
+	return m.Send(msg)
+}
+
+// RenderEmail builds the text and html bodies of an email from its template.
+func RenderEmail(userEmail string, emailData EmailData) (Message, error) {
+	if emailData.Template == "" {
+		return Message{}, fmt.Errorf("email %q has no template", emailData.Subject)
+	}
+
+	var text, html bytes.Buffer
+	if err := textTemplates.ExecuteTemplate(&text, emailData.Template+".txt", emailData); err != nil {
+		return Message{}, fmt.Errorf("error rendering email text: %v", err)
+	}
+
+	if err := htmlTemplates.ExecuteTemplate(&html, emailData.Template+".html", emailData); err != nil {
+		return Message{}, fmt.Errorf("error rendering email html: %v", err)
+	}
+
+	return Message{
+		To:             userEmail,
+		Subject:        emailData.Subject,
+		Text:           text.String(),
+		HTML:           html.String(),
+		UnsubscribeURL: emailData.Fields["unsubscribe_url"],
+	}, nil
+}
+
+// UnsubscribeToken signs an unsubscribe link for a user's email list, so it
+// works without logging in.
+func UnsubscribeToken(secret string, userId int64, list string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "unsubscribe.%d.%s", userId, list)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// ValidUnsubscribeToken reports whether the token was made by
+// UnsubscribeToken for the user and list.
+func ValidUnsubscribeToken(secret string, userId int64, list, token string) bool {
+	expected := UnsubscribeToken(secret, userId, list)
+	return hmac.Equal([]byte(expected), []byte(token))
 }
@@ -0,0 +1,44 @@
+package framework
+
+import "testing"
+
+func TestUnsubscribeToken(t *testing.T) {
+	const secret = "access-token-secret"
+	token := UnsubscribeToken(secret, 1, "agenda")
+
+	if len(token) != 64 {
+		t.Errorf("got token %q, want a hex sha256", token)
+	}
+	if UnsubscribeToken(secret, 1, "agenda") != token {
+		t.Error("token isn't the same each time")
+	}
+
+	tampered := token[:63] + "0"
+	if token[63] == '0' {
+		tampered = token[:63] + "1"
+	}
+
+	tests := []struct {
+		name   string
+		secret string
+		userId int64
+		list   string
+		token  string
+		want   bool
+	}{
+		{"same link", secret, 1, "agenda", token, true},
+		{"other user", secret, 2, "agenda", token, false},
+		{"other list", secret, 1, "digest", token, false},
+		{"all lists", secret, 1, "all", token, false},
+		{"other secret", "another secret", 1, "agenda", token, false},
+		{"tampered token", secret, 1, "agenda", tampered, false},
+		{"short token", secret, 1, "agenda", token[:32], false},
+		{"empty token", secret, 1, "agenda", "", false},
+	}
+
+	for _, tt := range tests {
+		if got := ValidUnsubscribeToken(tt.secret, tt.userId, tt.list, tt.token); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,284 @@
+package events
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+const (
+	// every instance subscribes to this channel and passes the events on
+	// to its own subscribers
+	eventsChannel = "events"
+
+	// the recent events of each user are kept in a stream so clients can
+	// resume from the last event they saw
+	streamMaxLen = 1000
+	streamExpiry = 24 * time.Hour
+
+	// events buffered for a slow subscriber before it's dropped, it can
+	// reconnect and resume from its last event
+	subscriberBuffer = 64
+)
+
+var ctx = context.Background()
+
+// Event is a change to one of a user's resources. ID is the id of the
+// event in the user's stream, ids increase so they can be used to resume.
+type Event struct {
+	ID     string          `json:"id"`
+	UserID int64           `json:"user_id"`
+	Type   string          `json:"type"`
+	Data   json.RawMessage `json:"data"`
+}
+
+// Subscription receives a user's events until it's closed. Events is
+// closed when the subscriber falls behind or the bus is closed.
+type Subscription struct {
+	Events <-chan Event
+	close  func()
+}
+
+func (s *Subscription) Close() {
+	s.close()
+}
+
+type subscriber struct {
+	userId int64
+	events chan Event
+}
+
+type bus struct {
+	client *redis.Client
+	pubsub *redis.PubSub
+
+	mu     sync.Mutex
+	subs   map[*subscriber]struct{}
+	closed bool
+}
+
+type Bus interface {
+	Publish(userId int64, eventType string, data interface{}) error
+	Subscribe(userId int64, lastEventId string) (*Subscription, error)
+	Close() error
+}
+
+// InitBus starts the event bus. Events are published through redis so
+// subscribers connected to any instance receive them.
+func InitBus(client *redis.Client) Bus {
+	b := &bus{
+		client: client,
+		pubsub: client.Subscribe(ctx, eventsChannel),
+		subs:   make(map[*subscriber]struct{}),
+	}
+
+	go b.run()
+	return b
+}
+
+func streamKey(userId int64) string {
+	return fmt.Sprintf("events:%d", userId)
+}
+
+// Publish adds an event to the user's stream and sends it to their
+// subscribers.
+func (b *bus) Publish(userId int64, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return fmt.Errorf("error encoding event: %v", err)
+	}
+
+	key := streamKey(userId)
+	id, err := b.client.XAdd(ctx, &redis.XAddArgs{
+		Stream: key,
+		MaxLen: streamMaxLen,
+		Approx: true,
+		Values: map[string]interface{}{"type": eventType, "data": string(payload)},
+	}).Result()
+	if err != nil {
+		return fmt.Errorf("error adding event to stream: %v", err)
+	}
+
+	if err := b.client.Expire(ctx, key, streamExpiry).Err(); err != nil {
+		return fmt.Errorf("error setting event stream expiry: %v", err)
+	}
+
+	msg, err := json.Marshal(Event{ID: id, UserID: userId, Type: eventType, Data: payload})
+	if err != nil {
+		return fmt.Errorf("error encoding event: %v", err)
+	}
+
+	if err := b.client.Publish(ctx, eventsChannel, msg).Err(); err != nil {
+		return fmt.Errorf("error publishing event: %v", err)
+	}
+
+	return nil
+}
+
+// Subscribe receives the user's events. With a last event id the events
+// after it that are still in the stream are sent first.
+func (b *bus) Subscribe(userId int64, lastEventId string) (*Subscription, error) {
+	var last streamID
+	if lastEventId != "" {
+		var err error
+		if last, err = parseStreamID(lastEventId); err != nil {
+			return nil, err
+		}
+	}
+
+	// subscribe before reading the stream so no event is missed in between,
+	// the live events already replayed are skipped
+	sub := &subscriber{userId: userId, events: make(chan Event, subscriberBuffer)}
+	b.mu.Lock()
+	if b.closed {
+		b.mu.Unlock()
+		return nil, fmt.Errorf("event bus is closed")
+	}
+	b.subs[sub] = struct{}{}
+	b.mu.Unlock()
+
+	var missed []Event
+	if lastEventId != "" {
+		msgs, err := b.client.XRange(ctx, streamKey(userId), lastEventId, "+").Result()
+		if err != nil {
+			b.unsubscribe(sub)
+			return nil, fmt.Errorf("error reading event stream: %v", err)
+		}
+
+		for _, m := range msgs {
+			e := eventFromStream(userId, m)
+			if id, err := parseStreamID(e.ID); err == nil && last.less(id) {
+				missed = append(missed, e)
+			}
+		}
+	}
+
+	out := make(chan Event)
+	done := make(chan struct{})
+	go func() {
+		defer close(out)
+
+		for _, e := range missed {
+			select {
+			case out <- e:
+				last, _ = parseStreamID(e.ID)
+			case <-done:
+				return
+			}
+		}
+
+		for e := range sub.events {
+			if id, err := parseStreamID(e.ID); err == nil && !last.less(id) {
+				continue
+			}
+			select {
+			case out <- e:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return &Subscription{
+		Events: out,
+		close: func() {
+			once.Do(func() {
+				close(done)
+				b.unsubscribe(sub)
+			})
+		},
+	}, nil
+}
+
+func (b *bus) unsubscribe(sub *subscriber) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	if _, ok := b.subs[sub]; ok {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+}
+
+// run passes the events published by every instance on to the local
+// subscribers of the user
+func (b *bus) run() {
+	for msg := range b.pubsub.Channel() {
+		var e Event
+		if err := json.Unmarshal([]byte(msg.Payload), &e); err != nil {
+			log.Printf("events: error decoding event: %v", err)
+			continue
+		}
+
+		b.mu.Lock()
+		for sub := range b.subs {
+			if sub.userId != e.UserID {
+				continue
+			}
+			select {
+			case sub.events <- e:
+			default:
+				// too slow, it's dropped and can resume from its last event
+				delete(b.subs, sub)
+				close(sub.events)
+			}
+		}
+		b.mu.Unlock()
+	}
+}
+
+// Close ends every subscription and stops receiving events.
+func (b *bus) Close() error {
+	b.mu.Lock()
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.events)
+	}
+	b.mu.Unlock()
+
+	return b.pubsub.Close()
+}
+
+func eventFromStream(userId int64, m redis.XMessage) Event {
+	e := Event{ID: m.ID, UserID: userId}
+	e.Type, _ = m.Values["type"].(string)
+	if data, ok := m.Values["data"].(string); ok {
+		e.Data = json.RawMessage(data)
+	}
+	return e
+}
+
+// streamID is a redis stream id, "<milliseconds>-<sequence>"
+type streamID struct {
+	ms, seq uint64
+}
+
+func parseStreamID(s string) (streamID, error) {
+	ms, seq, _ := strings.Cut(s, "-")
+
+	var id streamID
+	var err error
+	if id.ms, err = strconv.ParseUint(ms, 10, 64); err != nil {
+		return streamID{}, fmt.Errorf("invalid event id %q", s)
+	}
+	if seq != "" {
+		if id.seq, err = strconv.ParseUint(seq, 10, 64); err != nil {
+			return streamID{}, fmt.Errorf("invalid event id %q", s)
+		}
+	}
+
+	return id, nil
+}
+
+func (id streamID) less(other streamID) bool {
+	return id.ms < other.ms || (id.ms == other.ms && id.seq < other.seq)
+}
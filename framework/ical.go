@@ -0,0 +1,328 @@
+package framework
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+)
+
+// ICalComponent is a VTODO or VEVENT of an iCalendar (RFC 5545) file.
+// Zero fields are left out.
+type ICalComponent struct {
+	Kind        string
+	UID         string
+	Summary     string
+	Description string
+	Categories  []string
+	Priority    int
+	Start       time.Time
+	End         time.Time
+	Due         time.Time
+	Completed   time.Time
+	Status      string
+	RRule       string
+	Stamp       time.Time
+}
+
+const (
+	ICalTodo  = "VTODO"
+	ICalEvent = "VEVENT"
+)
+
+const icalTimeFormat = "20060102T150405Z"
+
+// WriteICal writes a VCALENDAR with the given components.
+func WriteICal(w io.Writer, name string, components []ICalComponent) error {
+	bw := bufio.NewWriter(w)
+	line := func(name, value string) {
+		writeICalLine(bw, name+":"+value)
+	}
+
+	line("BEGIN", "VCALENDAR")
+	line("VERSION", "2.0")
+	line("PRODID", "-//stms//tasks//EN")
+	line("CALSCALE", "GREGORIAN")
+	if name != "" {
+		line("X-WR-CALNAME", escapeICalText(name))
+	}
+
+	for _, c := range components {
+		line("BEGIN", c.Kind)
+		line("UID", escapeICalText(c.UID))
+
+		stamp := c.Stamp
+		if stamp.IsZero() {
+			stamp = time.Now()
+		}
+		line("DTSTAMP", formatICalTime(stamp))
+
+		line("SUMMARY", escapeICalText(c.Summary))
+		if c.Description != "" {
+			line("DESCRIPTION", escapeICalText(c.Description))
+		}
+		if len(c.Categories) > 0 {
+			cats := make([]string, len(c.Categories))
+			for i, cat := range c.Categories {
+				cats[i] = escapeICalText(cat)
+			}
+			line("CATEGORIES", strings.Join(cats, ","))
+		}
+		if c.Priority > 0 {
+			line("PRIORITY", fmt.Sprint(c.Priority))
+		}
+
+		if c.Kind == ICalEvent {
+			// an event without an end is an instant at its start
+			start := c.Start
+			if start.IsZero() {
+				start = c.Due
+			}
+			line("DTSTART", formatICalTime(start))
+			if !c.End.IsZero() {
+				line("DTEND", formatICalTime(c.End))
+			}
+		} else {
+			// recurrence is anchored on DTSTART, which todos may leave out
+			start := c.Start
+			if start.IsZero() && c.RRule != "" {
+				start = c.Due
+			}
+			if !start.IsZero() {
+				line("DTSTART", formatICalTime(start))
+			}
+			if !c.Due.IsZero() {
+				line("DUE", formatICalTime(c.Due))
+			}
+			if !c.Completed.IsZero() {
+				line("COMPLETED", formatICalTime(c.Completed))
+			}
+		}
+
+		if c.Status != "" {
+			line("STATUS", c.Status)
+		}
+		if c.RRule != "" {
+			line("RRULE", c.RRule)
+		}
+		line("END", c.Kind)
+	}
+
+	line("END", "VCALENDAR")
+	return bw.Flush()
+}
+
+func formatICalTime(t time.Time) string {
+	return t.UTC().Format(icalTimeFormat)
+}
+
+func escapeICalText(s string) string {
+	return strings.NewReplacer(
+		`\`, `\\`,
+		";", `\;`,
+		",", `\,`,
+		"\r\n", `\n`,
+		"\n", `\n`,
+	).Replace(s)
+}
+
+// writeICalLine writes a content line folded at 75 octets, without
+// splitting utf-8 characters.
+func writeICalLine(w *bufio.Writer, line string) {
+	// continuation lines start with a space, which counts towards the limit
+	limit := 75
+
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !isRuneStart(line[cut]) {
+			cut--
+		}
+		w.WriteString(line[:cut])
+		w.WriteString("\r\n ")
+		line = line[cut:]
+		limit = 74
+	}
+	w.WriteString(line)
+	w.WriteString("\r\n")
+}
+
+func isRuneStart(b byte) bool {
+	return b&0xC0 != 0x80
+}
+
+// ParseICal reads the VTODOs and VEVENTs of an iCalendar file. Times without
+// a timezone are read in loc, all-day dates are taken as the start of the
+// day. Unknown properties and components are ignored.
+func ParseICal(r io.Reader, loc *time.Location) ([]ICalComponent, error) {
+	lines, err := unfoldICalLines(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var components []ICalComponent
+	var current *ICalComponent
+	depth := 0 // nesting inside the current component, e.g. VALARM
+
+	for n, line := range lines {
+		name, params, value, err := parseICalLine(line)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v", n+1, err)
+		}
+
+		switch {
+		case name == "BEGIN" && current == nil && (value == ICalTodo || value == ICalEvent):
+			current = &ICalComponent{Kind: value}
+		case name == "BEGIN" && current != nil:
+			depth++
+		case name == "END" && current != nil && depth > 0:
+			depth--
+		case name == "END" && current != nil && value == current.Kind:
+			components = append(components, *current)
+			current = nil
+		case current == nil || depth > 0:
+			// outside a component or inside a nested one
+		case name == "UID":
+			current.UID = value
+		case name == "SUMMARY":
+			current.Summary = unescapeICalText(value)
+		case name == "DESCRIPTION":
+			current.Description = unescapeICalText(value)
+		case name == "CATEGORIES":
+			for _, cat := range splitICalList(value) {
+				current.Categories = append(current.Categories, unescapeICalText(cat))
+			}
+		case name == "PRIORITY":
+			fmt.Sscan(value, &current.Priority)
+		case name == "STATUS":
+			current.Status = strings.ToUpper(value)
+		case name == "RRULE":
+			current.RRule = value
+		case name == "DTSTART", name == "DUE", name == "COMPLETED", name == "DTSTAMP":
+			t, err := parseICalTime(value, params, loc)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", n+1, err)
+			}
+			switch name {
+			case "DTSTART":
+				current.Start = t
+			case "DUE":
+				current.Due = t
+			case "COMPLETED":
+				current.Completed = t
+			case "DTSTAMP":
+				current.Stamp = t
+			}
+		}
+	}
+
+	if current != nil {
+		return nil, fmt.Errorf("unterminated %s", current.Kind)
+	}
+
+	return components, nil
+}
+
+func unfoldICalLines(r io.Reader) ([]string, error) {
+	var lines []string
+
+	sc := bufio.NewScanner(r)
+	sc.Buffer(make([]byte, 64*1024), 1024*1024)
+	for sc.Scan() {
+		line := strings.TrimRight(sc.Text(), "\r")
+		if len(line) > 0 && (line[0] == ' ' || line[0] == '\t') && len(lines) > 0 {
+			lines[len(lines)-1] += line[1:]
+			continue
+		}
+		if line != "" {
+			lines = append(lines, line)
+		}
+	}
+
+	if err := sc.Err(); err != nil {
+		return nil, fmt.Errorf("error reading calendar: %v", err)
+	}
+
+	return lines, nil
+}
+
+// parseICalLine splits a content line like "DTSTART;TZID=Europe/Paris:2026..."
+// into its name, parameters and value
+func parseICalLine(line string) (name string, params map[string]string, value string, err error) {
+	// the value starts at the first colon outside a quoted parameter value
+	quoted := false
+	colon := -1
+	for i, c := range line {
+		if c == '"' {
+			quoted = !quoted
+		} else if c == ':' && !quoted {
+			colon = i
+			break
+		}
+	}
+
+	if colon < 0 {
+		return "", nil, "", fmt.Errorf("invalid content line %q", line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	params = make(map[string]string)
+	for _, p := range parts[1:] {
+		k, v, _ := strings.Cut(p, "=")
+		params[strings.ToUpper(k)] = strings.Trim(v, `"`)
+	}
+
+	return strings.ToUpper(parts[0]), params, line[colon+1:], nil
+}
+
+func parseICalTime(value string, params map[string]string, loc *time.Location) (time.Time, error) {
+	if params["VALUE"] == "DATE" || len(value) == 8 {
+		return time.ParseInLocation("20060102", value, loc)
+	}
+
+	if strings.HasSuffix(value, "Z") {
+		return time.Parse(icalTimeFormat, value)
+	}
+
+	if tzid := params["TZID"]; tzid != "" {
+		if tz, err := time.LoadLocation(tzid); err == nil {
+			loc = tz
+		}
+	}
+
+	return time.ParseInLocation("20060102T150405", value, loc)
+}
+
+// splitICalList splits a comma separated value, keeping escaped commas
+func splitICalList(value string) []string {
+	var items []string
+	start := 0
+	for i := 0; i < len(value); i++ {
+		switch value[i] {
+		case '\\':
+			i++
+		case ',':
+			items = append(items, value[start:i])
+			start = i + 1
+		}
+	}
+	return append(items, value[start:])
+}
+
+func unescapeICalText(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+1 < len(s) {
+			i++
+			switch s[i] {
+			case 'n', 'N':
+				b.WriteByte('\n')
+			default:
+				b.WriteByte(s[i])
+			}
+			continue
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
@@ -0,0 +1,233 @@
+package framework
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+)
+
+func TestWriteICal(t *testing.T) {
+	due := time.Date(2026, 10, 20, 9, 30, 0, 0, time.FixedZone("WAT", 3600))
+	stamp := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC)
+
+	var buf bytes.Buffer
+	err := WriteICal(&buf, "Tasks, mine", []ICalComponent{
+		{
+			Kind:        ICalTodo,
+			UID:         "task-1@stms",
+			Summary:     "Essay; draft",
+			Description: "line one\nline two, with \\ backslash",
+			Categories:  []string{"work", "a,b"},
+			Priority:    1,
+			Due:         due,
+			Status:      "NEEDS-ACTION",
+			RRule:       "FREQ=WEEKLY",
+			Stamp:       stamp,
+		},
+		{
+			Kind:    ICalEvent,
+			UID:     "exam-1@stms",
+			Summary: "Exam",
+			Start:   due,
+			End:     due.Add(2 * time.Hour),
+			Stamp:   stamp,
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//stms//tasks//EN",
+		"CALSCALE:GREGORIAN",
+		`X-WR-CALNAME:Tasks\, mine`,
+		"BEGIN:VTODO",
+		"UID:task-1@stms",
+		"DTSTAMP:20261019T120000Z",
+		`SUMMARY:Essay\; draft`,
+		`DESCRIPTION:line one\nline two\, with \\ backslash`,
+		`CATEGORIES:work,a\,b`,
+		"PRIORITY:1",
+		"DTSTART:20261020T083000Z",
+		"DUE:20261020T083000Z",
+		"STATUS:NEEDS-ACTION",
+		"RRULE:FREQ=WEEKLY",
+		"END:VTODO",
+		"BEGIN:VEVENT",
+		"UID:exam-1@stms",
+		"DTSTAMP:20261019T120000Z",
+		"SUMMARY:Exam",
+		"DTSTART:20261020T083000Z",
+		"DTEND:20261020T103000Z",
+		"END:VEVENT",
+		"END:VCALENDAR",
+		"",
+	}, "\r\n")
+
+	if buf.String() != want {
+		t.Errorf("got:\n%s\nwant:\n%s", buf.String(), want)
+	}
+}
+
+func TestWriteICalLineFolding(t *testing.T) {
+	summary := strings.Repeat("é", 100)
+
+	var buf bytes.Buffer
+	if err := WriteICal(&buf, "", []ICalComponent{{Kind: ICalTodo, UID: "1", Summary: summary}}); err != nil {
+		t.Fatal(err)
+	}
+
+	var unfolded string
+	for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\r\n"), "\r\n") {
+		if len(line) > 75 {
+			t.Errorf("line is %d octets, longer than 75: %q", len(line), line)
+		}
+		if !utf8.ValidString(line) {
+			t.Errorf("line splits a utf-8 character: %q", line)
+		}
+		if strings.HasPrefix(line, " ") {
+			unfolded += line[1:]
+		} else {
+			unfolded += "\n" + line
+		}
+	}
+
+	if !strings.Contains(unfolded, "\nSUMMARY:"+summary+"\n") {
+		t.Errorf("folded summary doesn't unfold back to itself:\n%s", unfolded)
+	}
+}
+
+func TestParseICal(t *testing.T) {
+	paris, err := time.LoadLocation("Europe/Paris")
+	if err != nil {
+		t.Skip("timezone data isn't available")
+	}
+	lagos := time.FixedZone("WAT", 3600)
+
+	input := strings.Join([]string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"BEGIN:VTIMEZONE",
+		"TZID:Europe/Paris",
+		"END:VTIMEZONE",
+		"BEGIN:VTODO",
+		"UID:todo-1",
+		`SUMMARY:Essay\; draft`,
+		`DESCRIPTION:line one\nline two\, with \\ backslash and a long line that`,
+		"  is folded",
+		`CATEGORIES:work,a\,b`,
+		"PRIORITY:1",
+		"DUE;TZID=Europe/Paris:20261020T093000",
+		"COMPLETED:20261019T120000Z",
+		"status:completed",
+		"RRULE:FREQ=WEEKLY;BYDAY=MO",
+		"BEGIN:VALARM",
+		"SUMMARY:not the task",
+		"END:VALARM",
+		"X-UNKNOWN;FOO=\"a:b\":ignored",
+		"END:VTODO",
+		"BEGIN:VEVENT",
+		"UID:event-1",
+		"SUMMARY:Exam",
+		"DTSTART;VALUE=DATE:20261021",
+		"END:VEVENT",
+		"BEGIN:VJOURNAL",
+		"SUMMARY:skipped",
+		"END:VJOURNAL",
+		"BEGIN:VTODO",
+		"SUMMARY:Floating",
+		"DUE:20261022T080000",
+		"END:VTODO",
+		"END:VCALENDAR",
+	}, "\r\n")
+
+	got, err := ParseICal(strings.NewReader(input), lagos)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 3 {
+		t.Fatalf("got %d components, want 3: %+v", len(got), got)
+	}
+
+	todo := got[0]
+	if todo.Kind != ICalTodo || todo.UID != "todo-1" || todo.Summary != "Essay; draft" {
+		t.Errorf("got todo %+v", todo)
+	}
+	if todo.Description != "line one\nline two, with \\ backslash and a long line that is folded" {
+		t.Errorf("got description %q", todo.Description)
+	}
+	if len(todo.Categories) != 2 || todo.Categories[0] != "work" || todo.Categories[1] != "a,b" {
+		t.Errorf("got categories %q", todo.Categories)
+	}
+	if todo.Priority != 1 || todo.Status != "COMPLETED" || todo.RRule != "FREQ=WEEKLY;BYDAY=MO" {
+		t.Errorf("got priority %d, status %q and rrule %q", todo.Priority, todo.Status, todo.RRule)
+	}
+	if want := time.Date(2026, 10, 20, 9, 30, 0, 0, paris); !todo.Due.Equal(want) {
+		t.Errorf("got due %s, want %s", todo.Due, want)
+	}
+	if want := time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC); !todo.Completed.Equal(want) {
+		t.Errorf("got completed %s, want %s", todo.Completed, want)
+	}
+
+	event := got[1]
+	if want := time.Date(2026, 10, 21, 0, 0, 0, 0, lagos); event.Kind != ICalEvent || !event.Start.Equal(want) {
+		t.Errorf("got event %+v, want it to start at %s", event, want)
+	}
+
+	if want := time.Date(2026, 10, 22, 8, 0, 0, 0, lagos); !got[2].Due.Equal(want) {
+		t.Errorf("got floating due %s, want %s", got[2].Due, want)
+	}
+}
+
+func TestParseICalErrors(t *testing.T) {
+	tests := []string{
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nSUMMARY:x\r\nEND:VCALENDAR",
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nno colon here\r\nEND:VTODO\r\nEND:VCALENDAR",
+		"BEGIN:VCALENDAR\r\nBEGIN:VTODO\r\nDUE:tomorrow\r\nEND:VTODO\r\nEND:VCALENDAR",
+	}
+
+	for _, input := range tests {
+		if _, err := ParseICal(strings.NewReader(input), time.UTC); err == nil {
+			t.Errorf("got no error for:\n%s", input)
+		}
+	}
+}
+
+func TestICalRoundTrip(t *testing.T) {
+	want := ICalComponent{
+		Kind:        ICalTodo,
+		UID:         "task-7@stms",
+		Summary:     strings.Repeat("Long summary, with; punctuation ", 4),
+		Description: "multi\nline",
+		Categories:  []string{"study"},
+		Priority:    5,
+		Due:         time.Date(2026, 10, 20, 9, 30, 0, 0, time.UTC),
+		Status:      "NEEDS-ACTION",
+		Stamp:       time.Date(2026, 10, 19, 12, 0, 0, 0, time.UTC),
+	}
+
+	var buf bytes.Buffer
+	if err := WriteICal(&buf, "", []ICalComponent{want}); err != nil {
+		t.Fatal(err)
+	}
+
+	got, err := ParseICal(&buf, time.UTC)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(got) != 1 {
+		t.Fatalf("got %d components, want 1", len(got))
+	}
+	c := got[0]
+	if c.UID != want.UID || c.Summary != want.Summary || c.Description != want.Description ||
+		len(c.Categories) != 1 || c.Categories[0] != "study" || c.Priority != want.Priority ||
+		!c.Due.Equal(want.Due) || c.Status != want.Status || !c.Stamp.Equal(want.Stamp) {
+		t.Errorf("got %+v, want %+v", c, want)
+	}
+}
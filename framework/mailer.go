@@ -0,0 +1,251 @@
+package framework
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message is a rendered email. From is filled in by the mailer when empty.
+type Message struct {
+	From    string
+	To      string
+	Subject string
+	Text    string
+	HTML    string
+
+	// link that unsubscribes the recipient, sent as the RFC 8058 one-click
+	// unsubscribe headers so mail clients can show their own button
+	UnsubscribeURL string
+}
+
+type Mailer interface {
+	Send(msg Message) error
+}
+
+type SMTPConfig struct {
+	Host     string
+	Port     string
+	Username string
+	Password string
+	From     string
+
+	// upgrade the connection with STARTTLS, sending fails if the server
+	// doesn't support it
+	StartTLS bool
+	// skip certificate verification, for local stand-ins only
+	InsecureSkipVerify bool
+}
+
+type smtpMailer struct {
+	cfg SMTPConfig
+}
+
+func NewSMTPMailer(cfg SMTPConfig) Mailer {
+	if cfg.Port == "" {
+		cfg.Port = "587"
+	}
+	return &smtpMailer{cfg}
+}
+
+func (m *smtpMailer) Send(msg Message) error {
+	if msg.From == "" {
+		msg.From = m.cfg.From
+	}
+
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	c, err := smtp.Dial(net.JoinHostPort(m.cfg.Host, m.cfg.Port))
+	if err != nil {
+		return fmt.Errorf("error connecting to smtp server: %v", err)
+	}
+	defer c.Close()
+
+	if m.cfg.StartTLS {
+		if ok, _ := c.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("smtp server doesn't support STARTTLS")
+		}
+
+		tlsCfg := &tls.Config{
+			ServerName:         m.cfg.Host,
+			InsecureSkipVerify: m.cfg.InsecureSkipVerify,
+		}
+		if err := c.StartTLS(tlsCfg); err != nil {
+			return fmt.Errorf("error starting tls: %v", err)
+		}
+	}
+
+	if m.cfg.Username != "" {
+		auth := smtp.PlainAuth("", m.cfg.Username, m.cfg.Password, m.cfg.Host)
+		if err := c.Auth(auth); err != nil {
+			return fmt.Errorf("error authenticating with smtp server: %v", err)
+		}
+	}
+
+	if err := c.Mail(addressOf(msg.From)); err != nil {
+		return fmt.Errorf("error setting sender: %v", err)
+	}
+
+	if err := c.Rcpt(addressOf(msg.To)); err != nil {
+		return fmt.Errorf("error setting recipient: %v", err)
+	}
+
+	w, err := c.Data()
+	if err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+
+	if _, err := w.Write(body); err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("error sending email: %v", err)
+	}
+
+	return c.Quit()
+}
+
+// addressOf strips the display name from an address like "STMS <a@b.c>"
+func addressOf(s string) string {
+	if i := strings.LastIndex(s, "<"); i >= 0 {
+		return strings.TrimSuffix(s[i+1:], ">")
+	}
+	return s
+}
+
+type consoleMailer struct {
+	mu   sync.Mutex
+	from string
+	out  io.Writer
+}
+
+// NewConsoleMailer returns a dev mailer that writes emails to out, stdout
+// when nil.
+func NewConsoleMailer(from string, out io.Writer) Mailer {
+	if out == nil {
+		out = os.Stdout
+	}
+	return &consoleMailer{from: from, out: out}
+}
+
+func (m *consoleMailer) Send(msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	_, err := fmt.Fprintf(m.out, "From: %s\nTo: %s\nSubject: %s\n\n%s\n\n",
+		msg.From, msg.To, msg.Subject, msg.Text)
+	return err
+}
+
+type fileMailer struct {
+	from string
+	dir  string
+}
+
+// NewFileMailer returns a dev mailer that saves each email as an .eml file
+// in dir.
+func NewFileMailer(from, dir string) (Mailer, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, fmt.Errorf("error creating mail directory: %v", err)
+	}
+	return &fileMailer{from, dir}, nil
+}
+
+func (m *fileMailer) Send(msg Message) error {
+	if msg.From == "" {
+		msg.From = m.from
+	}
+
+	body, err := msg.Bytes()
+	if err != nil {
+		return err
+	}
+
+	name := fmt.Sprintf("%d_%s.eml", time.Now().UnixNano(), strings.ReplaceAll(addressOf(msg.To), "@", "_at_"))
+	if err := os.WriteFile(filepath.Join(m.dir, name), body, 0o644); err != nil {
+		return fmt.Errorf("error writing email file: %v", err)
+	}
+
+	return nil
+}
+
+// Bytes formats the message as a multipart/alternative MIME email.
+func (msg Message) Bytes() ([]byte, error) {
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+
+	id := make([]byte, 12)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("error creating message id: %v", err)
+	}
+
+	domain := "localhost"
+	if i := strings.LastIndex(addressOf(msg.From), "@"); i >= 0 {
+		domain = addressOf(msg.From)[i+1:]
+	}
+
+	headers := []string{
+		"From: " + msg.From,
+		"To: " + msg.To,
+		"Subject: " + mime.QEncoding.Encode("utf-8", msg.Subject),
+		"Date: " + time.Now().Format(time.RFC1123Z),
+		fmt.Sprintf("Message-ID: <%x@%s>", id, domain),
+		"MIME-Version: 1.0",
+		"Content-Type: multipart/alternative; boundary=" + mw.Boundary(),
+	}
+	if msg.UnsubscribeURL != "" {
+		headers = append(headers,
+			"List-Unsubscribe: <"+msg.UnsubscribeURL+">",
+			"List-Unsubscribe-Post: List-Unsubscribe=One-Click",
+		)
+	}
+	header := strings.Join(headers, "\r\n") + "\r\n\r\n"
+
+	for _, part := range []struct{ contentType, body string }{
+		{"text/plain; charset=utf-8", msg.Text},
+		{"text/html; charset=utf-8", msg.HTML},
+	} {
+		w, err := mw.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"quoted-printable"},
+		})
+		if err != nil {
+			return nil, fmt.Errorf("error writing email: %v", err)
+		}
+
+		qw := quotedprintable.NewWriter(w)
+		if _, err := qw.Write([]byte(part.body)); err != nil {
+			return nil, fmt.Errorf("error writing email: %v", err)
+		}
+		if err := qw.Close(); err != nil {
+			return nil, fmt.Errorf("error writing email: %v", err)
+		}
+	}
+
+	if err := mw.Close(); err != nil {
+		return nil, fmt.Errorf("error writing email: %v", err)
+	}
+
+	return append([]byte(header), buf.Bytes()...), nil
+}
@@ -0,0 +1,390 @@
+package framework
+
+import (
+	"bufio"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"io"
+	"math/big"
+	"net"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// smtpStandIn is a local smtp server that accepts a single email and
+// records what it was sent
+type smtpStandIn struct {
+	ln       net.Listener
+	startTLS bool
+	cert     tls.Certificate
+
+	done     chan struct{}
+	tls      bool
+	auth     string
+	from     string
+	to       []string
+	data     string
+	commands []string
+}
+
+func newSMTPStandIn(t *testing.T, startTLS bool) *smtpStandIn {
+	t.Helper()
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	s := &smtpStandIn{ln: ln, startTLS: startTLS, cert: selfSignedCert(t), done: make(chan struct{})}
+	go s.serve()
+	return s
+}
+
+func (s *smtpStandIn) port() string {
+	return strconv.Itoa(s.ln.Addr().(*net.TCPAddr).Port)
+}
+
+func (s *smtpStandIn) serve() {
+	defer close(s.done)
+
+	conn, err := s.ln.Accept()
+	if err != nil {
+		return
+	}
+	defer conn.Close()
+
+	tp := textproto.NewConn(conn)
+	tp.PrintfLine("220 localhost ESMTP stand-in")
+
+	for {
+		line, err := tp.ReadLine()
+		if err != nil {
+			return
+		}
+
+		cmd, arg, _ := strings.Cut(line, " ")
+		cmd = strings.ToUpper(cmd)
+		s.commands = append(s.commands, cmd)
+
+		switch cmd {
+		case "EHLO", "HELO":
+			if s.startTLS && !s.tls {
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250-STARTTLS")
+				tp.PrintfLine("250 AUTH PLAIN")
+			} else {
+				tp.PrintfLine("250-localhost")
+				tp.PrintfLine("250 AUTH PLAIN")
+			}
+		case "STARTTLS":
+			tp.PrintfLine("220 ready to start tls")
+			tlsConn := tls.Server(conn, &tls.Config{Certificates: []tls.Certificate{s.cert}})
+			if err := tlsConn.Handshake(); err != nil {
+				return
+			}
+			conn = tlsConn
+			tp = textproto.NewConn(tlsConn)
+			s.tls = true
+		case "AUTH":
+			_, creds, _ := strings.Cut(arg, " ")
+			decoded, _ := base64.StdEncoding.DecodeString(creds)
+			s.auth = string(decoded)
+			tp.PrintfLine("235 authenticated")
+		case "MAIL":
+			s.from = arg
+			tp.PrintfLine("250 ok")
+		case "RCPT":
+			s.to = append(s.to, arg)
+			tp.PrintfLine("250 ok")
+		case "DATA":
+			tp.PrintfLine("354 go ahead")
+			data, err := io.ReadAll(tp.DotReader())
+			if err != nil {
+				return
+			}
+			s.data = string(data)
+			tp.PrintfLine("250 queued")
+		case "QUIT":
+			tp.PrintfLine("221 bye")
+			return
+		default:
+			tp.PrintfLine("502 not implemented")
+		}
+	}
+}
+
+func (s *smtpStandIn) wait(t *testing.T) {
+	t.Helper()
+	select {
+	case <-s.done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("smtp stand-in didn't finish")
+	}
+}
+
+func selfSignedCert(t *testing.T) tls.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		DNSNames:     []string{"localhost"},
+		IPAddresses:  []net.IP{net.ParseIP("127.0.0.1")},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}
+}
+
+func TestSMTPMailerSend(t *testing.T) {
+	server := newSMTPStandIn(t, true)
+
+	mailer := NewSMTPMailer(SMTPConfig{
+		Host:               "127.0.0.1",
+		Port:               server.port(),
+		Username:           "stms",
+		Password:           "secret",
+		From:               "STMS <noreply@stms.test>",
+		StartTLS:           true,
+		InsecureSkipVerify: true,
+	})
+
+	err := mailer.Send(Message{
+		To:      "student@stms.test",
+		Subject: "Reminder: Essay",
+		Text:    "Essay is due tomorrow.",
+		HTML:    "<p>Essay is due tomorrow.</p>",
+	})
+	if err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	server.wait(t)
+
+	if !server.tls {
+		t.Error("connection wasn't upgraded with STARTTLS")
+	}
+	if server.auth != "\x00stms\x00secret" {
+		t.Errorf("got auth %q, want PLAIN stms/secret", server.auth)
+	}
+	if server.from != "FROM:<noreply@stms.test>" {
+		t.Errorf("got sender %q", server.from)
+	}
+	if len(server.to) != 1 || server.to[0] != "TO:<student@stms.test>" {
+		t.Errorf("got recipients %q", server.to)
+	}
+
+	for _, want := range []string{
+		"From: STMS <noreply@stms.test>",
+		"To: student@stms.test",
+		"Subject: Reminder: Essay",
+		"Content-Type: multipart/alternative",
+		"Essay is due tomorrow.",
+		"<p>Essay is due tomorrow.</p>",
+	} {
+		if !strings.Contains(server.data, want) {
+			t.Errorf("email is missing %q:\n%s", want, server.data)
+		}
+	}
+
+	if got := strings.Join(server.commands, " "); got != "EHLO STARTTLS EHLO AUTH MAIL RCPT DATA QUIT" {
+		t.Errorf("got commands %s", got)
+	}
+}
+
+func TestSMTPMailerWithoutAuth(t *testing.T) {
+	server := newSMTPStandIn(t, false)
+
+	mailer := NewSMTPMailer(SMTPConfig{Host: "127.0.0.1", Port: server.port(), From: "noreply@stms.test"})
+	if err := mailer.Send(Message{To: "student@stms.test", Subject: "Hi", Text: "Hello"}); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	server.wait(t)
+
+	if server.tls || server.auth != "" {
+		t.Errorf("got tls %v and auth %q, want neither", server.tls, server.auth)
+	}
+	if !strings.Contains(server.data, "Hello") {
+		t.Errorf("email is missing its body:\n%s", server.data)
+	}
+}
+
+func TestSMTPMailerRequiresStartTLS(t *testing.T) {
+	server := newSMTPStandIn(t, false)
+
+	mailer := NewSMTPMailer(SMTPConfig{Host: "127.0.0.1", Port: server.port(), StartTLS: true})
+	err := mailer.Send(Message{To: "student@stms.test", Subject: "Hi", Text: "Hello"})
+	if err == nil || !strings.Contains(err.Error(), "STARTTLS") {
+		t.Fatalf("got error %v, want one about STARTTLS", err)
+	}
+}
+
+func TestMessageBytes(t *testing.T) {
+	body, err := Message{
+		From:    "noreply@stms.test",
+		To:      "student@stms.test",
+		Subject: "Café",
+		Text:    "plain",
+		HTML:    "<b>html</b>",
+	}.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(string(body)))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("reading headers: %v", err)
+	}
+
+	if msg.Get("Subject") != "=?utf-8?q?Caf=C3=A9?=" {
+		t.Errorf("got subject %q, want it Q-encoded", msg.Get("Subject"))
+	}
+	if !strings.HasSuffix(msg.Get("Message-Id"), "@stms.test>") {
+		t.Errorf("got message id %q", msg.Get("Message-Id"))
+	}
+	if msg.Get("List-Unsubscribe") != "" {
+		t.Errorf("got List-Unsubscribe %q on an email without an unsubscribe link", msg.Get("List-Unsubscribe"))
+	}
+}
+
+func TestMessageBytesUnsubscribe(t *testing.T) {
+	body, err := Message{
+		From:           "noreply@stms.test",
+		To:             "student@stms.test",
+		Subject:        "Your agenda",
+		Text:           "plain",
+		UnsubscribeURL: "https://stms.test/unsubscribe?list=agenda&token=abc&user=1",
+	}.Bytes()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	msg, err := textproto.NewReader(bufio.NewReader(strings.NewReader(string(body)))).ReadMIMEHeader()
+	if err != nil {
+		t.Fatalf("reading headers: %v", err)
+	}
+
+	if got := msg.Get("List-Unsubscribe"); got != "<https://stms.test/unsubscribe?list=agenda&token=abc&user=1>" {
+		t.Errorf("got List-Unsubscribe %q", got)
+	}
+	if got := msg.Get("List-Unsubscribe-Post"); got != "List-Unsubscribe=One-Click" {
+		t.Errorf("got List-Unsubscribe-Post %q", got)
+	}
+}
+
+func TestRenderEmail(t *testing.T) {
+	tests := []struct {
+		data     EmailData
+		wantText []string
+		wantHTML []string
+	}{
+		{
+			data: EmailData{
+				Template: TemplateVerification,
+				Subject:  "Verify your account",
+				Code:     "123456",
+				Fields:   map[string]string{"expiry": "Mon, 19 Oct 2026 18:00:00 UTC"},
+			},
+			wantText: []string{"verification code is 123456", "expires on Mon, 19 Oct 2026 18:00:00 UTC"},
+			wantHTML: []string{"123456"},
+		},
+		{
+			data: EmailData{
+				Template: TemplatePasswordReset,
+				Subject:  "Reset your password",
+				Code:     "654321",
+			},
+			wantText: []string{"Your reset code is 654321"},
+			wantHTML: []string{"654321"},
+		},
+		{
+			data: EmailData{
+				Template: TemplateReminder,
+				Subject:  "Reminder: Essay",
+				Message:  "Essay <draft> is due tomorrow.",
+				Fields:   map[string]string{"description": "Two pages"},
+			},
+			wantText: []string{"Essay <draft> is due tomorrow.", "Two pages"},
+			wantHTML: []string{"Essay &lt;draft&gt; is due tomorrow.", "Two pages"},
+		},
+		{
+			data: EmailData{
+				Template: TemplateAgenda,
+				Subject:  "Your agenda for Monday, 19 October",
+				Message:  "Here's what's on for Monday, 19 October.",
+				Fields: map[string]string{
+					"items":           "09:00-11:00 CS101: Algorithms (lecture), Room 4\n17:00 Due: Essay",
+					"unsubscribe_url": "https://stms.test/unsubscribe?list=agenda&token=abc&user=1",
+				},
+			},
+			wantText: []string{"Here's what's on", "09:00-11:00 CS101: Algorithms (lecture), Room 4", "17:00 Due: Essay", "Unsubscribe: https://stms.test/unsubscribe?list=agenda&token=abc&user=1"},
+			wantHTML: []string{"17:00 Due: Essay", `href="https://stms.test/unsubscribe?list=agenda&amp;token=abc&amp;user=1"`},
+		},
+		{
+			data: EmailData{
+				Template: TemplateDigest,
+				Subject:  "Your week of 19 October",
+				Message:  "Last week you completed 1 task. You have 0 tasks overdue and 1 task due this week.",
+				Fields: map[string]string{
+					"completed":       "Essay, Wed 14 Oct",
+					"upcoming":        "Lab report, due Thu 22 Oct 17:00",
+					"unsubscribe_url": "https://stms.test/unsubscribe?list=digest&token=abc&user=1",
+				},
+			},
+			wantText: []string{"Completed last week:\nEssay, Wed 14 Oct", "Due this week:\nLab report, due Thu 22 Oct 17:00", "Unsubscribe: https://stms.test/unsubscribe?list=digest"},
+			wantHTML: []string{"Essay, Wed 14 Oct", "Lab report, due Thu 22 Oct 17:00", "Unsubscribe</a>"},
+		},
+	}
+
+	for _, tt := range tests {
+		msg, err := RenderEmail("student@stms.test", tt.data)
+		if err != nil {
+			t.Errorf("%s: %v", tt.data.Template, err)
+			continue
+		}
+
+		if msg.To != "student@stms.test" || msg.Subject != tt.data.Subject {
+			t.Errorf("%s: got to %q and subject %q", tt.data.Template, msg.To, msg.Subject)
+		}
+		if msg.UnsubscribeURL != tt.data.Fields["unsubscribe_url"] {
+			t.Errorf("%s: got unsubscribe url %q", tt.data.Template, msg.UnsubscribeURL)
+		}
+		for _, want := range tt.wantText {
+			if !strings.Contains(msg.Text, want) {
+				t.Errorf("%s: text is missing %q:\n%s", tt.data.Template, want, msg.Text)
+			}
+		}
+		for _, want := range tt.wantHTML {
+			if !strings.Contains(msg.HTML, want) {
+				t.Errorf("%s: html is missing %q:\n%s", tt.data.Template, want, msg.HTML)
+			}
+		}
+	}
+}
+
+func TestRenderEmailUnknownTemplate(t *testing.T) {
+	for _, name := range []string{"", "missing"} {
+		if _, err := RenderEmail("student@stms.test", EmailData{Template: name}); err == nil {
+			t.Errorf("template %q: got no error", name)
+		}
+	}
+}
@@ -0,0 +1,393 @@
+package framework
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// RRule is the subset of an RFC 5545 recurrence rule supported for
+// recurring tasks: FREQ (daily, weekly, monthly, yearly), INTERVAL, COUNT,
+// UNTIL, BYDAY, BYMONTHDAY, BYMONTH and WKST.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      time.Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []time.Month
+	// WeekStart is the first day of the week, monday unless WKST says
+	// otherwise. It only changes weekly rules with an INTERVAL above 1.
+	WeekStart time.Weekday
+}
+
+// WeekdayNum is a BYDAY value like "MO", or "-1FR" for the last friday of
+// the month.
+type WeekdayNum struct {
+	N       int
+	Weekday time.Weekday
+}
+
+var rruleWeekdays = map[string]time.Weekday{
+	"SU": time.Sunday, "MO": time.Monday, "TU": time.Tuesday,
+	"WE": time.Wednesday, "TH": time.Thursday, "FR": time.Friday,
+	"SA": time.Saturday,
+}
+
+// maximum number of periods searched for the next occurrence, guards against
+// rules that never match like BYMONTHDAY=30;BYMONTH=2
+const rruleMaxPeriods = 1000
+
+func ParseRRule(s string) (RRule, error) {
+	r := RRule{Interval: 1, WeekStart: time.Monday}
+
+	s = strings.TrimPrefix(strings.TrimSpace(s), "RRULE:")
+	for _, part := range strings.Split(s, ";") {
+		if part == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(part, "=")
+		if !ok {
+			return RRule{}, fmt.Errorf("invalid rrule part %q", part)
+		}
+
+		var err error
+		switch strings.ToUpper(key) {
+		case "FREQ":
+			r.Freq = strings.ToUpper(value)
+		case "INTERVAL":
+			r.Interval, err = strconv.Atoi(value)
+			if err == nil && r.Interval < 1 {
+				err = fmt.Errorf("interval must be positive")
+			}
+		case "COUNT":
+			r.Count, err = strconv.Atoi(value)
+			if err == nil && r.Count < 1 {
+				err = fmt.Errorf("count must be positive")
+			}
+		case "UNTIL":
+			r.Until, err = parseRRuleTime(value)
+		case "BYDAY":
+			for _, day := range strings.Split(value, ",") {
+				var wd WeekdayNum
+				wd, err = parseWeekdayNum(day)
+				if err != nil {
+					break
+				}
+				r.ByDay = append(r.ByDay, wd)
+			}
+		case "BYMONTHDAY":
+			for _, day := range strings.Split(value, ",") {
+				var n int
+				n, err = strconv.Atoi(day)
+				if err == nil && (n == 0 || n < -31 || n > 31) {
+					err = fmt.Errorf("invalid month day %d", n)
+				}
+				if err != nil {
+					break
+				}
+				r.ByMonthDay = append(r.ByMonthDay, n)
+			}
+		case "BYMONTH":
+			for _, month := range strings.Split(value, ",") {
+				var n int
+				n, err = strconv.Atoi(month)
+				if err == nil && (n < 1 || n > 12) {
+					err = fmt.Errorf("invalid month %d", n)
+				}
+				if err != nil {
+					break
+				}
+				r.ByMonth = append(r.ByMonth, time.Month(n))
+			}
+		case "WKST":
+			wd, ok := rruleWeekdays[strings.ToUpper(value)]
+			if !ok {
+				err = fmt.Errorf("invalid weekday %q", value)
+			}
+			r.WeekStart = wd
+		default:
+			err = fmt.Errorf("unsupported rrule part %s", key)
+		}
+
+		if err != nil {
+			return RRule{}, fmt.Errorf("invalid rrule %s: %v", key, err)
+		}
+	}
+
+	switch r.Freq {
+	case "DAILY", "WEEKLY", "MONTHLY", "YEARLY":
+	case "":
+		return RRule{}, fmt.Errorf("rrule is missing FREQ")
+	default:
+		return RRule{}, fmt.Errorf("unsupported rrule FREQ %s", r.Freq)
+	}
+
+	if r.Count > 0 && !r.Until.IsZero() {
+		return RRule{}, fmt.Errorf("rrule can't have both COUNT and UNTIL")
+	}
+
+	for _, wd := range r.ByDay {
+		if wd.N != 0 && r.Freq != "MONTHLY" {
+			return RRule{}, fmt.Errorf("numbered BYDAY is only supported for monthly rules")
+		}
+	}
+
+	if (r.Freq == "DAILY" || r.Freq == "WEEKLY") && len(r.ByMonthDay) > 0 {
+		return RRule{}, fmt.Errorf("BYMONTHDAY is only supported for monthly and yearly rules")
+	}
+
+	if r.Freq == "YEARLY" && len(r.ByDay) > 0 {
+		return RRule{}, fmt.Errorf("BYDAY is not supported for yearly rules")
+	}
+
+	return r, nil
+}
+
+func parseRRuleTime(value string) (time.Time, error) {
+	for _, layout := range []string{"20060102T150405Z", "20060102T150405", "20060102"} {
+		if t, err := time.Parse(layout, value); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("invalid time %q", value)
+}
+
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return WeekdayNum{}, fmt.Errorf("invalid weekday %q", s)
+	}
+
+	wd, ok := rruleWeekdays[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, fmt.Errorf("invalid weekday %q", s)
+	}
+
+	n := 0
+	if prefix := s[:len(s)-2]; prefix != "" {
+		var err error
+		n, err = strconv.Atoi(prefix)
+		if err != nil || n == 0 || n < -5 || n > 5 {
+			return WeekdayNum{}, fmt.Errorf("invalid weekday %q", s)
+		}
+	}
+
+	return WeekdayNum{n, wd}, nil
+}
+
+func (w WeekdayNum) String() string {
+	for code, wd := range rruleWeekdays {
+		if wd == w.Weekday {
+			if w.N != 0 {
+				return strconv.Itoa(w.N) + code
+			}
+			return code
+		}
+	}
+	return ""
+}
+
+// String formats the rule back to its RFC 5545 form, without the "RRULE:"
+// prefix.
+func (r RRule) String() string {
+	parts := []string{"FREQ=" + r.Freq}
+	if r.Interval > 1 {
+		parts = append(parts, fmt.Sprintf("INTERVAL=%d", r.Interval))
+	}
+	if r.Count > 0 {
+		parts = append(parts, fmt.Sprintf("COUNT=%d", r.Count))
+	}
+	if !r.Until.IsZero() {
+		parts = append(parts, "UNTIL="+r.Until.UTC().Format("20060102T150405Z"))
+	}
+	if len(r.ByDay) > 0 {
+		days := make([]string, len(r.ByDay))
+		for i, wd := range r.ByDay {
+			days[i] = wd.String()
+		}
+		parts = append(parts, "BYDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonthDay) > 0 {
+		days := make([]string, len(r.ByMonthDay))
+		for i, d := range r.ByMonthDay {
+			days[i] = strconv.Itoa(d)
+		}
+		parts = append(parts, "BYMONTHDAY="+strings.Join(days, ","))
+	}
+	if len(r.ByMonth) > 0 {
+		months := make([]string, len(r.ByMonth))
+		for i, m := range r.ByMonth {
+			months[i] = strconv.Itoa(int(m))
+		}
+		parts = append(parts, "BYMONTH="+strings.Join(months, ","))
+	}
+	if r.WeekStart != time.Monday {
+		parts = append(parts, "WKST="+WeekdayNum{Weekday: r.WeekStart}.String())
+	}
+	return strings.Join(parts, ";")
+}
+
+// Next returns the first occurrence after prev, which must itself be an
+// occurrence of the rule (the start of the series for the first one). The
+// time of day is taken from prev. ok is false once the rule's UNTIL has been
+// passed, COUNT has to be checked by the caller since it depends on how many
+// occurrences came before prev.
+func (r RRule) Next(prev time.Time) (next time.Time, ok bool) {
+	for p := 0; p < rruleMaxPeriods; p++ {
+		for _, c := range r.candidates(prev, p) {
+			if !c.After(prev) {
+				continue
+			}
+			if !r.Until.IsZero() && c.After(r.Until) {
+				return time.Time{}, false
+			}
+			return c, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// candidates returns the sorted occurrences in the p-th period from the one
+// containing prev
+func (r RRule) candidates(prev time.Time, p int) []time.Time {
+	y, m, d := prev.Date()
+	hh, mm, ss := prev.Clock()
+	loc := prev.Location()
+	date := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, hh, mm, ss, prev.Nanosecond(), loc)
+	}
+
+	var days []time.Time
+	switch r.Freq {
+	case "DAILY":
+		c := date(y, m, d+p*r.Interval)
+		if r.matchesWeekday(c) && r.matchesMonth(c) {
+			days = append(days, c)
+		}
+
+	case "WEEKLY":
+		offset := r.weekdayOffset(prev.Weekday())
+		weekStart := date(y, m, d-offset+p*r.Interval*7)
+		if len(r.ByDay) == 0 {
+			days = append(days, weekStart.AddDate(0, 0, offset))
+		}
+		for _, wd := range r.ByDay {
+			c := weekStart.AddDate(0, 0, r.weekdayOffset(wd.Weekday))
+			if r.matchesMonth(c) {
+				days = append(days, c)
+			}
+		}
+
+	case "MONTHLY":
+		first := date(y, m+time.Month(p*r.Interval), 1)
+		if !r.matchesMonth(first) {
+			break
+		}
+		days = r.monthDays(first, d)
+
+	case "YEARLY":
+		months := r.ByMonth
+		if len(months) == 0 {
+			months = []time.Month{m}
+		}
+		for _, month := range months {
+			days = append(days, r.monthDays(date(y+p*r.Interval, month, 1), d)...)
+		}
+	}
+
+	sort.Slice(days, func(i, j int) bool { return days[i].Before(days[j]) })
+	return days
+}
+
+// monthDays returns the matching days of the month starting at first,
+// defaulting to day d when the rule has no BYMONTHDAY or BYDAY. When both
+// are set BYDAY limits BYMONTHDAY, so only days matching both are kept.
+func (r RRule) monthDays(first time.Time, d int) []time.Time {
+	daysIn := first.AddDate(0, 1, -1).Day()
+
+	byMonthDay := make(map[int]bool)
+	for _, n := range r.ByMonthDay {
+		if n < 0 {
+			n = daysIn + n + 1
+		}
+		if n >= 1 && n <= daysIn {
+			byMonthDay[n] = true
+		}
+	}
+
+	byDay := make(map[int]bool)
+	for _, wd := range r.ByDay {
+		var matching []int
+		for n := 1; n <= daysIn; n++ {
+			if first.AddDate(0, 0, n-1).Weekday() == wd.Weekday {
+				matching = append(matching, n)
+			}
+		}
+
+		switch {
+		case wd.N == 0:
+			for _, n := range matching {
+				byDay[n] = true
+			}
+		case wd.N > 0 && wd.N <= len(matching):
+			byDay[matching[wd.N-1]] = true
+		case wd.N < 0 && -wd.N <= len(matching):
+			byDay[matching[len(matching)+wd.N]] = true
+		}
+	}
+
+	var days []time.Time
+	for n := 1; n <= daysIn; n++ {
+		var match bool
+		switch {
+		case len(r.ByMonthDay) > 0 && len(r.ByDay) > 0:
+			match = byMonthDay[n] && byDay[n]
+		case len(r.ByMonthDay) > 0:
+			match = byMonthDay[n]
+		case len(r.ByDay) > 0:
+			match = byDay[n]
+		default:
+			match = n == d
+		}
+
+		if match {
+			days = append(days, first.AddDate(0, 0, n-1))
+		}
+	}
+
+	return days
+}
+
+// weekdayOffset is the number of days from the start of the week to wd
+func (r RRule) weekdayOffset(wd time.Weekday) int {
+	return (int(wd) - int(r.WeekStart) + 7) % 7
+}
+
+func (r RRule) matchesWeekday(t time.Time) bool {
+	if len(r.ByDay) == 0 {
+		return true
+	}
+	for _, wd := range r.ByDay {
+		if wd.Weekday == t.Weekday() {
+			return true
+		}
+	}
+	return false
+}
+
+func (r RRule) matchesMonth(t time.Time) bool {
+	if len(r.ByMonth) == 0 {
+		return true
+	}
+	for _, m := range r.ByMonth {
+		if m == t.Month() {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,122 @@
+package framework
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseRRule(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{in: "FREQ=DAILY", want: "FREQ=DAILY"},
+		{in: "RRULE:freq=weekly;byday=mo,we", want: "FREQ=WEEKLY;BYDAY=MO,WE"},
+		{in: "FREQ=DAILY;INTERVAL=1;COUNT=5", want: "FREQ=DAILY;COUNT=5"},
+		{in: "FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR", want: "FREQ=MONTHLY;INTERVAL=2;BYDAY=-1FR"},
+		{in: "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", want: "FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13"},
+		{in: "FREQ=YEARLY;BYMONTH=3,9;BYMONTHDAY=1", want: "FREQ=YEARLY;BYMONTHDAY=1;BYMONTH=3,9"},
+		{in: "FREQ=WEEKLY;UNTIL=20261231", want: "FREQ=WEEKLY;UNTIL=20261231T000000Z"},
+		{in: "FREQ=WEEKLY;WKST=MO", want: "FREQ=WEEKLY"},
+		{in: "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,SU;WKST=su", want: "FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,SU;WKST=SU"},
+		{in: "FREQ=DAILY;WKST=SU", want: "FREQ=DAILY;WKST=SU"},
+
+		{in: "", wantErr: true},
+		{in: "FREQ", wantErr: true},
+		{in: "FREQ=HOURLY", wantErr: true},
+		{in: "FREQ=DAILY;INTERVAL=0", wantErr: true},
+		{in: "FREQ=DAILY;COUNT=-1", wantErr: true},
+		{in: "FREQ=DAILY;COUNT=2;UNTIL=20260101", wantErr: true},
+		{in: "FREQ=DAILY;UNTIL=tomorrow", wantErr: true},
+		{in: "FREQ=WEEKLY;BYDAY=1MO", wantErr: true},
+		{in: "FREQ=MONTHLY;BYDAY=6MO", wantErr: true},
+		{in: "FREQ=MONTHLY;BYDAY=XX", wantErr: true},
+		{in: "FREQ=YEARLY;BYDAY=MO", wantErr: true},
+		{in: "FREQ=DAILY;BYMONTHDAY=1", wantErr: true},
+		{in: "FREQ=WEEKLY;BYMONTHDAY=1", wantErr: true},
+		{in: "FREQ=MONTHLY;BYMONTHDAY=32", wantErr: true},
+		{in: "FREQ=MONTHLY;BYMONTHDAY=0", wantErr: true},
+		{in: "FREQ=YEARLY;BYMONTH=13", wantErr: true},
+		{in: "FREQ=WEEKLY;WKST=1MO", wantErr: true},
+		{in: "FREQ=WEEKLY;WKST=XX", wantErr: true},
+		{in: "FREQ=DAILY;BYSETPOS=1", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseRRule(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("ParseRRule(%q): got error %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && r.String() != tt.want {
+			t.Errorf("ParseRRule(%q): got %q, want %q", tt.in, r.String(), tt.want)
+		}
+	}
+}
+
+func TestRRuleNext(t *testing.T) {
+	at := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 9, 30, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		rule   string
+		prev   time.Time
+		want   time.Time
+		wantOk bool
+	}{
+		{"FREQ=DAILY", at(2026, 1, 1), at(2026, 1, 2), true},
+		{"FREQ=DAILY;INTERVAL=3", at(2026, 1, 1), at(2026, 1, 4), true},
+		{"FREQ=DAILY;BYDAY=MO,WE", at(2026, 1, 5), at(2026, 1, 7), true},
+		{"FREQ=DAILY;BYMONTH=3", at(2026, 1, 31), at(2026, 3, 1), true},
+		{"FREQ=WEEKLY", at(2026, 1, 1), at(2026, 1, 8), true},
+		{"FREQ=WEEKLY;BYDAY=TU,TH", at(2026, 1, 1), at(2026, 1, 6), true},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=MO", at(2026, 1, 5), at(2026, 1, 19), true},
+		// the week start can't change a rule with an interval of 1
+		{"FREQ=WEEKLY;BYDAY=TU,SU;WKST=SU", at(2026, 1, 6), at(2026, 1, 11), true},
+		{"FREQ=WEEKLY;WKST=SU", at(2026, 1, 6), at(2026, 1, 13), true},
+		// the RFC 5545 example, every other week from a tuesday
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,SU;WKST=MO", at(2026, 1, 6), at(2026, 1, 11), true},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,SU;WKST=MO", at(2026, 1, 11), at(2026, 1, 20), true},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,SU;WKST=SU", at(2026, 1, 6), at(2026, 1, 18), true},
+		{"FREQ=WEEKLY;INTERVAL=2;BYDAY=TU,SU;WKST=SU", at(2026, 1, 18), at(2026, 1, 20), true},
+		{"FREQ=MONTHLY", at(2026, 1, 31), at(2026, 3, 31), true},
+		{"FREQ=MONTHLY;BYDAY=-1FR", at(2026, 1, 30), at(2026, 2, 27), true},
+		{"FREQ=MONTHLY;BYDAY=2MO", at(2026, 1, 12), at(2026, 2, 9), true},
+		{"FREQ=MONTHLY;BYMONTHDAY=1,-1", at(2026, 1, 1), at(2026, 1, 31), true},
+		{"FREQ=MONTHLY;BYMONTHDAY=-1", at(2026, 1, 31), at(2026, 2, 28), true},
+		{"FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", at(2026, 1, 1), at(2026, 2, 13), true},
+		{"FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", at(2026, 2, 13), at(2026, 3, 13), true},
+		{"FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", at(2026, 3, 13), at(2026, 11, 13), true},
+		{"FREQ=YEARLY", at(2024, 2, 29), at(2028, 2, 29), true},
+		{"FREQ=YEARLY;BYMONTH=3,9", at(2026, 3, 1), at(2026, 9, 1), true},
+		{"FREQ=DAILY;UNTIL=20260102T000000Z", at(2026, 1, 1), time.Time{}, false},
+		{"FREQ=MONTHLY;BYMONTHDAY=30;BYMONTH=2", at(2026, 1, 30), time.Time{}, false},
+	}
+
+	for _, tt := range tests {
+		r, err := ParseRRule(tt.rule)
+		if err != nil {
+			t.Fatalf("ParseRRule(%q): %v", tt.rule, err)
+		}
+
+		got, ok := r.Next(tt.prev)
+		if ok != tt.wantOk || !got.Equal(tt.want) {
+			t.Errorf("%s after %s: got %s, %v, want %s, %v", tt.rule, tt.prev.Format(time.RFC3339),
+				got.Format(time.RFC3339), ok, tt.want.Format(time.RFC3339), tt.wantOk)
+		}
+	}
+}
+
+func TestRRuleMonthDaysDedupe(t *testing.T) {
+	r, err := ParseRRule("FREQ=MONTHLY;BYMONTHDAY=31,-1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	days := r.monthDays(time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC), 1)
+	if len(days) != 1 || days[0].Day() != 31 {
+		t.Errorf("got %v, want only the 31st", days)
+	}
+}
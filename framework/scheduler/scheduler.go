@@ -0,0 +1,81 @@
+package scheduler
+
+import (
+	"log"
+	"sync"
+	"time"
+)
+
+// Job is a piece of background work. Errors are logged and the job runs
+// again on its next tick.
+type Job func() error
+
+type job struct {
+	name     string
+	interval time.Duration
+	run      Job
+}
+
+type scheduler struct {
+	jobs []job
+	done chan struct{}
+	wg   sync.WaitGroup
+}
+
+type Scheduler interface {
+	Every(name string, interval time.Duration, run Job)
+	Start()
+	Stop()
+}
+
+func InitScheduler() Scheduler {
+	return &scheduler{done: make(chan struct{})}
+}
+
+// Every registers a job to run once on Start and then every interval. Jobs
+// must be registered before the scheduler is started.
+func (s *scheduler) Every(name string, interval time.Duration, run Job) {
+	s.jobs = append(s.jobs, job{name, interval, run})
+}
+
+func (s *scheduler) Start() {
+	for _, j := range s.jobs {
+		s.wg.Add(1)
+		go s.loop(j)
+	}
+}
+
+// Stop signals the jobs to stop and waits for any running job to finish.
+func (s *scheduler) Stop() {
+	close(s.done)
+	s.wg.Wait()
+}
+
+func (s *scheduler) loop(j job) {
+	defer s.wg.Done()
+
+	ticker := time.NewTicker(j.interval)
+	defer ticker.Stop()
+
+	for {
+		s.run(j)
+
+		select {
+		case <-s.done:
+			return
+		case <-ticker.C:
+		}
+	}
+}
+
+func (s *scheduler) run(j job) {
+	defer func() {
+		if err := recover(); err != nil {
+			log.Printf("scheduler: job %s panicked: %v", j.name, err)
+		}
+	}()
+
+	if err := j.run(); err != nil {
+		log.Printf("scheduler: job %s failed: %v", j.name, err)
+	}
+}
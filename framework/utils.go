@@ -2,6 +2,8 @@ package framework
 
 import (
 	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"math"
 	"math/big"
@@ -33,3 +35,19 @@ func IsValidUsername(name string) bool {
 func IsValidPassword(pwd string) bool {
 	return len(pwd) >= 8
 }
+
+// CreateToken returns a random hex token of n bytes
+func CreateToken(n int) (string, error) {
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error generating token: %v", err)
+	}
+	return hex.EncodeToString(b), nil
+}
+
+// HashToken returns the sha256 of a token, for storing tokens that are
+// looked up but never shown again
+func HashToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
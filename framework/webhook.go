@@ -0,0 +1,132 @@
+package framework
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"strconv"
+	"syscall"
+	"time"
+)
+
+// headers sent with every webhook request. The signature is the hex
+// HMAC-SHA256 of "<timestamp>.<body>" keyed with the webhook's secret, so
+// receivers can check both where a request came from and how old it is.
+const (
+	WebhookEventHeader     = "X-Stms-Event"
+	WebhookDeliveryHeader  = "X-Stms-Delivery"
+	WebhookTimestampHeader = "X-Stms-Timestamp"
+	WebhookSignatureHeader = "X-Stms-Signature"
+)
+
+var webhookClient = &http.Client{
+	Timeout:   10 * time.Second,
+	Transport: webhookTransport(),
+	// a redirect counts as a failed delivery, the payload isn't sent on
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		return http.ErrUseLastResponse
+	},
+}
+
+// webhookTransport connects straight to the receiver, without a proxy, and
+// checks the address it dials after the host name is resolved
+func webhookTransport() *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	t.Proxy = nil
+	t.DialContext = (&net.Dialer{
+		Timeout:   10 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Control:   webhookDialControl,
+	}).DialContext
+	return t
+}
+
+// webhookDialControl refuses to connect to loopback, private, link-local
+// and unspecified addresses, so webhooks can't reach the server's own
+// network. WEBHOOK_ALLOW_PRIVATE=1 allows them, for receivers on the same
+// host or network.
+func webhookDialControl(network, address string, c syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+
+	ip := net.ParseIP(host)
+	if ip == nil {
+		return fmt.Errorf("invalid webhook address %s", address)
+	}
+
+	if isPrivateIP(ip) && os.Getenv("WEBHOOK_ALLOW_PRIVATE") != "1" {
+		return fmt.Errorf("webhook address %s is not public", ip)
+	}
+
+	return nil
+}
+
+func isPrivateIP(ip net.IP) bool {
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast()
+}
+
+// IsValidWebhookURL reports whether a url can receive webhooks. Host
+// names are checked when they're dialled, as they can resolve to another
+// address later.
+func IsValidWebhookURL(rawURL string) bool {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return false
+	}
+
+	if ip := net.ParseIP(u.Hostname()); ip != nil && isPrivateIP(ip) && os.Getenv("WEBHOOK_ALLOW_PRIVATE") != "1" {
+		return false
+	}
+
+	return (u.Scheme == "http" || u.Scheme == "https") && u.Host != ""
+}
+
+// SignWebhook returns the signature header value for a webhook body
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	fmt.Fprintf(mac, "%d.", timestamp)
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// PostWebhook sends a signed webhook and returns the response status, any
+// status outside 2xx is an error.
+func PostWebhook(rawURL, secret, eventType string, deliveryId int64, body []byte) (int, error) {
+	req, err := http.NewRequest(http.MethodPost, rawURL, bytes.NewReader(body))
+	if err != nil {
+		return 0, fmt.Errorf("error creating webhook request: %v", err)
+	}
+
+	timestamp := time.Now().Unix()
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "stms-webhooks")
+	req.Header.Set(WebhookEventHeader, eventType)
+	req.Header.Set(WebhookDeliveryHeader, strconv.FormatInt(deliveryId, 10))
+	req.Header.Set(WebhookTimestampHeader, strconv.FormatInt(timestamp, 10))
+	req.Header.Set(WebhookSignatureHeader, SignWebhook(secret, timestamp, body))
+
+	resp, err := webhookClient.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("error sending webhook: %v", err)
+	}
+	defer resp.Body.Close()
+
+	// drain some of the body so the connection can be reused
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("webhook receiver responded with %s", resp.Status)
+	}
+
+	return resp.StatusCode, nil
+}
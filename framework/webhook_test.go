@@ -0,0 +1,148 @@
+package framework
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"id":1}`)
+
+	tests := []struct {
+		secret    string
+		timestamp int64
+		want      string
+	}{
+		{"whsec_0123456789abcdef", 1760000000, "sha256=d86ec74113632a59986a8160e8c94287e8ab1859562868e7c646d7fbf4b63aca"},
+		{"other secret here", 1760000000, "sha256=17cb02614850aeb477b8c753ee811b5d3dfc0ae91b42defe28a463d6d58affb1"},
+	}
+
+	for _, tt := range tests {
+		if got := SignWebhook(tt.secret, tt.timestamp, body); got != tt.want {
+			t.Errorf("secret %q: got %s, want %s", tt.secret, got, tt.want)
+		}
+	}
+
+	if SignWebhook("whsec_0123456789abcdef", 1760000001, body) == tests[0].want {
+		t.Error("signature doesn't depend on the timestamp")
+	}
+}
+
+func TestPostWebhook(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE", "1")
+
+	var header http.Header
+	var body []byte
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		header = r.Header.Clone()
+		body, _ = io.ReadAll(r.Body)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer srv.Close()
+
+	payload := []byte(`{"id":7,"type":"task.created"}`)
+	status, err := PostWebhook(srv.URL, "whsec_0123456789abcdef", "task.created", 7, payload)
+	if err != nil || status != http.StatusNoContent {
+		t.Fatalf("got status %d and error %v, want 204", status, err)
+	}
+
+	if string(body) != string(payload) {
+		t.Errorf("got body %s", body)
+	}
+	if header.Get(WebhookEventHeader) != "task.created" || header.Get(WebhookDeliveryHeader) != "7" {
+		t.Errorf("got event %q and delivery %q", header.Get(WebhookEventHeader), header.Get(WebhookDeliveryHeader))
+	}
+
+	// the receiver can check the signature from the timestamp it was sent
+	timestamp, err := strconv.ParseInt(header.Get(WebhookTimestampHeader), 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)) > time.Minute {
+		t.Fatalf("got timestamp %q", header.Get(WebhookTimestampHeader))
+	}
+	if want := SignWebhook("whsec_0123456789abcdef", timestamp, payload); header.Get(WebhookSignatureHeader) != want {
+		t.Errorf("got signature %q, want %q", header.Get(WebhookSignatureHeader), want)
+	}
+}
+
+func TestPostWebhookFailures(t *testing.T) {
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE", "1")
+
+	tests := []struct {
+		status     int
+		wantStatus int
+	}{
+		{http.StatusInternalServerError, http.StatusInternalServerError},
+		{http.StatusNotFound, http.StatusNotFound},
+		// redirects aren't followed, the payload isn't sent on
+		{http.StatusFound, http.StatusFound},
+	}
+
+	for _, tt := range tests {
+		srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if tt.status == http.StatusFound {
+				w.Header().Set("Location", "http://example.com/")
+			}
+			w.WriteHeader(tt.status)
+		}))
+
+		status, err := PostWebhook(srv.URL, "whsec_0123456789abcdef", "task.created", 1, []byte(`{}`))
+		srv.Close()
+
+		if err == nil || !strings.Contains(err.Error(), strconv.Itoa(tt.status)) {
+			t.Errorf("status %d: got error %v", tt.status, err)
+		}
+		if status != tt.wantStatus {
+			t.Errorf("status %d: got %d", tt.status, status)
+		}
+	}
+}
+
+func TestPostWebhookRefusesPrivateAddresses(t *testing.T) {
+	var requests int
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+	}))
+	defer srv.Close()
+
+	// the test server listens on 127.0.0.1
+	status, err := PostWebhook(srv.URL, "whsec_0123456789abcdef", "task.created", 1, []byte(`{}`))
+	if err == nil || status != 0 {
+		t.Errorf("got status %d and error %v, want the address refused", status, err)
+	}
+	if requests != 0 {
+		t.Errorf("got %d requests to a loopback address", requests)
+	}
+}
+
+func TestIsValidWebhookURL(t *testing.T) {
+	tests := []struct {
+		url  string
+		want bool
+	}{
+		{"https://example.com/hooks", true},
+		{"http://203.0.113.7:8080/", true},
+		{"ftp://example.com/", false},
+		{"https://", false},
+		{"http://127.0.0.1:6379/", false},
+		{"http://169.254.169.254/latest/meta-data", false},
+		{"http://10.0.0.2/", false},
+		{"http://192.168.1.1/", false},
+		{"http://[::1]/", false},
+		{"http://0.0.0.0/", false},
+	}
+
+	for _, tt := range tests {
+		if got := IsValidWebhookURL(tt.url); got != tt.want {
+			t.Errorf("%s: got %v, want %v", tt.url, got, tt.want)
+		}
+	}
+
+	t.Setenv("WEBHOOK_ALLOW_PRIVATE", "1")
+	if !IsValidWebhookURL("http://127.0.0.1:9000/") {
+		t.Error("WEBHOOK_ALLOW_PRIVATE=1 didn't allow a loopback address")
+	}
+}
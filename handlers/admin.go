@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// RequireAdmin only lets admin users through, it must run after the jwt
+// middleware.
+func (h *handler) RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		user, err := h.app.GetProfile(getAuthUserId(c))
+		if err != nil || !user.IsAdmin {
+			data := map[string]interface{}{"detail": "admin access required"}
+			return c.JSON(http.StatusForbidden, newFailResp(data))
+		}
+		return next(c)
+	}
+}
+
+func (h *handler) GetEmails(c echo.Context) error {
+	data := make(map[string]interface{})
+
+	emails, err := h.app.GetOutboxEmails(c.QueryParam("status"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, newErrResp("error getting emails", err))
+	}
+
+	data["emails"] = emails
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) ReplayEmail(c echo.Context) error {
+	data := make(map[string]interface{})
+
+	emailId, err := parseIdParam(c, "emailId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	if err := h.app.ReplayEmail(emailId); err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(errStatus(err), newFailResp(data))
+	}
+
+	data["message"] = fmt.Sprintf("email %d queued for delivery", emailId)
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// BatchTasks runs a list of task operations in one transaction. The mode
+// is "atomic" (the default), where any failure rolls back the whole batch,
+// or "per_item", where only the failed operations are rolled back.
+func (h *handler) BatchTasks(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	req := new(batchRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	var atomic bool
+	switch req.Mode {
+	case "", "atomic":
+		atomic = true
+	case "per_item":
+		atomic = false
+	default:
+		data["detail"] = fmt.Sprintf("invalid mode %s, expected atomic or per_item", req.Mode)
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	results, committed, err := h.app.BatchTasks(userId, req.Operations, atomic)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error running batch", err))
+	}
+
+	data["committed"] = committed
+	data["results"] = results
+	if !committed {
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
@@ -0,0 +1,68 @@
+package handlers
+
+import (
+	"net/http"
+	"os"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+)
+
+const calendarContentType = "text/calendar; charset=utf-8"
+
+func (h *handler) ExportCalendar(c echo.Context) error {
+	userId := getAuthUserId(c)
+
+	cal, err := h.app.ExportCalendar(userId, c.QueryParam("component"))
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, newErrResp("error exporting calendar", err))
+	}
+
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="tasks.ics"`)
+	return c.Blob(http.StatusOK, calendarContentType, cal)
+}
+
+// CalendarFeed serves a user's calendar to calendar apps, authenticated by
+// the secret token in the url instead of a jwt
+func (h *handler) CalendarFeed(c echo.Context) error {
+	token := strings.TrimSuffix(c.Param("token"), ".ics")
+
+	cal, err := h.app.CalendarFeed(token, c.QueryParam("component"))
+	if err != nil {
+		data := map[string]interface{}{"detail": "calendar feed not found"}
+		return c.JSON(http.StatusNotFound, newFailResp(data))
+	}
+
+	return c.Blob(http.StatusOK, calendarContentType, cal)
+}
+
+func (h *handler) CreateCalendarFeed(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	token, err := h.app.CreateCalendarFeedToken(userId)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error creating calendar feed", err))
+	}
+
+	// the url is only shown once, creating a new feed revokes the old one
+	baseUrl := os.Getenv("PUBLIC_URL")
+	if baseUrl == "" {
+		baseUrl = c.Scheme() + "://" + c.Request().Host
+	}
+
+	data["url"] = strings.TrimSuffix(baseUrl, "/") + "/calendar/" + token + ".ics"
+	return c.JSON(http.StatusCreated, newSuccessResp(data))
+}
+
+func (h *handler) RevokeCalendarFeed(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	if err := h.app.RevokeCalendarFeedToken(userId); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error revoking calendar feed", err))
+	}
+
+	data["message"] = "calendar feed revoked"
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
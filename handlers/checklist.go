@@ -0,0 +1,119 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/michaelcosj/stms/models"
+)
+
+type checklistItemRequest struct {
+	Name        *string `json:"name"`
+	IsCompleted *bool   `json:"is_completed"`
+}
+
+type reorderChecklistRequest struct {
+	ItemIDs []int64 `json:"item_ids"`
+}
+
+func (h *handler) AddChecklistItem(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	taskId, err := parseIdParam(c, "taskId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	item := new(models.ChecklistItem)
+	if err := c.Bind(item); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	if err := h.app.AddChecklistItem(userId, taskId, item); err != nil {
+		return c.JSON(errStatus(err), newErrResp("error adding checklist item", err))
+	}
+
+	data["item"] = item
+	return c.JSON(http.StatusCreated, newSuccessResp(data))
+}
+
+func (h *handler) UpdateChecklistItem(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	taskId, err := parseIdParam(c, "taskId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	itemId, err := parseIdParam(c, "itemId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	req := new(checklistItemRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	item, err := h.app.UpdateChecklistItem(userId, taskId, itemId, req.Name, req.IsCompleted)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error updating checklist item", err))
+	}
+
+	data["item"] = item
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) ReorderChecklist(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	taskId, err := parseIdParam(c, "taskId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	req := new(reorderChecklistRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	items, err := h.app.ReorderChecklist(userId, taskId, req.ItemIDs)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error reordering checklist", err))
+	}
+
+	data["items"] = items
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) RemoveChecklistItem(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	taskId, err := parseIdParam(c, "taskId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	itemId, err := parseIdParam(c, "itemId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	if err := h.app.DeleteChecklistItem(userId, taskId, itemId); err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(errStatus(err), newFailResp(data))
+	}
+
+	data["message"] = "checklist item deleted successfully"
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/michaelcosj/stms/models"
+)
+
+func (h *handler) AddSemester(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	semester := new(models.Semester)
+	if err := c.Bind(semester); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	if err := h.app.AddSemester(userId, semester); err != nil {
+		return c.JSON(errStatus(err), newErrResp("error adding semester", err))
+	}
+
+	data["semester"] = semester
+	return c.JSON(http.StatusCreated, newSuccessResp(data))
+}
+
+func (h *handler) GetSemesters(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	semesters, err := h.app.GetSemesters(userId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting semesters", err))
+	}
+
+	data["semesters"] = semesters
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) GetSemester(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	semesterId, err := parseIdParam(c, "semesterId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	semester, err := h.app.GetSemester(userId, semesterId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting semester", err))
+	}
+
+	data["semester"] = semester
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) UpdateSemester(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	semesterId, err := parseIdParam(c, "semesterId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	update := new(models.SemesterUpdate)
+	if err := c.Bind(update); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	semester, err := h.app.UpdateSemester(userId, semesterId, *update)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error updating semester", err))
+	}
+
+	data["semester"] = semester
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+// ArchiveSemester archives a semester, or unarchives it on DELETE
+func (h *handler) ArchiveSemester(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	semesterId, err := parseIdParam(c, "semesterId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	archived := c.Request().Method != http.MethodDelete
+	semester, err := h.app.ArchiveSemester(userId, semesterId, archived)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error archiving semester", err))
+	}
+
+	data["semester"] = semester
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) RemoveSemester(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	semesterId, err := parseIdParam(c, "semesterId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	if err := h.app.DeleteSemester(userId, semesterId); err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(errStatus(err), newFailResp(data))
+	}
+
+	data["message"] = "semester deleted successfully"
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) AddCourse(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	course := new(models.Course)
+	if err := c.Bind(course); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	if err := h.app.AddCourse(userId, course); err != nil {
+		return c.JSON(errStatus(err), newErrResp("error adding course", err))
+	}
+
+	data["course"] = course
+	return c.JSON(http.StatusCreated, newSuccessResp(data))
+}
+
+func (h *handler) GetCourses(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	var semesterId int64
+	if s := c.QueryParam("semester_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			data["detail"] = "invalid semester_id " + s
+			return c.JSON(http.StatusBadRequest, newFailResp(data))
+		}
+		semesterId = id
+	}
+
+	courses, err := h.app.GetCourses(userId, semesterId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting courses", err))
+	}
+
+	data["courses"] = courses
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) GetCourse(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	courseId, err := parseIdParam(c, "courseId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	course, err := h.app.GetCourse(userId, courseId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting course", err))
+	}
+
+	data["course"] = course
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) UpdateCourse(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	courseId, err := parseIdParam(c, "courseId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	update := new(models.CourseUpdate)
+	if err := c.Bind(update); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	course, err := h.app.UpdateCourse(userId, courseId, *update)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error updating course", err))
+	}
+
+	data["course"] = course
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) RemoveCourse(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	courseId, err := parseIdParam(c, "courseId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	if err := h.app.DeleteCourse(userId, courseId); err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(errStatus(err), newFailResp(data))
+	}
+
+	data["message"] = "course deleted successfully"
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+// GetCourseGrades returns the grade report of a course. The optional target
+// query param is the final grade, in percent, to work out what's needed
+// for.
+func (h *handler) GetCourseGrades(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	courseId, err := parseIdParam(c, "courseId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	var target *float64
+	if s := c.QueryParam("target"); s != "" {
+		f, err := strconv.ParseFloat(s, 64)
+		if err != nil {
+			data["detail"] = "invalid target " + s
+			return c.JSON(http.StatusBadRequest, newFailResp(data))
+		}
+		target = &f
+	}
+
+	grades, err := h.app.GetCourseGrades(userId, courseId, target)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting course grades", err))
+	}
+
+	data["grades"] = grades
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
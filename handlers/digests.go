@@ -0,0 +1,101 @@
+package handlers
+
+import (
+	"bytes"
+	"html/template"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/michaelcosj/stms/app"
+)
+
+// unsubscribePage is shown to people opening an unsubscribe link, the
+// change is only made once they confirm it, so link scanners and
+// prefetching don't unsubscribe anyone
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+  <body style="font-family: sans-serif; color: #222;">
+    {{if .Done}}
+    <p>You've been unsubscribed from the {{.Lists}}.</p>
+    {{else}}
+    <p>Unsubscribe from the {{.Lists}}?</p>
+    <form method="post" action="{{.Action}}">
+      <button type="submit">Unsubscribe</button>
+    </form>
+    {{end}}
+  </body>
+</html>
+`))
+
+// unsubscribeLists names an email list in the unsubscribe page
+var unsubscribeLists = map[string]string{
+	"agenda": "morning agenda emails",
+	"digest": "weekly digest emails",
+	"all":    "morning agenda and weekly digest emails",
+}
+
+// unsubscribeLink reads the user, list and token of an unsubscribe link
+func unsubscribeLink(c echo.Context) (userId int64, list, token string, ok bool) {
+	userId, err := strconv.ParseInt(c.QueryParam("user"), 10, 64)
+	return userId, c.QueryParam("list"), c.QueryParam("token"), err == nil
+}
+
+func unsubscribeFailed(c echo.Context, err error) error {
+	if errStatus(err) == http.StatusNotFound {
+		data := map[string]interface{}{"detail": "unsubscribe link not found"}
+		return c.JSON(http.StatusNotFound, newFailResp(data))
+	}
+	return c.JSON(http.StatusInternalServerError, newErrResp("error unsubscribing", err))
+}
+
+func renderUnsubscribePage(c echo.Context, list string, done bool) error {
+	var page bytes.Buffer
+	if err := unsubscribePage.Execute(&page, map[string]interface{}{
+		"Lists":  unsubscribeLists[list],
+		"Action": c.Request().URL.RequestURI(),
+		"Done":   done,
+	}); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error rendering page", err))
+	}
+
+	return c.HTMLBlob(http.StatusOK, page.Bytes())
+}
+
+// ConfirmUnsubscribe shows the page the unsubscribe link in scheduled
+// emails opens, which asks before turning them off
+func (h *handler) ConfirmUnsubscribe(c echo.Context) error {
+	userId, list, token, ok := unsubscribeLink(c)
+	if !ok {
+		return unsubscribeFailed(c, app.ErrInvalidUnsubscribeLink)
+	}
+
+	if err := h.app.CheckUnsubscribeLink(userId, list, token); err != nil {
+		return unsubscribeFailed(c, err)
+	}
+
+	return renderUnsubscribePage(c, list, false)
+}
+
+// Unsubscribe turns off scheduled emails, from the confirmation page or a
+// mail client's RFC 8058 one-click unsubscribe. It's authenticated by the
+// signed token in the query instead of a jwt.
+func (h *handler) Unsubscribe(c echo.Context) error {
+	userId, list, token, ok := unsubscribeLink(c)
+	if !ok {
+		return unsubscribeFailed(c, app.ErrInvalidUnsubscribeLink)
+	}
+
+	if err := h.app.Unsubscribe(userId, list, token); err != nil {
+		return unsubscribeFailed(c, err)
+	}
+
+	// the confirmation page's form gets a page back
+	if strings.Contains(c.Request().Header.Get(echo.HeaderAccept), echo.MIMETextHTML) {
+		return renderUnsubscribePage(c, list, true)
+	}
+
+	data := map[string]interface{}{"message": "unsubscribed successfully"}
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
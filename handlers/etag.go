@@ -0,0 +1,73 @@
+package handlers
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/michaelcosj/stms/models"
+)
+
+const (
+	headerETag        = "ETag"
+	headerIfMatch     = "If-Match"
+	headerIfNoneMatch = "If-None-Match"
+)
+
+// taskETag is the etag of a single task, its version
+func taskETag(t models.Task) string {
+	return fmt.Sprintf(`"%d"`, t.Version)
+}
+
+// ifMatchVersion reads the task version a change was made against from the
+// If-Match header, "*" matches any version and is returned as 0. It
+// responds with 428 when the header is missing and 412 when it can't be a
+// task version, ok is false once a response has been sent.
+func ifMatchVersion(c echo.Context) (version int64, ok bool, err error) {
+	data := make(map[string]interface{})
+
+	tag := strings.TrimSpace(c.Request().Header.Get(headerIfMatch))
+	switch tag {
+	case "":
+		data["detail"] = "missing If-Match header, send the etag of the task"
+		return 0, false, c.JSON(http.StatusPreconditionRequired, newFailResp(data))
+	case "*":
+		return 0, true, nil
+	}
+
+	version, err = strconv.ParseInt(strings.Trim(strings.TrimPrefix(tag, "W/"), `"`), 10, 64)
+	if err != nil || version < 1 {
+		data["detail"] = fmt.Sprintf("invalid If-Match header %s", tag)
+		return 0, false, c.JSON(http.StatusPreconditionFailed, newFailResp(data))
+	}
+
+	return version, true, nil
+}
+
+// jsonWithETag responds with the body and a hash of it as the etag, or with
+// 304 when it matches the client's If-None-Match so polling clients don't
+// download an unchanged body again.
+func jsonWithETag(c echo.Context, code int, body interface{}) error {
+	b, err := json.Marshal(body)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error encoding response", err))
+	}
+
+	sum := sha256.Sum256(b)
+	etag := `"` + hex.EncodeToString(sum[:16]) + `"`
+	c.Response().Header().Set(headerETag, etag)
+
+	for _, tag := range strings.Split(c.Request().Header.Get(headerIfNoneMatch), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return c.NoContent(http.StatusNotModified)
+		}
+	}
+
+	return c.JSONBlob(code, b)
+}
@@ -0,0 +1,51 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+)
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header      string
+		wantVersion int64
+		wantOk      bool
+		wantStatus  int
+	}{
+		{header: `"3"`, wantVersion: 3, wantOk: true},
+		{header: `W/"12"`, wantVersion: 12, wantOk: true},
+		{header: ` "7" `, wantVersion: 7, wantOk: true},
+		{header: "5", wantVersion: 5, wantOk: true},
+		{header: "*", wantVersion: 0, wantOk: true},
+		{header: "", wantStatus: http.StatusPreconditionRequired},
+		{header: `"0"`, wantStatus: http.StatusPreconditionFailed},
+		{header: `"-2"`, wantStatus: http.StatusPreconditionFailed},
+		{header: `"abc"`, wantStatus: http.StatusPreconditionFailed},
+		{header: `"3", "4"`, wantStatus: http.StatusPreconditionFailed},
+	}
+
+	e := echo.New()
+	for _, tt := range tests {
+		req := httptest.NewRequest(http.MethodPatch, "/users/tasks/1", nil)
+		if tt.header != "" {
+			req.Header.Set(headerIfMatch, tt.header)
+		}
+		rec := httptest.NewRecorder()
+
+		version, ok, err := ifMatchVersion(e.NewContext(req, rec))
+		if err != nil {
+			t.Errorf("%q: %v", tt.header, err)
+			continue
+		}
+
+		if version != tt.wantVersion || ok != tt.wantOk {
+			t.Errorf("%q: got version %d and ok %v, want %d and %v", tt.header, version, ok, tt.wantVersion, tt.wantOk)
+		}
+		if !tt.wantOk && rec.Code != tt.wantStatus {
+			t.Errorf("%q: got status %d, want %d", tt.header, rec.Code, tt.wantStatus)
+		}
+	}
+}
@@ -0,0 +1,69 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// comments are sent on idle streams so proxies don't close them
+const streamKeepAlive = 25 * time.Second
+
+// StreamEvents sends the user's task events as server-sent events. A
+// client that reconnects with the Last-Event-ID header, or the
+// last_event_id query param, gets the events it missed first.
+func (h *handler) StreamEvents(c echo.Context) error {
+	userId := getAuthUserId(c)
+
+	lastEventId := c.Request().Header.Get("Last-Event-ID")
+	if lastEventId == "" {
+		lastEventId = c.QueryParam("last_event_id")
+	}
+
+	sub, err := h.app.SubscribeEvents(userId, lastEventId)
+	if err != nil {
+		return c.JSON(http.StatusBadRequest, newErrResp("error subscribing to events", err))
+	}
+	defer sub.Close()
+
+	res := c.Response()
+	res.Header().Set(echo.HeaderContentType, "text/event-stream")
+	res.Header().Set(echo.HeaderCacheControl, "no-cache")
+	res.Header().Set(echo.HeaderConnection, "keep-alive")
+	res.WriteHeader(http.StatusOK)
+	res.Flush()
+
+	keepAlive := time.NewTicker(streamKeepAlive)
+	defer keepAlive.Stop()
+
+	for {
+		select {
+		case e, ok := <-sub.Events:
+			if !ok {
+				return nil
+			}
+			if _, err := fmt.Fprintf(res, "id: %s\nevent: %s\ndata: %s\n\n", e.ID, e.Type, e.Data); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-keepAlive.C:
+			if _, err := fmt.Fprint(res, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+			res.Flush()
+		case <-c.Request().Context().Done():
+			return nil
+		case <-h.done:
+			return nil
+		}
+	}
+}
+
+// Shutdown ends the open event streams.
+func (h *handler) Shutdown() {
+	h.doneOnce.Do(func() {
+		close(h.done)
+	})
+}
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/michaelcosj/stms/models"
+)
+
+type studyPlanRequest struct {
+	StudyHours     float64 `json:"study_hours"`
+	SessionMinutes int     `json:"session_minutes"`
+}
+
+func (h *handler) AddExam(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	exam := new(models.Exam)
+	if err := c.Bind(exam); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	if err := h.app.AddExam(userId, exam); err != nil {
+		return c.JSON(errStatus(err), newErrResp("error adding exam", err))
+	}
+
+	data["exam"] = exam
+	return c.JSON(http.StatusCreated, newSuccessResp(data))
+}
+
+func (h *handler) GetExams(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	var courseId int64
+	if s := c.QueryParam("course_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			data["detail"] = "invalid course_id " + s
+			return c.JSON(http.StatusBadRequest, newFailResp(data))
+		}
+		courseId = id
+	}
+
+	exams, err := h.app.GetExams(userId, courseId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting exams", err))
+	}
+
+	data["exams"] = exams
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) GetExam(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	examId, err := parseIdParam(c, "examId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	exam, err := h.app.GetExam(userId, examId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting exam", err))
+	}
+
+	data["exam"] = exam
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) UpdateExam(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	examId, err := parseIdParam(c, "examId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	update := new(models.ExamUpdate)
+	if err := c.Bind(update); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	exam, err := h.app.UpdateExam(userId, examId, *update)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error updating exam", err))
+	}
+
+	data["exam"] = exam
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) RemoveExam(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	examId, err := parseIdParam(c, "examId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	if err := h.app.DeleteExam(userId, examId); err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(errStatus(err), newFailResp(data))
+	}
+
+	data["message"] = "exam deleted successfully"
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+// PlanExam plans study tasks for the exam, replacing the ones left of its
+// previous plan.
+func (h *handler) PlanExam(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	examId, err := parseIdParam(c, "examId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	req := new(studyPlanRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	exam, err := h.app.PlanExam(userId, examId, req.StudyHours, req.SessionMinutes)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error planning study", err))
+	}
+
+	data["exam"] = exam
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
@@ -1,16 +1,25 @@
 package handlers
 
 import (
+	"errors"
 	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
 
 	"github.com/golang-jwt/jwt/v5"
 	"github.com/labstack/echo/v4"
 	"github.com/michaelcosj/stms/app"
 	"github.com/michaelcosj/stms/framework"
+	"github.com/michaelcosj/stms/models"
 )
 
 type handler struct {
 	app app.App
+
+	// closed on shutdown to end the event streams
+	done     chan struct{}
+	doneOnce sync.Once
 }
 
 type Handler interface {
@@ -19,15 +28,93 @@ type Handler interface {
 	AddTask(c echo.Context) error
 	UpdateTask(c echo.Context) error
 	GetTasks(c echo.Context) error
+	GetTask(c echo.Context) error
 	RemoveTask(c echo.Context) error
+	BatchTasks(c echo.Context) error
+	SkipTask(c echo.Context) error
+	GetTrash(c echo.Context) error
+	RestoreTask(c echo.Context) error
+	GetTaskHistory(c echo.Context) error
+	RevertTask(c echo.Context) error
+	ImportTasks(c echo.Context) error
+	AddChecklistItem(c echo.Context) error
+	UpdateChecklistItem(c echo.Context) error
+	ReorderChecklist(c echo.Context) error
+	RemoveChecklistItem(c echo.Context) error
 	VerifyUser(c echo.Context) error
 	StartVerification(c echo.Context) error
+	GetProfile(c echo.Context) error
+	UpdateSettings(c echo.Context) error
+	ExportAccount(c echo.Context) error
+	ImportAccount(c echo.Context) error
+
+	ExportCalendar(c echo.Context) error
+	CalendarFeed(c echo.Context) error
+	CreateCalendarFeed(c echo.Context) error
+	RevokeCalendarFeed(c echo.Context) error
+	ConfirmUnsubscribe(c echo.Context) error
+	Unsubscribe(c echo.Context) error
+
+	AddSemester(c echo.Context) error
+	GetSemesters(c echo.Context) error
+	GetSemester(c echo.Context) error
+	UpdateSemester(c echo.Context) error
+	ArchiveSemester(c echo.Context) error
+	RemoveSemester(c echo.Context) error
+	AddCourse(c echo.Context) error
+	GetCourses(c echo.Context) error
+	GetCourse(c echo.Context) error
+	UpdateCourse(c echo.Context) error
+	RemoveCourse(c echo.Context) error
+	GetCourseGrades(c echo.Context) error
+	AddTimetableSession(c echo.Context) error
+	GetTimetable(c echo.Context) error
+	UpdateTimetableSession(c echo.Context) error
+	RemoveTimetableSession(c echo.Context) error
+	GetSchedule(c echo.Context) error
+	AddExam(c echo.Context) error
+	GetExams(c echo.Context) error
+	GetExam(c echo.Context) error
+	UpdateExam(c echo.Context) error
+	RemoveExam(c echo.Context) error
+	PlanExam(c echo.Context) error
+	GetAvailability(c echo.Context) error
+	SetAvailability(c echo.Context) error
+	PlanTasks(c echo.Context) error
+	GetTimeBlocks(c echo.Context) error
+	RemoveTimeBlock(c echo.Context) error
+	StartTimer(c echo.Context) error
+	GetTimer(c echo.Context) error
+	StopTimer(c echo.Context) error
+	AddTimeEntry(c echo.Context) error
+	GetTimeEntries(c echo.Context) error
+	RemoveTimeEntry(c echo.Context) error
+	GetTimeTotals(c echo.Context) error
+	GetStats(c echo.Context) error
+	GetStreak(c echo.Context) error
+	FreezeStreak(c echo.Context) error
+	GetBadges(c echo.Context) error
+
+	AddWebhook(c echo.Context) error
+	GetWebhooks(c echo.Context) error
+	UpdateWebhook(c echo.Context) error
+	RemoveWebhook(c echo.Context) error
+	GetWebhookDeliveries(c echo.Context) error
+	PingWebhook(c echo.Context) error
+
+	StreamEvents(c echo.Context) error
+	Shutdown()
+
+	RequireAdmin(next echo.HandlerFunc) echo.HandlerFunc
+	Idempotent(next echo.HandlerFunc) echo.HandlerFunc
+	GetEmails(c echo.Context) error
+	ReplayEmail(c echo.Context) error
 }
 
 // TODO: use [https://echo.labstack.com/docs/error-handling]
 
 func InitHandler(app app.App) Handler {
-	return &handler{app}
+	return &handler{app: app, done: make(chan struct{})}
 }
 
 type response struct {
@@ -46,6 +133,11 @@ type registerRequest struct {
 	Password string `json:"password"`
 }
 
+type batchRequest struct {
+	Mode       string                  `json:"mode"`
+	Operations []models.BatchOperation `json:"operations"`
+}
+
 type loginRequest struct {
 	Email    string `json:"email"`
 	Password string `json:"password"`
@@ -67,3 +159,32 @@ func getAuthUserId(c echo.Context) int64 {
 	token := c.Get("user").(*jwt.Token)
 	return token.Claims.(*framework.CustomClaims).UserID
 }
+
+func parseIdParam(c echo.Context, name string) (int64, error) {
+	idStr := c.Param(name)
+	id, err := strconv.ParseInt(idStr, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("error parsing %s %s: %v", name, idStr, err)
+	}
+	return id, nil
+}
+
+// errStatus picks the response status for an error returned by the app
+func errStatus(err error) int {
+	switch {
+	case errors.Is(err, app.ErrTaskNotFound), errors.Is(err, app.ErrItemNotFound),
+		errors.Is(err, app.ErrUserNotFound), errors.Is(err, app.ErrEmailNotFound),
+		errors.Is(err, app.ErrRevisionNotFound), errors.Is(err, app.ErrWebhookNotFound),
+		errors.Is(err, app.ErrSemesterNotFound), errors.Is(err, app.ErrCourseNotFound),
+		errors.Is(err, app.ErrSessionNotFound), errors.Is(err, app.ErrExamNotFound),
+		errors.Is(err, app.ErrBlockNotFound), errors.Is(err, app.ErrEntryNotFound),
+		errors.Is(err, app.ErrInvalidUnsubscribeLink):
+		return http.StatusNotFound
+	case errors.Is(err, app.ErrAccountNotEmpty), errors.Is(err, app.ErrTimerRunning):
+		return http.StatusConflict
+	case errors.Is(err, app.ErrVersionMismatch):
+		return http.StatusPreconditionFailed
+	default:
+		return http.StatusBadRequest
+	}
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/michaelcosj/stms/app"
+	"github.com/michaelcosj/stms/models"
+)
+
+const (
+	headerIdempotencyKey     = "Idempotency-Key"
+	headerIdempotentReplayed = "Idempotent-Replayed"
+
+	maxIdempotencyKeyLength = 255
+)
+
+// bodyRecorder keeps a copy of the response body as it's written
+type bodyRecorder struct {
+	http.ResponseWriter
+	body bytes.Buffer
+}
+
+func (w *bodyRecorder) Write(b []byte) (int, error) {
+	w.body.Write(b)
+	return w.ResponseWriter.Write(b)
+}
+
+// Idempotent makes retries of a request with the same Idempotency-Key
+// header replay the first response instead of running the handler again.
+// Requests without the header are handled as usual. It must run after the
+// jwt middleware, keys are per user.
+func (h *handler) Idempotent(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(c echo.Context) error {
+		key := c.Request().Header.Get(headerIdempotencyKey)
+		if key == "" {
+			return next(c)
+		}
+
+		data := make(map[string]interface{})
+		if len(key) > maxIdempotencyKeyLength {
+			data["detail"] = "idempotency key is too long"
+			return c.JSON(http.StatusBadRequest, newFailResp(data))
+		}
+
+		body, err := io.ReadAll(c.Request().Body)
+		if err != nil {
+			return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+		}
+		c.Request().Body = io.NopCloser(bytes.NewReader(body))
+
+		// the key must be reused with the same request
+		hash := sha256.New()
+		io.WriteString(hash, c.Request().Method+" "+c.Request().URL.Path+"\n")
+		hash.Write(body)
+		requestHash := hex.EncodeToString(hash.Sum(nil))
+
+		userId := getAuthUserId(c)
+		stored, err := h.app.StartIdempotentRequest(userId, key, requestHash)
+		switch {
+		case errors.Is(err, app.ErrIdempotencyKeyReused):
+			data["detail"] = err.Error()
+			return c.JSON(http.StatusUnprocessableEntity, newFailResp(data))
+		case errors.Is(err, app.ErrRequestInProgress):
+			data["detail"] = err.Error()
+			return c.JSON(http.StatusConflict, newFailResp(data))
+		case err != nil:
+			return c.JSON(http.StatusInternalServerError, newErrResp("error checking idempotency key", err))
+		case stored != nil:
+			for name, values := range stored.Header {
+				c.Response().Header()[name] = values
+			}
+			c.Response().Header().Set(headerIdempotentReplayed, "true")
+			c.Response().WriteHeader(stored.Status)
+			_, err := c.Response().Write(stored.Body)
+			return err
+		}
+
+		rec := &bodyRecorder{ResponseWriter: c.Response().Writer}
+		c.Response().Writer = rec
+		if err := next(c); err != nil {
+			c.Error(err)
+		}
+
+		// server errors may go away on a retry, so they aren't kept
+		status := c.Response().Status
+		if status >= http.StatusInternalServerError {
+			return h.app.ReleaseIdempotencyKey(userId, key)
+		}
+
+		return h.app.SaveIdempotentResponse(userId, key, models.IdempotentResponse{
+			RequestHash: requestHash,
+			Status:      status,
+			Header:      c.Response().Header().Clone(),
+			Body:        rec.body.Bytes(),
+		})
+	}
+}
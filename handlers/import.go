@@ -0,0 +1,72 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/labstack/echo/v4"
+	"github.com/michaelcosj/stms/models"
+)
+
+// largest import file accepted
+const maxImportSize = 5 << 20
+
+// ImportTasks takes a multipart upload with the file in "file". The format
+// comes from the "format" field or the file extension, "dry_run",
+// "mapping" (json) and "time_format" are optional.
+func (h *handler) ImportTasks(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	file, err := c.FormFile("file")
+	if err != nil {
+		data["detail"] = fmt.Sprintf("error reading uploaded file: %v", err)
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	if file.Size > maxImportSize {
+		data["detail"] = fmt.Sprintf("file is larger than %d bytes", maxImportSize)
+		return c.JSON(http.StatusRequestEntityTooLarge, newFailResp(data))
+	}
+
+	opts := models.ImportOptions{
+		Format:     c.FormValue("format"),
+		TimeFormat: c.FormValue("time_format"),
+	}
+
+	if opts.Format == "" {
+		opts.Format = strings.TrimPrefix(strings.ToLower(filepath.Ext(file.Filename)), ".")
+	}
+
+	if dryRun := c.FormValue("dry_run"); dryRun != "" {
+		if opts.DryRun, err = strconv.ParseBool(dryRun); err != nil {
+			data["detail"] = fmt.Sprintf("invalid dry_run %s", dryRun)
+			return c.JSON(http.StatusBadRequest, newFailResp(data))
+		}
+	}
+
+	if mapping := c.FormValue("mapping"); mapping != "" {
+		if err := json.Unmarshal([]byte(mapping), &opts.Mapping); err != nil {
+			data["detail"] = fmt.Sprintf("invalid mapping: %v", err)
+			return c.JSON(http.StatusBadRequest, newFailResp(data))
+		}
+	}
+
+	src, err := file.Open()
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+	defer src.Close()
+
+	result, err := h.app.ImportTasks(userId, src, opts)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error importing tasks", err))
+	}
+
+	data["import"] = result
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
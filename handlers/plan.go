@@ -0,0 +1,105 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/michaelcosj/stms/models"
+)
+
+type availabilityRequest struct {
+	Windows []models.AvailabilityWindow `json:"windows"`
+}
+
+type planRequest struct {
+	From    string  `json:"from"`
+	Days    int     `json:"days"`
+	TaskIDs []int64 `json:"task_ids"`
+	Accept  bool    `json:"accept"`
+}
+
+func (h *handler) GetAvailability(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	windows, err := h.app.GetAvailability(userId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting availability", err))
+	}
+
+	data["windows"] = windows
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+// SetAvailability replaces the user's weekly availability windows, an empty
+// list clears them.
+func (h *handler) SetAvailability(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	req := new(availabilityRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	windows, err := h.app.SetAvailability(userId, req.Windows)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error setting availability", err))
+	}
+
+	data["windows"] = windows
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+// PlanTasks proposes blocks of work on the user's tasks, they're saved when
+// the plan is accepted.
+func (h *handler) PlanTasks(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	req := new(planRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	plan, err := h.app.PlanTasks(userId, req.From, req.Days, req.TaskIDs, req.Accept)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error planning tasks", err))
+	}
+
+	data["plan"] = plan
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+// GetTimeBlocks returns the accepted blocks between the from and to dates,
+// a week from today by default.
+func (h *handler) GetTimeBlocks(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	blocks, err := h.app.GetTimeBlocks(userId, c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting time blocks", err))
+	}
+
+	data["blocks"] = blocks
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) RemoveTimeBlock(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	blockId, err := parseIdParam(c, "blockId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	if err := h.app.DeleteTimeBlock(userId, blockId); err != nil {
+		return c.JSON(errStatus(err), newErrResp("error deleting time block", err))
+	}
+
+	data["message"] = "time block deleted successfully"
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/labstack/echo/v4"
+	"github.com/michaelcosj/stms/models"
+)
+
+func (h *handler) AddTimetableSession(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	session := new(models.TimetableSession)
+	if err := c.Bind(session); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	if err := h.app.AddTimetableSession(userId, session); err != nil {
+		return c.JSON(errStatus(err), newErrResp("error adding timetable session", err))
+	}
+
+	data["session"] = session
+	return c.JSON(http.StatusCreated, newSuccessResp(data))
+}
+
+func (h *handler) GetTimetable(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	var courseId int64
+	if s := c.QueryParam("course_id"); s != "" {
+		id, err := strconv.ParseInt(s, 10, 64)
+		if err != nil {
+			data["detail"] = "invalid course_id " + s
+			return c.JSON(http.StatusBadRequest, newFailResp(data))
+		}
+		courseId = id
+	}
+
+	sessions, err := h.app.GetTimetable(userId, courseId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting timetable", err))
+	}
+
+	data["sessions"] = sessions
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) UpdateTimetableSession(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	sessionId, err := parseIdParam(c, "sessionId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	update := new(models.TimetableSessionUpdate)
+	if err := c.Bind(update); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	session, err := h.app.UpdateTimetableSession(userId, sessionId, *update)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error updating timetable session", err))
+	}
+
+	data["session"] = session
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) RemoveTimetableSession(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	sessionId, err := parseIdParam(c, "sessionId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	if err := h.app.DeleteTimetableSession(userId, sessionId); err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(errStatus(err), newFailResp(data))
+	}
+
+	data["message"] = "timetable session deleted successfully"
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+// GetSchedule returns the agenda of each day between the from and to
+// dates, a week from today by default.
+func (h *handler) GetSchedule(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	days, err := h.app.GetSchedule(userId, c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting schedule", err))
+	}
+
+	data["days"] = days
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
@@ -0,0 +1,22 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+// GetStats returns statistics of the user's tasks, with the completions of
+// the days between the from and to dates, the last 30 days by default.
+func (h *handler) GetStats(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	stats, err := h.app.GetStats(userId, c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting stats", err))
+	}
+
+	data["stats"] = stats
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
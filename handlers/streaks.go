@@ -0,0 +1,56 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+)
+
+type freezeRequest struct {
+	Date string `json:"date"`
+}
+
+func (h *handler) GetStreak(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	streak, err := h.app.GetStreak(userId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting streak", err))
+	}
+
+	data["streak"] = streak
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+// FreezeStreak uses a streak freeze on a day, today when no date is given.
+func (h *handler) FreezeStreak(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	req := new(freezeRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	streak, err := h.app.FreezeStreak(userId, req.Date)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error freezing streak", err))
+	}
+
+	data["streak"] = streak
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) GetBadges(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	badges, err := h.app.GetBadges(userId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting badges", err))
+	}
+
+	data["badges"] = badges
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
@@ -23,6 +23,7 @@ func (h *handler) AddTask(c echo.Context) error {
 	}
 
 	data["task"] = t
+	c.Response().Header().Set(headerETag, taskETag(*t))
 	return c.JSON(http.StatusOK, newSuccessResp(data))
 }
 
@@ -39,10 +40,31 @@ func (h *handler) GetTasks(c echo.Context) error {
 	}
 
 	data["tasks"] = tasks
+	return jsonWithETag(c, http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) GetTask(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	taskId, err := parseIdParam(c, "taskId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	task, err := h.app.GetTask(userId, taskId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting task", err))
+	}
+
+	data["task"] = task
+	c.Response().Header().Set(headerETag, taskETag(task))
 	return c.JSON(http.StatusOK, newSuccessResp(data))
 }
 
 func (h *handler) UpdateTask(c echo.Context) error {
+	userId := getAuthUserId(c)
 	data := make(map[string]interface{})
 
 	newTask := new(models.Task)
@@ -58,15 +80,34 @@ func (h *handler) UpdateTask(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, newFailResp(data))
 	}
 
-	if err := h.app.UpdateTask(int64(taskId), newTask); err != nil {
-		return c.JSON(http.StatusBadRequest, newErrResp("error updating task", err))
+	version, ok, err := ifMatchVersion(c)
+	if !ok {
+		return err
+	}
+
+	// recurring tasks can be edited for this occurrence only or for this
+	// and all future occurrences
+	switch scope := c.QueryParam("scope"); scope {
+	case "", "this":
+		err = h.app.UpdateTask(userId, int64(taskId), version, newTask)
+	case "future":
+		err = h.app.UpdateTaskSeries(userId, int64(taskId), version, newTask)
+	default:
+		data["detail"] = fmt.Sprintf("invalid scope %s, expected this or future", scope)
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error updating task", err))
 	}
 
 	data["task"] = newTask
+	c.Response().Header().Set(headerETag, taskETag(*newTask))
 	return c.JSON(http.StatusOK, newSuccessResp(data))
 }
 
 func (h *handler) RemoveTask(c echo.Context) error {
+	userId := getAuthUserId(c)
 	data := make(map[string]interface{})
 
 	taskIdStr := c.Param("taskId")
@@ -76,11 +117,116 @@ func (h *handler) RemoveTask(c echo.Context) error {
 		return c.JSON(http.StatusBadRequest, newFailResp(data))
 	}
 
-	if err := h.app.DeleteTask(int64(taskId)); err != nil {
+	version, ok, err := ifMatchVersion(c)
+	if !ok {
+		return err
+	}
+
+	if err := h.app.DeleteTask(userId, int64(taskId), version); err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(errStatus(err), newFailResp(data))
+	}
+
+	data["message"] = "task moved to trash"
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) SkipTask(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	taskId, err := parseIdParam(c, "taskId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	version, ok, err := ifMatchVersion(c)
+	if !ok {
+		return err
+	}
+
+	next, err := h.app.SkipTaskOccurrence(userId, taskId, version)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error skipping task", err))
+	}
+
+	data["next_task"] = next
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) GetTrash(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	tasks, err := h.app.GetTrash(userId)
+	if err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error getting trash", err))
+	}
+
+	data["tasks"] = tasks
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) RestoreTask(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	taskId, err := parseIdParam(c, "taskId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	task, err := h.app.RestoreTask(userId, taskId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error restoring task", err))
+	}
+
+	data["task"] = task
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) GetTaskHistory(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	taskId, err := parseIdParam(c, "taskId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	revs, err := h.app.GetTaskHistory(userId, taskId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting task history", err))
+	}
+
+	data["history"] = revs
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) RevertTask(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	taskId, err := parseIdParam(c, "taskId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	revisionId, err := parseIdParam(c, "revisionId")
+	if err != nil {
 		data["detail"] = err.Error()
-		return c.JSON(http.StatusNotFound, newFailResp(data))
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	task, err := h.app.RevertTask(userId, taskId, revisionId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error reverting task", err))
 	}
 
-	data["message"] = "task deleted successfully"
+	data["task"] = task
 	return c.JSON(http.StatusOK, newSuccessResp(data))
 }
@@ -0,0 +1,142 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/michaelcosj/stms/models"
+)
+
+type timerRequest struct {
+	TaskID int64  `json:"task_id"`
+	Kind   string `json:"kind"`
+	Note   string `json:"note"`
+}
+
+// StartTimer starts a timer or a pomodoro on a task, only one can run at
+// a time.
+func (h *handler) StartTimer(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	req := new(timerRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	timer, err := h.app.StartTimer(userId, req.TaskID, req.Kind, req.Note)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error starting timer", err))
+	}
+
+	data["timer"] = timer
+	return c.JSON(http.StatusCreated, newSuccessResp(data))
+}
+
+// GetTimer returns the running timer, null when none is running.
+func (h *handler) GetTimer(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	timer, err := h.app.GetTimer(userId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting timer", err))
+	}
+
+	data["timer"] = timer
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) StopTimer(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	timer, err := h.app.StopTimer(userId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error stopping timer", err))
+	}
+
+	data["timer"] = timer
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) AddTimeEntry(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	taskId, err := parseIdParam(c, "taskId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	entry := new(models.TimeEntry)
+	if err := c.Bind(entry); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	if err := h.app.AddTimeEntry(userId, taskId, entry); err != nil {
+		return c.JSON(errStatus(err), newErrResp("error adding time entry", err))
+	}
+
+	data["entry"] = entry
+	return c.JSON(http.StatusCreated, newSuccessResp(data))
+}
+
+func (h *handler) GetTimeEntries(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	taskId, err := parseIdParam(c, "taskId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	entries, err := h.app.GetTaskTimeEntries(userId, taskId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting time entries", err))
+	}
+
+	data["entries"] = entries
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) RemoveTimeEntry(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	taskId, err := parseIdParam(c, "taskId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	entryId, err := parseIdParam(c, "entryId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	if err := h.app.DeleteTimeEntry(userId, taskId, entryId); err != nil {
+		return c.JSON(errStatus(err), newErrResp("error deleting time entry", err))
+	}
+
+	data["message"] = "time entry deleted successfully"
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+// GetTimeTotals returns the time tracked between the from and to dates by
+// task, course and week, the last four weeks by default.
+func (h *handler) GetTimeTotals(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	totals, err := h.app.GetTimeTotals(userId, c.QueryParam("from"), c.QueryParam("to"))
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting time totals", err))
+	}
+
+	data["totals"] = totals
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
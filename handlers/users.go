@@ -0,0 +1,78 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/michaelcosj/stms/models"
+)
+
+func (h *handler) GetProfile(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	user, err := h.app.GetProfile(userId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting user", err))
+	}
+
+	data["user"] = user
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) UpdateSettings(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	req := new(models.UserSettings)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	user, err := h.app.UpdateSettings(userId, *req)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error updating settings", err))
+	}
+
+	data["user"] = user
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+// largest account archive accepted
+const maxAccountImportSize = 20 << 20
+
+func (h *handler) ExportAccount(c echo.Context) error {
+	userId := getAuthUserId(c)
+
+	export, err := h.app.ExportAccount(userId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error exporting account", err))
+	}
+
+	filename := fmt.Sprintf("stms-export-%s.json", export.ExportedAt.Format("2006-01-02"))
+	c.Response().Header().Set(echo.HeaderContentDisposition, `attachment; filename="`+filename+`"`)
+	return c.JSON(http.StatusOK, export)
+}
+
+// ImportAccount takes an archive made by ExportAccount as the request body
+func (h *handler) ImportAccount(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	export := new(models.AccountExport)
+	body := http.MaxBytesReader(c.Response(), c.Request().Body, maxAccountImportSize)
+	if err := json.NewDecoder(body).Decode(export); err != nil {
+		data["detail"] = fmt.Sprintf("invalid account archive: %v", err)
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	user, err := h.app.ImportAccount(userId, *export)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error importing account", err))
+	}
+
+	data["user"] = user
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
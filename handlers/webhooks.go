@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/michaelcosj/stms/models"
+)
+
+type webhookRequest struct {
+	URL        *string   `json:"url"`
+	EventTypes *[]string `json:"event_types"`
+	IsActive   *bool     `json:"is_active"`
+}
+
+func (h *handler) AddWebhook(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	webhook := new(models.Webhook)
+	if err := c.Bind(webhook); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	if err := h.app.AddWebhook(userId, webhook); err != nil {
+		return c.JSON(errStatus(err), newErrResp("error adding webhook", err))
+	}
+
+	data["webhook"] = webhook
+	return c.JSON(http.StatusCreated, newSuccessResp(data))
+}
+
+func (h *handler) GetWebhooks(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	webhooks, err := h.app.GetWebhooks(userId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting webhooks", err))
+	}
+
+	data["webhooks"] = webhooks
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) UpdateWebhook(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	webhookId, err := parseIdParam(c, "webhookId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	req := new(webhookRequest)
+	if err := c.Bind(req); err != nil {
+		return c.JSON(http.StatusInternalServerError, newErrResp("error handling request", err))
+	}
+
+	webhook, err := h.app.UpdateWebhook(userId, webhookId, req.URL, req.EventTypes, req.IsActive)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error updating webhook", err))
+	}
+
+	data["webhook"] = webhook
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) RemoveWebhook(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	webhookId, err := parseIdParam(c, "webhookId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	if err := h.app.DeleteWebhook(userId, webhookId); err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(errStatus(err), newFailResp(data))
+	}
+
+	data["message"] = "webhook deleted successfully"
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) GetWebhookDeliveries(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	webhookId, err := parseIdParam(c, "webhookId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	deliveries, err := h.app.GetWebhookDeliveries(userId, webhookId, c.QueryParam("status"))
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error getting webhook deliveries", err))
+	}
+
+	data["deliveries"] = deliveries
+	return c.JSON(http.StatusOK, newSuccessResp(data))
+}
+
+func (h *handler) PingWebhook(c echo.Context) error {
+	userId := getAuthUserId(c)
+	data := make(map[string]interface{})
+
+	webhookId, err := parseIdParam(c, "webhookId")
+	if err != nil {
+		data["detail"] = err.Error()
+		return c.JSON(http.StatusBadRequest, newFailResp(data))
+	}
+
+	delivery, err := h.app.PingWebhook(userId, webhookId)
+	if err != nil {
+		return c.JSON(errStatus(err), newErrResp("error pinging webhook", err))
+	}
+
+	data["delivery"] = delivery
+	return c.JSON(http.StatusAccepted, newSuccessResp(data))
+}
@@ -7,7 +7,6 @@ import (
 )
 
 const (
-	// TODO: priority should be boolean (high and low)
 	// TODO: tags should be an enum (study, work, others)
 	migrateDbSchema = `
     CREATE TABLE IF NOT EXISTS users (
@@ -35,11 +34,435 @@ const (
   `
 )
 
+// migrations are applied in order on top of migrateDbSchema. The number of
+// applied migrations is tracked in sqlite's user_version pragma, so new
+// migrations must only ever be appended to the end of the list.
+var migrations = []string{
+	// priority levels (none, low, medium, high, urgent) replace the boolean
+	// flag, old high priority tasks become "high". The table is rebuilt since
+	// sqlite can't change the type of a column.
+	`
+    CREATE TABLE tasks_new (
+      task_id         INTEGER   PRIMARY KEY NOT NULL,
+      name            TEXT      NOT NULL,
+      TAG             TEXT,
+      priority        INTEGER   NOT NULL DEFAULT 0,
+      is_completed    BOOLEAN   DEFAULT 0,
+      description     TEXT      NOT NULL,
+      time_due        DATETIME  NOT NULL,
+      time_created    DATETIME  NOT NULL,
+      time_completed  DATETIME  NOT NULL DEFAULT 0,
+      user_id         INTEGER   NOT NULL REFERENCES users
+    );
+
+    INSERT INTO tasks_new
+    SELECT task_id, name, TAG, CASE WHEN priority THEN 3 ELSE 0 END,
+      is_completed, description, time_due, time_created, time_completed,
+      user_id
+    FROM tasks;
+
+    DROP TABLE tasks;
+    ALTER TABLE tasks_new RENAME TO tasks;
+  `,
+
+	// checklist items under a task
+	`
+    CREATE TABLE checklist_items (
+      item_id         INTEGER   PRIMARY KEY NOT NULL,
+      task_id         INTEGER   NOT NULL REFERENCES tasks,
+      name            TEXT      NOT NULL,
+      position        INTEGER   NOT NULL DEFAULT 0,
+      is_completed    BOOLEAN   NOT NULL DEFAULT 0
+    );
+
+    CREATE INDEX checklist_items_task_id ON checklist_items (task_id, position);
+  `,
+
+	// recurring tasks, each occurrence is a task linked to its series
+	`
+    CREATE TABLE task_series (
+      series_id       INTEGER   PRIMARY KEY NOT NULL,
+      rrule           TEXT      NOT NULL,
+      name            TEXT      NOT NULL,
+      tag             TEXT,
+      priority        INTEGER   NOT NULL DEFAULT 0,
+      description     TEXT      NOT NULL,
+      user_id         INTEGER   NOT NULL REFERENCES users
+    );
+
+    ALTER TABLE tasks ADD COLUMN series_id INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE tasks ADD COLUMN occurrence INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE tasks ADD COLUMN recurrence_time DATETIME NOT NULL DEFAULT 0;
+
+    CREATE INDEX tasks_series_id ON tasks (series_id, occurrence);
+  `,
+
+	// due date reminders. sent_reminders records every reminder sent so a
+	// restart never sends one twice, time_due is in unix seconds so a moved
+	// due date gets its reminders again.
+	`
+    ALTER TABLE users ADD COLUMN timezone TEXT NOT NULL DEFAULT 'UTC';
+    ALTER TABLE users ADD COLUMN quiet_hours_start TEXT NOT NULL DEFAULT '';
+    ALTER TABLE users ADD COLUMN quiet_hours_end TEXT NOT NULL DEFAULT '';
+
+    CREATE TABLE task_reminders (
+      task_id         INTEGER   NOT NULL REFERENCES tasks,
+      offset_minutes  INTEGER   NOT NULL,
+      PRIMARY KEY (task_id, offset_minutes)
+    );
+
+    CREATE TABLE sent_reminders (
+      task_id         INTEGER   NOT NULL,
+      kind            TEXT      NOT NULL,
+      offset_minutes  INTEGER   NOT NULL,
+      time_due        INTEGER   NOT NULL,
+      time_sent       DATETIME  NOT NULL,
+      UNIQUE (task_id, kind, offset_minutes, time_due)
+    );
+  `,
+
+	// email outbox, emails are queued here and delivered by a background
+	// worker. Emails that keep failing are marked dead for an admin to
+	// look at and replay.
+	`
+    ALTER TABLE users ADD COLUMN is_admin BOOLEAN NOT NULL DEFAULT 0;
+
+    CREATE TABLE email_outbox (
+      email_id        INTEGER   PRIMARY KEY NOT NULL,
+      recipient       TEXT      NOT NULL,
+      template        TEXT      NOT NULL,
+      subject         TEXT      NOT NULL,
+      code            TEXT      NOT NULL DEFAULT '',
+      message         TEXT      NOT NULL DEFAULT '',
+      fields          TEXT      NOT NULL DEFAULT '{}',
+      status          TEXT      NOT NULL DEFAULT 'pending',
+      attempts        INTEGER   NOT NULL DEFAULT 0,
+      last_error      TEXT      NOT NULL DEFAULT '',
+      next_attempt_at DATETIME  NOT NULL,
+      time_created    DATETIME  NOT NULL,
+      time_sent       DATETIME  NOT NULL DEFAULT 0
+    );
+
+    CREATE INDEX email_outbox_status ON email_outbox (status, next_attempt_at);
+  `,
+
+	// secret calendar feed, only the sha256 of the token is stored
+	`
+    ALTER TABLE users ADD COLUMN calendar_token_hash TEXT NOT NULL DEFAULT '';
+
+    CREATE INDEX users_calendar_token_hash ON users (calendar_token_hash);
+  `,
+
+	// external uid of imported tasks, used to skip them on re-import
+	`
+    ALTER TABLE tasks ADD COLUMN uid TEXT NOT NULL DEFAULT '';
+
+    CREATE UNIQUE INDEX tasks_user_uid ON tasks (user_id, uid) WHERE uid != '';
+  `,
+
+	// trash, deleted tasks keep their deletion time until they're purged
+	`
+    ALTER TABLE tasks ADD COLUMN deleted_at DATETIME;
+
+    CREATE INDEX tasks_deleted_at ON tasks (deleted_at) WHERE deleted_at IS NOT NULL;
+  `,
+
+	// task history, a revision for every change with the changed fields
+	// and the task as it was after the change, in json
+	`
+    CREATE TABLE task_revisions (
+      revision_id     INTEGER   PRIMARY KEY NOT NULL,
+      task_id         INTEGER   NOT NULL REFERENCES tasks,
+      user_id         INTEGER   NOT NULL REFERENCES users,
+      action          TEXT      NOT NULL,
+      changes         TEXT      NOT NULL DEFAULT '{}',
+      snapshot        TEXT      NOT NULL,
+      time_created    DATETIME  NOT NULL
+    );
+
+    CREATE INDEX task_revisions_task_id ON task_revisions (task_id, revision_id);
+  `,
+
+	// version of a task, bumped on every change and used as its etag
+	`
+    ALTER TABLE tasks ADD COLUMN version INTEGER NOT NULL DEFAULT 1;
+  `,
+
+	// outgoing webhooks, event_types is a json list and failures counts
+	// the failed deliveries in a row. Deliveries are queued like emails in
+	// the outbox and kept as a log for the user.
+	`
+    CREATE TABLE webhooks (
+      webhook_id      INTEGER   PRIMARY KEY NOT NULL,
+      user_id         INTEGER   NOT NULL REFERENCES users,
+      url             TEXT      NOT NULL,
+      event_types     TEXT      NOT NULL DEFAULT '[]',
+      secret          TEXT      NOT NULL,
+      is_active       BOOLEAN   NOT NULL DEFAULT 1,
+      failures        INTEGER   NOT NULL DEFAULT 0,
+      time_created    DATETIME  NOT NULL
+    );
+
+    CREATE INDEX webhooks_user_id ON webhooks (user_id);
+
+    CREATE TABLE webhook_deliveries (
+      delivery_id     INTEGER   PRIMARY KEY NOT NULL,
+      webhook_id      INTEGER   NOT NULL REFERENCES webhooks,
+      event_type      TEXT      NOT NULL,
+      payload         TEXT      NOT NULL,
+      status          TEXT      NOT NULL DEFAULT 'pending',
+      attempts        INTEGER   NOT NULL DEFAULT 0,
+      response_status INTEGER   NOT NULL DEFAULT 0,
+      last_error      TEXT      NOT NULL DEFAULT '',
+      next_attempt_at DATETIME  NOT NULL,
+      time_created    DATETIME  NOT NULL,
+      time_delivered  DATETIME  NOT NULL DEFAULT 0
+    );
+
+    CREATE INDEX webhook_deliveries_status ON webhook_deliveries (status, next_attempt_at);
+    CREATE INDEX webhook_deliveries_webhook_id ON webhook_deliveries (webhook_id, delivery_id);
+  `,
+
+	// semesters and courses, dates are "2006-01-02" strings. A course or
+	// task with a semester_id or course_id of 0 doesn't belong to one.
+	`
+    CREATE TABLE semesters (
+      semester_id     INTEGER   PRIMARY KEY NOT NULL,
+      user_id         INTEGER   NOT NULL REFERENCES users,
+      name            TEXT      NOT NULL,
+      start_date      TEXT      NOT NULL,
+      end_date        TEXT      NOT NULL,
+      is_archived     BOOLEAN   NOT NULL DEFAULT 0,
+      time_created    DATETIME  NOT NULL
+    );
+
+    CREATE INDEX semesters_user_id ON semesters (user_id);
+
+    CREATE TABLE courses (
+      course_id       INTEGER   PRIMARY KEY NOT NULL,
+      user_id         INTEGER   NOT NULL REFERENCES users,
+      semester_id     INTEGER   NOT NULL DEFAULT 0,
+      name            TEXT      NOT NULL,
+      code            TEXT      NOT NULL DEFAULT '',
+      instructor      TEXT      NOT NULL DEFAULT '',
+      colour          TEXT      NOT NULL DEFAULT '',
+      start_date      TEXT      NOT NULL DEFAULT '',
+      end_date        TEXT      NOT NULL DEFAULT '',
+      time_created    DATETIME  NOT NULL
+    );
+
+    CREATE INDEX courses_user_id ON courses (user_id);
+
+    ALTER TABLE tasks ADD COLUMN course_id INTEGER NOT NULL DEFAULT 0;
+
+    CREATE INDEX tasks_course_id ON tasks (course_id) WHERE course_id != 0;
+  `,
+
+	// weekly timetable sessions of a course, day is the lowercase weekday,
+	// times are "15:04" in the user's timezone and weeks is all, odd or even
+	`
+    CREATE TABLE timetable_sessions (
+      session_id      INTEGER   PRIMARY KEY NOT NULL,
+      course_id       INTEGER   NOT NULL REFERENCES courses,
+      user_id         INTEGER   NOT NULL REFERENCES users,
+      day             TEXT      NOT NULL,
+      start_time      TEXT      NOT NULL,
+      end_time        TEXT      NOT NULL,
+      location        TEXT      NOT NULL DEFAULT '',
+      kind            TEXT      NOT NULL DEFAULT '',
+      weeks           TEXT      NOT NULL DEFAULT 'all',
+      time_created    DATETIME  NOT NULL
+    );
+
+    CREATE INDEX timetable_sessions_user_id ON timetable_sessions (user_id);
+  `,
+
+	// exams of a course, topics is a json array of strings. Study tasks
+	// planned for an exam are linked to it by their exam_id.
+	`
+    CREATE TABLE exams (
+      exam_id         INTEGER   PRIMARY KEY NOT NULL,
+      user_id         INTEGER   NOT NULL REFERENCES users,
+      course_id       INTEGER   NOT NULL REFERENCES courses,
+      title           TEXT      NOT NULL,
+      exam_time       DATETIME  NOT NULL,
+      location        TEXT      NOT NULL DEFAULT '',
+      weight          REAL      NOT NULL DEFAULT 0,
+      topics          TEXT      NOT NULL DEFAULT '[]',
+      study_hours     REAL      NOT NULL DEFAULT 0,
+      session_minutes INTEGER   NOT NULL DEFAULT 0,
+      time_created    DATETIME  NOT NULL
+    );
+
+    CREATE INDEX exams_user_id ON exams (user_id);
+
+    ALTER TABLE tasks ADD COLUMN exam_id INTEGER NOT NULL DEFAULT 0;
+
+    CREATE INDEX tasks_exam_id ON tasks (exam_id) WHERE exam_id != 0;
+  `,
+
+	// assessments of tasks, a task is assessed when max_score isn't 0 and
+	// score is null until it's graded
+	`
+    ALTER TABLE tasks ADD COLUMN assessment_weight REAL NOT NULL DEFAULT 0;
+    ALTER TABLE tasks ADD COLUMN max_score REAL NOT NULL DEFAULT 0;
+    ALTER TABLE tasks ADD COLUMN score REAL;
+  `,
+
+	// estimates of tasks, the weekly windows users are available to work
+	// in, like timetable sessions, and the blocks of time accepted from a
+	// plan to work on a task
+	`
+    ALTER TABLE tasks ADD COLUMN estimate_minutes INTEGER NOT NULL DEFAULT 0;
+
+    CREATE TABLE availability_windows (
+      window_id       INTEGER   PRIMARY KEY NOT NULL,
+      user_id         INTEGER   NOT NULL REFERENCES users,
+      day             TEXT      NOT NULL,
+      start_time      TEXT      NOT NULL,
+      end_time        TEXT      NOT NULL
+    );
+
+    CREATE INDEX availability_windows_user_id ON availability_windows (user_id);
+
+    CREATE TABLE time_blocks (
+      block_id        INTEGER   PRIMARY KEY NOT NULL,
+      user_id         INTEGER   NOT NULL REFERENCES users,
+      task_id         INTEGER   NOT NULL REFERENCES tasks,
+      start_time      DATETIME  NOT NULL,
+      end_time        DATETIME  NOT NULL,
+      time_created    DATETIME  NOT NULL
+    );
+
+    CREATE INDEX time_blocks_user_id ON time_blocks (user_id);
+    CREATE INDEX time_blocks_task_id ON time_blocks (task_id);
+  `,
+
+	// pomodoro lengths of users and the time spent on tasks. An entry
+	// without an end is a running timer, a user has at most one.
+	`
+    ALTER TABLE users ADD COLUMN pomodoro_work_minutes INTEGER NOT NULL DEFAULT 25;
+    ALTER TABLE users ADD COLUMN pomodoro_break_minutes INTEGER NOT NULL DEFAULT 5;
+
+    CREATE TABLE time_entries (
+      entry_id        INTEGER   PRIMARY KEY NOT NULL,
+      user_id         INTEGER   NOT NULL REFERENCES users,
+      task_id         INTEGER   NOT NULL REFERENCES tasks,
+      kind            TEXT      NOT NULL,
+      start_time      DATETIME  NOT NULL,
+      end_time        DATETIME,
+      planned_end     DATETIME,
+      break_minutes   INTEGER   NOT NULL DEFAULT 0,
+      note            TEXT      NOT NULL DEFAULT '',
+      time_created    DATETIME  NOT NULL
+    );
+
+    CREATE INDEX time_entries_user_id ON time_entries (user_id);
+    CREATE INDEX time_entries_task_id ON time_entries (task_id);
+    CREATE UNIQUE INDEX time_entries_running ON time_entries (user_id) WHERE end_time IS NULL;
+  `,
+
+	// daily completion streaks of users, last_day is the "2006-01-02" day
+	// in their timezone they last completed a task on, the days they froze
+	// to keep their streak and the badges they earned
+	`
+    ALTER TABLE users ADD COLUMN streak_current INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE users ADD COLUMN streak_longest INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE users ADD COLUMN streak_last_day TEXT NOT NULL DEFAULT '';
+    ALTER TABLE users ADD COLUMN streak_freezes INTEGER NOT NULL DEFAULT 0;
+
+    CREATE TABLE streak_freezes (
+      user_id         INTEGER   NOT NULL REFERENCES users,
+      day             TEXT      NOT NULL,
+      time_created    DATETIME  NOT NULL,
+      PRIMARY KEY (user_id, day)
+    );
+
+    CREATE TABLE user_badges (
+      user_id         INTEGER   NOT NULL REFERENCES users,
+      badge           TEXT      NOT NULL,
+      time_earned     DATETIME  NOT NULL,
+      PRIMARY KEY (user_id, badge)
+    );
+  `,
+
+	// scheduled emails users opted in to, agenda_time is the "15:04" time
+	// in their timezone they go out at and the sent_on columns are the
+	// "2006-01-02" days in their timezone the last ones were sent on
+	`
+    ALTER TABLE users ADD COLUMN agenda_emails INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE users ADD COLUMN digest_emails INTEGER NOT NULL DEFAULT 0;
+    ALTER TABLE users ADD COLUMN agenda_time TEXT NOT NULL DEFAULT '07:00';
+    ALTER TABLE users ADD COLUMN agenda_sent_on TEXT NOT NULL DEFAULT '';
+    ALTER TABLE users ADD COLUMN digest_sent_on TEXT NOT NULL DEFAULT '';
+  `,
+
+	// email verification codes waiting to be used, a code is added along
+	// with the email that sends it
+	`
+    CREATE TABLE verification_codes (
+      code            TEXT      PRIMARY KEY,
+      email           TEXT      NOT NULL,
+      expires_at      DATETIME  NOT NULL
+    );
+  `,
+
+	// an occurrence of a series is only created once. Occurrences that were
+	// created twice are moved to the end of their series first.
+	`
+    WITH duplicates AS (
+      SELECT t.task_id, t.series_id,
+        ROW_NUMBER() OVER (PARTITION BY t.series_id ORDER BY t.task_id) AS n
+      FROM tasks t
+      WHERE t.series_id != 0 AND EXISTS (
+        SELECT 1 FROM tasks o
+        WHERE o.series_id = t.series_id AND o.occurrence = t.occurrence
+          AND o.task_id < t.task_id
+      )
+    ), last AS (
+      SELECT series_id, MAX(occurrence) AS occurrence
+      FROM tasks WHERE series_id != 0 GROUP BY series_id
+    )
+    UPDATE tasks SET occurrence = last.occurrence + duplicates.n
+    FROM duplicates JOIN last USING (series_id)
+    WHERE tasks.task_id = duplicates.task_id;
+
+    CREATE UNIQUE INDEX tasks_series_occurrence ON tasks (series_id, occurrence)
+    WHERE series_id != 0;
+  `,
+}
+
 func RunMigrations(db *sql.DB) error {
 	if _, err := db.Exec(migrateDbSchema); err != nil {
 		return err
 	}
 
+	var version int
+	if err := db.QueryRow("PRAGMA user_version").Scan(&version); err != nil {
+		return fmt.Errorf("error getting schema version: %v", err)
+	}
+
+	for i := version; i < len(migrations); i++ {
+		tx, err := db.Begin()
+		if err != nil {
+			return fmt.Errorf("error starting migration %d: %v", i+1, err)
+		}
+
+		if _, err := tx.Exec(migrations[i]); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error running migration %d: %v", i+1, err)
+		}
+
+		if _, err := tx.Exec(fmt.Sprintf("PRAGMA user_version = %d", i+1)); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("error updating schema version: %v", err)
+		}
+
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("error committing migration %d: %v", i+1, err)
+		}
+	}
+
 	return nil
 }
 
@@ -1,24 +1,776 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
 
 type User struct {
 	ID         int64  `json:"id"`
 	Email      string `json:"email"`
 	Username   string `json:"username"`
-	Password   string `json:"password"`
+	Password   string `json:"-"`
 	IsVerified bool   `json:"is_verified"`
+	IsAdmin    bool   `json:"is_admin"`
 	Tasks      []Task `json:"tasks"`
+
+	// settings, quiet hours are "15:04" times in the user's timezone and
+	// disabled when empty
+	Timezone             string `json:"timezone"`
+	QuietHoursStart      string `json:"quiet_hours_start"`
+	QuietHoursEnd        string `json:"quiet_hours_end"`
+	PomodoroWorkMinutes  int    `json:"pomodoro_work_minutes"`
+	PomodoroBreakMinutes int    `json:"pomodoro_break_minutes"`
+
+	// scheduled emails, the morning agenda goes out daily at AgendaTime in
+	// the user's timezone and the weekly digest on Mondays at the same time
+	AgendaEmails bool   `json:"agenda_emails"`
+	DigestEmails bool   `json:"digest_emails"`
+	AgendaTime   string `json:"agenda_time"`
+
+	// filled in for the user's profile
+	Streak *Streak `json:"streak,omitempty"`
+	Badges []Badge `json:"badges,omitempty"`
+}
+
+// Streak is the user's run of days in a row they completed a task on, in
+// their timezone. Days they froze keep the streak going without adding to
+// it, Freezes is how many they have left to use. Current is 0 once the
+// streak is broken, LastDay is the last day they completed a task on.
+type Streak struct {
+	Current    int      `json:"current"`
+	Longest    int      `json:"longest"`
+	LastDay    string   `json:"last_day"`
+	Freezes    int      `json:"freezes"`
+	FrozenDays []string `json:"frozen_days"`
+}
+
+// Badge is an achievement the user earned.
+type Badge struct {
+	Name        string    `json:"name"`
+	Title       string    `json:"title"`
+	Description string    `json:"description"`
+	TimeEarned  time.Time `json:"time_earned"`
+}
+
+// UserSettings is an update to the user's settings, nil fields are left
+// unchanged.
+type UserSettings struct {
+	Timezone             *string `json:"timezone"`
+	QuietHoursStart      *string `json:"quiet_hours_start"`
+	QuietHoursEnd        *string `json:"quiet_hours_end"`
+	PomodoroWorkMinutes  *int    `json:"pomodoro_work_minutes"`
+	PomodoroBreakMinutes *int    `json:"pomodoro_break_minutes"`
+	AgendaEmails         *bool   `json:"agenda_emails"`
+	DigestEmails         *bool   `json:"digest_emails"`
+	AgendaTime           *string `json:"agenda_time"`
 }
 
 type Task struct {
 	ID            int64     `json:"id"`
+	UID           string    `json:"uid"`
+	Version       int64     `json:"version"`
 	Name          string    `json:"name"`
 	Tag           string    `json:"tag"`
-	Priority      bool      `json:"priority"`
+	Priority      Priority  `json:"priority"`
 	IsCompleted   bool      `json:"is_completed"`
 	Description   string    `json:"description"`
 	TimeDue       time.Time `json:"time_due"`
 	TimeCreated   time.Time `json:"time_created"`
 	TimeCompleted time.Time `json:"time_completed"`
+
+	// course the task is for, 0 when it isn't for one
+	CourseID int64 `json:"course_id"`
+
+	// exam the task was planned to study for, it's set by the planner
+	ExamID int64 `json:"exam_id"`
+
+	// set when the task is graded work of its course
+	Assessment *Assessment `json:"assessment,omitempty"`
+
+	// minutes of work the task is expected to take, 0 when it isn't known
+	EstimateMinutes int `json:"estimate_minutes"`
+
+	// set while the task is in the trash
+	TimeDeleted *time.Time `json:"time_deleted,omitempty"`
+
+	Items    []ChecklistItem `json:"items"`
+	Progress int             `json:"progress"`
+
+	// minutes before the due time to send a reminder at
+	Reminders []int `json:"reminders"`
+
+	// recurrence, RecurrenceTime is when the occurrence was scheduled by the
+	// rule, before any change to its due time
+	RRule          string    `json:"rrule"`
+	SeriesID       int64     `json:"series_id"`
+	Occurrence     int       `json:"occurrence"`
+	RecurrenceTime time.Time `json:"-"`
+}
+
+// Assessment is the grading of a task. Weight is the share of the course's
+// final grade it's worth, in percent, and Score is nil until it's graded.
+type Assessment struct {
+	Weight   float64  `json:"weight"`
+	MaxScore float64  `json:"max_score"`
+	Score    *float64 `json:"score"`
+}
+
+// CourseGrades is the grade report of a course, from the assessments of its
+// tasks. Grades are percentages weighted by the assessments' weights, the
+// average and projection are nil until something is graded. Needed is the
+// average the ungraded assessments need to reach Target, it's only worked
+// out when a target is given.
+type CourseGrades struct {
+	CourseID       int64       `json:"course_id"`
+	TotalWeight    float64     `json:"total_weight"`
+	GradedWeight   float64     `json:"graded_weight"`
+	CurrentAverage *float64    `json:"current_average"`
+	ProjectedFinal *float64    `json:"projected_final"`
+	Target         *float64    `json:"target,omitempty"`
+	Needed         *float64    `json:"needed,omitempty"`
+	Achievable     *bool       `json:"achievable,omitempty"`
+	Items          []GradeItem `json:"items"`
+}
+
+// GradeItem is an assessed task of a course. NeededScore is the score it
+// needs for the course to reach the report's target.
+type GradeItem struct {
+	TaskID      int64     `json:"task_id"`
+	Name        string    `json:"name"`
+	TimeDue     time.Time `json:"time_due"`
+	Weight      float64   `json:"weight"`
+	MaxScore    float64   `json:"max_score"`
+	Score       *float64  `json:"score"`
+	Percentage  *float64  `json:"percentage"`
+	NeededScore *float64  `json:"needed_score,omitempty"`
+}
+
+// Reminder is a reminder email due to be sent about a task. Kind is
+// "upcoming" for a reminder Offset minutes before the due time or "overdue"
+// once the task is late.
+type Reminder struct {
+	TaskID   int64
+	UserID   int64
+	Kind     string
+	Offset   int
+	TimeDue  time.Time
+	TaskName string
+
+	Email           string
+	Timezone        string
+	QuietHoursStart string
+	QuietHoursEnd   string
+}
+
+// EmailSubscriber is a user who opted in to scheduled emails. The sent on
+// dates are the "2006-01-02" days in their timezone the last agenda and
+// digest were sent on.
+type EmailSubscriber struct {
+	UserID       int64
+	Email        string
+	Timezone     string
+	AgendaEmails bool
+	DigestEmails bool
+	AgendaTime   string
+	AgendaSentOn string
+	DigestSentOn string
+}
+
+// ImportOptions control how tasks are imported. Mapping maps task fields
+// (name, description, tag, priority, time_due, rrule, uid) to csv column
+// headers, fields not mapped are read from a column of the same name.
+// TimeFormat is a go time layout for csv times.
+type ImportOptions struct {
+	Format     string            `json:"format"`
+	DryRun     bool              `json:"dry_run"`
+	Mapping    map[string]string `json:"mapping"`
+	TimeFormat string            `json:"time_format"`
+}
+
+// ImportResult lists the tasks created by an import, or that would be on a
+// dry run, and the entries that were skipped or failed validation.
+type ImportResult struct {
+	DryRun  bool          `json:"dry_run"`
+	Created []Task        `json:"created"`
+	Skipped []ImportIssue `json:"skipped"`
+	Failed  []ImportIssue `json:"failed"`
+}
+
+// ImportIssue is an entry of an import that wasn't created. Entry is the
+// csv row or the position of the calendar component, starting at 1.
+type ImportIssue struct {
+	Entry  int    `json:"entry"`
+	UID    string `json:"uid"`
+	Name   string `json:"name"`
+	Reason string `json:"reason"`
+}
+
+// AccountExportVersion is the version of the account archive format. It's
+// bumped when the format changes in a way older versions can't restore.
+const AccountExportVersion = 2
+
+// AccountExport is the archive of a user's data. Ids are the ones of the
+// exporting instance, tasks refer to their series by them, revisions, time
+// blocks and time entries to their tasks, and everything is given new ids
+// when the archive is restored.
+type AccountExport struct {
+	Version      int                  `json:"version"`
+	ExportedAt   time.Time            `json:"exported_at"`
+	Profile      AccountProfile       `json:"profile"`
+	Semesters    []Semester           `json:"semesters"`
+	Courses      []Course             `json:"courses"`
+	Timetable    []TimetableSession   `json:"timetable"`
+	Exams        []Exam               `json:"exams"`
+	Availability []AvailabilityWindow `json:"availability"`
+	Series       []TaskSeries         `json:"series"`
+	Tasks        []ExportedTask       `json:"tasks"`
+
+	// added in version 2, archives of version 1 restore without them
+	Trash       []ExportedTask      `json:"trash"`
+	Revisions   []TaskRevision      `json:"revisions"`
+	TimeBlocks  []TimeBlock         `json:"time_blocks"`
+	TimeEntries []ExportedTimeEntry `json:"time_entries"`
+	Webhooks    []Webhook           `json:"webhooks"`
+	Streak      Streak              `json:"streak"`
+	Badges      []Badge             `json:"badges"`
+}
+
+// AccountProfile is the exported part of the user, without credentials.
+type AccountProfile struct {
+	Username             string `json:"username"`
+	Email                string `json:"email"`
+	Timezone             string `json:"timezone"`
+	QuietHoursStart      string `json:"quiet_hours_start"`
+	QuietHoursEnd        string `json:"quiet_hours_end"`
+	PomodoroWorkMinutes  int    `json:"pomodoro_work_minutes"`
+	PomodoroBreakMinutes int    `json:"pomodoro_break_minutes"`
+	AgendaEmails         bool   `json:"agenda_emails"`
+	DigestEmails         bool   `json:"digest_emails"`
+	AgendaTime           string `json:"agenda_time"`
+}
+
+// ExportedTask is a task along with the recurrence state that isn't part of
+// its json.
+type ExportedTask struct {
+	Task
+	RecurrenceTime time.Time `json:"recurrence_time"`
+}
+
+// ExportedTimeEntry is a time entry along with the break length that isn't
+// part of its json.
+type ExportedTimeEntry struct {
+	TimeEntry
+	BreakMinutes int `json:"break_minutes"`
+}
+
+// BatchOperation is one operation of a task batch. Op is create, update,
+// complete or delete. Task holds the fields to create or update with and
+// Version is the version the operation expects the task to be at, it's
+// required by all but create.
+type BatchOperation struct {
+	Op      string `json:"op"`
+	TaskID  int64  `json:"task_id"`
+	Version int64  `json:"version"`
+	Task    *Task  `json:"task"`
+}
+
+// BatchResult is the outcome of a batch operation. Status is "ok",
+// "failed", "rolled_back" when an all-or-nothing batch failed on another
+// operation, or "skipped" for operations after the one that failed.
+type BatchResult struct {
+	Index  int    `json:"index"`
+	Op     string `json:"op"`
+	TaskID int64  `json:"task_id"`
+	Status string `json:"status"`
+	Task   *Task  `json:"task,omitempty"`
+	Error  string `json:"error,omitempty"`
+}
+
+// IdempotentResponse is the response stored for an idempotency key, it's
+// replayed when a request is retried with the same key. RequestHash
+// identifies the request the key was first used with and Status is 0 while
+// that request is still being handled.
+type IdempotentResponse struct {
+	RequestHash string              `json:"request_hash"`
+	Status      int                 `json:"status"`
+	Header      map[string][]string `json:"header"`
+	Body        []byte              `json:"body"`
+}
+
+// Semester is a term the user's courses are grouped into, dates are
+// "2006-01-02". Archiving a semester hides its courses' tasks from the task
+// list unless they're asked for.
+type Semester struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	StartDate   string    `json:"start_date"`
+	EndDate     string    `json:"end_date"`
+	IsArchived  bool      `json:"is_archived"`
+	TimeCreated time.Time `json:"time_created"`
+
+	Courses []Course `json:"courses,omitempty"`
+}
+
+// SemesterUpdate is an update to a semester, nil fields are left unchanged.
+type SemesterUpdate struct {
+	Name      *string `json:"name"`
+	StartDate *string `json:"start_date"`
+	EndDate   *string `json:"end_date"`
+}
+
+// Course is a course the user takes, in a semester when SemesterID isn't
+// 0. Its dates are optional and narrow down the semester's, IsArchived is
+// the semester's.
+type Course struct {
+	ID          int64     `json:"id"`
+	SemesterID  int64     `json:"semester_id"`
+	Name        string    `json:"name"`
+	Code        string    `json:"code"`
+	Instructor  string    `json:"instructor"`
+	Colour      string    `json:"colour"`
+	StartDate   string    `json:"start_date"`
+	EndDate     string    `json:"end_date"`
+	IsArchived  bool      `json:"is_archived"`
+	TimeCreated time.Time `json:"time_created"`
+}
+
+// TimetableSession is a weekly class of a course, like a lecture or a lab.
+// Day is the lowercase weekday and the times are "15:04" in the user's
+// timezone. Weeks is "all", or "odd" or "even" to only hold the session
+// every other week, counted from the start of the course's term.
+type TimetableSession struct {
+	ID          int64     `json:"id"`
+	CourseID    int64     `json:"course_id"`
+	Day         string    `json:"day"`
+	StartTime   string    `json:"start_time"`
+	EndTime     string    `json:"end_time"`
+	Location    string    `json:"location"`
+	Kind        string    `json:"kind"`
+	Weeks       string    `json:"weeks"`
+	TimeCreated time.Time `json:"time_created"`
+}
+
+// TimetableSessionUpdate is an update to a session, nil fields are left
+// unchanged.
+type TimetableSessionUpdate struct {
+	CourseID  *int64  `json:"course_id"`
+	Day       *string `json:"day"`
+	StartTime *string `json:"start_time"`
+	EndTime   *string `json:"end_time"`
+	Location  *string `json:"location"`
+	Kind      *string `json:"kind"`
+	Weeks     *string `json:"weeks"`
+}
+
+// AgendaDay is a day of the user's schedule, in their timezone.
+type AgendaDay struct {
+	Date  string       `json:"date"`
+	Items []AgendaItem `json:"items"`
+}
+
+// AgendaItem is a timetable session on a given day, an exam, a block of
+// time planned for a task or a task due on it. Exams and tasks have no end.
+type AgendaItem struct {
+	Type       string     `json:"type"`
+	Start      time.Time  `json:"start"`
+	End        *time.Time `json:"end,omitempty"`
+	Title      string     `json:"title"`
+	CourseID   int64      `json:"course_id"`
+	CourseCode string     `json:"course_code,omitempty"`
+	Colour     string     `json:"colour,omitempty"`
+	Location   string     `json:"location,omitempty"`
+	Kind       string     `json:"kind,omitempty"`
+	SessionID  int64      `json:"session_id,omitempty"`
+	ExamID     int64      `json:"exam_id,omitempty"`
+	BlockID    int64      `json:"block_id,omitempty"`
+	Task       *Task      `json:"task,omitempty"`
+}
+
+// AvailabilityWindow is a time of the week the user is available to work
+// on their tasks. Day is the lowercase weekday and the times are "15:04" in
+// the user's timezone.
+type AvailabilityWindow struct {
+	Day       string `json:"day"`
+	StartTime string `json:"start_time"`
+	EndTime   string `json:"end_time"`
+}
+
+// TimeBlock is a block of time to work on a task, ID is 0 until it's
+// accepted from a plan.
+type TimeBlock struct {
+	ID       int64     `json:"id,omitempty"`
+	TaskID   int64     `json:"task_id"`
+	TaskName string    `json:"task_name"`
+	Start    time.Time `json:"start"`
+	End      time.Time `json:"end"`
+}
+
+// Plan is a proposed schedule of work on the user's tasks, as blocks of
+// time on each day from From to To. Unplanned lists the tasks that didn't
+// fit, or only partly did.
+type Plan struct {
+	From      string          `json:"from"`
+	To        string          `json:"to"`
+	Accepted  bool            `json:"accepted"`
+	Days      []PlanDay       `json:"days"`
+	Unplanned []UnplannedTask `json:"unplanned"`
+}
+
+// PlanDay is a day of a plan, in the user's timezone.
+type PlanDay struct {
+	Date    string      `json:"date"`
+	Minutes int         `json:"minutes"`
+	Blocks  []TimeBlock `json:"blocks"`
+}
+
+// UnplannedTask is a task with MinutesLeft of its estimate a plan couldn't
+// fit in.
+type UnplannedTask struct {
+	TaskID      int64  `json:"task_id"`
+	Name        string `json:"name"`
+	MinutesLeft int    `json:"minutes_left"`
+	Reason      string `json:"reason"`
+}
+
+// TimeEntry is time spent on a task. Kind is "timer" or "pomodoro" for
+// time tracked live and "manual" for time entered afterwards. A running
+// timer has no end yet, a pomodoro stops on its own at PlannedEnd and is
+// followed by a break until BreakUntil. Minutes counts up to now while the
+// timer runs.
+type TimeEntry struct {
+	ID          int64      `json:"id"`
+	TaskID      int64      `json:"task_id"`
+	TaskName    string     `json:"task_name"`
+	Kind        string     `json:"kind"`
+	Start       time.Time  `json:"start"`
+	End         *time.Time `json:"end"`
+	PlannedEnd  *time.Time `json:"planned_end,omitempty"`
+	BreakUntil  *time.Time `json:"break_until,omitempty"`
+	Minutes     int        `json:"minutes"`
+	Note        string     `json:"note"`
+	TimeCreated time.Time  `json:"time_created"`
+
+	// length of a pomodoro's break
+	BreakMinutes int `json:"-"`
+}
+
+// TimeTotals sums the time tracked from From to To, by task, by course and
+// by week, weeks starting on monday in the user's timezone.
+type TimeTotals struct {
+	From    string       `json:"from"`
+	To      string       `json:"to"`
+	Minutes int          `json:"minutes"`
+	Tasks   []TaskTime   `json:"tasks"`
+	Courses []CourseTime `json:"courses"`
+	Weeks   []WeekTime   `json:"weeks"`
+}
+
+// TaskTime is the time tracked on a task, along with its estimate.
+type TaskTime struct {
+	TaskID          int64  `json:"task_id"`
+	Name            string `json:"name"`
+	CourseID        int64  `json:"course_id"`
+	Minutes         int    `json:"minutes"`
+	EstimateMinutes int    `json:"estimate_minutes"`
+}
+
+// CourseTime is the time tracked on a course's tasks, CourseID 0 is for
+// the tasks without a course.
+type CourseTime struct {
+	CourseID int64  `json:"course_id"`
+	Name     string `json:"name"`
+	Minutes  int    `json:"minutes"`
+}
+
+// WeekTime is the time tracked in the week starting on Week.
+type WeekTime struct {
+	Week    string `json:"week"`
+	Minutes int    `json:"minutes"`
+}
+
+// TaskStats are statistics of the user's tasks. The counts, rate and
+// breakdowns are of all their tasks, the completion figures below them of
+// the tasks completed from From to To. A completion is on time when it's
+// no later than the task's due time, tasks without one are neither.
+type TaskStats struct {
+	From           string  `json:"from"`
+	To             string  `json:"to"`
+	Total          int     `json:"total"`
+	Completed      int     `json:"completed"`
+	CompletionRate float64 `json:"completion_rate"`
+	Overdue        int     `json:"overdue"`
+
+	CompletedInRange int      `json:"completed_in_range"`
+	OnTime           int      `json:"on_time"`
+	Late             int      `json:"late"`
+	OnTimeRate       *float64 `json:"on_time_rate"`
+	AverageLeadHours *float64 `json:"average_lead_hours"`
+
+	Tags    []TagStats         `json:"tags"`
+	Courses []CourseStats      `json:"courses"`
+	Daily   []DailyCompletions `json:"daily"`
+}
+
+// TagStats counts the tasks of a tag.
+type TagStats struct {
+	Tag       string `json:"tag"`
+	Total     int    `json:"total"`
+	Completed int    `json:"completed"`
+	Overdue   int    `json:"overdue"`
+}
+
+// CourseStats counts the tasks of a course, CourseID 0 is for the tasks
+// without a course.
+type CourseStats struct {
+	CourseID  int64  `json:"course_id"`
+	Name      string `json:"name"`
+	Total     int    `json:"total"`
+	Completed int    `json:"completed"`
+	Overdue   int    `json:"overdue"`
+}
+
+// DailyCompletions is the number of tasks completed on a day.
+type DailyCompletions struct {
+	Date      string `json:"date"`
+	Completed int    `json:"completed"`
+}
+
+// Exam is an exam of a course. Weight is the share of the final grade it's
+// worth, in percent. StudyHours and SessionMinutes are what its study plan
+// was made with, 0 when it has none, and DaysLeft counts the days to it in
+// the user's timezone.
+type Exam struct {
+	ID             int64     `json:"id"`
+	CourseID       int64     `json:"course_id"`
+	Title          string    `json:"title"`
+	Time           time.Time `json:"time"`
+	Location       string    `json:"location"`
+	Weight         float64   `json:"weight"`
+	Topics         []string  `json:"topics"`
+	StudyHours     float64   `json:"study_hours"`
+	SessionMinutes int       `json:"session_minutes"`
+	DaysLeft       int       `json:"days_left"`
+	TimeCreated    time.Time `json:"time_created"`
+	StudyTasks     []Task    `json:"study_tasks,omitempty"`
+}
+
+// ExamUpdate is an update to an exam, nil fields are left unchanged.
+type ExamUpdate struct {
+	CourseID *int64     `json:"course_id"`
+	Title    *string    `json:"title"`
+	Time     *time.Time `json:"time"`
+	Location *string    `json:"location"`
+	Weight   *float64   `json:"weight"`
+	Topics   *[]string  `json:"topics"`
+}
+
+// CourseUpdate is an update to a course, nil fields are left unchanged.
+type CourseUpdate struct {
+	SemesterID *int64  `json:"semester_id"`
+	Name       *string `json:"name"`
+	Code       *string `json:"code"`
+	Instructor *string `json:"instructor"`
+	Colour     *string `json:"colour"`
+	StartDate  *string `json:"start_date"`
+	EndDate    *string `json:"end_date"`
+}
+
+// TaskSeries holds the rule and the template of a recurring task. Each
+// occurrence is a task of its own, the next one is created from the series
+// when the current one is completed or skipped.
+type TaskSeries struct {
+	ID          int64    `json:"id"`
+	RRule       string   `json:"rrule"`
+	Name        string   `json:"name"`
+	Tag         string   `json:"tag"`
+	Priority    Priority `json:"priority"`
+	Description string   `json:"description"`
+}
+
+// TaskRevision is a recorded change to a task, made by the user UserID.
+// Action is one of create, update, complete, delete, restore or revert.
+// Changes holds the fields that changed, keyed by their json name, and Task
+// is the task as it was after the change.
+type TaskRevision struct {
+	ID          int64                  `json:"id"`
+	TaskID      int64                  `json:"task_id"`
+	UserID      int64                  `json:"user_id"`
+	Action      string                 `json:"action"`
+	Changes     map[string]FieldChange `json:"changes"`
+	Task        Task                   `json:"task"`
+	TimeCreated time.Time              `json:"time_created"`
+}
+
+// FieldChange is the value of a field before and after a change.
+type FieldChange struct {
+	Old interface{} `json:"old"`
+	New interface{} `json:"new"`
+}
+
+// ChecklistItem is a step of a task. Items are ordered by position.
+type ChecklistItem struct {
+	ID          int64  `json:"id"`
+	TaskID      int64  `json:"task_id"`
+	Name        string `json:"name"`
+	Position    int    `json:"position"`
+	IsCompleted bool   `json:"is_completed"`
+}
+
+// UpdateProgress sets the task's completion percentage from its checklist.
+// A completed task is always done, whatever the state of its items.
+func (t *Task) UpdateProgress() {
+	switch {
+	case t.IsCompleted:
+		t.Progress = 100
+	case len(t.Items) == 0:
+		t.Progress = 0
+	default:
+		done := 0
+		for _, i := range t.Items {
+			if i.IsCompleted {
+				done++
+			}
+		}
+		t.Progress = done * 100 / len(t.Items)
+	}
+}
+
+// Priority is an ordered priority level. It is stored as an integer so
+// levels can be compared and sorted in sql.
+type Priority int
+
+const (
+	PriorityNone Priority = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+var priorityNames = []string{"none", "low", "medium", "high", "urgent"}
+
+func (p Priority) String() string {
+	if p < PriorityNone || p > PriorityUrgent {
+		return fmt.Sprintf("Priority(%d)", int(p))
+	}
+	return priorityNames[p]
+}
+
+func (p Priority) IsValid() bool {
+	return p >= PriorityNone && p <= PriorityUrgent
+}
+
+// ParsePriority parses a priority level name. The legacy boolean values are
+// accepted too, "true" being high and "false" being none.
+func ParsePriority(s string) (Priority, error) {
+	s = strings.ToLower(strings.TrimSpace(s))
+	switch s {
+	case "true":
+		return PriorityHigh, nil
+	case "false", "":
+		return PriorityNone, nil
+	}
+
+	for i, name := range priorityNames {
+		if s == name {
+			return Priority(i), nil
+		}
+	}
+
+	return PriorityNone, fmt.Errorf("invalid priority %q", s)
+}
+
+func (p Priority) MarshalJSON() ([]byte, error) {
+	return json.Marshal(p.String())
+}
+
+// UnmarshalJSON accepts a level name, its numeric value or the legacy
+// boolean flag sent by older clients.
+func (p *Priority) UnmarshalJSON(data []byte) error {
+	var v interface{}
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+
+	switch v := v.(type) {
+	case nil:
+		*p = PriorityNone
+	case bool:
+		*p = PriorityNone
+		if v {
+			*p = PriorityHigh
+		}
+	case float64:
+		if !Priority(v).IsValid() || v != float64(int(v)) {
+			return fmt.Errorf("invalid priority %v", v)
+		}
+		*p = Priority(v)
+	case string:
+		level, err := ParsePriority(v)
+		if err != nil {
+			return err
+		}
+		*p = level
+	default:
+		return fmt.Errorf("invalid priority %s", data)
+	}
+
+	return nil
+}
+
+// OutboxEmail is an email queued for delivery. Status is "pending" until
+// it's "sent", or "dead" once the worker has given up on it.
+type OutboxEmail struct {
+	ID          int64             `json:"id"`
+	Recipient   string            `json:"recipient"`
+	Template    string            `json:"template"`
+	Subject     string            `json:"subject"`
+	Code        string            `json:"-"`
+	Message     string            `json:"message"`
+	Fields      map[string]string `json:"fields"`
+	Status      string            `json:"status"`
+	Attempts    int               `json:"attempts"`
+	LastError   string            `json:"last_error"`
+	NextAttempt time.Time         `json:"next_attempt"`
+	TimeCreated time.Time         `json:"time_created"`
+	TimeSent    time.Time         `json:"time_sent"`
+}
+
+// Webhook is a user's subscription to their task events. Events are POSTed
+// to the url signed with the secret, which is only shown when the webhook
+// is created. A webhook is disabled after too many failed deliveries in a
+// row and picks up its pending deliveries again once it's re-enabled.
+type Webhook struct {
+	ID          int64     `json:"id"`
+	UserID      int64     `json:"-"`
+	URL         string    `json:"url"`
+	EventTypes  []string  `json:"event_types"`
+	Secret      string    `json:"secret,omitempty"`
+	IsActive    bool      `json:"is_active"`
+	Failures    int       `json:"failures"`
+	TimeCreated time.Time `json:"time_created"`
+}
+
+// WebhookDelivery is an event queued for a webhook. Status is "pending"
+// until it's "delivered", or "dead" once the worker has given up on it.
+type WebhookDelivery struct {
+	ID             int64           `json:"id"`
+	WebhookID      int64           `json:"webhook_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status"`
+	LastError      string          `json:"last_error"`
+	NextAttempt    time.Time       `json:"next_attempt"`
+	TimeCreated    time.Time       `json:"time_created"`
+	TimeDelivered  time.Time       `json:"time_delivered"`
+
+	// where and how to deliver it, filled in for the worker
+	UserID int64  `json:"-"`
+	URL    string `json:"-"`
+	Secret string `json:"-"`
 }
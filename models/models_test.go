@@ -0,0 +1,54 @@
+package models
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestPriorityUnmarshalJSON(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    Priority
+		wantErr bool
+	}{
+		{`"none"`, PriorityNone, false},
+		{`"low"`, PriorityLow, false},
+		{`"Medium"`, PriorityMedium, false},
+		{`"high"`, PriorityHigh, false},
+		{`"urgent"`, PriorityUrgent, false},
+		{`""`, PriorityNone, false},
+		{`null`, PriorityNone, false},
+		{`true`, PriorityHigh, false},
+		{`false`, PriorityNone, false},
+		{`"true"`, PriorityHigh, false},
+		{`0`, PriorityNone, false},
+		{`4`, PriorityUrgent, false},
+		{`5`, 0, true},
+		{`-1`, 0, true},
+		{`1.5`, 0, true},
+		{`"critical"`, 0, true},
+		{`[]`, 0, true},
+	}
+
+	for _, tt := range tests {
+		var p Priority
+		err := json.Unmarshal([]byte(tt.in), &p)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("unmarshal %s: got error %v, want error %v", tt.in, err, tt.wantErr)
+			continue
+		}
+		if err == nil && p != tt.want {
+			t.Errorf("unmarshal %s: got %v, want %v", tt.in, p, tt.want)
+		}
+	}
+}
+
+func TestPriorityMarshalJSON(t *testing.T) {
+	data, err := json.Marshal(PriorityMedium)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != `"medium"` {
+		t.Errorf("got %s, want \"medium\"", data)
+	}
+}
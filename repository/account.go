@@ -0,0 +1,267 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// ImportAccount restores exported data into a user's account in a single
+// transaction. Everything is given new ids, the semester, course, exam,
+// series and task ids everything refers to are the exported ones and are
+// mapped to the new ones.
+func (r *repo) ImportAccount(userId int64, user models.User, export models.AccountExport) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error importing account: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		updateUserStmt, user.Username, user.IsVerified, user.Timezone,
+		user.QuietHoursStart, user.QuietHoursEnd, user.PomodoroWorkMinutes,
+		user.PomodoroBreakMinutes, user.AgendaEmails, user.DigestEmails,
+		user.AgendaTime, userId,
+	); err != nil {
+		return fmt.Errorf("error updating user: %v", err)
+	}
+
+	semesterIds := make(map[int64]int64)
+	for _, s := range export.Semesters {
+		res, err := tx.Exec(insertSemesterStmt, userId, s.Name, s.StartDate, s.EndDate, s.TimeCreated)
+		if err != nil {
+			return fmt.Errorf("error inserting semester to database: %v", err)
+		}
+
+		if semesterIds[s.ID], err = res.LastInsertId(); err != nil {
+			return err
+		}
+
+		if s.IsArchived {
+			if _, err := tx.Exec(
+				updateSemesterStmt, s.Name, s.StartDate, s.EndDate, true, semesterIds[s.ID],
+			); err != nil {
+				return fmt.Errorf("error archiving semester: %v", err)
+			}
+		}
+	}
+
+	if err := setAvailability(tx, userId, export.Availability); err != nil {
+		return err
+	}
+
+	courseIds := make(map[int64]int64)
+	for _, c := range export.Courses {
+		if c.SemesterID != 0 {
+			semesterId, ok := semesterIds[c.SemesterID]
+			if !ok {
+				return fmt.Errorf("course %q refers to unknown semester %d", c.Name, c.SemesterID)
+			}
+			c.SemesterID = semesterId
+		}
+
+		res, err := tx.Exec(
+			insertCourseStmt, userId, c.SemesterID, c.Name, c.Code, c.Instructor,
+			c.Colour, c.StartDate, c.EndDate, c.TimeCreated,
+		)
+		if err != nil {
+			return fmt.Errorf("error inserting course to database: %v", err)
+		}
+
+		if courseIds[c.ID], err = res.LastInsertId(); err != nil {
+			return err
+		}
+	}
+
+	for _, s := range export.Timetable {
+		courseId, ok := courseIds[s.CourseID]
+		if !ok {
+			return fmt.Errorf("timetable session %d refers to unknown course %d", s.ID, s.CourseID)
+		}
+
+		if _, err := tx.Exec(
+			insertTimetableSessionStmt, courseId, userId, s.Day, s.StartTime,
+			s.EndTime, s.Location, s.Kind, s.Weeks, s.TimeCreated,
+		); err != nil {
+			return fmt.Errorf("error inserting timetable session to database: %v", err)
+		}
+	}
+
+	examIds := make(map[int64]int64)
+	for _, e := range export.Exams {
+		courseId, ok := courseIds[e.CourseID]
+		if !ok {
+			return fmt.Errorf("exam %q refers to unknown course %d", e.Title, e.CourseID)
+		}
+		e.CourseID = courseId
+
+		if examIds[e.ID], err = addExam(tx, userId, e); err != nil {
+			return err
+		}
+	}
+
+	seriesIds := make(map[int64]int64)
+	for _, s := range export.Series {
+		res, err := tx.Exec(insertTaskSeriesStmt, s.RRule, s.Name, s.Tag, s.Priority, s.Description, userId)
+		if err != nil {
+			return fmt.Errorf("error inserting task series to database: %v", err)
+		}
+
+		if seriesIds[s.ID], err = res.LastInsertId(); err != nil {
+			return err
+		}
+	}
+
+	taskIds := make(map[int64]int64)
+	for _, e := range append(export.Tasks, export.Trash...) {
+		t := e.Task
+		if t.SeriesID != 0 {
+			seriesId, ok := seriesIds[t.SeriesID]
+			if !ok {
+				return fmt.Errorf("task %q refers to unknown series %d", t.Name, t.SeriesID)
+			}
+			t.SeriesID = seriesId
+		}
+
+		if t.CourseID != 0 {
+			courseId, ok := courseIds[t.CourseID]
+			if !ok {
+				return fmt.Errorf("task %q refers to unknown course %d", t.Name, t.CourseID)
+			}
+			t.CourseID = courseId
+		}
+
+		if t.ExamID != 0 {
+			examId, ok := examIds[t.ExamID]
+			if !ok {
+				return fmt.Errorf("task %q refers to unknown exam %d", t.Name, t.ExamID)
+			}
+			t.ExamID = examId
+		}
+
+		taskId, err := addTask(tx, userId, t)
+		if err != nil {
+			return err
+		}
+		taskIds[t.ID] = taskId
+
+		for _, item := range t.Items {
+			if _, err := tx.Exec(insertChecklistItemStmt, taskId, item.Name, taskId, item.IsCompleted); err != nil {
+				return fmt.Errorf("error inserting checklist item to database: %v", err)
+			}
+		}
+
+		for _, offset := range t.Reminders {
+			if _, err := tx.Exec(insertTaskReminderStmt, taskId, offset); err != nil {
+				return fmt.Errorf("error inserting task reminder to database: %v", err)
+			}
+		}
+
+		if t.TimeDeleted != nil {
+			if _, err := tx.Exec(importTrashedTaskStmt, t.TimeDeleted.UTC(), taskId); err != nil {
+				return fmt.Errorf("error moving task to trash: %v", err)
+			}
+		}
+	}
+
+	for _, rev := range export.Revisions {
+		taskId, ok := taskIds[rev.TaskID]
+		if !ok {
+			return fmt.Errorf("revision %d refers to unknown task %d", rev.ID, rev.TaskID)
+		}
+		rev.TaskID = taskId
+		rev.UserID = userId
+
+		// the snapshot is restored by reverting, the ids it refers to are
+		// mapped too and dropped when they weren't exported
+		rev.Task.ID = taskId
+		rev.Task.SeriesID = seriesIds[rev.Task.SeriesID]
+		rev.Task.CourseID = courseIds[rev.Task.CourseID]
+		rev.Task.ExamID = examIds[rev.Task.ExamID]
+
+		if _, err := addTaskRevision(tx, rev); err != nil {
+			return err
+		}
+	}
+
+	now := time.Now()
+	for _, b := range export.TimeBlocks {
+		taskId, ok := taskIds[b.TaskID]
+		if !ok {
+			return fmt.Errorf("time block %d refers to unknown task %d", b.ID, b.TaskID)
+		}
+
+		if _, err := tx.Exec(insertTimeBlockStmt, userId, taskId, b.Start.UTC(), b.End.UTC(), now); err != nil {
+			return fmt.Errorf("error inserting time block to database: %v", err)
+		}
+	}
+
+	for _, e := range export.TimeEntries {
+		taskId, ok := taskIds[e.TaskID]
+		if !ok {
+			return fmt.Errorf("time entry %d refers to unknown task %d", e.ID, e.TaskID)
+		}
+
+		var end, plannedEnd interface{}
+		if e.End != nil {
+			end = e.End.UTC()
+		}
+		if e.PlannedEnd != nil {
+			plannedEnd = e.PlannedEnd.UTC()
+		}
+
+		if _, err := tx.Exec(
+			insertTimeEntryStmt, userId, taskId, e.Kind, e.Start.UTC(), end,
+			plannedEnd, e.BreakMinutes, e.Note, e.TimeCreated,
+		); err != nil {
+			return fmt.Errorf("error inserting time entry to database: %v", err)
+		}
+	}
+
+	for _, w := range export.Webhooks {
+		eventTypes, err := json.Marshal(w.EventTypes)
+		if err != nil {
+			return fmt.Errorf("error encoding webhook event types: %v", err)
+		}
+
+		res, err := tx.Exec(insertWebhookStmt, userId, w.URL, string(eventTypes), w.Secret, w.TimeCreated)
+		if err != nil {
+			return fmt.Errorf("error inserting webhook to database: %v", err)
+		}
+
+		webhookId, err := res.LastInsertId()
+		if err != nil {
+			return err
+		}
+
+		if _, err := tx.Exec(
+			updateWebhookStmt, w.URL, string(eventTypes), w.IsActive, w.Failures, webhookId,
+		); err != nil {
+			return fmt.Errorf("error updating webhook: %v", err)
+		}
+	}
+
+	// archives made before streaks were exported keep the user's streak
+	if export.Version >= 2 {
+		s := export.Streak
+		if _, err := tx.Exec(updateStreakStmt, s.Current, s.Longest, s.LastDay, s.Freezes, userId); err != nil {
+			return fmt.Errorf("error updating streak: %v", err)
+		}
+
+		for _, day := range s.FrozenDays {
+			if _, err := tx.Exec(insertImportedFrozenDayStmt, userId, day, now); err != nil {
+				return fmt.Errorf("error inserting frozen day to database: %v", err)
+			}
+		}
+	}
+
+	for _, b := range export.Badges {
+		if _, err := tx.Exec(insertBadgeStmt, userId, b.Name, b.TimeEarned); err != nil {
+			return fmt.Errorf("error inserting badge to database: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
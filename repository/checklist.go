@@ -0,0 +1,108 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// getChecklistItems runs a checklist item query and groups the items by
+// their task id, keeping the order of the query.
+func (r *repo) getChecklistItems(query string, args ...any) (map[int64][]models.ChecklistItem, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting checklist items from database: %v", err)
+	}
+	defer rows.Close()
+
+	items := make(map[int64][]models.ChecklistItem)
+	for rows.Next() {
+		var i models.ChecklistItem
+		if err := rows.Scan(&i.ID, &i.TaskID, &i.Name, &i.Position, &i.IsCompleted); err != nil {
+			return nil, fmt.Errorf("error getting checklist item from database: %v", err)
+		}
+		items[i.TaskID] = append(items[i.TaskID], i)
+	}
+
+	return items, rows.Err()
+}
+
+func (r *repo) AddChecklistItem(taskId int64, item models.ChecklistItem) (int64, error) {
+	res, err := r.db.Exec(insertChecklistItemStmt, taskId, item.Name, taskId, item.IsCompleted)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting checklist item to database: %v", err)
+	}
+
+	return res.LastInsertId()
+}
+
+func (r *repo) GetChecklistItem(taskId, itemId int64) (models.ChecklistItem, error) {
+	var i models.ChecklistItem
+
+	row := r.db.QueryRow(selectChecklistItemStmt, itemId, taskId)
+	if err := row.Scan(&i.ID, &i.TaskID, &i.Name, &i.Position, &i.IsCompleted); err != nil {
+		if err == sql.ErrNoRows {
+			return models.ChecklistItem{}, ErrItemNotFound
+		}
+		return models.ChecklistItem{}, fmt.Errorf("error getting checklist item from database: %v", err)
+	}
+
+	return i, nil
+}
+
+func (r *repo) UpdateChecklistItem(taskId, itemId int64, item models.ChecklistItem) error {
+	if _, err := r.db.Exec(updateChecklistItemStmt, item.Name, item.IsCompleted, itemId, taskId); err != nil {
+		return fmt.Errorf("error updating checklist item: %v", err)
+	}
+
+	return nil
+}
+
+// ReorderChecklistItems sets the position of each item to its index in
+// itemIds.
+func (r *repo) ReorderChecklistItems(taskId int64, itemIds []int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error reordering checklist items: %v", err)
+	}
+	defer tx.Rollback()
+
+	for pos, id := range itemIds {
+		if _, err := tx.Exec(updateChecklistItemPositionStmt, pos, id, taskId); err != nil {
+			return fmt.Errorf("error reordering checklist items: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *repo) CompleteChecklistItems(taskId int64) error {
+	if _, err := r.db.Exec(completeChecklistItemsStmt, taskId); err != nil {
+		return fmt.Errorf("error completing checklist items: %v", err)
+	}
+
+	return nil
+}
+
+func (r *repo) DeleteChecklistItem(taskId, itemId int64) error {
+	res, err := r.db.Exec(deleteChecklistItemStmt, itemId, taskId)
+	if err != nil {
+		return fmt.Errorf("error deleting checklist item: %v", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrItemNotFound
+	}
+
+	return nil
+}
+
+// BumpTaskVersion marks the task as changed when only its checklist was
+func (r *repo) BumpTaskVersion(taskId int64) error {
+	if _, err := r.db.Exec(bumpTaskVersionStmt, taskId); err != nil {
+		return fmt.Errorf("error updating task version: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func (r *repo) AddSemester(userId int64, s models.Semester) (int64, error) {
+	res, err := r.db.Exec(insertSemesterStmt, userId, s.Name, s.StartDate, s.EndDate, s.TimeCreated)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting semester to database: %v", err)
+	}
+
+	return res.LastInsertId()
+}
+
+func scanSemester(row scanner) (models.Semester, error) {
+	var s models.Semester
+	err := row.Scan(&s.ID, &s.Name, &s.StartDate, &s.EndDate, &s.IsArchived, &s.TimeCreated)
+	return s, err
+}
+
+// GetSemesters returns the user's semesters, latest first, without their
+// courses.
+func (r *repo) GetSemesters(userId int64) ([]models.Semester, error) {
+	rows, err := r.db.Query(selectSemestersStmt, userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting semesters from database: %v", err)
+	}
+	defer rows.Close()
+
+	var semesters []models.Semester
+	for rows.Next() {
+		s, err := scanSemester(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error getting semester from database: %v", err)
+		}
+		semesters = append(semesters, s)
+	}
+
+	return semesters, rows.Err()
+}
+
+func (r *repo) GetSemester(userId, semesterId int64) (models.Semester, error) {
+	s, err := scanSemester(r.db.QueryRow(selectSemesterStmt, semesterId, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Semester{}, ErrSemesterNotFound
+		}
+		return models.Semester{}, fmt.Errorf("error getting semester from database: %v", err)
+	}
+
+	return s, nil
+}
+
+func (r *repo) UpdateSemester(semesterId int64, s models.Semester) error {
+	if _, err := r.db.Exec(
+		updateSemesterStmt, s.Name, s.StartDate, s.EndDate, s.IsArchived, semesterId,
+	); err != nil {
+		return fmt.Errorf("error updating semester: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteSemester deletes a semester, its courses are kept without one.
+func (r *repo) DeleteSemester(semesterId int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error deleting semester: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(unlinkSemesterCoursesStmt, semesterId); err != nil {
+		return fmt.Errorf("error unlinking semester courses: %v", err)
+	}
+
+	if _, err := tx.Exec(deleteSemesterStmt, semesterId); err != nil {
+		return fmt.Errorf("error deleting semester: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *repo) AddCourse(userId int64, c models.Course) (int64, error) {
+	res, err := r.db.Exec(
+		insertCourseStmt, userId, c.SemesterID, c.Name, c.Code, c.Instructor,
+		c.Colour, c.StartDate, c.EndDate, c.TimeCreated,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting course to database: %v", err)
+	}
+
+	return res.LastInsertId()
+}
+
+func scanCourse(row scanner) (models.Course, error) {
+	var c models.Course
+	err := row.Scan(
+		&c.ID, &c.SemesterID, &c.Name, &c.Code, &c.Instructor, &c.Colour,
+		&c.StartDate, &c.EndDate, &c.IsArchived, &c.TimeCreated,
+	)
+	return c, err
+}
+
+func (r *repo) GetCourses(userId int64) ([]models.Course, error) {
+	rows, err := r.db.Query(selectCoursesStmt, userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting courses from database: %v", err)
+	}
+	defer rows.Close()
+
+	var courses []models.Course
+	for rows.Next() {
+		c, err := scanCourse(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error getting course from database: %v", err)
+		}
+		courses = append(courses, c)
+	}
+
+	return courses, rows.Err()
+}
+
+func (r *repo) GetCourse(userId, courseId int64) (models.Course, error) {
+	c, err := scanCourse(r.db.QueryRow(selectCourseStmt, courseId, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Course{}, ErrCourseNotFound
+		}
+		return models.Course{}, fmt.Errorf("error getting course from database: %v", err)
+	}
+
+	return c, nil
+}
+
+func (r *repo) UpdateCourse(courseId int64, c models.Course) error {
+	if _, err := r.db.Exec(
+		updateCourseStmt, c.SemesterID, c.Name, c.Code, c.Instructor, c.Colour,
+		c.StartDate, c.EndDate, courseId,
+	); err != nil {
+		return fmt.Errorf("error updating course: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteCourse deletes a course with its timetable and exams, its tasks are
+// kept without one or their assessments.
+func (r *repo) DeleteCourse(courseId int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error deleting course: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteCourseTimetableSessionsStmt, courseId); err != nil {
+		return fmt.Errorf("error deleting course timetable: %v", err)
+	}
+
+	if _, err := tx.Exec(unlinkCourseExamTasksStmt, courseId); err != nil {
+		return fmt.Errorf("error unlinking course exam tasks: %v", err)
+	}
+
+	if _, err := tx.Exec(deleteCourseExamsStmt, courseId); err != nil {
+		return fmt.Errorf("error deleting course exams: %v", err)
+	}
+
+	if _, err := tx.Exec(unlinkCourseTasksStmt, courseId); err != nil {
+		return fmt.Errorf("error unlinking course tasks: %v", err)
+	}
+
+	if _, err := tx.Exec(deleteCourseStmt, courseId); err != nil {
+		return fmt.Errorf("error deleting course: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *repo) AddTimetableSession(userId int64, s models.TimetableSession) (int64, error) {
+	res, err := r.db.Exec(
+		insertTimetableSessionStmt, s.CourseID, userId, s.Day, s.StartTime,
+		s.EndTime, s.Location, s.Kind, s.Weeks, s.TimeCreated,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting timetable session to database: %v", err)
+	}
+
+	return res.LastInsertId()
+}
+
+func scanTimetableSession(row scanner) (models.TimetableSession, error) {
+	var s models.TimetableSession
+	err := row.Scan(
+		&s.ID, &s.CourseID, &s.Day, &s.StartTime, &s.EndTime, &s.Location,
+		&s.Kind, &s.Weeks, &s.TimeCreated,
+	)
+	return s, err
+}
+
+// GetTimetableSessions returns the user's timetable in weekday order,
+// starting on monday.
+func (r *repo) GetTimetableSessions(userId int64) ([]models.TimetableSession, error) {
+	rows, err := r.db.Query(selectTimetableSessionsStmt, userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting timetable from database: %v", err)
+	}
+	defer rows.Close()
+
+	var sessions []models.TimetableSession
+	for rows.Next() {
+		s, err := scanTimetableSession(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error getting timetable session from database: %v", err)
+		}
+		sessions = append(sessions, s)
+	}
+
+	return sessions, rows.Err()
+}
+
+func (r *repo) GetTimetableSession(userId, sessionId int64) (models.TimetableSession, error) {
+	s, err := scanTimetableSession(r.db.QueryRow(selectTimetableSessionStmt, sessionId, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.TimetableSession{}, ErrSessionNotFound
+		}
+		return models.TimetableSession{}, fmt.Errorf("error getting timetable session from database: %v", err)
+	}
+
+	return s, nil
+}
+
+func (r *repo) UpdateTimetableSession(sessionId int64, s models.TimetableSession) error {
+	if _, err := r.db.Exec(
+		updateTimetableSessionStmt, s.CourseID, s.Day, s.StartTime, s.EndTime,
+		s.Location, s.Kind, s.Weeks, sessionId,
+	); err != nil {
+		return fmt.Errorf("error updating timetable session: %v", err)
+	}
+
+	return nil
+}
+
+func (r *repo) DeleteTimetableSession(sessionId int64) error {
+	if _, err := r.db.Exec(deleteTimetableSessionStmt, sessionId); err != nil {
+		return fmt.Errorf("error deleting timetable session: %v", err)
+	}
+
+	return nil
+}
@@ -0,0 +1,72 @@
+package repository
+
+import (
+	"fmt"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func (r *repo) GetEmailSubscribers() ([]models.EmailSubscriber, error) {
+	rows, err := r.db.Query(selectEmailSubscribersStmt)
+	if err != nil {
+		return nil, fmt.Errorf("error getting email subscribers from database: %v", err)
+	}
+	defer rows.Close()
+
+	var subscribers []models.EmailSubscriber
+	for rows.Next() {
+		var s models.EmailSubscriber
+		if err := rows.Scan(
+			&s.UserID, &s.Email, &s.Timezone, &s.AgendaEmails, &s.DigestEmails,
+			&s.AgendaTime, &s.AgendaSentOn, &s.DigestSentOn,
+		); err != nil {
+			return nil, fmt.Errorf("error getting email subscriber from database: %v", err)
+		}
+		subscribers = append(subscribers, s)
+	}
+
+	return subscribers, rows.Err()
+}
+
+// ClaimAgendaEmail marks the user's agenda as sent on the day and queues
+// the email, if there is one, returning false if it was already sent that
+// day.
+func (r *repo) ClaimAgendaEmail(userId int64, day string, email *models.OutboxEmail) (bool, error) {
+	return r.claimScheduledEmail(claimAgendaEmailStmt, userId, day, email)
+}
+
+// ClaimDigestEmail marks the user's digest as sent on the day and queues
+// the email, if there is one, returning false if it was already sent that
+// day.
+func (r *repo) ClaimDigestEmail(userId int64, day string, email *models.OutboxEmail) (bool, error) {
+	return r.claimScheduledEmail(claimDigestEmailStmt, userId, day, email)
+}
+
+func (r *repo) claimScheduledEmail(stmt string, userId int64, day string, email *models.OutboxEmail) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error claiming scheduled email: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(stmt, day, userId, day)
+	if err != nil {
+		return false, fmt.Errorf("error claiming scheduled email: %v", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if email != nil {
+		if _, err := addOutboxEmail(tx, *email); err != nil {
+			return false, err
+		}
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error claiming scheduled email: %v", err)
+	}
+
+	return true, nil
+}
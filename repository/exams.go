@@ -0,0 +1,116 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func (r *repo) AddExam(userId int64, e models.Exam) (int64, error) {
+	return addExam(r.db, userId, e)
+}
+
+func addExam(db execer, userId int64, e models.Exam) (int64, error) {
+	topics, err := json.Marshal(e.Topics)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding exam topics: %v", err)
+	}
+
+	res, err := db.Exec(
+		insertExamStmt, userId, e.CourseID, e.Title, e.Time, e.Location, e.Weight,
+		string(topics), e.StudyHours, e.SessionMinutes, e.TimeCreated,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting exam to database: %v", err)
+	}
+
+	return res.LastInsertId()
+}
+
+func scanExam(row scanner) (models.Exam, error) {
+	var e models.Exam
+	var topics string
+
+	if err := row.Scan(
+		&e.ID, &e.CourseID, &e.Title, &e.Time, &e.Location, &e.Weight, &topics,
+		&e.StudyHours, &e.SessionMinutes, &e.TimeCreated,
+	); err != nil {
+		return models.Exam{}, err
+	}
+
+	if err := json.Unmarshal([]byte(topics), &e.Topics); err != nil {
+		return models.Exam{}, fmt.Errorf("error decoding exam topics: %v", err)
+	}
+
+	return e, nil
+}
+
+// GetExams returns the user's exams, soonest first.
+func (r *repo) GetExams(userId int64) ([]models.Exam, error) {
+	rows, err := r.db.Query(selectExamsStmt, userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting exams from database: %v", err)
+	}
+	defer rows.Close()
+
+	var exams []models.Exam
+	for rows.Next() {
+		e, err := scanExam(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error getting exam from database: %v", err)
+		}
+		exams = append(exams, e)
+	}
+
+	return exams, rows.Err()
+}
+
+func (r *repo) GetExam(userId, examId int64) (models.Exam, error) {
+	e, err := scanExam(r.db.QueryRow(selectExamStmt, examId, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Exam{}, ErrExamNotFound
+		}
+		return models.Exam{}, fmt.Errorf("error getting exam from database: %v", err)
+	}
+
+	return e, nil
+}
+
+func (r *repo) UpdateExam(examId int64, e models.Exam) error {
+	topics, err := json.Marshal(e.Topics)
+	if err != nil {
+		return fmt.Errorf("error encoding exam topics: %v", err)
+	}
+
+	if _, err := r.db.Exec(
+		updateExamStmt, e.CourseID, e.Title, e.Time, e.Location, e.Weight,
+		string(topics), e.StudyHours, e.SessionMinutes, examId,
+	); err != nil {
+		return fmt.Errorf("error updating exam: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteExam deletes an exam, the tasks planned for it are kept without
+// one.
+func (r *repo) DeleteExam(examId int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error deleting exam: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(unlinkExamTasksStmt, examId); err != nil {
+		return fmt.Errorf("error unlinking exam tasks: %v", err)
+	}
+
+	if _, err := tx.Exec(deleteExamStmt, examId); err != nil {
+		return fmt.Errorf("error deleting exam: %v", err)
+	}
+
+	return tx.Commit()
+}
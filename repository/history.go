@@ -0,0 +1,89 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func (r *repo) AddTaskRevision(rev models.TaskRevision) (int64, error) {
+	return addTaskRevision(r.db, rev)
+}
+
+func addTaskRevision(db execer, rev models.TaskRevision) (int64, error) {
+	changes, err := json.Marshal(rev.Changes)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding task changes: %v", err)
+	}
+
+	snapshot, err := json.Marshal(rev.Task)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding task snapshot: %v", err)
+	}
+
+	res, err := db.Exec(
+		insertTaskRevisionStmt, rev.TaskID, rev.UserID, rev.Action,
+		string(changes), string(snapshot), rev.TimeCreated,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting task revision to database: %v", err)
+	}
+
+	return res.LastInsertId()
+}
+
+func scanTaskRevision(row scanner) (models.TaskRevision, error) {
+	var rev models.TaskRevision
+	var changes, snapshot string
+
+	if err := row.Scan(
+		&rev.ID, &rev.TaskID, &rev.UserID, &rev.Action, &changes, &snapshot,
+		&rev.TimeCreated,
+	); err != nil {
+		return models.TaskRevision{}, err
+	}
+
+	if err := json.Unmarshal([]byte(changes), &rev.Changes); err != nil {
+		return models.TaskRevision{}, fmt.Errorf("error decoding task changes: %v", err)
+	}
+
+	if err := json.Unmarshal([]byte(snapshot), &rev.Task); err != nil {
+		return models.TaskRevision{}, fmt.Errorf("error decoding task snapshot: %v", err)
+	}
+
+	return rev, nil
+}
+
+// GetTaskRevisions returns the history of a task, newest first.
+func (r *repo) GetTaskRevisions(taskId int64) ([]models.TaskRevision, error) {
+	rows, err := r.db.Query(selectTaskRevisionsStmt, taskId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting task history from database: %v", err)
+	}
+	defer rows.Close()
+
+	var revs []models.TaskRevision
+	for rows.Next() {
+		rev, err := scanTaskRevision(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error getting task revision from database: %v", err)
+		}
+		revs = append(revs, rev)
+	}
+
+	return revs, rows.Err()
+}
+
+func (r *repo) GetTaskRevision(taskId, revisionId int64) (models.TaskRevision, error) {
+	rev, err := scanTaskRevision(r.db.QueryRow(selectTaskRevisionStmt, revisionId, taskId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.TaskRevision{}, ErrRevisionNotFound
+		}
+		return models.TaskRevision{}, fmt.Errorf("error getting task revision from database: %v", err)
+	}
+
+	return rev, nil
+}
@@ -0,0 +1,127 @@
+package repository
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func addOutboxEmail(db execer, e models.OutboxEmail) (int64, error) {
+	fields, err := json.Marshal(e.Fields)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding email fields: %v", err)
+	}
+
+	res, err := db.Exec(
+		insertOutboxEmailStmt, e.Recipient, e.Template, e.Subject, e.Code,
+		e.Message, string(fields), e.NextAttempt.UTC(), e.TimeCreated,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting email to outbox: %v", err)
+	}
+
+	return res.LastInsertId()
+}
+
+func scanOutboxEmail(row scanner) (models.OutboxEmail, error) {
+	var e models.OutboxEmail
+	var fields string
+
+	if err := row.Scan(
+		&e.ID, &e.Recipient, &e.Template, &e.Subject, &e.Code, &e.Message,
+		&fields, &e.Status, &e.Attempts, &e.LastError, &e.NextAttempt,
+		&e.TimeCreated, &e.TimeSent,
+	); err != nil {
+		return models.OutboxEmail{}, err
+	}
+
+	if err := json.Unmarshal([]byte(fields), &e.Fields); err != nil {
+		return models.OutboxEmail{}, fmt.Errorf("error decoding email fields: %v", err)
+	}
+
+	return e, nil
+}
+
+func (r *repo) getOutboxEmails(query string, args ...any) ([]models.OutboxEmail, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting emails from outbox: %v", err)
+	}
+	defer rows.Close()
+
+	var emails []models.OutboxEmail
+	for rows.Next() {
+		e, err := scanOutboxEmail(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error getting email from outbox: %v", err)
+		}
+		emails = append(emails, e)
+	}
+
+	return emails, rows.Err()
+}
+
+func (r *repo) AddOutboxEmail(e models.OutboxEmail) (int64, error) {
+	return addOutboxEmail(r.db, e)
+}
+
+func (r *repo) GetDueOutboxEmails(now time.Time, limit int) ([]models.OutboxEmail, error) {
+	return r.getOutboxEmails(selectDueOutboxEmailsStmt, now.UTC(), limit)
+}
+
+func (r *repo) GetOutboxEmails(status string, limit int) ([]models.OutboxEmail, error) {
+	return r.getOutboxEmails(selectOutboxEmailsByStatusStmt, status, limit)
+}
+
+// LeaseOutboxEmail holds a due email until the given time, returning false
+// if another worker got to it first.
+func (r *repo) LeaseOutboxEmail(emailId int64, now, until time.Time) (bool, error) {
+	res, err := r.db.Exec(leaseOutboxEmailStmt, until.UTC(), emailId, now.UTC())
+	if err != nil {
+		return false, fmt.Errorf("error leasing email: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error leasing email: %v", err)
+	}
+
+	return n > 0, nil
+}
+
+func (r *repo) MarkOutboxEmailSent(emailId int64, now time.Time) error {
+	if _, err := r.db.Exec(updateOutboxEmailSentStmt, now, emailId); err != nil {
+		return fmt.Errorf("error marking email as sent: %v", err)
+	}
+
+	return nil
+}
+
+func (r *repo) MarkOutboxEmailFailed(emailId int64, attempts int, lastErr string, next time.Time, dead bool) error {
+	status := "pending"
+	if dead {
+		status = "dead"
+	}
+
+	if _, err := r.db.Exec(updateOutboxEmailFailedStmt, status, attempts, lastErr, next.UTC(), emailId); err != nil {
+		return fmt.Errorf("error marking email as failed: %v", err)
+	}
+
+	return nil
+}
+
+// ReplayOutboxEmail queues a dead email to be delivered again.
+func (r *repo) ReplayOutboxEmail(emailId int64, now time.Time) error {
+	res, err := r.db.Exec(replayOutboxEmailStmt, now.UTC(), emailId)
+	if err != nil {
+		return fmt.Errorf("error replaying email: %v", err)
+	}
+
+	if n, _ := res.RowsAffected(); n == 0 {
+		return ErrEmailNotFound
+	}
+
+	return nil
+}
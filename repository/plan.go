@@ -0,0 +1,161 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// GetAvailability returns the user's availability windows in weekday
+// order, starting on monday.
+func (r *repo) GetAvailability(userId int64) ([]models.AvailabilityWindow, error) {
+	rows, err := r.db.Query(selectAvailabilityWindowsStmt, userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting availability from database: %v", err)
+	}
+	defer rows.Close()
+
+	var windows []models.AvailabilityWindow
+	for rows.Next() {
+		var w models.AvailabilityWindow
+		if err := rows.Scan(&w.Day, &w.StartTime, &w.EndTime); err != nil {
+			return nil, fmt.Errorf("error getting availability window from database: %v", err)
+		}
+		windows = append(windows, w)
+	}
+
+	return windows, rows.Err()
+}
+
+// SetAvailability replaces the user's availability windows.
+func (r *repo) SetAvailability(userId int64, windows []models.AvailabilityWindow) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error setting availability: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := setAvailability(tx, userId, windows); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
+
+func setAvailability(db execer, userId int64, windows []models.AvailabilityWindow) error {
+	if _, err := db.Exec(deleteAvailabilityWindowsStmt, userId); err != nil {
+		return fmt.Errorf("error deleting availability: %v", err)
+	}
+
+	for _, w := range windows {
+		if _, err := db.Exec(insertAvailabilityWindowStmt, userId, w.Day, w.StartTime, w.EndTime); err != nil {
+			return fmt.Errorf("error inserting availability window to database: %v", err)
+		}
+	}
+
+	return nil
+}
+
+func scanTimeBlock(row scanner) (models.TimeBlock, error) {
+	var b models.TimeBlock
+	err := row.Scan(&b.ID, &b.TaskID, &b.TaskName, &b.Start, &b.End)
+	return b, err
+}
+
+// GetTimeBlocks returns the user's time blocks that overlap the given
+// times, earliest first.
+func (r *repo) GetTimeBlocks(userId int64, from, to time.Time) ([]models.TimeBlock, error) {
+	rows, err := r.db.Query(selectTimeBlocksStmt, userId, from.UTC(), to.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("error getting time blocks from database: %v", err)
+	}
+	defer rows.Close()
+
+	var blocks []models.TimeBlock
+	for rows.Next() {
+		b, err := scanTimeBlock(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error getting time block from database: %v", err)
+		}
+		blocks = append(blocks, b)
+	}
+
+	return blocks, rows.Err()
+}
+
+func (r *repo) GetTimeBlock(userId, blockId int64) (models.TimeBlock, error) {
+	b, err := scanTimeBlock(r.db.QueryRow(selectTimeBlockStmt, blockId, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.TimeBlock{}, ErrBlockNotFound
+		}
+		return models.TimeBlock{}, fmt.Errorf("error getting time block from database: %v", err)
+	}
+
+	return b, nil
+}
+
+// GetPastTimeBlockMinutes returns the minutes of the blocks that started
+// before the given time, by task.
+func (r *repo) GetPastTimeBlockMinutes(userId int64, before time.Time) (map[int64]int, error) {
+	rows, err := r.db.Query(selectPastTimeBlockMinutesStmt, userId, before.UTC())
+	if err != nil {
+		return nil, fmt.Errorf("error getting time blocks from database: %v", err)
+	}
+	defer rows.Close()
+
+	minutes := make(map[int64]int)
+	for rows.Next() {
+		var taskId int64
+		var m int
+		if err := rows.Scan(&taskId, &m); err != nil {
+			return nil, fmt.Errorf("error getting time blocks from database: %v", err)
+		}
+		minutes[taskId] = m
+	}
+
+	return minutes, rows.Err()
+}
+
+// ReplaceTimeBlocks replaces the blocks of the given tasks that start from
+// the given time with new ones, and returns the ids of the new ones.
+func (r *repo) ReplaceTimeBlocks(userId int64, taskIds []int64, from time.Time, blocks []models.TimeBlock) ([]int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return nil, fmt.Errorf("error replacing time blocks: %v", err)
+	}
+	defer tx.Rollback()
+
+	for _, taskId := range taskIds {
+		if _, err := tx.Exec(deleteTaskFutureTimeBlocksStmt, taskId, from.UTC()); err != nil {
+			return nil, fmt.Errorf("error deleting time blocks: %v", err)
+		}
+	}
+
+	now := time.Now()
+	ids := make([]int64, 0, len(blocks))
+	for _, b := range blocks {
+		res, err := tx.Exec(insertTimeBlockStmt, userId, b.TaskID, b.Start.UTC(), b.End.UTC(), now)
+		if err != nil {
+			return nil, fmt.Errorf("error inserting time block to database: %v", err)
+		}
+
+		id, err := res.LastInsertId()
+		if err != nil {
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+
+	return ids, tx.Commit()
+}
+
+func (r *repo) DeleteTimeBlock(blockId int64) error {
+	if _, err := r.db.Exec(deleteTimeBlockStmt, blockId); err != nil {
+		return fmt.Errorf("error deleting time block: %v", err)
+	}
+
+	return nil
+}
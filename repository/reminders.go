@@ -0,0 +1,109 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// getTaskReminders runs a task reminder query and groups the offsets by
+// their task id.
+func (r *repo) getTaskReminders(query string, args ...any) (map[int64][]int, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting task reminders from database: %v", err)
+	}
+	defer rows.Close()
+
+	reminders := make(map[int64][]int)
+	for rows.Next() {
+		var taskId int64
+		var offset int
+		if err := rows.Scan(&taskId, &offset); err != nil {
+			return nil, fmt.Errorf("error getting task reminder from database: %v", err)
+		}
+		reminders[taskId] = append(reminders[taskId], offset)
+	}
+
+	return reminders, rows.Err()
+}
+
+// SetTaskReminders replaces the reminder offsets of a task.
+func (r *repo) SetTaskReminders(taskId int64, offsets []int) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error setting task reminders: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteTaskRemindersStmt, taskId); err != nil {
+		return fmt.Errorf("error setting task reminders: %v", err)
+	}
+
+	for _, offset := range offsets {
+		if _, err := tx.Exec(insertTaskReminderStmt, taskId, offset); err != nil {
+			return fmt.Errorf("error setting task reminders: %v", err)
+		}
+	}
+
+	return tx.Commit()
+}
+
+func (r *repo) GetPendingReminders(now time.Time, grace time.Duration) ([]models.Reminder, error) {
+	rows, err := r.db.Query(
+		selectPendingRemindersStmt,
+		sql.Named("now", now.UTC()),
+		sql.Named("grace", grace.Hours()/24),
+	)
+	if err != nil {
+		return nil, fmt.Errorf("error getting pending reminders from database: %v", err)
+	}
+	defer rows.Close()
+
+	var reminders []models.Reminder
+	for rows.Next() {
+		var rem models.Reminder
+		if err := rows.Scan(
+			&rem.TaskID, &rem.UserID, &rem.Kind, &rem.Offset, &rem.TimeDue,
+			&rem.TaskName, &rem.Email, &rem.Timezone, &rem.QuietHoursStart,
+			&rem.QuietHoursEnd,
+		); err != nil {
+			return nil, fmt.Errorf("error getting pending reminder from database: %v", err)
+		}
+		reminders = append(reminders, rem)
+	}
+
+	return reminders, rows.Err()
+}
+
+// ClaimReminder records the reminder as sent and queues its email in the
+// same transaction, returning false if it was already claimed. A restart
+// never sends a reminder twice since the outbox takes over delivery.
+func (r *repo) ClaimReminder(rem models.Reminder, now time.Time, email models.OutboxEmail) (bool, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error claiming reminder: %v", err)
+	}
+	defer tx.Rollback()
+
+	res, err := tx.Exec(insertSentReminderStmt, rem.TaskID, rem.Kind, rem.Offset, rem.TimeDue.Unix(), now)
+	if err != nil {
+		return false, fmt.Errorf("error claiming reminder: %v", err)
+	}
+
+	if n, err := res.RowsAffected(); err != nil || n == 0 {
+		return false, err
+	}
+
+	if _, err := addOutboxEmail(tx, email); err != nil {
+		return false, err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return false, fmt.Errorf("error claiming reminder: %v", err)
+	}
+
+	return true, nil
+}
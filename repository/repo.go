@@ -10,14 +10,34 @@ import (
 
 var (
 	ErrUserNotFound = fmt.Errorf("user not found")
+	ErrCodeNotFound = fmt.Errorf("verification code not found")
 	ErrTaskNotFound = fmt.Errorf("task not found")
+	ErrItemNotFound = fmt.Errorf("checklist item not found")
+
+	ErrSeriesNotFound = fmt.Errorf("task series not found")
+	ErrEmailNotFound  = fmt.Errorf("email not found")
+
+	ErrRevisionNotFound = fmt.Errorf("task revision not found")
+	ErrVersionMismatch  = fmt.Errorf("task was changed since it was read")
+
+	ErrWebhookNotFound = fmt.Errorf("webhook not found")
+
+	ErrSemesterNotFound = fmt.Errorf("semester not found")
+	ErrCourseNotFound   = fmt.Errorf("course not found")
+	ErrSessionNotFound  = fmt.Errorf("timetable session not found")
+	ErrExamNotFound     = fmt.Errorf("exam not found")
+	ErrBlockNotFound    = fmt.Errorf("time block not found")
+	ErrEntryNotFound    = fmt.Errorf("time entry not found")
+	ErrTimerRunning     = fmt.Errorf("a timer is already running")
 )
 
 type repo struct {
-	db *sql.DB
+	db conn
 }
 
 type Repo interface {
+	Transaction(fn func(Repo) error) error
+
 	// user management
 	NewUser(user models.User) (int64, error)
 	GetUserByID(userId int64) (models.User, error)
@@ -26,16 +46,143 @@ type Repo interface {
 	DeleteUser(userId int64) error
 	UserEmailExists(userEmail string) bool
 	CheckUserIDExists(userId int64) bool
+	SetCalendarToken(userId int64, tokenHash string) error
+	GetUserIDByCalendarToken(tokenHash string) (int64, error)
+	ImportAccount(userId int64, user models.User, export models.AccountExport) error
+	AddVerificationCode(code, email string, expiry time.Time, mail models.OutboxEmail) error
+	GetVerificationCode(code string, now time.Time) (string, error)
+	DeleteVerificationCode(code string) error
 
 	// task management
 	AddTask(userId int64, task models.Task) (int64, error)
 	GetTasks(userId int64) ([]models.Task, error)
+	GetTask(userId, taskId int64) (models.Task, error)
+	TaskUIDExists(userId int64, uid string) (bool, error)
 	UpdateTask(taskId int64, task models.Task) error
 	DeleteTask(taskId int64) error
+
+	// semester and course management
+	AddSemester(userId int64, semester models.Semester) (int64, error)
+	GetSemesters(userId int64) ([]models.Semester, error)
+	GetSemester(userId, semesterId int64) (models.Semester, error)
+	UpdateSemester(semesterId int64, semester models.Semester) error
+	DeleteSemester(semesterId int64) error
+	AddCourse(userId int64, course models.Course) (int64, error)
+	GetCourses(userId int64) ([]models.Course, error)
+	GetCourse(userId, courseId int64) (models.Course, error)
+	UpdateCourse(courseId int64, course models.Course) error
+	DeleteCourse(courseId int64) error
+	AddTimetableSession(userId int64, session models.TimetableSession) (int64, error)
+	GetTimetableSessions(userId int64) ([]models.TimetableSession, error)
+	GetTimetableSession(userId, sessionId int64) (models.TimetableSession, error)
+	UpdateTimetableSession(sessionId int64, session models.TimetableSession) error
+	DeleteTimetableSession(sessionId int64) error
+	AddExam(userId int64, exam models.Exam) (int64, error)
+	GetExams(userId int64) ([]models.Exam, error)
+	GetExam(userId, examId int64) (models.Exam, error)
+	UpdateExam(examId int64, exam models.Exam) error
+	DeleteExam(examId int64) error
+	GetAvailability(userId int64) ([]models.AvailabilityWindow, error)
+	SetAvailability(userId int64, windows []models.AvailabilityWindow) error
+	GetTimeBlocks(userId int64, from, to time.Time) ([]models.TimeBlock, error)
+	GetTimeBlock(userId, blockId int64) (models.TimeBlock, error)
+	GetPastTimeBlockMinutes(userId int64, before time.Time) (map[int64]int, error)
+	ReplaceTimeBlocks(userId int64, taskIds []int64, from time.Time, blocks []models.TimeBlock) ([]int64, error)
+	DeleteTimeBlock(blockId int64) error
+
+	// time tracking
+	AddTimeEntry(userId int64, e models.TimeEntry) (int64, error)
+	GetRunningTimeEntry(userId int64) (models.TimeEntry, error)
+	GetTimeEntry(userId, entryId int64) (models.TimeEntry, error)
+	GetTaskTimeEntries(taskId int64) ([]models.TimeEntry, error)
+	GetTimeEntries(userId int64, from, to time.Time) ([]models.TimeEntry, error)
+	StopTimeEntry(entryId int64, end time.Time) error
+	DeleteTimeEntry(entryId int64) error
+
+	// statistics
+	GetTaskStats(userId int64, now time.Time, days []time.Time) (models.TaskStats, error)
+
+	// streaks and badges
+	GetStreak(userId int64) (models.Streak, error)
+	UpdateStreak(userId int64, s models.Streak) error
+	FreezeStreakDay(userId int64, day string) error
+	AddBadge(userId int64, badge string, earned time.Time) (bool, error)
+	GetBadges(userId int64) ([]models.Badge, error)
+	GetDueTaskCounts(userId int64) (open, late, onTime int, err error)
+
+	// task history management
+	AddTaskRevision(rev models.TaskRevision) (int64, error)
+	GetTaskRevisions(taskId int64) ([]models.TaskRevision, error)
+	GetTaskRevision(taskId, revisionId int64) (models.TaskRevision, error)
+
+	// task trash management
+	TrashTask(taskId, version int64, now time.Time) error
+	RestoreTask(taskId int64) error
+	GetTrashedTasks(userId int64) ([]models.Task, error)
+	GetTrashedTask(userId, taskId int64) (models.Task, error)
+	PurgeTrashedTasks(before time.Time) (int64, error)
+
+	// recurring task management
+	AddTaskSeries(userId int64, series models.TaskSeries) (int64, error)
+	GetTaskSeries(seriesId int64) (models.TaskSeries, error)
+	GetUserTaskSeries(userId int64) ([]models.TaskSeries, error)
+	UpdateTaskSeries(seriesId int64, series models.TaskSeries) error
+	GetSeriesOccurrence(seriesId int64, occurrence int) (models.Task, error)
+	GetFutureOccurrences(seriesId int64, occurrence int) ([]models.Task, error)
+
+	// reminder management
+	SetTaskReminders(taskId int64, offsets []int) error
+	GetPendingReminders(now time.Time, grace time.Duration) ([]models.Reminder, error)
+	ClaimReminder(reminder models.Reminder, now time.Time, email models.OutboxEmail) (bool, error)
+
+	// scheduled email management
+	GetEmailSubscribers() ([]models.EmailSubscriber, error)
+	ClaimAgendaEmail(userId int64, day string, email *models.OutboxEmail) (bool, error)
+	ClaimDigestEmail(userId int64, day string, email *models.OutboxEmail) (bool, error)
+
+	// email outbox management
+	AddOutboxEmail(email models.OutboxEmail) (int64, error)
+	GetDueOutboxEmails(now time.Time, limit int) ([]models.OutboxEmail, error)
+	GetOutboxEmails(status string, limit int) ([]models.OutboxEmail, error)
+	LeaseOutboxEmail(emailId int64, now, until time.Time) (bool, error)
+	MarkOutboxEmailSent(emailId int64, now time.Time) error
+	MarkOutboxEmailFailed(emailId int64, attempts int, lastErr string, next time.Time, dead bool) error
+	ReplayOutboxEmail(emailId int64, now time.Time) error
+
+	// webhook management
+	AddWebhook(userId int64, webhook models.Webhook) (int64, error)
+	GetWebhooks(userId int64) ([]models.Webhook, error)
+	GetWebhook(userId, webhookId int64) (models.Webhook, error)
+	UpdateWebhook(webhookId int64, webhook models.Webhook) error
+	DeleteWebhook(webhookId int64) error
+	AddWebhookDelivery(delivery models.WebhookDelivery) (int64, error)
+	QueueWebhookDeliveries(userId int64, eventType string, payload []byte, now time.Time) (int64, error)
+	GetDueWebhookDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error)
+	GetWebhookDeliveries(webhookId int64, status string, limit int) ([]models.WebhookDelivery, error)
+	LeaseWebhookDelivery(deliveryId int64, now, until time.Time) (bool, error)
+	MarkWebhookDelivered(delivery models.WebhookDelivery, responseStatus int, now time.Time) error
+	MarkWebhookDeliveryFailed(delivery models.WebhookDelivery, attempts, responseStatus int, lastErr string, next time.Time, dead bool, maxFailures int) (bool, error)
+
+	// task checklist management
+	AddChecklistItem(taskId int64, item models.ChecklistItem) (int64, error)
+	GetChecklistItem(taskId, itemId int64) (models.ChecklistItem, error)
+	UpdateChecklistItem(taskId, itemId int64, item models.ChecklistItem) error
+	ReorderChecklistItems(taskId int64, itemIds []int64) error
+	CompleteChecklistItems(taskId int64) error
+	DeleteChecklistItem(taskId, itemId int64) error
+	BumpTaskVersion(taskId int64) error
+}
+
+type scanner interface {
+	Scan(dest ...any) error
+}
+
+type execer interface {
+	Exec(query string, args ...any) (sql.Result, error)
 }
 
 func InitRepo(db *sql.DB) *repo {
-	return &repo{db}
+	return &repo{dbConn{db}}
 }
 
 func (r *repo) NewUser(user models.User) (int64, error) {
@@ -52,11 +199,23 @@ func (r *repo) NewUser(user models.User) (int64, error) {
 	return id, nil
 }
 
-func (r *repo) GetUserByID(userId int64) (models.User, error) {
-	var user models.User
+func scanUser(row scanner) (models.User, error) {
+	var u models.User
+	err := row.Scan(
+		&u.ID, &u.Email, &u.Username, &u.Password, &u.IsVerified,
+		&u.Timezone, &u.QuietHoursStart, &u.QuietHoursEnd, &u.IsAdmin,
+		&u.PomodoroWorkMinutes, &u.PomodoroBreakMinutes, &u.AgendaEmails,
+		&u.DigestEmails, &u.AgendaTime,
+	)
+	return u, err
+}
 
-	row := r.db.QueryRow(selectUserByIDStmt, userId)
-	if err := row.Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.IsVerified); err != nil {
+func (r *repo) GetUserByID(userId int64) (models.User, error) {
+	user, err := scanUser(r.db.QueryRow(selectUserByIDStmt, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.User{}, ErrUserNotFound
+		}
 		return models.User{}, fmt.Errorf("error getting user from database: %v", err)
 	}
 
@@ -73,10 +232,8 @@ func (r *repo) GetUserByID(userId int64) (models.User, error) {
 }
 
 func (r *repo) GetUserByEmail(userEmail string) (models.User, error) {
-	var user models.User
-
-	row := r.db.QueryRow(selectUserByEmailStmt, userEmail)
-	if err := row.Scan(&user.ID, &user.Email, &user.Username, &user.Password, &user.IsVerified); err != nil {
+	user, err := scanUser(r.db.QueryRow(selectUserByEmailStmt, userEmail))
+	if err != nil {
 		if err == sql.ErrNoRows {
 			return models.User{}, ErrUserNotFound
 		}
@@ -96,7 +253,12 @@ func (r *repo) GetUserByEmail(userEmail string) (models.User, error) {
 }
 
 func (r *repo) UpdateUser(userId int64, user models.User) error {
-	if _, err := r.db.Exec(updateUserStmt, user.Username, user.IsVerified, userId); err != nil {
+	if _, err := r.db.Exec(
+		updateUserStmt, user.Username, user.IsVerified, user.Timezone,
+		user.QuietHoursStart, user.QuietHoursEnd, user.PomodoroWorkMinutes,
+		user.PomodoroBreakMinutes, user.AgendaEmails, user.DigestEmails,
+		user.AgendaTime, userId,
+	); err != nil {
 		return fmt.Errorf("error updating user: %v", err)
 	}
 
@@ -122,8 +284,45 @@ func (r *repo) CheckUserIDExists(userId int64) bool {
 	return !(err == ErrUserNotFound)
 }
 
-func (r *repo) AddTask(userId int64, t models.Task) (int64, error) {
-	res, err := r.db.Exec(insertTaskStmt, t.Name, t.Tag, t.Priority, t.IsCompleted, t.Description, t.TimeDue, t.TimeCreated, userId)
+// SetCalendarToken sets the hash of the user's calendar feed token, an
+// empty hash revokes the feed.
+func (r *repo) SetCalendarToken(userId int64, tokenHash string) error {
+	if _, err := r.db.Exec(updateCalendarTokenStmt, tokenHash, userId); err != nil {
+		return fmt.Errorf("error updating calendar token: %v", err)
+	}
+
+	return nil
+}
+
+func (r *repo) GetUserIDByCalendarToken(tokenHash string) (int64, error) {
+	var userId int64
+	if err := r.db.QueryRow(selectUserIDByCalendarTokenStmt, tokenHash).Scan(&userId); err != nil {
+		if err == sql.ErrNoRows {
+			return 0, ErrUserNotFound
+		}
+		return 0, fmt.Errorf("error getting user from database: %v", err)
+	}
+
+	return userId, nil
+}
+
+// assessmentColumns returns the values of a task's assessment columns,
+// zero ones when it isn't assessed
+func assessmentColumns(t models.Task) (float64, float64, *float64) {
+	if t.Assessment == nil {
+		return 0, 0, nil
+	}
+	return t.Assessment.Weight, t.Assessment.MaxScore, t.Assessment.Score
+}
+
+func addTask(db execer, userId int64, t models.Task) (int64, error) {
+	weight, maxScore, score := assessmentColumns(t)
+	res, err := db.Exec(
+		insertTaskStmt, t.Name, t.Tag, t.Priority, t.IsCompleted, t.Description,
+		t.TimeDue, t.TimeCreated, t.TimeCompleted, t.SeriesID, t.Occurrence,
+		t.RecurrenceTime, t.UID, t.CourseID, t.ExamID, weight, maxScore, score,
+		t.EstimateMinutes, userId,
+	)
 	if err != nil {
 		return 0, fmt.Errorf("error inserting task to database: %v", err)
 	}
@@ -136,44 +335,168 @@ func (r *repo) AddTask(userId int64, t models.Task) (int64, error) {
 	return task_id, nil
 }
 
+func (r *repo) AddTask(userId int64, t models.Task) (int64, error) {
+	return addTask(r.db, userId, t)
+}
+
+func scanTask(row scanner) (models.Task, error) {
+	var t models.Task
+	var a models.Assessment
+	err := row.Scan(
+		&t.ID, &t.Name, &t.Tag, &t.Priority,
+		&t.IsCompleted, &t.Description, &t.TimeDue,
+		&t.TimeCreated, &t.TimeCompleted, &t.RRule,
+		&t.SeriesID, &t.Occurrence, &t.RecurrenceTime, &t.UID,
+		&t.TimeDeleted, &t.Version, &t.CourseID, &t.ExamID,
+		&a.Weight, &a.MaxScore, &a.Score, &t.EstimateMinutes,
+	)
+
+	if a.MaxScore != 0 {
+		t.Assessment = &a
+	}
+	return t, err
+}
+
 func (r *repo) GetTasks(userId int64) ([]models.Task, error) {
+	return r.getTasks(selectTasksStmt, userId)
+}
+
+// getTasks runs a query for a user's tasks and adds their checklist items
+// and reminders
+func (r *repo) getTasks(query string, userId int64) ([]models.Task, error) {
 	var tasks []models.Task
 
-	row, err := r.db.Query(selectTasksStmt, userId)
+	row, err := r.db.Query(query, userId)
 	if err != nil {
 		return nil, fmt.Errorf("error getting tasks from database: %v", err)
 	}
+	defer row.Close()
 
 	for row.Next() {
-		var t models.Task
-		if err := row.Scan(
-			&t.ID, &t.Name, &t.Tag, &t.Priority,
-			&t.IsCompleted, &t.Description, &t.TimeDue,
-			&t.TimeCreated, &t.TimeCompleted,
-		); err != nil {
+		t, err := scanTask(row)
+		if err != nil {
 			return nil, fmt.Errorf("error getting task from database: %v", err)
 		}
 		tasks = append(tasks, t)
 	}
 
+	items, err := r.getChecklistItems(selectChecklistItemsByUserStmt, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	reminders, err := r.getTaskReminders(selectTaskRemindersByUserStmt, userId)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range tasks {
+		tasks[i].Items = items[tasks[i].ID]
+		tasks[i].Reminders = reminders[tasks[i].ID]
+	}
+
 	return tasks, nil
 }
 
+func (r *repo) GetTask(userId, taskId int64) (models.Task, error) {
+	t, err := scanTask(r.db.QueryRow(selectTaskStmt, taskId, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Task{}, ErrTaskNotFound
+		}
+		return models.Task{}, fmt.Errorf("error getting task from database: %v", err)
+	}
+
+	items, err := r.getChecklistItems(selectChecklistItemsByTaskStmt, taskId)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	reminders, err := r.getTaskReminders(selectTaskRemindersStmt, taskId)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	t.Items = items[taskId]
+	t.Reminders = reminders[taskId]
+	return t, nil
+}
+
+func (r *repo) TaskUIDExists(userId int64, uid string) (bool, error) {
+	var exists bool
+	if err := r.db.QueryRow(selectTaskUIDExistsStmt, userId, uid).Scan(&exists); err != nil {
+		return false, fmt.Errorf("error checking task uid: %v", err)
+	}
+
+	return exists, nil
+}
+
+// UpdateTask updates the task if it's still at t.Version, otherwise it
+// returns ErrVersionMismatch. The stored version is bumped.
 func (r *repo) UpdateTask(id int64, t models.Task) error {
-	_, err := r.db.Exec(updateTaskStmt, t.Name, t.Priority, t.IsCompleted, t.Description, t.TimeDue, id)
+	weight, maxScore, score := assessmentColumns(t)
+	res, err := r.db.Exec(
+		updateTaskStmt, t.Name, t.Tag, t.Priority, t.IsCompleted,
+		t.Description, t.TimeDue, t.TimeCompleted, t.SeriesID,
+		t.Occurrence, t.RecurrenceTime, t.CourseID, t.ExamID,
+		weight, maxScore, score, t.EstimateMinutes, id, t.Version,
+	)
 	if err != nil {
 		return fmt.Errorf("error updating task: %v", err)
 	}
 
+	return checkVersionUpdate(res)
+}
+
+// checkVersionUpdate turns an update that matched no row into a version
+// mismatch, the task was changed or removed since it was read
+func checkVersionUpdate(res sql.Result) error {
+	n, err := res.RowsAffected()
+	if err != nil {
+		return err
+	}
+	if n == 0 {
+		return ErrVersionMismatch
+	}
+
 	return nil
 }
 
-// TODO
+// deletes the task along with its checklist items, reminders and history
 func (r *repo) DeleteTask(taskId int64) error {
-	_, err := r.db.Exec(deleteTaskStmt, taskId)
+	tx, err := r.db.Begin()
 	if err != nil {
 		return fmt.Errorf("error deleting task: %v", err)
 	}
+	defer tx.Rollback()
 
-	return nil
+	if _, err := tx.Exec(deleteTaskChecklistItemsStmt, taskId); err != nil {
+		return fmt.Errorf("error deleting task checklist: %v", err)
+	}
+
+	if _, err := tx.Exec(deleteTaskRemindersStmt, taskId); err != nil {
+		return fmt.Errorf("error deleting task reminders: %v", err)
+	}
+
+	if _, err := tx.Exec(deleteTaskSentRemindersStmt, taskId); err != nil {
+		return fmt.Errorf("error deleting task sent reminders: %v", err)
+	}
+
+	if _, err := tx.Exec(deleteTaskRevisionsStmt, taskId); err != nil {
+		return fmt.Errorf("error deleting task history: %v", err)
+	}
+
+	if _, err := tx.Exec(deleteTaskTimeBlocksStmt, taskId); err != nil {
+		return fmt.Errorf("error deleting task time blocks: %v", err)
+	}
+
+	if _, err := tx.Exec(deleteTaskTimeEntriesStmt, taskId); err != nil {
+		return fmt.Errorf("error deleting task time entries: %v", err)
+	}
+
+	if _, err := tx.Exec(deleteTaskStmt, taskId); err != nil {
+		return fmt.Errorf("error deleting task: %v", err)
+	}
+
+	return tx.Commit()
 }
@@ -0,0 +1,111 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func (r *repo) AddTaskSeries(userId int64, s models.TaskSeries) (int64, error) {
+	res, err := r.db.Exec(insertTaskSeriesStmt, s.RRule, s.Name, s.Tag, s.Priority, s.Description, userId)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting task series to database: %v", err)
+	}
+
+	return res.LastInsertId()
+}
+
+func (r *repo) GetTaskSeries(seriesId int64) (models.TaskSeries, error) {
+	var s models.TaskSeries
+
+	row := r.db.QueryRow(selectTaskSeriesStmt, seriesId)
+	if err := row.Scan(&s.ID, &s.RRule, &s.Name, &s.Tag, &s.Priority, &s.Description); err != nil {
+		if err == sql.ErrNoRows {
+			return models.TaskSeries{}, ErrSeriesNotFound
+		}
+		return models.TaskSeries{}, fmt.Errorf("error getting task series from database: %v", err)
+	}
+
+	return s, nil
+}
+
+func (r *repo) GetUserTaskSeries(userId int64) ([]models.TaskSeries, error) {
+	rows, err := r.db.Query(selectTaskSeriesByUserStmt, userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting task series from database: %v", err)
+	}
+	defer rows.Close()
+
+	var series []models.TaskSeries
+	for rows.Next() {
+		var s models.TaskSeries
+		if err := rows.Scan(&s.ID, &s.RRule, &s.Name, &s.Tag, &s.Priority, &s.Description); err != nil {
+			return nil, fmt.Errorf("error getting task series from database: %v", err)
+		}
+		series = append(series, s)
+	}
+
+	return series, rows.Err()
+}
+
+func (r *repo) UpdateTaskSeries(seriesId int64, s models.TaskSeries) error {
+	if _, err := r.db.Exec(updateTaskSeriesStmt, s.RRule, s.Name, s.Tag, s.Priority, s.Description, seriesId); err != nil {
+		return fmt.Errorf("error updating task series: %v", err)
+	}
+
+	return nil
+}
+
+// GetSeriesOccurrence gets an occurrence of a series, in the trash or not
+func (r *repo) GetSeriesOccurrence(seriesId int64, occurrence int) (models.Task, error) {
+	t, err := scanTask(r.db.QueryRow(selectSeriesOccurrenceStmt, seriesId, occurrence))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Task{}, ErrTaskNotFound
+		}
+		return models.Task{}, fmt.Errorf("error getting task from database: %v", err)
+	}
+
+	return t, nil
+}
+
+// GetFutureOccurrences gets the uncompleted occurrences after the given one
+// that are already in the user's tasks, with their checklists and reminders
+func (r *repo) GetFutureOccurrences(seriesId int64, occurrence int) ([]models.Task, error) {
+	rows, err := r.db.Query(selectFutureOccurrencesStmt, seriesId, occurrence)
+	if err != nil {
+		return nil, fmt.Errorf("error getting future occurrences from database: %v", err)
+	}
+	defer rows.Close()
+
+	var tasks []models.Task
+	for rows.Next() {
+		t, err := scanTask(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error getting future occurrences from database: %v", err)
+		}
+		tasks = append(tasks, t)
+	}
+	if err := rows.Err(); err != nil {
+		return nil, fmt.Errorf("error getting future occurrences from database: %v", err)
+	}
+	rows.Close()
+
+	for i := range tasks {
+		items, err := r.getChecklistItems(selectChecklistItemsByTaskStmt, tasks[i].ID)
+		if err != nil {
+			return nil, err
+		}
+
+		reminders, err := r.getTaskReminders(selectTaskRemindersStmt, tasks[i].ID)
+		if err != nil {
+			return nil, err
+		}
+
+		tasks[i].Items = items[tasks[i].ID]
+		tasks[i].Reminders = reminders[tasks[i].ID]
+	}
+
+	return tasks, nil
+}
@@ -6,21 +6,40 @@ const (
     (email, username, password, time_created) 
     VALUES (?, ?, ?, ?)
   `
+	userColumns = `
+    user_id, email, username, password, is_verified, timezone,
+      quiet_hours_start, quiet_hours_end, is_admin, pomodoro_work_minutes,
+      pomodoro_break_minutes, agenda_emails, digest_emails, agenda_time
+  `
+
 	selectUserByIDStmt = `
-    SELECT user_id, email, username, password, is_verified
+    SELECT ` + userColumns + `
     FROM users WHERE user_id = ?
   `
 
 	selectUserByEmailStmt = `
-    SELECT user_id, email, username, password, is_verified
+    SELECT ` + userColumns + `
     FROM users WHERE email = ?
   `
 
 	updateUserStmt = `
-    UPDATE users SET username = ?, is_verified = ?
+    UPDATE users SET username = ?, is_verified = ?, timezone = ?,
+      quiet_hours_start = ?, quiet_hours_end = ?, pomodoro_work_minutes = ?,
+      pomodoro_break_minutes = ?, agenda_emails = ?, digest_emails = ?,
+      agenda_time = ?
+    WHERE user_id = ?
+  `
+
+	updateCalendarTokenStmt = `
+    UPDATE users SET calendar_token_hash = ?
     WHERE user_id = ?
   `
 
+	selectUserIDByCalendarTokenStmt = `
+    SELECT user_id FROM users
+    WHERE calendar_token_hash = ? AND calendar_token_hash != ''
+  `
+
 	deleteUserStmt = `
     DELETE FROM users
     WHERE user_id = ?
@@ -29,24 +48,932 @@ const (
 	insertTaskStmt = `
     INSERT INTO tasks
     (name, tag, priority, is_completed, description, time_due,
-      time_created, user_id)
-    VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+      time_created, time_completed, series_id, occurrence, recurrence_time,
+      uid, course_id, exam_id, assessment_weight, max_score, score,
+      estimate_minutes, user_id)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `
+
+	taskColumns = `
+    task_id, name, tag, priority, is_completed, description,
+      time_due, time_created, time_completed,
+      COALESCE((
+        SELECT rrule FROM task_series s WHERE s.series_id = tasks.series_id
+      ), ''),
+      series_id, occurrence, recurrence_time, uid, deleted_at, version,
+      course_id, exam_id, assessment_weight, max_score, score,
+      estimate_minutes
   `
 
 	selectTasksStmt = `
-    SELECT task_id, name, tag, priority, is_completed, description,
-      time_due, time_created, time_completed
-    FROM tasks WHERE user_id = ?
+    SELECT ` + taskColumns + `
+    FROM tasks WHERE user_id = ? AND deleted_at IS NULL
+    ORDER BY priority DESC, time_due ASC, task_id ASC
+  `
+
+	selectTaskStmt = `
+    SELECT ` + taskColumns + `
+    FROM tasks WHERE task_id = ? AND user_id = ? AND deleted_at IS NULL
+  `
+
+	selectTrashedTasksStmt = `
+    SELECT ` + taskColumns + `
+    FROM tasks WHERE user_id = ? AND deleted_at IS NOT NULL
+    ORDER BY deleted_at DESC, task_id ASC
+  `
+
+	selectTrashedTaskStmt = `
+    SELECT ` + taskColumns + `
+    FROM tasks WHERE task_id = ? AND user_id = ? AND deleted_at IS NOT NULL
+  `
+
+	trashTaskStmt = `
+    UPDATE tasks SET deleted_at = ?, version = version + 1
+    WHERE task_id = ? AND version = ?
+  `
+
+	// sets when an imported task was trashed, keeping its version
+	importTrashedTaskStmt = `
+    UPDATE tasks SET deleted_at = ?
+    WHERE task_id = ?
+  `
+
+	restoreTaskStmt = `
+    UPDATE tasks SET deleted_at = NULL, version = version + 1
+    WHERE task_id = ?
+  `
+
+	// tasks in the trash since before the given time, along with their
+	// checklist items, reminders and the record of reminders sent
+	purgeTrashedChecklistItemsStmt = `
+    DELETE FROM checklist_items WHERE task_id IN (
+      SELECT task_id FROM tasks
+      WHERE deleted_at IS NOT NULL AND julianday(deleted_at) <= julianday(?)
+    )
+  `
+
+	purgeTrashedRemindersStmt = `
+    DELETE FROM task_reminders WHERE task_id IN (
+      SELECT task_id FROM tasks
+      WHERE deleted_at IS NOT NULL AND julianday(deleted_at) <= julianday(?)
+    )
+  `
+
+	purgeTrashedSentRemindersStmt = `
+    DELETE FROM sent_reminders WHERE task_id IN (
+      SELECT task_id FROM tasks
+      WHERE deleted_at IS NOT NULL AND julianday(deleted_at) <= julianday(?)
+    )
+  `
+
+	purgeTrashedTimeBlocksStmt = `
+    DELETE FROM time_blocks WHERE task_id IN (
+      SELECT task_id FROM tasks
+      WHERE deleted_at IS NOT NULL AND julianday(deleted_at) <= julianday(?)
+    )
+  `
+
+	purgeTrashedTimeEntriesStmt = `
+    DELETE FROM time_entries WHERE task_id IN (
+      SELECT task_id FROM tasks
+      WHERE deleted_at IS NOT NULL AND julianday(deleted_at) <= julianday(?)
+    )
+  `
+
+	purgeTrashedTasksStmt = `
+    DELETE FROM tasks
+    WHERE deleted_at IS NOT NULL AND julianday(deleted_at) <= julianday(?)
   `
 
 	updateTaskStmt = `
     UPDATE tasks SET name = ?, tag = ?, priority = ?, is_completed = ?,
-      description = ?, time_due = ?
-    WHERE task_id = ? 
+      description = ?, time_due = ?, time_completed = ?, series_id = ?,
+      occurrence = ?, recurrence_time = ?, course_id = ?, exam_id = ?,
+      assessment_weight = ?, max_score = ?, score = ?, estimate_minutes = ?,
+      version = version + 1
+    WHERE task_id = ? AND version = ?
   `
 
 	deleteTaskStmt = `
     DELETE FROM tasks 
     WHERE task_id = ?
+  `
+
+	selectTaskUIDExistsStmt = `
+    SELECT EXISTS (SELECT 1 FROM tasks WHERE user_id = ? AND uid = ?)
+  `
+
+	selectSeriesOccurrenceStmt = `
+    SELECT ` + taskColumns + `
+    FROM tasks WHERE series_id = ? AND occurrence = ?
+  `
+
+	insertVerificationCodeStmt = `
+    INSERT OR REPLACE INTO verification_codes (code, email, expires_at)
+    VALUES (?, ?, ?)
+  `
+
+	selectVerificationCodeStmt = `
+    SELECT email FROM verification_codes WHERE code = ? AND expires_at > ?
+  `
+
+	deleteVerificationCodeStmt = `
+    DELETE FROM verification_codes WHERE code = ?
+  `
+
+	deleteExpiredVerificationCodesStmt = `
+    DELETE FROM verification_codes WHERE expires_at <= ?
+  `
+
+	insertTaskSeriesStmt = `
+    INSERT INTO task_series
+    (rrule, name, tag, priority, description, user_id)
+    VALUES (?, ?, ?, ?, ?, ?)
+  `
+
+	selectTaskSeriesStmt = `
+    SELECT series_id, rrule, name, tag, priority, description
+    FROM task_series WHERE series_id = ?
+  `
+
+	selectTaskSeriesByUserStmt = `
+    SELECT series_id, rrule, name, tag, priority, description
+    FROM task_series WHERE user_id = ?
+    ORDER BY series_id
+  `
+
+	updateTaskSeriesStmt = `
+    UPDATE task_series SET rrule = ?, name = ?, tag = ?, priority = ?,
+      description = ?
+    WHERE series_id = ?
+  `
+
+	selectFutureOccurrencesStmt = `
+    SELECT ` + taskColumns + `
+    FROM tasks
+    WHERE series_id = ? AND occurrence > ? AND is_completed = 0
+      AND deleted_at IS NULL
+    ORDER BY occurrence
+  `
+
+	selectTaskRemindersStmt = `
+    SELECT task_id, offset_minutes
+    FROM task_reminders WHERE task_id = ?
+    ORDER BY offset_minutes
+  `
+
+	selectTaskRemindersByUserStmt = `
+    SELECT r.task_id, r.offset_minutes
+    FROM task_reminders r JOIN tasks t ON t.task_id = r.task_id
+    WHERE t.user_id = ?
+    ORDER BY r.task_id, r.offset_minutes
+  `
+
+	insertTaskReminderStmt = `
+    INSERT OR IGNORE INTO task_reminders (task_id, offset_minutes)
+    VALUES (?, ?)
+  `
+
+	deleteTaskRemindersStmt = `
+    DELETE FROM task_reminders
+    WHERE task_id = ?
+  `
+
+	// reminders that are due and haven't been sent. Upcoming reminders are
+	// sent from offset minutes before the due time until the task is due,
+	// overdue notices are only sent within the grace period after it, so
+	// old tasks don't all get one at once.
+	selectPendingRemindersStmt = `
+    SELECT t.task_id, t.user_id, 'upcoming', r.offset_minutes, t.time_due,
+      t.name, u.email, u.timezone, u.quiet_hours_start, u.quiet_hours_end
+    FROM task_reminders r
+      JOIN tasks t ON t.task_id = r.task_id
+      JOIN users u ON u.user_id = t.user_id
+    WHERE t.is_completed = 0 AND t.deleted_at IS NULL AND u.is_verified = 1
+      AND julianday(t.time_due) > julianday(:now)
+      AND julianday(t.time_due) - r.offset_minutes / 1440.0 <= julianday(:now)
+      AND NOT EXISTS (
+        SELECT 1 FROM sent_reminders s
+        WHERE s.task_id = t.task_id AND s.kind = 'upcoming'
+          AND s.offset_minutes = r.offset_minutes
+          AND s.time_due = CAST(strftime('%s', t.time_due) AS INTEGER)
+      )
+
+    UNION ALL
+
+    SELECT t.task_id, t.user_id, 'overdue', 0, t.time_due,
+      t.name, u.email, u.timezone, u.quiet_hours_start, u.quiet_hours_end
+    FROM tasks t
+      JOIN users u ON u.user_id = t.user_id
+    WHERE t.is_completed = 0 AND t.deleted_at IS NULL AND u.is_verified = 1
+      AND julianday(t.time_due) <= julianday(:now)
+      AND julianday(t.time_due) > julianday(:now) - :grace
+      AND NOT EXISTS (
+        SELECT 1 FROM sent_reminders s
+        WHERE s.task_id = t.task_id AND s.kind = 'overdue'
+          AND s.time_due = CAST(strftime('%s', t.time_due) AS INTEGER)
+      )
+  `
+
+	deleteTaskSentRemindersStmt = `
+    DELETE FROM sent_reminders
+    WHERE task_id = ?
+  `
+
+	insertSentReminderStmt = `
+    INSERT OR IGNORE INTO sent_reminders
+    (task_id, kind, offset_minutes, time_due, time_sent)
+    VALUES (?, ?, ?, ?, ?)
+  `
+
+	insertOutboxEmailStmt = `
+    INSERT INTO email_outbox
+    (recipient, template, subject, code, message, fields, next_attempt_at,
+      time_created)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?)
+  `
+
+	outboxEmailColumns = `
+    email_id, recipient, template, subject, code, message, fields, status,
+      attempts, last_error, next_attempt_at, time_created, time_sent
+  `
+
+	selectDueOutboxEmailsStmt = `
+    SELECT ` + outboxEmailColumns + `
+    FROM email_outbox
+    WHERE status = 'pending' AND julianday(next_attempt_at) <= julianday(?)
+    ORDER BY next_attempt_at
+    LIMIT ?
+  `
+
+	selectOutboxEmailsByStatusStmt = `
+    SELECT ` + outboxEmailColumns + `
+    FROM email_outbox WHERE status = ?
+    ORDER BY email_id DESC
+    LIMIT ?
+  `
+
+	// an email is leased by pushing its next attempt back, so concurrent
+	// workers don't both send it and a crash mid send retries it later
+	leaseOutboxEmailStmt = `
+    UPDATE email_outbox SET next_attempt_at = ?
+    WHERE email_id = ? AND status = 'pending'
+      AND julianday(next_attempt_at) <= julianday(?)
+  `
+
+	// the code is cleared once sent so it doesn't sit in the database
+	updateOutboxEmailSentStmt = `
+    UPDATE email_outbox SET status = 'sent', code = '', attempts = attempts + 1,
+      last_error = '', time_sent = ?
+    WHERE email_id = ?
+  `
+
+	updateOutboxEmailFailedStmt = `
+    UPDATE email_outbox SET status = ?, attempts = ?, last_error = ?,
+      next_attempt_at = ?
+    WHERE email_id = ?
+  `
+
+	replayOutboxEmailStmt = `
+    UPDATE email_outbox SET status = 'pending', attempts = 0, last_error = '',
+      next_attempt_at = ?
+    WHERE email_id = ? AND status = 'dead'
+  `
+
+	insertChecklistItemStmt = `
+    INSERT INTO checklist_items (task_id, name, position, is_completed)
+    VALUES (?, ?, (
+      SELECT COALESCE(MAX(position), -1) + 1
+      FROM checklist_items WHERE task_id = ?
+    ), ?)
+  `
+
+	selectChecklistItemStmt = `
+    SELECT item_id, task_id, name, position, is_completed
+    FROM checklist_items WHERE item_id = ? AND task_id = ?
+  `
+
+	selectChecklistItemsByTaskStmt = `
+    SELECT item_id, task_id, name, position, is_completed
+    FROM checklist_items WHERE task_id = ?
+    ORDER BY position
+  `
+
+	selectChecklistItemsByUserStmt = `
+    SELECT i.item_id, i.task_id, i.name, i.position, i.is_completed
+    FROM checklist_items i JOIN tasks t ON t.task_id = i.task_id
+    WHERE t.user_id = ?
+    ORDER BY i.task_id, i.position
+  `
+
+	updateChecklistItemStmt = `
+    UPDATE checklist_items SET name = ?, is_completed = ?
+    WHERE item_id = ? AND task_id = ?
+  `
+
+	updateChecklistItemPositionStmt = `
+    UPDATE checklist_items SET position = ?
+    WHERE item_id = ? AND task_id = ?
+  `
+
+	completeChecklistItemsStmt = `
+    UPDATE checklist_items SET is_completed = 1
+    WHERE task_id = ?
+  `
+
+	bumpTaskVersionStmt = `
+    UPDATE tasks SET version = version + 1 WHERE task_id = ?
+  `
+
+	deleteChecklistItemStmt = `
+    DELETE FROM checklist_items
+    WHERE item_id = ? AND task_id = ?
+  `
+
+	insertTaskRevisionStmt = `
+    INSERT INTO task_revisions
+    (task_id, user_id, action, changes, snapshot, time_created)
+    VALUES (?, ?, ?, ?, ?, ?)
+  `
+
+	taskRevisionColumns = `
+    revision_id, task_id, user_id, action, changes, snapshot, time_created
+  `
+
+	selectTaskRevisionsStmt = `
+    SELECT ` + taskRevisionColumns + `
+    FROM task_revisions WHERE task_id = ?
+    ORDER BY revision_id DESC
+  `
+
+	selectTaskRevisionStmt = `
+    SELECT ` + taskRevisionColumns + `
+    FROM task_revisions WHERE revision_id = ? AND task_id = ?
+  `
+
+	deleteTaskRevisionsStmt = `
+    DELETE FROM task_revisions
+    WHERE task_id = ?
+  `
+
+	purgeTrashedRevisionsStmt = `
+    DELETE FROM task_revisions WHERE task_id IN (
+      SELECT task_id FROM tasks
+      WHERE deleted_at IS NOT NULL AND julianday(deleted_at) <= julianday(?)
+    )
+  `
+
+	deleteTaskChecklistItemsStmt = `
+    DELETE FROM checklist_items
+    WHERE task_id = ?
+  `
+
+	insertWebhookStmt = `
+    INSERT INTO webhooks (user_id, url, event_types, secret, time_created)
+    VALUES (?, ?, ?, ?, ?)
+  `
+
+	webhookColumns = `
+    webhook_id, user_id, url, event_types, secret, is_active, failures,
+      time_created
+  `
+
+	selectWebhooksStmt = `
+    SELECT ` + webhookColumns + `
+    FROM webhooks WHERE user_id = ?
+    ORDER BY webhook_id
+  `
+
+	selectWebhookStmt = `
+    SELECT ` + webhookColumns + `
+    FROM webhooks WHERE webhook_id = ? AND user_id = ?
+  `
+
+	updateWebhookStmt = `
+    UPDATE webhooks SET url = ?, event_types = ?, is_active = ?, failures = ?
+    WHERE webhook_id = ?
+  `
+
+	deleteWebhookStmt = `
+    DELETE FROM webhooks
+    WHERE webhook_id = ?
+  `
+
+	deleteWebhookDeliveriesStmt = `
+    DELETE FROM webhook_deliveries
+    WHERE webhook_id = ?
+  `
+
+	insertWebhookDeliveryStmt = `
+    INSERT INTO webhook_deliveries
+    (webhook_id, event_type, payload, next_attempt_at, time_created)
+    VALUES (?, ?, ?, ?, ?)
+  `
+
+	// a webhook with no event types gets every event
+	insertWebhookDeliveriesStmt = `
+    INSERT INTO webhook_deliveries
+    (webhook_id, event_type, payload, next_attempt_at, time_created)
+    SELECT webhook_id, :type, :payload, :now, :now
+    FROM webhooks
+    WHERE user_id = :user AND is_active = 1 AND (
+      event_types = '[]' OR
+      EXISTS (SELECT 1 FROM json_each(event_types) WHERE value = :type)
+    )
+  `
+
+	webhookDeliveryColumns = `
+    d.delivery_id, d.webhook_id, d.event_type, d.payload, d.status,
+      d.attempts, d.response_status, d.last_error, d.next_attempt_at,
+      d.time_created, d.time_delivered, w.user_id, w.url, w.secret
+  `
+
+	selectDueWebhookDeliveriesStmt = `
+    SELECT ` + webhookDeliveryColumns + `
+    FROM webhook_deliveries d
+      JOIN webhooks w ON w.webhook_id = d.webhook_id
+    WHERE d.status = 'pending' AND w.is_active = 1
+      AND julianday(d.next_attempt_at) <= julianday(?)
+    ORDER BY d.next_attempt_at
+    LIMIT ?
+  `
+
+	selectWebhookDeliveriesStmt = `
+    SELECT ` + webhookDeliveryColumns + `
+    FROM webhook_deliveries d
+      JOIN webhooks w ON w.webhook_id = d.webhook_id
+    WHERE d.webhook_id = ? AND (? = '' OR d.status = ?)
+    ORDER BY d.delivery_id DESC
+    LIMIT ?
+  `
+
+	// deliveries of a disabled webhook wait until it's enabled again
+	leaseWebhookDeliveryStmt = `
+    UPDATE webhook_deliveries SET next_attempt_at = ?
+    WHERE delivery_id = ? AND status = 'pending'
+      AND julianday(next_attempt_at) <= julianday(?)
+      AND webhook_id IN (SELECT webhook_id FROM webhooks WHERE is_active = 1)
+  `
+
+	updateWebhookDeliveredStmt = `
+    UPDATE webhook_deliveries SET status = 'delivered',
+      attempts = attempts + 1, response_status = ?, last_error = '',
+      time_delivered = ?
+    WHERE delivery_id = ?
+  `
+
+	updateWebhookDeliveryFailedStmt = `
+    UPDATE webhook_deliveries SET status = ?, attempts = ?,
+      response_status = ?, last_error = ?, next_attempt_at = ?
+    WHERE delivery_id = ?
+  `
+
+	resetWebhookFailuresStmt = `
+    UPDATE webhooks SET failures = 0
+    WHERE webhook_id = ?
+  `
+
+	selectWebhookIsActiveStmt = `
+    SELECT is_active FROM webhooks WHERE webhook_id = ?
+  `
+
+	// the webhook is disabled once it reaches the failure limit
+	addWebhookFailureStmt = `
+    UPDATE webhooks SET failures = failures + 1,
+      is_active = CASE WHEN failures + 1 >= ? THEN 0 ELSE is_active END
+    WHERE webhook_id = ?
+  `
+
+	insertSemesterStmt = `
+    INSERT INTO semesters (user_id, name, start_date, end_date, time_created)
+    VALUES (?, ?, ?, ?, ?)
+  `
+
+	semesterColumns = `
+    semester_id, name, start_date, end_date, is_archived, time_created
+  `
+
+	selectSemestersStmt = `
+    SELECT ` + semesterColumns + `
+    FROM semesters WHERE user_id = ?
+    ORDER BY start_date DESC, semester_id
+  `
+
+	selectSemesterStmt = `
+    SELECT ` + semesterColumns + `
+    FROM semesters WHERE semester_id = ? AND user_id = ?
+  `
+
+	updateSemesterStmt = `
+    UPDATE semesters SET name = ?, start_date = ?, end_date = ?, is_archived = ?
+    WHERE semester_id = ?
+  `
+
+	deleteSemesterStmt = `
+    DELETE FROM semesters
+    WHERE semester_id = ?
+  `
+
+	unlinkSemesterCoursesStmt = `
+    UPDATE courses SET semester_id = 0
+    WHERE semester_id = ?
+  `
+
+	insertCourseStmt = `
+    INSERT INTO courses
+    (user_id, semester_id, name, code, instructor, colour, start_date,
+      end_date, time_created)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `
+
+	courseColumns = `
+    c.course_id, c.semester_id, c.name, c.code, c.instructor, c.colour,
+      c.start_date, c.end_date, COALESCE(s.is_archived, 0), c.time_created
+  `
+
+	selectCoursesStmt = `
+    SELECT ` + courseColumns + `
+    FROM courses c LEFT JOIN semesters s ON s.semester_id = c.semester_id
+    WHERE c.user_id = ?
+    ORDER BY c.code, c.name, c.course_id
+  `
+
+	selectCourseStmt = `
+    SELECT ` + courseColumns + `
+    FROM courses c LEFT JOIN semesters s ON s.semester_id = c.semester_id
+    WHERE c.course_id = ? AND c.user_id = ?
+  `
+
+	updateCourseStmt = `
+    UPDATE courses SET semester_id = ?, name = ?, code = ?, instructor = ?,
+      colour = ?, start_date = ?, end_date = ?
+    WHERE course_id = ?
+  `
+
+	deleteCourseStmt = `
+    DELETE FROM courses
+    WHERE course_id = ?
+  `
+
+	// tasks of a deleted course are kept without one, trashed tasks too
+	// assessments only count towards their course, so they go with it
+	unlinkCourseTasksStmt = `
+    UPDATE tasks SET course_id = 0, assessment_weight = 0, max_score = 0,
+      score = NULL, version = version + 1
+    WHERE course_id = ?
+  `
+
+	insertTimetableSessionStmt = `
+    INSERT INTO timetable_sessions
+    (course_id, user_id, day, start_time, end_time, location, kind, weeks,
+      time_created)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `
+
+	timetableSessionColumns = `
+    session_id, course_id, day, start_time, end_time, location, kind, weeks,
+      time_created
+  `
+
+	selectTimetableSessionsStmt = `
+    SELECT ` + timetableSessionColumns + `
+    FROM timetable_sessions WHERE user_id = ?
+    ORDER BY CASE day
+        WHEN 'monday' THEN 1 WHEN 'tuesday' THEN 2 WHEN 'wednesday' THEN 3
+        WHEN 'thursday' THEN 4 WHEN 'friday' THEN 5 WHEN 'saturday' THEN 6
+        ELSE 7
+      END, start_time, session_id
+  `
+
+	selectTimetableSessionStmt = `
+    SELECT ` + timetableSessionColumns + `
+    FROM timetable_sessions WHERE session_id = ? AND user_id = ?
+  `
+
+	updateTimetableSessionStmt = `
+    UPDATE timetable_sessions SET course_id = ?, day = ?, start_time = ?,
+      end_time = ?, location = ?, kind = ?, weeks = ?
+    WHERE session_id = ?
+  `
+
+	deleteTimetableSessionStmt = `
+    DELETE FROM timetable_sessions
+    WHERE session_id = ?
+  `
+
+	deleteCourseTimetableSessionsStmt = `
+    DELETE FROM timetable_sessions
+    WHERE course_id = ?
+  `
+
+	insertExamStmt = `
+    INSERT INTO exams
+    (user_id, course_id, title, exam_time, location, weight, topics,
+      study_hours, session_minutes, time_created)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `
+
+	examColumns = `
+    exam_id, course_id, title, exam_time, location, weight, topics,
+      study_hours, session_minutes, time_created
+  `
+
+	selectExamsStmt = `
+    SELECT ` + examColumns + `
+    FROM exams WHERE user_id = ?
+    ORDER BY exam_time, exam_id
+  `
+
+	selectExamStmt = `
+    SELECT ` + examColumns + `
+    FROM exams WHERE exam_id = ? AND user_id = ?
+  `
+
+	updateExamStmt = `
+    UPDATE exams SET course_id = ?, title = ?, exam_time = ?, location = ?,
+      weight = ?, topics = ?, study_hours = ?, session_minutes = ?
+    WHERE exam_id = ?
+  `
+
+	deleteExamStmt = `
+    DELETE FROM exams
+    WHERE exam_id = ?
+  `
+
+	unlinkExamTasksStmt = `
+    UPDATE tasks SET exam_id = 0, version = version + 1
+    WHERE exam_id = ?
+  `
+
+	unlinkCourseExamTasksStmt = `
+    UPDATE tasks SET exam_id = 0, version = version + 1
+    WHERE exam_id IN (SELECT exam_id FROM exams WHERE course_id = ?)
+  `
+
+	deleteCourseExamsStmt = `
+    DELETE FROM exams
+    WHERE course_id = ?
+  `
+
+	insertAvailabilityWindowStmt = `
+    INSERT INTO availability_windows
+    (user_id, day, start_time, end_time)
+    VALUES (?, ?, ?, ?)
+  `
+
+	selectAvailabilityWindowsStmt = `
+    SELECT day, start_time, end_time
+    FROM availability_windows WHERE user_id = ?
+    ORDER BY (CASE day
+      WHEN 'monday' THEN 1 WHEN 'tuesday' THEN 2 WHEN 'wednesday' THEN 3
+      WHEN 'thursday' THEN 4 WHEN 'friday' THEN 5 WHEN 'saturday' THEN 6
+      ELSE 7 END), start_time
+  `
+
+	deleteAvailabilityWindowsStmt = `
+    DELETE FROM availability_windows
+    WHERE user_id = ?
+  `
+
+	insertTimeBlockStmt = `
+    INSERT INTO time_blocks
+    (user_id, task_id, start_time, end_time, time_created)
+    VALUES (?, ?, ?, ?, ?)
+  `
+
+	timeBlockColumns = `
+    b.block_id, b.task_id, t.name, b.start_time, b.end_time
+  `
+
+	// blocks overlapping the given times
+	selectTimeBlocksStmt = `
+    SELECT ` + timeBlockColumns + `
+    FROM time_blocks b JOIN tasks t ON t.task_id = b.task_id
+    WHERE b.user_id = ? AND julianday(b.end_time) > julianday(?)
+      AND julianday(b.start_time) < julianday(?)
+    ORDER BY julianday(b.start_time), b.block_id
+  `
+
+	selectTimeBlockStmt = `
+    SELECT ` + timeBlockColumns + `
+    FROM time_blocks b JOIN tasks t ON t.task_id = b.task_id
+    WHERE b.block_id = ? AND b.user_id = ?
+  `
+
+	// minutes of each task's blocks that started before the given time
+	selectPastTimeBlockMinutesStmt = `
+    SELECT task_id,
+      CAST(ROUND(SUM(julianday(end_time) - julianday(start_time)) * 1440) AS INTEGER)
+    FROM time_blocks
+    WHERE user_id = ? AND julianday(start_time) < julianday(?)
+    GROUP BY task_id
+  `
+
+	deleteTaskFutureTimeBlocksStmt = `
+    DELETE FROM time_blocks
+    WHERE task_id = ? AND julianday(start_time) >= julianday(?)
+  `
+
+	deleteTimeBlockStmt = `
+    DELETE FROM time_blocks
+    WHERE block_id = ?
+  `
+
+	deleteTaskTimeBlocksStmt = `
+    DELETE FROM time_blocks
+    WHERE task_id = ?
+  `
+
+	insertTimeEntryStmt = `
+    INSERT INTO time_entries
+    (user_id, task_id, kind, start_time, end_time, planned_end,
+      break_minutes, note, time_created)
+    VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?)
+  `
+
+	timeEntryColumns = `
+    e.entry_id, e.task_id, t.name, e.kind, e.start_time, e.end_time,
+      e.planned_end, e.break_minutes, e.note, e.time_created
+  `
+
+	selectRunningTimeEntryStmt = `
+    SELECT ` + timeEntryColumns + `
+    FROM time_entries e JOIN tasks t ON t.task_id = e.task_id
+    WHERE e.user_id = ? AND e.end_time IS NULL
+  `
+
+	selectTimeEntryStmt = `
+    SELECT ` + timeEntryColumns + `
+    FROM time_entries e JOIN tasks t ON t.task_id = e.task_id
+    WHERE e.entry_id = ? AND e.user_id = ?
+  `
+
+	selectTaskTimeEntriesStmt = `
+    SELECT ` + timeEntryColumns + `
+    FROM time_entries e JOIN tasks t ON t.task_id = e.task_id
+    WHERE e.task_id = ?
+    ORDER BY julianday(e.start_time) DESC, e.entry_id DESC
+  `
+
+	// entries that started in the given times, running ones included and
+	// the ones of trashed tasks left out
+	selectTimeEntriesStmt = `
+    SELECT ` + timeEntryColumns + `
+    FROM time_entries e JOIN tasks t ON t.task_id = e.task_id
+    WHERE e.user_id = ? AND t.deleted_at IS NULL
+      AND julianday(e.start_time) >= julianday(?)
+      AND julianday(e.start_time) < julianday(?)
+    ORDER BY julianday(e.start_time), e.entry_id
+  `
+
+	stopTimeEntryStmt = `
+    UPDATE time_entries SET end_time = ?
+    WHERE entry_id = ? AND end_time IS NULL
+  `
+
+	deleteTimeEntryStmt = `
+    DELETE FROM time_entries
+    WHERE entry_id = ?
+  `
+
+	deleteTaskTimeEntriesStmt = `
+    DELETE FROM time_entries
+    WHERE task_id = ?
+  `
+
+	// a task is overdue when it has a due time that has passed and isn't
+	// completed, tasks without one are due at the zero time
+	statsOverdue = `
+    (is_completed = 0 AND julianday(time_due) > julianday('1970-01-01')
+      AND julianday(time_due) < julianday(:now))
+  `
+
+	selectTaskCountsStmt = `
+    SELECT COUNT(*), COALESCE(SUM(is_completed), 0),
+      COALESCE(SUM(` + statsOverdue + `), 0)
+    FROM tasks
+    WHERE user_id = :user AND deleted_at IS NULL
+  `
+
+	// completions in the given times, lead time is from creation to
+	// completion in days
+	selectCompletionStatsStmt = `
+    SELECT COUNT(*),
+      COALESCE(SUM(julianday(time_due) > julianday('1970-01-01')
+        AND julianday(time_completed) <= julianday(time_due)), 0),
+      COALESCE(SUM(julianday(time_due) > julianday('1970-01-01')
+        AND julianday(time_completed) > julianday(time_due)), 0),
+      AVG(julianday(time_completed) - julianday(time_created))
+    FROM tasks
+    WHERE user_id = :user AND deleted_at IS NULL AND is_completed = 1
+      AND julianday(time_completed) >= julianday(:from)
+      AND julianday(time_completed) < julianday(:to)
+  `
+
+	selectTagStatsStmt = `
+    SELECT lower(tag), COUNT(*), COALESCE(SUM(is_completed), 0),
+      COALESCE(SUM(` + statsOverdue + `), 0)
+    FROM tasks
+    WHERE user_id = :user AND deleted_at IS NULL
+    GROUP BY lower(tag)
+    ORDER BY COUNT(*) DESC, lower(tag)
+  `
+
+	selectCourseStatsStmt = `
+    SELECT t.course_id, COALESCE(c.name, ''), COUNT(*),
+      COALESCE(SUM(t.is_completed), 0), COALESCE(SUM(` + statsOverdue + `), 0)
+    FROM tasks t LEFT JOIN courses c ON c.course_id = t.course_id
+    WHERE t.user_id = :user AND t.deleted_at IS NULL
+    GROUP BY t.course_id
+    ORDER BY COUNT(*) DESC, t.course_id
+  `
+
+	// days is a json array of [date, start, end] days, their times are
+	// the bounds of the day in the user's timezone
+	selectDailyCompletionsStmt = `
+    SELECT d.day, COUNT(t.task_id)
+    FROM (
+      SELECT json_extract(value, '$[0]') AS day,
+        json_extract(value, '$[1]') AS day_start,
+        json_extract(value, '$[2]') AS day_end
+      FROM json_each(:days)
+    ) d
+    LEFT JOIN tasks t ON t.user_id = :user AND t.deleted_at IS NULL
+      AND t.is_completed = 1
+      AND julianday(t.time_completed) >= julianday(d.day_start)
+      AND julianday(t.time_completed) < julianday(d.day_end)
+    GROUP BY d.day
+    ORDER BY d.day
+  `
+
+	selectStreakStmt = `
+    SELECT streak_current, streak_longest, streak_last_day, streak_freezes
+    FROM users WHERE user_id = ?
+  `
+
+	updateStreakStmt = `
+    UPDATE users SET streak_current = ?, streak_longest = ?,
+      streak_last_day = ?, streak_freezes = ?
+    WHERE user_id = ?
+  `
+
+	// the days frozen since the user last completed a task
+	selectFrozenDaysStmt = `
+    SELECT f.day FROM streak_freezes f JOIN users u ON u.user_id = f.user_id
+    WHERE f.user_id = ? AND f.day > u.streak_last_day
+    ORDER BY f.day
+  `
+
+	insertFrozenDayStmt = `
+    INSERT INTO streak_freezes (user_id, day, time_created)
+    VALUES (?, ?, ?)
+  `
+
+	// days frozen before an account was restored may be in its archive too
+	insertImportedFrozenDayStmt = `
+    INSERT OR IGNORE INTO streak_freezes (user_id, day, time_created)
+    VALUES (?, ?, ?)
+  `
+
+	insertBadgeStmt = `
+    INSERT OR IGNORE INTO user_badges (user_id, badge, time_earned)
+    VALUES (?, ?, ?)
+  `
+
+	selectBadgesStmt = `
+    SELECT badge, time_earned FROM user_badges
+    WHERE user_id = ?
+    ORDER BY julianday(time_earned), badge
+  `
+
+	// tasks with a due time that are still open, and the ones completed
+	// after and before it
+	selectDueTaskCountsStmt = `
+    SELECT
+      COALESCE(SUM(is_completed = 0), 0),
+      COALESCE(SUM(is_completed = 1
+        AND julianday(time_completed) > julianday(time_due)), 0),
+      COALESCE(SUM(is_completed = 1
+        AND julianday(time_completed) <= julianday(time_due)), 0)
+    FROM tasks
+    WHERE user_id = ? AND deleted_at IS NULL
+      AND julianday(time_due) > julianday('1970-01-01')
+  `
+
+	selectEmailSubscribersStmt = `
+    SELECT user_id, email, timezone, agenda_emails, digest_emails,
+      agenda_time, agenda_sent_on, digest_sent_on
+    FROM users
+    WHERE is_verified = 1 AND (agenda_emails = 1 OR digest_emails = 1)
+  `
+
+	claimAgendaEmailStmt = `
+    UPDATE users SET agenda_sent_on = ?
+    WHERE user_id = ? AND agenda_sent_on != ?
+  `
+
+	claimDigestEmailStmt = `
+    UPDATE users SET digest_sent_on = ?
+    WHERE user_id = ? AND digest_sent_on != ?
   `
 )
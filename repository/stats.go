@@ -0,0 +1,106 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// GetTaskStats counts the user's tasks, overdue ones as of now. Completions
+// are counted over the given days, each a day long from its start in the
+// user's timezone.
+func (r *repo) GetTaskStats(userId int64, now time.Time, days []time.Time) (models.TaskStats, error) {
+	var stats models.TaskStats
+	if len(days) == 0 {
+		return stats, nil
+	}
+
+	user := sql.Named("user", userId)
+	if err := r.db.QueryRow(selectTaskCountsStmt, user, sql.Named("now", now.UTC())).Scan(
+		&stats.Total, &stats.Completed, &stats.Overdue,
+	); err != nil {
+		return stats, fmt.Errorf("error counting tasks: %v", err)
+	}
+
+	from, to := days[0], days[len(days)-1].AddDate(0, 0, 1)
+	var leadDays sql.NullFloat64
+	if err := r.db.QueryRow(
+		selectCompletionStatsStmt, user, sql.Named("from", from.UTC()), sql.Named("to", to.UTC()),
+	).Scan(&stats.CompletedInRange, &stats.OnTime, &stats.Late, &leadDays); err != nil {
+		return stats, fmt.Errorf("error counting completed tasks: %v", err)
+	}
+	if leadDays.Valid {
+		hours := leadDays.Float64 * 24
+		stats.AverageLeadHours = &hours
+	}
+
+	rows, err := r.db.Query(selectTagStatsStmt, user, sql.Named("now", now.UTC()))
+	if err != nil {
+		return stats, fmt.Errorf("error counting tasks by tag: %v", err)
+	}
+	defer rows.Close()
+
+	stats.Tags = []models.TagStats{}
+	for rows.Next() {
+		var t models.TagStats
+		if err := rows.Scan(&t.Tag, &t.Total, &t.Completed, &t.Overdue); err != nil {
+			return stats, fmt.Errorf("error counting tasks by tag: %v", err)
+		}
+		stats.Tags = append(stats.Tags, t)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	rows, err = r.db.Query(selectCourseStatsStmt, user, sql.Named("now", now.UTC()))
+	if err != nil {
+		return stats, fmt.Errorf("error counting tasks by course: %v", err)
+	}
+	defer rows.Close()
+
+	stats.Courses = []models.CourseStats{}
+	for rows.Next() {
+		var c models.CourseStats
+		if err := rows.Scan(&c.CourseID, &c.Name, &c.Total, &c.Completed, &c.Overdue); err != nil {
+			return stats, fmt.Errorf("error counting tasks by course: %v", err)
+		}
+		stats.Courses = append(stats.Courses, c)
+	}
+	if err := rows.Err(); err != nil {
+		return stats, err
+	}
+
+	bounds := make([][3]string, 0, len(days))
+	for _, day := range days {
+		bounds = append(bounds, [3]string{
+			day.Format("2006-01-02"),
+			day.UTC().Format(time.RFC3339),
+			day.AddDate(0, 0, 1).UTC().Format(time.RFC3339),
+		})
+	}
+
+	daysJSON, err := json.Marshal(bounds)
+	if err != nil {
+		return stats, fmt.Errorf("error encoding days: %v", err)
+	}
+
+	rows, err = r.db.Query(selectDailyCompletionsStmt, user, sql.Named("days", string(daysJSON)))
+	if err != nil {
+		return stats, fmt.Errorf("error counting daily completions: %v", err)
+	}
+	defer rows.Close()
+
+	stats.Daily = []models.DailyCompletions{}
+	for rows.Next() {
+		var d models.DailyCompletions
+		if err := rows.Scan(&d.Date, &d.Completed); err != nil {
+			return stats, fmt.Errorf("error counting daily completions: %v", err)
+		}
+		stats.Daily = append(stats.Daily, d)
+	}
+
+	return stats, rows.Err()
+}
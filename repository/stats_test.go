@@ -0,0 +1,139 @@
+package repository
+
+import (
+	"math"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/michaelcosj/stms/framework/database"
+	"github.com/michaelcosj/stms/migrations"
+	"github.com/michaelcosj/stms/models"
+)
+
+func newTestRepo(t *testing.T) *repo {
+	t.Helper()
+
+	db, err := database.InitDb(filepath.Join(t.TempDir(), "stms.db"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { db.Close() })
+
+	if err := migrations.RunMigrations(db); err != nil {
+		t.Fatal(err)
+	}
+
+	return InitRepo(db)
+}
+
+func TestGetTaskStats(t *testing.T) {
+	ny, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Skip(err)
+	}
+
+	r := newTestRepo(t)
+
+	userId, err := r.NewUser(models.User{Email: "student@stms.test", Username: "student", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	otherId, err := r.NewUser(models.User{Email: "other@stms.test", Username: "other", Password: "hash"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	courseId, err := r.AddCourse(userId, models.Course{Name: "Chemistry", Colour: "#336699"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	at := func(day, hour int) time.Time {
+		return time.Date(2026, 10, day, hour, 0, 0, 0, time.UTC)
+	}
+	never := time.Unix(0, 0).UTC()
+	add := func(userId int64, tag string, courseId int64, created, due, completed time.Time) int64 {
+		t.Helper()
+		id, err := r.AddTask(userId, models.Task{
+			Name:          "Task",
+			Tag:           tag,
+			Description:   "Task",
+			IsCompleted:   !completed.Equal(never),
+			TimeCreated:   created,
+			TimeDue:       due,
+			TimeCompleted: completed,
+			CourseID:      courseId,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return id
+	}
+
+	// completed two days after it was created, before it was due
+	add(userId, "study", courseId, at(16, 10), at(18, 12), at(18, 10))
+	// completed a day after it was created, after it was due, on the 18th
+	// in new york but the 19th in utc
+	add(userId, "study", courseId, at(18, 2), at(18, 20), at(19, 2))
+	// completed an hour after it was created, without a due time
+	add(userId, "study", 0, at(17, 4), time.Time{}, at(17, 5))
+	// completed before the range
+	add(userId, "work", 0, at(15, 10), time.Time{}, at(16, 10))
+	// overdue and due later, with the tag in another case
+	add(userId, "work", 0, at(15, 10), at(19, 10), never)
+	add(userId, "Work", 0, at(15, 10), at(25, 10), never)
+
+	// a trashed task and another user's don't count
+	trashed := add(userId, "study", 0, at(17, 10), time.Time{}, at(19, 5))
+	if err := r.TrashTask(trashed, 1, at(19, 6)); err != nil {
+		t.Fatal(err)
+	}
+	add(otherId, "study", 0, at(17, 10), time.Time{}, at(18, 10))
+
+	days := []time.Time{
+		time.Date(2026, 10, 17, 0, 0, 0, 0, ny),
+		time.Date(2026, 10, 18, 0, 0, 0, 0, ny),
+		time.Date(2026, 10, 19, 0, 0, 0, 0, ny),
+	}
+
+	stats, err := r.GetTaskStats(userId, at(19, 12), days)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if stats.Total != 6 || stats.Completed != 4 || stats.Overdue != 1 {
+		t.Errorf("got %d tasks, %d completed and %d overdue, want 6, 4 and 1", stats.Total, stats.Completed, stats.Overdue)
+	}
+	if stats.CompletedInRange != 3 || stats.OnTime != 1 || stats.Late != 1 {
+		t.Errorf("got %d completed in range, %d on time and %d late, want 3, 1 and 1", stats.CompletedInRange, stats.OnTime, stats.Late)
+	}
+	if want := (48.0 + 24 + 1) / 3; stats.AverageLeadHours == nil || math.Abs(*stats.AverageLeadHours-want) > 0.001 {
+		t.Errorf("got average lead %v hours, want %v", stats.AverageLeadHours, want)
+	}
+
+	wantTags := []models.TagStats{
+		{Tag: "study", Total: 3, Completed: 3},
+		{Tag: "work", Total: 3, Completed: 1, Overdue: 1},
+	}
+	if !reflect.DeepEqual(stats.Tags, wantTags) {
+		t.Errorf("got tags %+v, want %+v", stats.Tags, wantTags)
+	}
+
+	wantCourses := []models.CourseStats{
+		{CourseID: 0, Total: 4, Completed: 2, Overdue: 1},
+		{CourseID: courseId, Name: "Chemistry", Total: 2, Completed: 2},
+	}
+	if !reflect.DeepEqual(stats.Courses, wantCourses) {
+		t.Errorf("got courses %+v, want %+v", stats.Courses, wantCourses)
+	}
+
+	wantDaily := []models.DailyCompletions{
+		{Date: "2026-10-17", Completed: 1},
+		{Date: "2026-10-18", Completed: 2},
+		{Date: "2026-10-19", Completed: 0},
+	}
+	if !reflect.DeepEqual(stats.Daily, wantDaily) {
+		t.Errorf("got daily completions %+v, want %+v", stats.Daily, wantDaily)
+	}
+}
@@ -0,0 +1,102 @@
+package repository
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// GetStreak returns the user's streak as it's stored, along with the days
+// frozen since its last day.
+func (r *repo) GetStreak(userId int64) (models.Streak, error) {
+	var s models.Streak
+	if err := r.db.QueryRow(selectStreakStmt, userId).Scan(
+		&s.Current, &s.Longest, &s.LastDay, &s.Freezes,
+	); err != nil {
+		return models.Streak{}, fmt.Errorf("error getting streak from database: %v", err)
+	}
+
+	rows, err := r.db.Query(selectFrozenDaysStmt, userId)
+	if err != nil {
+		return models.Streak{}, fmt.Errorf("error getting frozen days from database: %v", err)
+	}
+	defer rows.Close()
+
+	s.FrozenDays = []string{}
+	for rows.Next() {
+		var day string
+		if err := rows.Scan(&day); err != nil {
+			return models.Streak{}, fmt.Errorf("error getting frozen day from database: %v", err)
+		}
+		s.FrozenDays = append(s.FrozenDays, day)
+	}
+
+	return s, rows.Err()
+}
+
+func (r *repo) UpdateStreak(userId int64, s models.Streak) error {
+	if _, err := r.db.Exec(
+		updateStreakStmt, s.Current, s.Longest, s.LastDay, s.Freezes, userId,
+	); err != nil {
+		return fmt.Errorf("error updating streak: %v", err)
+	}
+
+	return nil
+}
+
+// FreezeStreakDay freezes a "2006-01-02" day of the user's streak.
+func (r *repo) FreezeStreakDay(userId int64, day string) error {
+	if _, err := r.db.Exec(insertFrozenDayStmt, userId, day, time.Now()); err != nil {
+		return fmt.Errorf("error inserting frozen day to database: %v", err)
+	}
+
+	return nil
+}
+
+// AddBadge gives the user a badge, and reports whether they didn't have it
+// yet.
+func (r *repo) AddBadge(userId int64, badge string, earned time.Time) (bool, error) {
+	res, err := r.db.Exec(insertBadgeStmt, userId, badge, earned)
+	if err != nil {
+		return false, fmt.Errorf("error inserting badge to database: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, err
+	}
+
+	return n > 0, nil
+}
+
+// GetBadges returns the user's badges, earliest first. Only their name and
+// time are filled in.
+func (r *repo) GetBadges(userId int64) ([]models.Badge, error) {
+	rows, err := r.db.Query(selectBadgesStmt, userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting badges from database: %v", err)
+	}
+	defer rows.Close()
+
+	var badges []models.Badge
+	for rows.Next() {
+		var b models.Badge
+		if err := rows.Scan(&b.Name, &b.TimeEarned); err != nil {
+			return nil, fmt.Errorf("error getting badge from database: %v", err)
+		}
+		badges = append(badges, b)
+	}
+
+	return badges, rows.Err()
+}
+
+// GetDueTaskCounts counts the user's tasks with a due time that are still
+// open, were completed late and were completed on time.
+func (r *repo) GetDueTaskCounts(userId int64) (open, late, onTime int, err error) {
+	if err := r.db.QueryRow(selectDueTaskCountsStmt, userId).Scan(&open, &late, &onTime); err != nil {
+		return 0, 0, 0, fmt.Errorf("error counting due tasks: %v", err)
+	}
+
+	return open, late, onTime, nil
+}
@@ -0,0 +1,138 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// AddTimeEntry adds a time entry, one without an end starts the user's
+// timer and fails with ErrTimerRunning when one is already running.
+func (r *repo) AddTimeEntry(userId int64, e models.TimeEntry) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error adding time entry: %v", err)
+	}
+	defer tx.Rollback()
+
+	var end, plannedEnd interface{}
+	if e.End != nil {
+		end = e.End.UTC()
+	} else {
+		if _, err := scanTimeEntry(tx.QueryRow(selectRunningTimeEntryStmt, userId)); err == nil {
+			return 0, ErrTimerRunning
+		} else if err != sql.ErrNoRows {
+			return 0, fmt.Errorf("error getting running timer from database: %v", err)
+		}
+	}
+	if e.PlannedEnd != nil {
+		plannedEnd = e.PlannedEnd.UTC()
+	}
+
+	res, err := tx.Exec(
+		insertTimeEntryStmt, userId, e.TaskID, e.Kind, e.Start.UTC(), end,
+		plannedEnd, e.BreakMinutes, e.Note, e.TimeCreated,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting time entry to database: %v", err)
+	}
+
+	id, err := res.LastInsertId()
+	if err != nil {
+		return 0, err
+	}
+
+	return id, tx.Commit()
+}
+
+func scanTimeEntry(row scanner) (models.TimeEntry, error) {
+	var e models.TimeEntry
+	var end, plannedEnd sql.NullTime
+	err := row.Scan(
+		&e.ID, &e.TaskID, &e.TaskName, &e.Kind, &e.Start, &end, &plannedEnd,
+		&e.BreakMinutes, &e.Note, &e.TimeCreated,
+	)
+	if end.Valid {
+		e.End = &end.Time
+	}
+	if plannedEnd.Valid {
+		e.PlannedEnd = &plannedEnd.Time
+	}
+	return e, err
+}
+
+func (r *repo) queryTimeEntries(query string, args ...interface{}) ([]models.TimeEntry, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting time entries from database: %v", err)
+	}
+	defer rows.Close()
+
+	var entries []models.TimeEntry
+	for rows.Next() {
+		e, err := scanTimeEntry(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error getting time entry from database: %v", err)
+		}
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
+
+// GetRunningTimeEntry returns the user's running timer, ErrEntryNotFound
+// when there's none.
+func (r *repo) GetRunningTimeEntry(userId int64) (models.TimeEntry, error) {
+	e, err := scanTimeEntry(r.db.QueryRow(selectRunningTimeEntryStmt, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.TimeEntry{}, ErrEntryNotFound
+		}
+		return models.TimeEntry{}, fmt.Errorf("error getting running timer from database: %v", err)
+	}
+
+	return e, nil
+}
+
+func (r *repo) GetTimeEntry(userId, entryId int64) (models.TimeEntry, error) {
+	e, err := scanTimeEntry(r.db.QueryRow(selectTimeEntryStmt, entryId, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.TimeEntry{}, ErrEntryNotFound
+		}
+		return models.TimeEntry{}, fmt.Errorf("error getting time entry from database: %v", err)
+	}
+
+	return e, nil
+}
+
+// GetTaskTimeEntries returns the time entries of a task, latest first.
+func (r *repo) GetTaskTimeEntries(taskId int64) ([]models.TimeEntry, error) {
+	return r.queryTimeEntries(selectTaskTimeEntriesStmt, taskId)
+}
+
+// GetTimeEntries returns the user's time entries that started in the given
+// times, earliest first.
+func (r *repo) GetTimeEntries(userId int64, from, to time.Time) ([]models.TimeEntry, error) {
+	return r.queryTimeEntries(selectTimeEntriesStmt, userId, from.UTC(), to.UTC())
+}
+
+// StopTimeEntry ends a running timer, it's left alone if it was stopped
+// since.
+func (r *repo) StopTimeEntry(entryId int64, end time.Time) error {
+	if _, err := r.db.Exec(stopTimeEntryStmt, end.UTC(), entryId); err != nil {
+		return fmt.Errorf("error stopping timer: %v", err)
+	}
+
+	return nil
+}
+
+func (r *repo) DeleteTimeEntry(entryId int64) error {
+	if _, err := r.db.Exec(deleteTimeEntryStmt, entryId); err != nil {
+		return fmt.Errorf("error deleting time entry: %v", err)
+	}
+
+	return nil
+}
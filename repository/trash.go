@@ -0,0 +1,103 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// TrashTask moves a task to the trash if it's still at the given version,
+// it's hidden from the other task queries until it's restored or purged.
+func (r *repo) TrashTask(taskId, version int64, now time.Time) error {
+	res, err := r.db.Exec(trashTaskStmt, now.UTC(), taskId, version)
+	if err != nil {
+		return fmt.Errorf("error moving task to trash: %v", err)
+	}
+
+	return checkVersionUpdate(res)
+}
+
+func (r *repo) RestoreTask(taskId int64) error {
+	if _, err := r.db.Exec(restoreTaskStmt, taskId); err != nil {
+		return fmt.Errorf("error restoring task: %v", err)
+	}
+
+	return nil
+}
+
+func (r *repo) GetTrashedTasks(userId int64) ([]models.Task, error) {
+	return r.getTasks(selectTrashedTasksStmt, userId)
+}
+
+func (r *repo) GetTrashedTask(userId, taskId int64) (models.Task, error) {
+	t, err := scanTask(r.db.QueryRow(selectTrashedTaskStmt, taskId, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Task{}, ErrTaskNotFound
+		}
+		return models.Task{}, fmt.Errorf("error getting task from database: %v", err)
+	}
+
+	items, err := r.getChecklistItems(selectChecklistItemsByTaskStmt, taskId)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	reminders, err := r.getTaskReminders(selectTaskRemindersStmt, taskId)
+	if err != nil {
+		return models.Task{}, err
+	}
+
+	t.Items = items[taskId]
+	t.Reminders = reminders[taskId]
+	return t, nil
+}
+
+// PurgeTrashedTasks permanently deletes the tasks trashed before the given
+// time, with their history, and returns how many were deleted.
+func (r *repo) PurgeTrashedTasks(before time.Time) (int64, error) {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return 0, fmt.Errorf("error purging trash: %v", err)
+	}
+	defer tx.Rollback()
+
+	before = before.UTC()
+	if _, err := tx.Exec(purgeTrashedChecklistItemsStmt, before); err != nil {
+		return 0, fmt.Errorf("error purging trashed checklists: %v", err)
+	}
+
+	if _, err := tx.Exec(purgeTrashedRemindersStmt, before); err != nil {
+		return 0, fmt.Errorf("error purging trashed reminders: %v", err)
+	}
+
+	if _, err := tx.Exec(purgeTrashedSentRemindersStmt, before); err != nil {
+		return 0, fmt.Errorf("error purging trashed task sent reminders: %v", err)
+	}
+
+	if _, err := tx.Exec(purgeTrashedTimeBlocksStmt, before); err != nil {
+		return 0, fmt.Errorf("error purging trashed task time blocks: %v", err)
+	}
+
+	if _, err := tx.Exec(purgeTrashedTimeEntriesStmt, before); err != nil {
+		return 0, fmt.Errorf("error purging trashed task time entries: %v", err)
+	}
+
+	if _, err := tx.Exec(purgeTrashedRevisionsStmt, before); err != nil {
+		return 0, fmt.Errorf("error purging trashed task history: %v", err)
+	}
+
+	res, err := tx.Exec(purgeTrashedTasksStmt, before)
+	if err != nil {
+		return 0, fmt.Errorf("error purging trashed tasks: %v", err)
+	}
+
+	purged, err := res.RowsAffected()
+	if err != nil {
+		return 0, err
+	}
+
+	return purged, tx.Commit()
+}
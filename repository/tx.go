@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"sync/atomic"
+)
+
+// conn is what the repo runs its statements on, the database or the
+// transaction it was created for by Transaction
+type conn interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Begin() (txn, error)
+}
+
+// txn is a transaction, or a savepoint within one
+type txn interface {
+	Exec(query string, args ...any) (sql.Result, error)
+	Query(query string, args ...any) (*sql.Rows, error)
+	QueryRow(query string, args ...any) *sql.Row
+	Commit() error
+	Rollback() error
+}
+
+type dbConn struct {
+	*sql.DB
+}
+
+func (c dbConn) Begin() (txn, error) {
+	return c.DB.Begin()
+}
+
+// txConn runs statements in a transaction, transactions begun on it are
+// savepoints so repo methods that use a transaction of their own still
+// work in it
+type txConn struct {
+	txn
+}
+
+func (c txConn) Begin() (txn, error) {
+	return newSavepoint(c.txn)
+}
+
+var savepointCount atomic.Int64
+
+type savepoint struct {
+	tx   txn
+	name string
+	done bool
+}
+
+func newSavepoint(tx txn) (*savepoint, error) {
+	name := fmt.Sprintf("sp_%d", savepointCount.Add(1))
+	if _, err := tx.Exec("SAVEPOINT " + name); err != nil {
+		return nil, err
+	}
+
+	return &savepoint{tx: tx, name: name}, nil
+}
+
+func (s *savepoint) Exec(query string, args ...any) (sql.Result, error) {
+	return s.tx.Exec(query, args...)
+}
+
+func (s *savepoint) Query(query string, args ...any) (*sql.Rows, error) {
+	return s.tx.Query(query, args...)
+}
+
+func (s *savepoint) QueryRow(query string, args ...any) *sql.Row {
+	return s.tx.QueryRow(query, args...)
+}
+
+func (s *savepoint) Commit() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+
+	_, err := s.tx.Exec("RELEASE " + s.name)
+	return err
+}
+
+func (s *savepoint) Rollback() error {
+	if s.done {
+		return sql.ErrTxDone
+	}
+	s.done = true
+
+	_, err := s.tx.Exec("ROLLBACK TO " + s.name + "; RELEASE " + s.name)
+	return err
+}
+
+// Transaction runs fn with a repo whose statements all run in a single
+// transaction, which is committed if fn returns nil and rolled back
+// otherwise. Transactions started within fn are nested as savepoints.
+func (r *repo) Transaction(fn func(Repo) error) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error starting transaction: %v", err)
+	}
+	defer tx.Rollback()
+
+	if err := fn(&repo{txConn{tx}}); err != nil {
+		return err
+	}
+
+	return tx.Commit()
+}
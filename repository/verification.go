@@ -0,0 +1,60 @@
+package repository
+
+import (
+	"database/sql"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+// AddVerificationCode stores a code for the email until it expires and
+// queues the email that sends it, both or neither are saved. Expired codes
+// are cleared out on the way.
+func (r *repo) AddVerificationCode(code, email string, expiry time.Time, mail models.OutboxEmail) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error adding verification code: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteExpiredVerificationCodesStmt, time.Now().UTC()); err != nil {
+		return fmt.Errorf("error removing expired verification codes: %v", err)
+	}
+
+	if _, err := tx.Exec(insertVerificationCodeStmt, code, email, expiry.UTC()); err != nil {
+		return fmt.Errorf("error adding verification code: %v", err)
+	}
+
+	if _, err := addOutboxEmail(tx, mail); err != nil {
+		return err
+	}
+
+	if err := tx.Commit(); err != nil {
+		return fmt.Errorf("error adding verification code: %v", err)
+	}
+
+	return nil
+}
+
+// GetVerificationCode returns the email a code was sent to if it hasn't
+// expired
+func (r *repo) GetVerificationCode(code string, now time.Time) (string, error) {
+	var email string
+	if err := r.db.QueryRow(selectVerificationCodeStmt, code, now.UTC()).Scan(&email); err != nil {
+		if err == sql.ErrNoRows {
+			return "", ErrCodeNotFound
+		}
+		return "", fmt.Errorf("error getting verification code from database: %v", err)
+	}
+
+	return email, nil
+}
+
+func (r *repo) DeleteVerificationCode(code string) error {
+	if _, err := r.db.Exec(deleteVerificationCodeStmt, code); err != nil {
+		return fmt.Errorf("error removing verification code: %v", err)
+	}
+
+	return nil
+}
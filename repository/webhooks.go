@@ -0,0 +1,253 @@
+package repository
+
+import (
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/michaelcosj/stms/models"
+)
+
+func (r *repo) AddWebhook(userId int64, w models.Webhook) (int64, error) {
+	eventTypes, err := json.Marshal(w.EventTypes)
+	if err != nil {
+		return 0, fmt.Errorf("error encoding webhook event types: %v", err)
+	}
+
+	res, err := r.db.Exec(
+		insertWebhookStmt, userId, w.URL, string(eventTypes), w.Secret, w.TimeCreated,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting webhook to database: %v", err)
+	}
+
+	return res.LastInsertId()
+}
+
+func scanWebhook(row scanner) (models.Webhook, error) {
+	var w models.Webhook
+	var eventTypes string
+
+	if err := row.Scan(
+		&w.ID, &w.UserID, &w.URL, &eventTypes, &w.Secret, &w.IsActive,
+		&w.Failures, &w.TimeCreated,
+	); err != nil {
+		return models.Webhook{}, err
+	}
+
+	if err := json.Unmarshal([]byte(eventTypes), &w.EventTypes); err != nil {
+		return models.Webhook{}, fmt.Errorf("error decoding webhook event types: %v", err)
+	}
+
+	return w, nil
+}
+
+func (r *repo) GetWebhooks(userId int64) ([]models.Webhook, error) {
+	rows, err := r.db.Query(selectWebhooksStmt, userId)
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhooks from database: %v", err)
+	}
+	defer rows.Close()
+
+	var webhooks []models.Webhook
+	for rows.Next() {
+		w, err := scanWebhook(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error getting webhook from database: %v", err)
+		}
+		webhooks = append(webhooks, w)
+	}
+
+	return webhooks, rows.Err()
+}
+
+func (r *repo) GetWebhook(userId, webhookId int64) (models.Webhook, error) {
+	w, err := scanWebhook(r.db.QueryRow(selectWebhookStmt, webhookId, userId))
+	if err != nil {
+		if err == sql.ErrNoRows {
+			return models.Webhook{}, ErrWebhookNotFound
+		}
+		return models.Webhook{}, fmt.Errorf("error getting webhook from database: %v", err)
+	}
+
+	return w, nil
+}
+
+func (r *repo) UpdateWebhook(webhookId int64, w models.Webhook) error {
+	eventTypes, err := json.Marshal(w.EventTypes)
+	if err != nil {
+		return fmt.Errorf("error encoding webhook event types: %v", err)
+	}
+
+	if _, err := r.db.Exec(
+		updateWebhookStmt, w.URL, string(eventTypes), w.IsActive, w.Failures, webhookId,
+	); err != nil {
+		return fmt.Errorf("error updating webhook: %v", err)
+	}
+
+	return nil
+}
+
+// DeleteWebhook deletes a webhook along with its delivery log.
+func (r *repo) DeleteWebhook(webhookId int64) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error deleting webhook: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(deleteWebhookDeliveriesStmt, webhookId); err != nil {
+		return fmt.Errorf("error deleting webhook deliveries: %v", err)
+	}
+
+	if _, err := tx.Exec(deleteWebhookStmt, webhookId); err != nil {
+		return fmt.Errorf("error deleting webhook: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+func (r *repo) AddWebhookDelivery(d models.WebhookDelivery) (int64, error) {
+	res, err := r.db.Exec(
+		insertWebhookDeliveryStmt, d.WebhookID, d.EventType, string(d.Payload),
+		d.NextAttempt.UTC(), d.TimeCreated,
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error inserting webhook delivery to database: %v", err)
+	}
+
+	return res.LastInsertId()
+}
+
+// QueueWebhookDeliveries queues an event for each of the user's active
+// webhooks that subscribe to it, and returns how many were queued.
+func (r *repo) QueueWebhookDeliveries(userId int64, eventType string, payload []byte, now time.Time) (int64, error) {
+	res, err := r.db.Exec(
+		insertWebhookDeliveriesStmt,
+		sql.Named("type", eventType),
+		sql.Named("payload", string(payload)),
+		sql.Named("now", now.UTC()),
+		sql.Named("user", userId),
+	)
+	if err != nil {
+		return 0, fmt.Errorf("error queueing webhook deliveries: %v", err)
+	}
+
+	return res.RowsAffected()
+}
+
+func scanWebhookDelivery(row scanner) (models.WebhookDelivery, error) {
+	var d models.WebhookDelivery
+	var payload string
+
+	if err := row.Scan(
+		&d.ID, &d.WebhookID, &d.EventType, &payload, &d.Status, &d.Attempts,
+		&d.ResponseStatus, &d.LastError, &d.NextAttempt, &d.TimeCreated,
+		&d.TimeDelivered, &d.UserID, &d.URL, &d.Secret,
+	); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	d.Payload = json.RawMessage(payload)
+	return d, nil
+}
+
+func (r *repo) getWebhookDeliveries(query string, args ...any) ([]models.WebhookDelivery, error) {
+	rows, err := r.db.Query(query, args...)
+	if err != nil {
+		return nil, fmt.Errorf("error getting webhook deliveries from database: %v", err)
+	}
+	defer rows.Close()
+
+	var deliveries []models.WebhookDelivery
+	for rows.Next() {
+		d, err := scanWebhookDelivery(rows)
+		if err != nil {
+			return nil, fmt.Errorf("error getting webhook delivery from database: %v", err)
+		}
+		deliveries = append(deliveries, d)
+	}
+
+	return deliveries, rows.Err()
+}
+
+func (r *repo) GetDueWebhookDeliveries(now time.Time, limit int) ([]models.WebhookDelivery, error) {
+	return r.getWebhookDeliveries(selectDueWebhookDeliveriesStmt, now.UTC(), limit)
+}
+
+// GetWebhookDeliveries returns the delivery log of a webhook, newest
+// first. An empty status returns deliveries of any status.
+func (r *repo) GetWebhookDeliveries(webhookId int64, status string, limit int) ([]models.WebhookDelivery, error) {
+	return r.getWebhookDeliveries(selectWebhookDeliveriesStmt, webhookId, status, status, limit)
+}
+
+// LeaseWebhookDelivery holds a due delivery until the given time, returning
+// false if another worker got to it first or the webhook was disabled.
+func (r *repo) LeaseWebhookDelivery(deliveryId int64, now, until time.Time) (bool, error) {
+	res, err := r.db.Exec(leaseWebhookDeliveryStmt, until.UTC(), deliveryId, now.UTC())
+	if err != nil {
+		return false, fmt.Errorf("error leasing webhook delivery: %v", err)
+	}
+
+	n, err := res.RowsAffected()
+	if err != nil {
+		return false, fmt.Errorf("error leasing webhook delivery: %v", err)
+	}
+
+	return n > 0, nil
+}
+
+// MarkWebhookDelivered records a successful delivery, which also resets the
+// webhook's failures in a row.
+func (r *repo) MarkWebhookDelivered(d models.WebhookDelivery, responseStatus int, now time.Time) error {
+	tx, err := r.db.Begin()
+	if err != nil {
+		return fmt.Errorf("error marking webhook delivery as delivered: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(updateWebhookDeliveredStmt, responseStatus, now, d.ID); err != nil {
+		return fmt.Errorf("error marking webhook delivery as delivered: %v", err)
+	}
+
+	if _, err := tx.Exec(resetWebhookFailuresStmt, d.WebhookID); err != nil {
+		return fmt.Errorf("error resetting webhook failures: %v", err)
+	}
+
+	return tx.Commit()
+}
+
+// MarkWebhookDeliveryFailed records a failed delivery attempt and adds to
+// the webhook's failures in a row, disabling it once it reaches
+// maxFailures. It returns whether the webhook is now disabled.
+func (r *repo) MarkWebhookDeliveryFailed(d models.WebhookDelivery, attempts, responseStatus int, lastErr string, next time.Time, dead bool, maxFailures int) (bool, error) {
+	status := "pending"
+	if dead {
+		status = "dead"
+	}
+
+	tx, err := r.db.Begin()
+	if err != nil {
+		return false, fmt.Errorf("error marking webhook delivery as failed: %v", err)
+	}
+	defer tx.Rollback()
+
+	if _, err := tx.Exec(
+		updateWebhookDeliveryFailedStmt, status, attempts, responseStatus,
+		lastErr, next.UTC(), d.ID,
+	); err != nil {
+		return false, fmt.Errorf("error marking webhook delivery as failed: %v", err)
+	}
+
+	if _, err := tx.Exec(addWebhookFailureStmt, maxFailures, d.WebhookID); err != nil {
+		return false, fmt.Errorf("error adding webhook failure: %v", err)
+	}
+
+	var isActive bool
+	if err := tx.QueryRow(selectWebhookIsActiveStmt, d.WebhookID).Scan(&isActive); err != nil {
+		return false, fmt.Errorf("error getting webhook from database: %v", err)
+	}
+
+	return !isActive, tx.Commit()
+}
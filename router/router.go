@@ -1,8 +1,12 @@
 package router
 
 import (
+	"context"
 	"fmt"
+	"net/http"
 	"os"
+	"os/signal"
+	"syscall"
 	"time"
 
 	"github.com/golang-jwt/jwt/v5"
@@ -51,6 +55,15 @@ func (r *router) Run(port string) error {
 	e.GET("/verify", r.handler.StartVerification)
 	e.POST("/verify", r.handler.VerifyUser)
 
+	// Calendar feed, authenticated by the token in the url
+	e.GET("/calendar/:token", r.handler.CalendarFeed)
+
+	// Unsubscribing from scheduled emails, authenticated by the signed
+	// token in the link. GET asks to confirm, POST unsubscribes and is also
+	// mail clients' one-click unsubscribe
+	e.GET("/unsubscribe", r.handler.ConfirmUnsubscribe)
+	e.POST("/unsubscribe", r.handler.Unsubscribe)
+
 	// Task endpoints
 	t := e.Group("/users")
 
@@ -63,11 +76,115 @@ func (r *router) Run(port string) error {
 	}
 	t.Use(echojwt.WithConfig(jwtMiddlewareCfg))
 
+	// the event stream also takes the token in the query, browsers can't
+	// set headers on an EventSource
+	streamJwtCfg := jwtMiddlewareCfg
+	streamJwtCfg.TokenLookup = "header:Authorization:Bearer ,query:token"
+	e.GET("/users/events", r.handler.StreamEvents, echojwt.WithConfig(streamJwtCfg))
+
+	t.GET("/me", r.handler.GetProfile)
+	t.PATCH("/me/settings", r.handler.UpdateSettings)
+	t.GET("/me/export", r.handler.ExportAccount)
+	t.POST("/me/import", r.handler.ImportAccount)
+	t.POST("/me/calendar-feed", r.handler.CreateCalendarFeed)
+	t.DELETE("/me/calendar-feed", r.handler.RevokeCalendarFeed)
+
 	t.GET("/tasks", r.handler.GetTasks)
-	t.POST("/tasks", r.handler.AddTask)
+	t.GET("/tasks.ics", r.handler.ExportCalendar)
+	t.POST("/tasks", r.handler.AddTask, r.handler.Idempotent)
+	t.POST("/tasks/import", r.handler.ImportTasks)
+	t.POST("/tasks/batch", r.handler.BatchTasks, r.handler.Idempotent)
+	t.GET("/tasks/:taskId", r.handler.GetTask)
 	t.PATCH("/tasks/:taskId", r.handler.UpdateTask)
 	t.DELETE("/tasks/:taskId", r.handler.RemoveTask)
+	t.POST("/tasks/:taskId/skip", r.handler.SkipTask)
+	t.GET("/tasks/trash", r.handler.GetTrash)
+	t.POST("/tasks/:taskId/restore", r.handler.RestoreTask)
+	t.GET("/tasks/:taskId/history", r.handler.GetTaskHistory)
+	t.POST("/tasks/:taskId/history/:revisionId/revert", r.handler.RevertTask)
+
+	t.GET("/semesters", r.handler.GetSemesters)
+	t.POST("/semesters", r.handler.AddSemester)
+	t.GET("/semesters/:semesterId", r.handler.GetSemester)
+	t.PATCH("/semesters/:semesterId", r.handler.UpdateSemester)
+	t.DELETE("/semesters/:semesterId", r.handler.RemoveSemester)
+	t.POST("/semesters/:semesterId/archive", r.handler.ArchiveSemester)
+	t.DELETE("/semesters/:semesterId/archive", r.handler.ArchiveSemester)
+
+	t.GET("/courses", r.handler.GetCourses)
+	t.POST("/courses", r.handler.AddCourse)
+	t.GET("/courses/:courseId", r.handler.GetCourse)
+	t.PATCH("/courses/:courseId", r.handler.UpdateCourse)
+	t.DELETE("/courses/:courseId", r.handler.RemoveCourse)
+	t.GET("/courses/:courseId/grades", r.handler.GetCourseGrades)
+
+	t.GET("/timetable", r.handler.GetTimetable)
+	t.POST("/timetable", r.handler.AddTimetableSession)
+	t.PATCH("/timetable/:sessionId", r.handler.UpdateTimetableSession)
+	t.DELETE("/timetable/:sessionId", r.handler.RemoveTimetableSession)
+	t.GET("/schedule", r.handler.GetSchedule)
+
+	t.GET("/exams", r.handler.GetExams)
+	t.POST("/exams", r.handler.AddExam)
+	t.GET("/exams/:examId", r.handler.GetExam)
+	t.PATCH("/exams/:examId", r.handler.UpdateExam)
+	t.DELETE("/exams/:examId", r.handler.RemoveExam)
+	t.POST("/exams/:examId/plan", r.handler.PlanExam)
+
+	t.GET("/availability", r.handler.GetAvailability)
+	t.PUT("/availability", r.handler.SetAvailability)
+	t.POST("/plan", r.handler.PlanTasks)
+	t.GET("/blocks", r.handler.GetTimeBlocks)
+	t.DELETE("/blocks/:blockId", r.handler.RemoveTimeBlock)
+
+	t.GET("/timer", r.handler.GetTimer)
+	t.POST("/timer", r.handler.StartTimer)
+	t.DELETE("/timer", r.handler.StopTimer)
+	t.GET("/time", r.handler.GetTimeTotals)
+	t.GET("/stats", r.handler.GetStats)
+	t.GET("/streak", r.handler.GetStreak)
+	t.POST("/streak/freeze", r.handler.FreezeStreak)
+	t.GET("/badges", r.handler.GetBadges)
+
+	t.GET("/webhooks", r.handler.GetWebhooks)
+	t.POST("/webhooks", r.handler.AddWebhook)
+	t.PATCH("/webhooks/:webhookId", r.handler.UpdateWebhook)
+	t.DELETE("/webhooks/:webhookId", r.handler.RemoveWebhook)
+	t.GET("/webhooks/:webhookId/deliveries", r.handler.GetWebhookDeliveries)
+	t.POST("/webhooks/:webhookId/ping", r.handler.PingWebhook)
+
+	t.POST("/tasks/:taskId/items", r.handler.AddChecklistItem)
+	t.PUT("/tasks/:taskId/items/order", r.handler.ReorderChecklist)
+	t.PATCH("/tasks/:taskId/items/:itemId", r.handler.UpdateChecklistItem)
+	t.DELETE("/tasks/:taskId/items/:itemId", r.handler.RemoveChecklistItem)
+
+	t.GET("/tasks/:taskId/time", r.handler.GetTimeEntries)
+	t.POST("/tasks/:taskId/time", r.handler.AddTimeEntry)
+	t.DELETE("/tasks/:taskId/time/:entryId", r.handler.RemoveTimeEntry)
+
+	// Admin endpoints
+	a := e.Group("/admin")
+	a.Use(echojwt.WithConfig(jwtMiddlewareCfg), r.handler.RequireAdmin)
+
+	a.GET("/emails", r.handler.GetEmails)
+	a.POST("/emails/:emailId/replay", r.handler.ReplayEmail)
+
+	go func() {
+		if err := e.Start(":" + port); err != nil && err != http.ErrServerClosed {
+			e.Logger.Fatal(err)
+		}
+	}()
+
+	// wait for an interrupt, then give in-flight requests time to finish
+	quit := make(chan os.Signal, 1)
+	signal.Notify(quit, os.Interrupt, syscall.SIGTERM)
+	<-quit
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	// event streams stay open until they're told to close
+	r.handler.Shutdown()
 
-	e.Logger.Fatal(e.Start(":" + port))
-	return nil
+	return e.Shutdown(ctx)
 }